@@ -0,0 +1,10 @@
+//go:build !unix
+
+package client
+
+import "net"
+
+// peerOpen без неблокирующего чтения проверить сокет нельзя; обрыв обнаружится при запросе
+func peerOpen(net.Conn) bool {
+	return true
+}
@@ -0,0 +1,37 @@
+//go:build unix
+
+package client
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// peerOpen неблокирующим MSG_PEEK проверяет, что сокет не закрыт другой стороной
+func peerOpen(conn net.Conn) bool {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return true
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return false
+	}
+
+	open := true
+	err = raw.Read(func(fd uintptr) bool {
+		var buf [1]byte
+		n, _, err := syscall.Recvfrom(int(fd), buf[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+		switch {
+		case n == 0 && err == nil: // EOF
+			open = false
+		case err != nil && !errors.Is(err, syscall.EAGAIN) && !errors.Is(err, syscall.EWOULDBLOCK):
+			open = false
+		case n > 0: // сервер не должен ничего присылать в простаивающее соединение
+			open = false
+		}
+		return true
+	})
+	return err == nil && open
+}
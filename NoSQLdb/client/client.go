@@ -0,0 +1,325 @@
+// Package client — Go-клиент NoSQLdb: пул соединений, переподключение
+// с экспоненциальной задержкой, вызовы с context и типизированные команды.
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"nosql_db/internal/api"
+	"sync"
+	"time"
+)
+
+// Типы протокола (алиасы внутренних типов сервера)
+type (
+	Request       = api.Request
+	Response      = api.Response
+	DocumentError = api.DocumentError
+	Change        = api.Change
+)
+
+// Статусы ответов
+const (
+	StatusSuccess = api.StatusSuccess
+	StatusError   = api.StatusError
+	StatusPartial = api.StatusPartial
+)
+
+// ErrClosed клиент закрыт
+var ErrClosed = errors.New("nosqldb: client is closed")
+
+// ServerError сервер ответил статусом error
+type ServerError struct {
+	Message string
+}
+
+func (e *ServerError) Error() string {
+	return "nosqldb: " + e.Message
+}
+
+// Options настройки клиента; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	PoolSize    int           // максимум одновременно открытых соединений (4)
+	DialTimeout time.Duration // таймаут установки соединения (5s)
+	IdleTimeout time.Duration // простаивающее соединение старше этого не переиспользуется (30s)
+
+	MaxRetries int           // повторов при сетевой ошибке (3), -1 — без повторов
+	MinBackoff time.Duration // первая задержка перед повтором (100ms)
+	MaxBackoff time.Duration // предел задержки (5s)
+
+	// Dial заменяет установку TCP-соединения (для тестов)
+	Dial func(ctx context.Context, addr string) (net.Conn, error)
+}
+
+func (o Options) withDefaults() Options {
+	if o.PoolSize <= 0 {
+		o.PoolSize = 4
+	}
+	if o.DialTimeout <= 0 {
+		o.DialTimeout = 5 * time.Second
+	}
+	if o.IdleTimeout <= 0 {
+		o.IdleTimeout = 30 * time.Second
+	}
+	if o.MaxRetries == 0 {
+		o.MaxRetries = 3
+	} else if o.MaxRetries < 0 {
+		o.MaxRetries = 0
+	}
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = 100 * time.Millisecond
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 5 * time.Second
+	}
+	if o.Dial == nil {
+		timeout := o.DialTimeout
+		o.Dial = func(ctx context.Context, addr string) (net.Conn, error) {
+			d := net.Dialer{Timeout: timeout}
+			return d.DialContext(ctx, "tcp", addr)
+		}
+	}
+	return o
+}
+
+// Client безопасен для использования из нескольких горутин
+type Client struct {
+	addr string
+	opts Options
+
+	slots chan struct{} // ограничивает число открытых соединений
+	mu    sync.Mutex
+	idle  []*conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+type conn struct {
+	net.Conn
+	reader   *bufio.Reader
+	lastUsed time.Time
+}
+
+// alive проверяет без ожидания, что сервер не закрыл простаивающее соединение
+func (cn *conn) alive() bool {
+	if cn.reader.Buffered() > 0 {
+		// непрочитанные данные в простаивающем соединении — поток рассинхронизирован
+		return false
+	}
+	return peerOpen(cn.Conn)
+}
+
+// New создаёт клиент для сервера addr (host:port); соединения открываются по требованию
+func New(addr string, opts Options) *Client {
+	opts = opts.withDefaults()
+	return &Client{
+		addr:  addr,
+		opts:  opts,
+		slots: make(chan struct{}, opts.PoolSize),
+		done:  make(chan struct{}),
+	}
+}
+
+// Close закрывает простаивающие соединения; соединения занятых вызовов закрываются по их завершении
+func (c *Client) Close() error {
+	c.once.Do(func() {
+		close(c.done)
+		c.mu.Lock()
+		for _, cn := range c.idle {
+			cn.Close()
+		}
+		c.idle = nil
+		c.mu.Unlock()
+	})
+	return nil
+}
+
+func (c *Client) closed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Do отправляет запрос и возвращает ответ как есть, без разбора статуса.
+// Сетевые ошибки до отправки запроса повторяются всегда, после отправки —
+// только для идемпотентных запросов (чтение, insert с заданными _id)
+func (c *Client) Do(ctx context.Context, req Request) (Response, error) {
+	var lastErr error
+	for attempt := 0; attempt <= c.opts.MaxRetries; attempt++ {
+		if attempt > 0 {
+			if err := c.sleep(ctx, c.backoff(attempt-1)); err != nil {
+				return Response{}, errors.Join(err, lastErr)
+			}
+		}
+
+		resp, sent, err := c.roundTrip(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil || errors.Is(err, ErrClosed) {
+			return Response{}, err
+		}
+		if sent && !idempotent(req) {
+			return Response{}, fmt.Errorf("nosqldb: %s failed after the request was sent, not retrying: %w", req.Command, err)
+		}
+	}
+	return Response{}, fmt.Errorf("nosqldb: %s failed after %d attempts: %w", req.Command, c.opts.MaxRetries+1, lastErr)
+}
+
+// roundTrip выполняет запрос на одном соединении; sent — запрос мог дойти до сервера
+func (c *Client) roundTrip(ctx context.Context, req Request) (Response, bool, error) {
+	cn, err := c.acquire(ctx)
+	if err != nil {
+		return Response{}, false, err
+	}
+
+	// отмена ctx прерывает ожидание ответа
+	stop := context.AfterFunc(ctx, func() {
+		_ = cn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	deadline, _ := ctx.Deadline()
+	_ = cn.SetDeadline(deadline)
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		c.release(cn, true)
+		return Response{}, false, fmt.Errorf("nosqldb: encode request: %w", err)
+	}
+	if _, err := cn.Write(append(data, '\n')); err != nil {
+		c.release(cn, false)
+		return Response{}, true, c.ctxErr(ctx, err)
+	}
+
+	line, err := cn.reader.ReadBytes('\n')
+	if err != nil {
+		c.release(cn, false)
+		return Response{}, true, c.ctxErr(ctx, err)
+	}
+
+	var resp Response
+	if err := json.Unmarshal(line, &resp); err != nil {
+		c.release(cn, false)
+		return Response{}, true, fmt.Errorf("nosqldb: decode response: %w", err)
+	}
+
+	c.release(cn, stop())
+	return resp, true, nil
+}
+
+func (c *Client) ctxErr(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// acquire берёт простаивающее соединение или открывает новое в пределах PoolSize
+func (c *Client) acquire(ctx context.Context) (*conn, error) {
+	select {
+	case c.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+		return nil, ErrClosed
+	}
+	if c.closed() {
+		<-c.slots
+		return nil, ErrClosed
+	}
+
+	c.mu.Lock()
+	for len(c.idle) > 0 {
+		cn := c.idle[len(c.idle)-1]
+		c.idle = c.idle[:len(c.idle)-1]
+		// сервер мог закрыть соединение по таймауту простоя или при перезапуске
+		if time.Since(cn.lastUsed) < c.opts.IdleTimeout && cn.alive() {
+			c.mu.Unlock()
+			return cn, nil
+		}
+		cn.Close()
+	}
+	c.mu.Unlock()
+
+	netConn, err := c.dial(ctx)
+	if err != nil {
+		<-c.slots
+		return nil, err
+	}
+	return &conn{Conn: netConn, reader: bufio.NewReader(netConn)}, nil
+}
+
+func (c *Client) dial(ctx context.Context) (net.Conn, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, c.opts.DialTimeout)
+	defer cancel()
+	netConn, err := c.opts.Dial(dialCtx, c.addr)
+	if err != nil {
+		return nil, fmt.Errorf("nosqldb: connect to %s: %w", c.addr, err)
+	}
+	return netConn, nil
+}
+
+// release возвращает соединение в пул или закрывает его
+func (c *Client) release(cn *conn, reuse bool) {
+	defer func() { <-c.slots }()
+
+	if !reuse || c.closed() {
+		cn.Close()
+		return
+	}
+	_ = cn.SetDeadline(time.Time{})
+	cn.lastUsed = time.Now()
+
+	c.mu.Lock()
+	c.idle = append(c.idle, cn)
+	c.mu.Unlock()
+}
+
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.opts.MinBackoff << attempt
+	if delay <= 0 || delay > c.opts.MaxBackoff {
+		delay = c.opts.MaxBackoff
+	}
+	return delay
+}
+
+func (c *Client) sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.done:
+		return ErrClosed
+	}
+}
+
+// idempotent повтор запроса не меняет результат на сервере
+func idempotent(req Request) bool {
+	switch req.Command {
+	case api.CmdFind, api.CmdCount, api.CmdDistinct, api.CmdGetSchema, api.CmdSetSchema,
+		api.CmdListCollections, api.CmdListIndexes, api.CmdListFields, api.CmdRebuildIndexes,
+		api.CmdCreateCapped:
+		return true
+	case api.CmdInsert:
+		// документы с _id при повторе не дублируются
+		for _, doc := range req.Data {
+			if _, ok := doc["_id"].(string); !ok {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,233 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"nosql_db/client"
+	"nosql_db/client/clienttest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newClient(t *testing.T, addr string, opts client.Options) *client.Client {
+	t.Helper()
+	if opts.MinBackoff == 0 {
+		opts.MinBackoff = time.Millisecond
+	}
+	c := client.New(addr, opts)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestCommands(t *testing.T) {
+	srv := clienttest.NewServer()
+	defer srv.Close()
+	c := newClient(t, srv.Addr(), client.Options{})
+	ctx := context.Background()
+
+	result, err := c.Insert(ctx, "events",
+		map[string]any{"_id": "e1", "host": "web-1", "severity": "high"},
+		map[string]any{"_id": "e2", "host": "web-2", "severity": "low"},
+		map[string]any{"_id": "e3", "host": "web-1", "severity": "low"},
+	)
+	if err != nil || result.Inserted != 3 || !reflect.DeepEqual(result.IDs, []string{"e1", "e2", "e3"}) {
+		t.Fatalf("insert: %+v, %v", result, err)
+	}
+
+	docs, err := c.Find(ctx, "events", map[string]any{"severity": "low"})
+	if err != nil || len(docs) != 2 {
+		t.Errorf("find: expected 2 documents, got %d (%v)", len(docs), err)
+	}
+
+	if n, err := c.Count(ctx, "events", map[string]any{"host": "web-1"}); err != nil || n != 2 {
+		t.Errorf("count: expected 2, got %d (%v)", n, err)
+	}
+
+	values, err := c.Distinct(ctx, "events", "host", nil)
+	if err != nil || !reflect.DeepEqual(values, []any{"web-1", "web-2"}) {
+		t.Errorf("distinct: got %v (%v)", values, err)
+	}
+
+	if err := c.CreateIndex(ctx, "events", "host"); err != nil {
+		t.Errorf("create index: %v", err)
+	}
+	if err := c.CreateIndex(ctx, "events", "severity"); err != nil {
+		t.Errorf("create index: %v", err)
+	}
+	if err := c.DropIndex(ctx, "events", "severity"); err != nil {
+		t.Errorf("drop index: %v", err)
+	}
+	if names, err := c.ListIndexes(ctx, "events"); err != nil || !reflect.DeepEqual(names, []string{"host"}) {
+		t.Errorf("list indexes: got %v (%v)", names, err)
+	}
+	if names, err := c.ListCollections(ctx); err != nil || !reflect.DeepEqual(names, []string{"events"}) {
+		t.Errorf("list collections: got %v (%v)", names, err)
+	}
+	if names, err := c.ListFields(ctx, "events"); err != nil || !reflect.DeepEqual(names, []string{"_id", "host", "severity"}) {
+		t.Errorf("list fields: got %v (%v)", names, err)
+	}
+
+	schema := map[string]any{"required": []any{"host"}}
+	if err := c.SetSchema(ctx, "events", schema); err != nil {
+		t.Fatalf("set schema: %v", err)
+	}
+	if got, err := c.GetSchema(ctx, "events"); err != nil || !reflect.DeepEqual(got, schema) {
+		t.Errorf("get schema: got %v (%v)", got, err)
+	}
+
+	result, err = c.Insert(ctx, "events", map[string]any{"host": "db-1"}, map[string]any{"severity": "high"})
+	if err != nil || !result.Partial || result.Inserted != 1 || len(result.Errors) != 1 || result.Errors[0].Index != 1 {
+		t.Errorf("partial insert: %+v, %v", result, err)
+	}
+
+	if n, err := c.Delete(ctx, "events", map[string]any{"host": "web-1"}); err != nil || n != 2 {
+		t.Errorf("delete: expected 2, got %d (%v)", n, err)
+	}
+
+	var serverErr *client.ServerError
+	if _, err := c.Find(ctx, "", nil); !errors.As(err, &serverErr) || serverErr.Message != "database name is required" {
+		t.Errorf("expected server error, got %v", err)
+	}
+}
+
+func TestReconnectAfterServerClosesConnections(t *testing.T) {
+	srv := clienttest.NewServer()
+	defer srv.Close()
+	c := newClient(t, srv.Addr(), client.Options{})
+	ctx := context.Background()
+
+	if _, err := c.Insert(ctx, "events", map[string]any{"n": 1.0}); err != nil {
+		t.Fatal(err)
+	}
+	srv.CloseConnections()
+	time.Sleep(10 * time.Millisecond)
+
+	// insert без _id не повторяется, поэтому мёртвое соединение из пула не должно использоваться
+	if _, err := c.Insert(ctx, "events", map[string]any{"n": 2.0}); err != nil {
+		t.Fatalf("insert after reconnect: %v", err)
+	}
+	if docs := srv.Documents("events"); len(docs) != 2 {
+		t.Errorf("expected 2 documents, got %d", len(docs))
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	srv := clienttest.NewServer()
+	defer srv.Close()
+	c := newClient(t, srv.Addr(), client.Options{MaxRetries: 2})
+	ctx := context.Background()
+
+	// первый запрос каждой команды обрывается сервером после получения
+	var drops sync.Map
+	srv.Handle(func(req client.Request) (client.Response, bool) {
+		if _, seen := drops.LoadOrStore(req.Command, true); !seen {
+			return client.Response{}, true
+		}
+		return client.Response{}, false
+	})
+
+	if _, err := c.Find(ctx, "events", nil); err != nil {
+		t.Errorf("find should be retried: %v", err)
+	}
+	if _, err := c.Insert(ctx, "events", map[string]any{"_id": "a-1"}); err != nil {
+		t.Errorf("insert with _id should be retried: %v", err)
+	}
+	if _, err := c.Delete(ctx, "events", nil); err == nil {
+		t.Error("delete must not be retried after the request was sent")
+	}
+}
+
+func TestDialBackoffAndContext(t *testing.T) {
+	var dials atomic.Int32
+	failing := func(ctx context.Context, addr string) (net.Conn, error) {
+		dials.Add(1)
+		return nil, errors.New("connection refused")
+	}
+
+	c := newClient(t, "db:5140", client.Options{MaxRetries: 3, Dial: failing})
+	if _, err := c.Count(context.Background(), "events", nil); err == nil {
+		t.Fatal("expected error when server is unreachable")
+	}
+	if got := dials.Load(); got != 4 {
+		t.Errorf("expected 4 dial attempts, got %d", got)
+	}
+
+	slow := newClient(t, "db:5140", client.Options{MaxRetries: 10, MinBackoff: time.Second, Dial: failing})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := slow.Count(ctx, "events", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("backoff did not respect context")
+	}
+}
+
+func TestPoolLimitsConnections(t *testing.T) {
+	srv := clienttest.NewServer()
+	defer srv.Close()
+
+	var open, maxOpen atomic.Int32
+	dial := func(ctx context.Context, addr string) (net.Conn, error) {
+		n := open.Add(1)
+		for {
+			m := maxOpen.Load()
+			if n <= m || maxOpen.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		return &countedConn{Conn: conn, open: &open}, nil
+	}
+	c := newClient(t, srv.Addr(), client.Options{PoolSize: 2, Dial: dial})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Count(context.Background(), "events", nil); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if maxOpen.Load() > 2 {
+		t.Errorf("expected at most 2 open connections, got %d", maxOpen.Load())
+	}
+	if got := len(srv.Requests()); got != 20 {
+		t.Errorf("expected 20 requests, got %d", got)
+	}
+}
+
+type countedConn struct {
+	net.Conn
+	open *atomic.Int32
+	once sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(func() { c.open.Add(-1) })
+	return c.Conn.Close()
+}
+
+func TestClosedClient(t *testing.T) {
+	srv := clienttest.NewServer()
+	defer srv.Close()
+	c := newClient(t, srv.Addr(), client.Options{})
+	c.Close()
+
+	if _, err := c.Find(context.Background(), "events", nil); !errors.Is(err, client.ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
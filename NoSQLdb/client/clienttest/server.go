@@ -0,0 +1,373 @@
+// Package clienttest — NoSQLdb в памяти для тестов клиентов: говорит по тому же
+// протоколу, хранит коллекции без диска и записывает полученные запросы.
+package clienttest
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"nosql_db/client"
+	"nosql_db/internal/api"
+	"nosql_db/internal/datetime"
+	"nosql_db/internal/index"
+	"nosql_db/internal/operators"
+	"nosql_db/internal/schema"
+	"nosql_db/internal/storage"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Server фейковый сервер на 127.0.0.1 со случайным портом
+type Server struct {
+	listener net.Listener
+
+	mu          sync.Mutex
+	collections map[string]*storage.Collection
+	indexes     map[string]map[string]bool // имена индексов по коллекциям, сами деревья не строятся
+	requests    []client.Request
+	handler     func(client.Request) (client.Response, bool)
+	conns       map[net.Conn]struct{}
+	wg          sync.WaitGroup
+}
+
+// NewServer запускает фейковый сервер; остановить — Close
+func NewServer() *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("clienttest: listen: %v", err))
+	}
+	s := &Server{
+		listener:    listener,
+		collections: make(map[string]*storage.Collection),
+		indexes:     make(map[string]map[string]bool),
+		conns:       make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Addr адрес для client.New
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close останавливает сервер и закрывает соединения
+func (s *Server) Close() {
+	s.listener.Close()
+	s.CloseConnections()
+	s.wg.Wait()
+}
+
+// CloseConnections обрывает открытые соединения (имитация перезапуска сервера)
+func (s *Server) CloseConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// Handle подменяет ответы: если fn возвращает true, клиент получает её ответ,
+// иначе запрос выполняется как обычно. Ответ с пустым Status обрывает соединение
+func (s *Server) Handle(fn func(client.Request) (client.Response, bool)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handler = fn
+}
+
+// Requests возвращает копию всех полученных запросов
+func (s *Server) Requests() []client.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]client.Request(nil), s.requests...)
+}
+
+// Documents возвращает документы коллекции
+func (s *Server) Documents(database string) []map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if coll, ok := s.collections[database]; ok {
+		return coll.All()
+	}
+	return nil
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handleConn(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return
+		}
+		var req client.Request
+		if err := json.Unmarshal(line, &req); err != nil {
+			return
+		}
+
+		resp := s.execute(req)
+		if resp.Status == "" {
+			return
+		}
+		data, _ := json.Marshal(resp)
+		if _, err := conn.Write(append(data, '\n')); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) execute(req client.Request) client.Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, req)
+	if s.handler != nil {
+		if resp, ok := s.handler(req); ok {
+			return resp
+		}
+	}
+
+	if req.Command == api.CmdListCollections {
+		names := make([]string, 0, len(s.collections))
+		for name := range s.collections {
+			names = append(names, name)
+		}
+		return namesResponse(names)
+	}
+	if req.Database == "" {
+		return errorResponse("database name is required")
+	}
+	coll, ok := s.collections[req.Database]
+	if !ok {
+		coll = storage.NewCollection(req.Database)
+		s.collections[req.Database] = coll
+		s.indexes[req.Database] = make(map[string]bool)
+	}
+	indexes := s.indexes[req.Database]
+
+	switch req.Command {
+	case api.CmdFind, api.CmdCount, api.CmdDistinct, api.CmdDelete:
+		query, err := datetime.ResolveQuery(req.Query, time.Now())
+		if err != nil {
+			return errorResponse(fmt.Sprintf("invalid query: %v", err))
+		}
+		req.Query = query
+	}
+
+	switch req.Command {
+	case api.CmdInsert:
+		return insert(coll, req)
+	case api.CmdFind:
+		docs := match(coll, req.Query)
+		return client.Response{Status: api.StatusSuccess, Data: docs, Count: len(docs)}
+	case api.CmdCount:
+		return client.Response{Status: api.StatusSuccess, Count: len(match(coll, req.Query))}
+	case api.CmdDistinct:
+		return distinct(coll, req)
+	case api.CmdDelete:
+		deleted := 0
+		for _, doc := range match(coll, req.Query) {
+			if coll.Delete(doc["_id"].(string)) {
+				deleted++
+			}
+		}
+		return client.Response{Status: api.StatusSuccess, Message: fmt.Sprintf("Deleted %d document(s)", deleted), Count: deleted}
+	case api.CmdCreateIndex, api.CmdDropIndex:
+		// индексы не влияют на результаты, запоминаются только имена полей;
+		// правило сравнения индекса не моделируется
+		field := indexField(req)
+		if field == "" {
+			return errorResponse("field name required in query")
+		}
+		if req.Command == api.CmdCreateIndex {
+			if indexes[field] {
+				return errorResponse(fmt.Sprintf("failed to create index: index on field '%s' already exists", field))
+			}
+			indexes[field] = true
+			return client.Response{Status: api.StatusSuccess, Message: "Index created"}
+		}
+		if !indexes[field] {
+			return errorResponse(fmt.Sprintf("failed to drop index: index on field '%s' does not exist", field))
+		}
+		delete(indexes, field)
+		return client.Response{Status: api.StatusSuccess, Message: "Index dropped"}
+	case api.CmdListIndexes:
+		names := make([]string, 0, len(indexes))
+		for name := range indexes {
+			names = append(names, name)
+		}
+		return namesResponse(names)
+	case api.CmdRebuildIndexes:
+		return client.Response{Status: api.StatusSuccess, Message: fmt.Sprintf("%d index(es) rebuilt", len(indexes))}
+	case api.CmdListFields:
+		seen := make(map[string]bool)
+		var names []string
+		for _, doc := range coll.All() {
+			for field := range doc {
+				if !seen[field] {
+					seen[field] = true
+					names = append(names, field)
+				}
+			}
+		}
+		return namesResponse(names)
+	case api.CmdSetSchema:
+		coll.Schema = nil
+		if len(req.Query) > 0 {
+			parsed, err := schema.Parse(req.Query)
+			if err != nil {
+				return errorResponse(fmt.Sprintf("invalid schema: %v", err))
+			}
+			coll.Schema = parsed
+		}
+		return client.Response{Status: api.StatusSuccess, Message: "Schema updated"}
+	case api.CmdCreateCapped:
+		options, err := storage.ParseCappedOptions(req.Query)
+		if err != nil {
+			return errorResponse(fmt.Sprintf("invalid capped options: %v", err))
+		}
+		if err := coll.SetCapped(options); err != nil {
+			return errorResponse(fmt.Sprintf("failed to create capped collection: %v", err))
+		}
+		return client.Response{Status: api.StatusSuccess, Message: "Capped collection updated"}
+	case api.CmdGetSchema:
+		if coll.Schema == nil {
+			return client.Response{Status: api.StatusSuccess, Message: "No schema defined"}
+		}
+		return client.Response{Status: api.StatusSuccess, Data: []map[string]any{coll.Schema.Raw()}, Count: 1}
+	default:
+		return errorResponse(fmt.Sprintf("command %s is not supported by the fake server", req.Command))
+	}
+}
+
+func errorResponse(message string) client.Response {
+	return client.Response{Status: api.StatusError, Message: message}
+}
+
+func indexField(req client.Request) string {
+	if req.Field != "" {
+		return req.Field
+	}
+	for field := range req.Query {
+		return field
+	}
+	return ""
+}
+
+// namesResponse отсортированный список имён в поле values
+func namesResponse(names []string) client.Response {
+	sort.Strings(names)
+	values := make([]any, len(names))
+	for i, name := range names {
+		values[i] = name
+	}
+	return client.Response{Status: api.StatusSuccess, Values: values, Count: len(values)}
+}
+
+func match(coll *storage.Collection, query map[string]any) []map[string]any {
+	var docs []map[string]any
+	for _, doc := range coll.All() {
+		if operators.MatchDocument(doc, query) {
+			docs = append(docs, doc)
+		}
+	}
+	return docs
+}
+
+// insert повторяет семантику сервера: документы независимы, повтор с тем же _id — no-op
+func insert(coll *storage.Collection, req client.Request) client.Response {
+	if len(req.Data) == 0 {
+		return errorResponse("no data provided for insert")
+	}
+
+	ids := make([]string, len(req.Data))
+	var docErrors []client.DocumentError
+	inserted := 0
+	for i, doc := range req.Data {
+		if err := datetime.NormalizeDocument(doc); err != nil {
+			docErrors = append(docErrors, client.DocumentError{Index: i, Reasons: []string{err.Error()}})
+			continue
+		}
+		coll.Normalize(doc)
+		if reasons := coll.Validate(doc); len(reasons) > 0 {
+			docErrors = append(docErrors, client.DocumentError{Index: i, Reasons: reasons})
+			continue
+		}
+		id, err := coll.Insert(doc)
+		switch {
+		case errors.Is(err, storage.ErrAlreadyStored):
+			ids[i] = id
+		case err != nil:
+			docErrors = append(docErrors, client.DocumentError{Index: i, Reasons: []string{err.Error()}})
+		default:
+			ids[i] = id
+			inserted++
+		}
+	}
+
+	status := api.StatusSuccess
+	switch {
+	case len(docErrors) == len(req.Data):
+		status = api.StatusError
+	case len(docErrors) > 0:
+		status = api.StatusPartial
+	}
+	return client.Response{
+		Status:  status,
+		Message: fmt.Sprintf("Inserted %d document(s), %d rejected", inserted, len(docErrors)),
+		Count:   inserted,
+		IDs:     ids,
+		Errors:  docErrors,
+	}
+}
+
+func distinct(coll *storage.Collection, req client.Request) client.Response {
+	if req.Field == "" {
+		return errorResponse("field is required for distinct")
+	}
+
+	seen := make(map[string]any)
+	for _, doc := range match(coll, req.Query) {
+		if value, ok := doc[req.Field]; ok {
+			seen[string(index.ValueToKey(value))] = value
+		}
+	}
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := make([]any, len(keys))
+	for i, key := range keys {
+		values[i] = seen[key]
+	}
+	return client.Response{Status: api.StatusSuccess, Values: values, Count: len(values)}
+}
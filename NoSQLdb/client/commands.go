@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"nosql_db/internal/api"
+)
+
+// InsertResult итог insert; при частичном успехе отклонённые документы перечислены в Errors
+type InsertResult struct {
+	Inserted int             // вставлено новых документов
+	IDs      []string        // _id в порядке документов запроса ("" для отклонённых)
+	Errors   []DocumentError // причины отказа по документам
+	Partial  bool            // часть документов отклонена
+	Message  string
+}
+
+// call выполняет запрос и превращает статус error в *ServerError
+func (c *Client) call(ctx context.Context, req Request) (Response, error) {
+	resp, err := c.Do(ctx, req)
+	if err != nil {
+		return Response{}, err
+	}
+	if resp.Status == StatusError {
+		return resp, &ServerError{Message: resp.Message}
+	}
+	return resp, nil
+}
+
+// Insert вставляет документы. Ошибка возвращается, если отклонены все документы;
+// при частичном успехе InsertResult.Partial == true
+func (c *Client) Insert(ctx context.Context, database string, docs ...map[string]any) (InsertResult, error) {
+	resp, err := c.call(ctx, Request{Database: database, Command: api.CmdInsert, Data: docs})
+	result := InsertResult{
+		Inserted: resp.Count,
+		IDs:      resp.IDs,
+		Errors:   resp.Errors,
+		Partial:  resp.Status == StatusPartial,
+		Message:  resp.Message,
+	}
+	return result, err
+}
+
+// Find возвращает документы, подходящие под query
+func (c *Client) Find(ctx context.Context, database string, query map[string]any) ([]map[string]any, error) {
+	resp, err := c.call(ctx, Request{Database: database, Command: api.CmdFind, Query: query})
+	return resp.Data, err
+}
+
+// Delete удаляет документы, подходящие под query, и возвращает их число
+func (c *Client) Delete(ctx context.Context, database string, query map[string]any) (int, error) {
+	resp, err := c.call(ctx, Request{Database: database, Command: api.CmdDelete, Query: query})
+	return resp.Count, err
+}
+
+// CreateIndex создаёт индекс на поле
+func (c *Client) CreateIndex(ctx context.Context, database, field string) error {
+	_, err := c.call(ctx, Request{Database: database, Command: api.CmdCreateIndex, Query: map[string]any{field: nil}})
+	return err
+}
+
+// IndexOptions параметры создаваемого индекса
+type IndexOptions struct {
+	// Collation правило сравнения строк: "binary" (по умолчанию) или
+	// "case_insensitive" — ключи хранятся без учёта регистра
+	Collation string
+}
+
+// CreateIndexWithOptions создаёт индекс на поле с параметрами opts
+func (c *Client) CreateIndexWithOptions(ctx context.Context, database, field string, opts IndexOptions) error {
+	var params map[string]any
+	if opts.Collation != "" {
+		params = map[string]any{"collation": opts.Collation}
+	}
+	_, err := c.call(ctx, Request{Database: database, Command: api.CmdCreateIndex, Query: map[string]any{field: params}})
+	return err
+}
+
+// CappedOptions пределы capped-коллекции; 0 — без ограничения
+type CappedOptions struct {
+	MaxDocs  int   // максимум документов
+	MaxBytes int64 // максимум суммарного размера документов в JSON
+}
+
+// CreateCapped делает пустую коллекцию capped или меняет пределы уже capped-коллекции.
+// При превышении предела самые старые документы вытесняются, find отдаёт документы
+// в порядке вставки
+func (c *Client) CreateCapped(ctx context.Context, database string, opts CappedOptions) error {
+	limits := make(map[string]any)
+	if opts.MaxDocs > 0 {
+		limits["max_docs"] = opts.MaxDocs
+	}
+	if opts.MaxBytes > 0 {
+		limits["max_bytes"] = opts.MaxBytes
+	}
+	_, err := c.call(ctx, Request{Database: database, Command: api.CmdCreateCapped, Query: limits})
+	return err
+}
+
+// Count возвращает число документов, подходящих под query (nil — вся коллекция)
+func (c *Client) Count(ctx context.Context, database string, query map[string]any) (int, error) {
+	resp, err := c.call(ctx, Request{Database: database, Command: api.CmdCount, Query: query})
+	return resp.Count, err
+}
+
+// Distinct возвращает уникальные значения поля среди документов, подходящих под query
+func (c *Client) Distinct(ctx context.Context, database, field string, query map[string]any) ([]any, error) {
+	resp, err := c.call(ctx, Request{Database: database, Command: api.CmdDistinct, Field: field, Query: query})
+	return resp.Values, err
+}
+
+// SetSchema задаёт схему коллекции; nil снимает схему
+func (c *Client) SetSchema(ctx context.Context, database string, schema map[string]any) error {
+	_, err := c.call(ctx, Request{Database: database, Command: api.CmdSetSchema, Query: schema})
+	return err
+}
+
+// GetSchema возвращает схему коллекции или nil, если схема не задана
+func (c *Client) GetSchema(ctx context.Context, database string) (map[string]any, error) {
+	resp, err := c.call(ctx, Request{Database: database, Command: api.CmdGetSchema})
+	if err != nil || len(resp.Data) == 0 {
+		return nil, err
+	}
+	return resp.Data[0], nil
+}
+
+// DropIndex удаляет индекс на поле
+func (c *Client) DropIndex(ctx context.Context, database, field string) error {
+	_, err := c.call(ctx, Request{Database: database, Command: api.CmdDropIndex, Field: field})
+	return err
+}
+
+// RebuildIndexes пересоздаёт все индексы коллекции
+func (c *Client) RebuildIndexes(ctx context.Context, database string) error {
+	_, err := c.call(ctx, Request{Database: database, Command: api.CmdRebuildIndexes})
+	return err
+}
+
+// ListIndexes возвращает отсортированные имена индексированных полей
+func (c *Client) ListIndexes(ctx context.Context, database string) ([]string, error) {
+	resp, err := c.call(ctx, Request{Database: database, Command: api.CmdListIndexes})
+	return stringValues(resp.Values), err
+}
+
+// ListCollections возвращает отсортированные имена коллекций сервера
+func (c *Client) ListCollections(ctx context.Context) ([]string, error) {
+	resp, err := c.call(ctx, Request{Command: api.CmdListCollections})
+	return stringValues(resp.Values), err
+}
+
+// ListFields возвращает имена полей верхнего уровня, встречающихся в коллекции
+func (c *Client) ListFields(ctx context.Context, database string) ([]string, error) {
+	resp, err := c.call(ctx, Request{Database: database, Command: api.CmdListFields})
+	return stringValues(resp.Values), err
+}
+
+func stringValues(values []any) []string {
+	if values == nil {
+		return nil
+	}
+	names := make([]string, 0, len(values))
+	for _, v := range values {
+		if name, ok := v.(string); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"nosql_db/internal/api"
+)
+
+// Event изменение из потока watch
+type Event struct {
+	Operation string         // insert или delete
+	ID        string         // _id документа
+	Document  map[string]any // документ (для delete — последняя версия)
+	Token     string         // позиция для продолжения потока
+}
+
+// Stream поток изменений коллекции на отдельном соединении вне пула
+type Stream struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	token  string
+	stop   func() bool
+}
+
+// Watch открывает поток изменений коллекции. query фильтрует документы,
+// resumeAfter — токен последнего полученного события ("" — с текущего момента)
+func (c *Client) Watch(ctx context.Context, database string, query map[string]any, resumeAfter string) (*Stream, error) {
+	if c.closed() {
+		return nil, ErrClosed
+	}
+	netConn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Stream{conn: netConn, reader: bufio.NewReader(netConn)}
+	// отмена ctx закрывает поток
+	s.stop = context.AfterFunc(ctx, func() { netConn.Close() })
+
+	req := Request{Database: database, Command: api.CmdWatch, Query: query, ResumeAfter: resumeAfter}
+	data, err := json.Marshal(req)
+	if err == nil {
+		_, err = netConn.Write(append(data, '\n'))
+	}
+	if err != nil {
+		s.Close()
+		return nil, fmt.Errorf("nosqldb: start watch: %w", err)
+	}
+
+	resp, err := s.read()
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	if resp.Status == StatusError {
+		s.Close()
+		return nil, &ServerError{Message: resp.Message}
+	}
+	s.token = resp.Token
+	return s, nil
+}
+
+// Next блокируется до следующего изменения
+func (s *Stream) Next() (Event, error) {
+	resp, err := s.read()
+	if err != nil {
+		return Event{}, err
+	}
+	if resp.Status == StatusError {
+		return Event{}, &ServerError{Message: resp.Message}
+	}
+	if resp.Change == nil {
+		return Event{}, fmt.Errorf("nosqldb: unexpected watch message: %s", resp.Message)
+	}
+
+	s.token = resp.Token
+	event := Event{Operation: resp.Change.Operation, ID: resp.Change.ID, Token: resp.Token}
+	if len(resp.Data) > 0 {
+		event.Document = resp.Data[0]
+	}
+	return event, nil
+}
+
+// Token позиция последнего полученного события (или начала потока)
+func (s *Stream) Token() string {
+	return s.token
+}
+
+// Close завершает поток
+func (s *Stream) Close() error {
+	s.stop()
+	return s.conn.Close()
+}
+
+func (s *Stream) read() (Response, error) {
+	line, err := s.reader.ReadBytes('\n')
+	if err != nil {
+		return Response{}, fmt.Errorf("nosqldb: watch stream: %w", err)
+	}
+	var resp Response
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return Response{}, fmt.Errorf("nosqldb: decode watch message: %w", err)
+	}
+	return resp, nil
+}
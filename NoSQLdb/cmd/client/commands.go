@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"nosql_db/internal/api"
+	"nosql_db/internal/query"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// argKind тип позиционного аргумента команды (для разбора и автодополнения)
+type argKind int
+
+const (
+	argCollection argKind = iota
+	argField              // любое поле коллекции
+	argIndex              // индексированное поле
+)
+
+// payloadKind что ожидается в JSON после позиционных аргументов
+type payloadKind int
+
+const (
+	payloadNone          payloadKind = iota
+	payloadQuery                     // необязательный фильтр
+	payloadFilter                    // обязательный фильтр
+	payloadDocuments                 // документ или массив документов
+	payloadSchema                    // схема коллекции
+	payloadIndexOptions              // необязательные параметры индекса
+	payloadCappedOptions             // пределы capped-коллекции
+)
+
+// noun что за JSON ожидается, для сообщений об ошибках
+func (k payloadKind) noun() string {
+	if k == payloadCappedOptions {
+		return "limits"
+	}
+	return "schema"
+}
+
+// commandSpec описание команды REPL
+type commandSpec struct {
+	name    string
+	api     string
+	args    []argKind
+	payload payloadKind
+	usage   string
+	write   bool // меняет данные или индексы (сбрасывает кэш автодополнения)
+}
+
+var commandSpecs = []commandSpec{
+	{name: "INSERT", api: api.CmdInsert, args: []argKind{argCollection}, payload: payloadDocuments, write: true,
+		usage: "INSERT <collection> <document | [documents]>"},
+	{name: "FIND", api: api.CmdFind, args: []argKind{argCollection}, payload: payloadQuery,
+		usage: "FIND <collection> [query]"},
+	{name: "DELETE", api: api.CmdDelete, args: []argKind{argCollection}, payload: payloadFilter, write: true,
+		usage: "DELETE <collection> <query>"},
+	{name: "COUNT", api: api.CmdCount, args: []argKind{argCollection}, payload: payloadQuery,
+		usage: "COUNT <collection> [query]"},
+	{name: "DISTINCT", api: api.CmdDistinct, args: []argKind{argCollection, argField}, payload: payloadQuery,
+		usage: "DISTINCT <collection> <field> [query]"},
+	{name: "CREATE_INDEX", api: api.CmdCreateIndex, args: []argKind{argCollection, argField}, payload: payloadIndexOptions, write: true,
+		usage: `CREATE_INDEX <collection> <field> [{"collation": "case_insensitive"}]`},
+	{name: "DROP_INDEX", api: api.CmdDropIndex, args: []argKind{argCollection, argIndex}, write: true,
+		usage: "DROP_INDEX <collection> <field>"},
+	{name: "LIST_INDEXES", api: api.CmdListIndexes, args: []argKind{argCollection},
+		usage: "LIST_INDEXES <collection>"},
+	{name: "REBUILD_INDEXES", api: api.CmdRebuildIndexes, args: []argKind{argCollection}, write: true,
+		usage: "REBUILD_INDEXES <collection>"},
+	{name: "LIST_COLLECTIONS", api: api.CmdListCollections,
+		usage: "LIST_COLLECTIONS"},
+	{name: "LIST_FIELDS", api: api.CmdListFields, args: []argKind{argCollection},
+		usage: "LIST_FIELDS <collection>"},
+	{name: "SET_SCHEMA", api: api.CmdSetSchema, args: []argKind{argCollection}, payload: payloadSchema, write: true,
+		usage: "SET_SCHEMA <collection> <schema>"},
+	{name: "GET_SCHEMA", api: api.CmdGetSchema, args: []argKind{argCollection},
+		usage: "GET_SCHEMA <collection>"},
+	{name: "CREATE_CAPPED", api: api.CmdCreateCapped, args: []argKind{argCollection}, payload: payloadCappedOptions, write: true,
+		usage: `CREATE_CAPPED <collection> {"max_docs": N, "max_bytes": N}`},
+}
+
+// команды самого клиента, не отправляются на сервер
+const (
+	cmdHelp = "HELP"
+	cmdExit = "EXIT"
+	cmdQuit = "QUIT"
+)
+
+func lookupCommand(name string) (commandSpec, bool) {
+	name = strings.ToUpper(name)
+	for _, spec := range commandSpecs {
+		if spec.name == name {
+			return spec, true
+		}
+	}
+	return commandSpec{}, false
+}
+
+// commandNames имена всех команд, включая команды клиента
+func commandNames() []string {
+	names := make([]string, 0, len(commandSpecs)+3)
+	for _, spec := range commandSpecs {
+		names = append(names, spec.name)
+	}
+	names = append(names, cmdHelp, cmdExit, cmdQuit)
+	sort.Strings(names)
+	return names
+}
+
+func helpText() string {
+	var b strings.Builder
+	b.WriteString("Commands:\n")
+	for _, spec := range commandSpecs {
+		fmt.Fprintf(&b, "  %s\n", spec.usage)
+	}
+	b.WriteString("  HELP, EXIT\n")
+	return b.String()
+}
+
+// nextToken отделяет первое слово строки; JSON в остатке не трогается
+func nextToken(s string) (string, string) {
+	s = strings.TrimLeftFunc(s, unicode.IsSpace)
+	end := strings.IndexFunc(s, unicode.IsSpace)
+	if end < 0 {
+		return s, ""
+	}
+	return s[:end], strings.TrimLeftFunc(s[end:], unicode.IsSpace)
+}
+
+// parseStatement разбирает команду в запрос к серверу
+func parseStatement(stmt string) (commandSpec, api.Request, error) {
+	name, rest := nextToken(stmt)
+	spec, ok := lookupCommand(name)
+	if !ok {
+		return commandSpec{}, api.Request{}, fmt.Errorf("unknown command: %s", name)
+	}
+
+	req := api.Request{Command: spec.api}
+	for _, arg := range spec.args {
+		var value string
+		value, rest = nextToken(rest)
+		if value == "" || strings.HasPrefix(value, "{") || strings.HasPrefix(value, "[") {
+			return spec, req, fmt.Errorf("usage: %s", spec.usage)
+		}
+		if arg == argCollection {
+			req.Database = value
+		} else {
+			req.Field = value
+		}
+	}
+
+	payload := strings.TrimSpace(rest)
+	var err error
+	switch spec.payload {
+	case payloadNone:
+		if payload != "" {
+			err = fmt.Errorf("unexpected arguments: %s (usage: %s)", payload, spec.usage)
+		}
+	case payloadQuery, payloadFilter:
+		if payload == "" {
+			if spec.payload == payloadFilter {
+				err = fmt.Errorf("missing JSON query (usage: %s)", spec.usage)
+			}
+			break
+		}
+		var q *query.Query
+		if q, err = query.Parse(payload); err == nil {
+			req.Query = q.Conditions
+		}
+	case payloadDocuments:
+		req.Data, err = parseDocuments(payload)
+	case payloadIndexOptions:
+		if payload == "" {
+			break
+		}
+		var q *query.Query
+		if q, err = query.Parse(payload); err == nil {
+			req.Query = map[string]any{req.Field: q.Conditions}
+		}
+	case payloadSchema, payloadCappedOptions:
+		if payload == "" {
+			err = fmt.Errorf("missing JSON %s (usage: %s)", spec.payload.noun(), spec.usage)
+			break
+		}
+		var q *query.Query
+		if q, err = query.Parse(payload); err == nil {
+			req.Query = q.Conditions
+		}
+	}
+	return spec, req, err
+}
+
+// parseDocuments принимает один документ или JSON-массив документов
+func parseDocuments(payload string) ([]map[string]any, error) {
+	if payload == "" {
+		return nil, fmt.Errorf("missing JSON document")
+	}
+	if strings.HasPrefix(payload, "[") {
+		var docs []map[string]any
+		if err := json.Unmarshal([]byte(payload), &docs); err != nil {
+			return nil, fmt.Errorf("invalid JSON documents: %w", err)
+		}
+		if len(docs) == 0 {
+			return nil, fmt.Errorf("empty document list")
+		}
+		return docs, nil
+	}
+	doc, err := query.ParseDocument(payload)
+	if err != nil {
+		return nil, err
+	}
+	return []map[string]any{doc}, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"nosql_db/client"
+	"sort"
+	"strings"
+	"time"
+)
+
+// completionTimeout запросы имён для дополнения не должны подвешивать ввод
+const completionTimeout = 2 * time.Second
+
+// completer дополняет команды, имена коллекций и полей. Имена запрашиваются
+// у сервера (list_collections, list_fields, list_indexes) и кэшируются
+// до первой команды, меняющей данные
+type completer struct {
+	client *client.Client
+
+	collections []string
+	fields      map[string][]string
+	indexes     map[string][]string
+}
+
+func newCompleter(c *client.Client) *completer {
+	return &completer{client: c}
+}
+
+// reset сбрасывает кэш имён
+func (c *completer) reset() {
+	c.collections = nil
+	c.fields = nil
+	c.indexes = nil
+}
+
+// complete возвращает варианты для последнего слова line
+func (c *completer) complete(line string) []string {
+	word := lastWord(line)
+	head := strings.TrimSuffix(line, word)
+	tokens := strings.Fields(head)
+
+	if len(tokens) == 0 {
+		return matchPrefix(commandNames(), word, true)
+	}
+	spec, ok := lookupCommand(tokens[0])
+	if !ok {
+		return nil
+	}
+
+	// позиционный аргумент: слово отделено от команды только пробелами
+	if pos := len(tokens) - 1; pos < len(spec.args) && !strings.ContainsAny(head, wordBreaks+`"`) {
+		switch spec.args[pos] {
+		case argCollection:
+			return matchPrefix(c.collectionNames(), word, false)
+		case argField:
+			return matchPrefix(c.fieldNames(tokens[1]), word, false)
+		case argIndex:
+			return matchPrefix(c.indexNames(tokens[1]), word, false)
+		}
+	}
+
+	// внутри JSON дополняются имена полей в кавычках
+	if strings.HasPrefix(word, `"`) && len(spec.args) > 0 && len(tokens) > 1 {
+		names := c.fieldNames(tokens[1])
+		quoted := make([]string, len(names))
+		for i, name := range names {
+			quoted[i] = `"` + name + `"`
+		}
+		return matchPrefix(quoted, word, false)
+	}
+	return nil
+}
+
+func (c *completer) collectionNames() []string {
+	if c.collections == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+		defer cancel()
+		names, err := c.client.ListCollections(ctx)
+		if err != nil {
+			return nil
+		}
+		c.collections = names
+	}
+	return c.collections
+}
+
+// fieldNames поля документов и индексов коллекции
+func (c *completer) fieldNames(collection string) []string {
+	if names, ok := c.fields[collection]; ok {
+		return names
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+	names, err := c.client.ListFields(ctx, collection)
+	if err != nil {
+		return nil
+	}
+	names = mergeNames(names, c.indexNames(collection))
+
+	if c.fields == nil {
+		c.fields = make(map[string][]string)
+	}
+	c.fields[collection] = names
+	return names
+}
+
+func (c *completer) indexNames(collection string) []string {
+	if names, ok := c.indexes[collection]; ok {
+		return names
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+	names, err := c.client.ListIndexes(ctx, collection)
+	if err != nil {
+		return nil
+	}
+
+	if c.indexes == nil {
+		c.indexes = make(map[string][]string)
+	}
+	c.indexes[collection] = names
+	return names
+}
+
+func mergeNames(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var names []string
+	for _, name := range append(append([]string(nil), a...), b...) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// matchPrefix отбирает варианты, начинающиеся с prefix
+func matchPrefix(options []string, prefix string, ignoreCase bool) []string {
+	var matches []string
+	for _, option := range options {
+		if strings.HasPrefix(option, prefix) || (ignoreCase && strings.HasPrefix(option, strings.ToUpper(prefix))) {
+			matches = append(matches, option)
+		}
+	}
+	return matches
+}
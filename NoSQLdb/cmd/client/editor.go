@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// errInterrupted строка сброшена по Ctrl-C
+var errInterrupted = errors.New("interrupted")
+
+// maxHistory сколько последних команд хранится в файле истории
+const maxHistory = 1000
+
+// history история команд с сохранением в файл
+type history struct {
+	entries []string
+	path    string // пустой — без сохранения
+}
+
+// loadHistory читает историю из файла; отсутствие файла не ошибка
+func loadHistory(path string) *history {
+	h := &history{path: path}
+	if path == "" {
+		return h
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return h
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			h.entries = append(h.entries, line)
+		}
+	}
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+	}
+	return h
+}
+
+// add запоминает команду; повтор предыдущей не записывается.
+// Многострочные команды сохраняются одной строкой
+func (h *history) add(line string) {
+	line = strings.Join(strings.Fields(line), " ")
+	if line == "" || (len(h.entries) > 0 && h.entries[len(h.entries)-1] == line) {
+		return
+	}
+	h.entries = append(h.entries, line)
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+	}
+	if h.path == "" {
+		return
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
+
+// completeFunc возвращает варианты для слова, которое заканчивается в конце line
+type completeFunc func(line string) []string
+
+// editor редактор строки для терминала в raw-режиме: курсор, история (стрелки
+// вверх/вниз), Ctrl-A/E/U/K/W, дополнение по Tab
+type editor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	history  *history
+	complete completeFunc
+}
+
+const (
+	keyCtrlA     = 1
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyLF        = 10
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyCR        = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+// lineState состояние редактируемой строки
+type lineState struct {
+	prompt string
+	buf    []rune
+	pos    int
+	hist   int    // позиция в истории; len(entries) — новая строка
+	saved  []rune // набранное до перехода по истории
+}
+
+// readLine читает строку; io.EOF — Ctrl-D на пустой строке
+func (e *editor) readLine(prompt string) (string, error) {
+	st := &lineState{prompt: prompt, hist: len(e.history.entries)}
+	e.refresh(st)
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case keyCR, keyLF:
+			fmt.Fprint(e.out, "\r\n")
+			return string(st.buf), nil
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupted
+		case keyCtrlD:
+			if len(st.buf) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			st.deleteAt(st.pos)
+		case keyBackspace, keyCtrlH:
+			if st.pos > 0 {
+				st.pos--
+				st.deleteAt(st.pos)
+			}
+		case keyCtrlA:
+			st.pos = 0
+		case keyCtrlE:
+			st.pos = len(st.buf)
+		case keyCtrlU:
+			st.buf = append([]rune(nil), st.buf[st.pos:]...)
+			st.pos = 0
+		case keyCtrlK:
+			st.buf = st.buf[:st.pos]
+		case keyCtrlW:
+			st.deleteWord()
+		case keyCtrlL:
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case keyCtrlP:
+			e.historyMove(st, -1)
+		case keyCtrlN:
+			e.historyMove(st, 1)
+		case keyTab:
+			e.completeWord(st)
+		case keyEscape:
+			if err := e.escape(st); err != nil {
+				return "", err
+			}
+		default:
+			if unicode.IsPrint(r) {
+				st.buf = append(st.buf[:st.pos], append([]rune{r}, st.buf[st.pos:]...)...)
+				st.pos++
+			}
+		}
+		e.refresh(st)
+	}
+}
+
+// escape разбирает escape-последовательности стрелок, Home/End и Delete
+func (e *editor) escape(st *lineState) error {
+	next, _, err := e.in.ReadRune()
+	if err != nil {
+		return err
+	}
+	if next != '[' && next != 'O' {
+		return nil
+	}
+	code, _, err := e.in.ReadRune()
+	if err != nil {
+		return err
+	}
+	if code >= '0' && code <= '9' {
+		// ESC [ n ~
+		for {
+			r, _, err := e.in.ReadRune()
+			if err != nil {
+				return err
+			}
+			if r == '~' {
+				break
+			}
+		}
+		switch code {
+		case '1', '7':
+			st.pos = 0
+		case '4', '8':
+			st.pos = len(st.buf)
+		case '3':
+			st.deleteAt(st.pos)
+		}
+		return nil
+	}
+	switch code {
+	case 'A':
+		e.historyMove(st, -1)
+	case 'B':
+		e.historyMove(st, 1)
+	case 'C':
+		if st.pos < len(st.buf) {
+			st.pos++
+		}
+	case 'D':
+		if st.pos > 0 {
+			st.pos--
+		}
+	case 'H':
+		st.pos = 0
+	case 'F':
+		st.pos = len(st.buf)
+	}
+	return nil
+}
+
+func (st *lineState) deleteAt(pos int) {
+	if pos < len(st.buf) {
+		st.buf = append(st.buf[:pos], st.buf[pos+1:]...)
+	}
+}
+
+// deleteWord удаляет слово перед курсором вместе с пробелами после него
+func (st *lineState) deleteWord() {
+	start := st.pos
+	for start > 0 && unicode.IsSpace(st.buf[start-1]) {
+		start--
+	}
+	for start > 0 && !unicode.IsSpace(st.buf[start-1]) {
+		start--
+	}
+	st.buf = append(st.buf[:start], st.buf[st.pos:]...)
+	st.pos = start
+}
+
+func (e *editor) historyMove(st *lineState, delta int) {
+	target := st.hist + delta
+	if target < 0 || target > len(e.history.entries) {
+		return
+	}
+	if st.hist == len(e.history.entries) {
+		st.saved = append([]rune(nil), st.buf...)
+	}
+	st.hist = target
+	if target == len(e.history.entries) {
+		st.buf = append([]rune(nil), st.saved...)
+	} else {
+		st.buf = []rune(e.history.entries[target])
+	}
+	st.pos = len(st.buf)
+}
+
+// completeWord дополняет слово перед курсором. Единственный вариант
+// подставляется целиком, при нескольких — общий префикс, а если дополнять
+// нечего, варианты выводятся под строкой
+func (e *editor) completeWord(st *lineState) {
+	if e.complete == nil {
+		return
+	}
+	before := string(st.buf[:st.pos])
+	word := []rune(lastWord(before))
+	candidates := e.complete(before)
+	if len(candidates) == 0 {
+		return
+	}
+
+	insert := []rune(commonPrefix(candidates))
+	if len(candidates) == 1 && !strings.HasPrefix(candidates[0], `"`) {
+		insert = append(insert, ' ')
+	}
+	if len(insert) > len(word) {
+		st.buf = append(st.buf[:st.pos-len(word)], append(insert, st.buf[st.pos:]...)...)
+		st.pos += len(insert) - len(word)
+		return
+	}
+	if len(candidates) > 1 {
+		fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+	}
+}
+
+// refresh перерисовывает строку и ставит курсор
+func (e *editor) refresh(st *lineState) {
+	var b strings.Builder
+	b.WriteString("\r")
+	b.WriteString(st.prompt)
+	b.WriteString(string(st.buf))
+	b.WriteString("\x1b[K")
+	if back := len(st.buf) - st.pos; back > 0 {
+		fmt.Fprintf(&b, "\x1b[%dD", back)
+	}
+	io.WriteString(e.out, b.String())
+}
+
+// wordBreaks символы, отделяющие слово для дополнения (пробелы и JSON-разметка)
+const wordBreaks = "{}[],:"
+
+// lastWord слово, заканчивающееся в конце строки
+func lastWord(line string) string {
+	start := strings.LastIndexFunc(line, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(wordBreaks, r)
+	})
+	if start < 0 {
+		return line
+	}
+	_, size := utf8.DecodeRuneInString(line[start:])
+	return line[start+size:]
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, w := range words[1:] {
+		for !strings.HasPrefix(w, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	return prefix
+}
@@ -2,149 +2,184 @@ package main
 
 import (
 	"bufio"
-	"encoding/json"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net"
-	"nosql_db/internal/api"
-	"nosql_db/internal/query"
+	"nosql_db/client"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
 var (
-	host = flag.String("host", "localhost", "Server host address")
-	port = flag.String("port", "8080", "Server port")
+	host        = flag.String("host", "localhost", "Server host address")
+	port        = flag.String("port", "5140", "Server port")
+	scriptFile  = flag.String("f", "", "Execute commands from file (\"-\" for stdin) and exit")
+	format      = flag.String("o", string(formatPretty), "Output format: pretty, jsonl or table")
+	timeout     = flag.Duration("timeout", 30*time.Second, "Timeout for each command (0 disables)")
+	keepGoing   = flag.Bool("continue", false, "Continue executing a script after a failed command")
+	historyPath = flag.String("history", defaultHistoryPath(), "Command history file (empty disables)")
+
+	commands []string
 )
 
+func init() {
+	flag.Func("e", "Execute commands and exit (may be repeated; commands are separated by ';' or newlines)", func(s string) error {
+		commands = append(commands, s)
+		return nil
+	})
+}
+
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".nosqldb_history")
+}
+
+// Коды завершения: 0 — все команды выполнены, 1 — хотя бы одна команда
+// завершилась ошибкой, 2 — неверные аргументы или недоступный скрипт
 func main() {
 	flag.Parse()
 
-	addr := fmt.Sprintf("%s:%s", *host, *port)
-
-	conn, err := net.Dial("tcp", addr)
+	outFormat, err := parseFormat(*format)
 	if err != nil {
-		log.Fatalf("Failed to connect to server %s: %v", addr, err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
-	defer conn.Close()
 
-	log.Printf("Connected to server %s", addr)
+	addr := net.JoinHostPort(*host, *port)
+	c := client.New(addr, client.Options{PoolSize: 1})
+	defer c.Close()
 
-	runREPL(conn)
+	r := &runner{client: c, out: os.Stdout, format: outFormat, timeout: *timeout}
+
+	if len(commands) > 0 || *scriptFile != "" || !isInteractive() {
+		os.Exit(runBatch(r))
+	}
+
+	log.Printf("Using server %s", addr)
+	runREPL(r)
 }
 
-func runREPL(conn net.Conn) {
-	reader := bufio.NewReader(os.Stdin)
-	decoder := json.NewDecoder(conn)
-	encoder := json.NewEncoder(conn)
+// isInteractive stdin подключён к терминалу, а не к файлу или pipe
+func isInteractive() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
 
-	fmt.Println("\nAvailable commands: INSERT, FIND, DELETE, CREATE_INDEX")
-	fmt.Print("> ")
+// runBatch выполняет -e, затем -f; без них — команды из stdin
+func runBatch(r *runner) int {
+	ctx := context.Background()
+	ok := true
 
-	for {
-		input, err := reader.ReadString('\n')
+	run := func(name string, src io.Reader) bool {
+		scriptOK, err := r.runScript(ctx, name, src, os.Stderr, *keepGoing)
 		if err != nil {
-			if err == io.EOF {
-				return
-			}
-			log.Printf("Error reading input: %v", err)
-			continue
+			fmt.Fprintln(os.Stderr, err)
+			return false
 		}
+		ok = ok && scriptOK
+		return scriptOK || *keepGoing
+	}
 
-		line := strings.TrimSpace(input)
-		if line == "" {
-			fmt.Print("> ")
-			continue
+	for i, cmd := range commands {
+		if !run(fmt.Sprintf("-e#%d", i+1), strings.NewReader(cmd)) {
+			return 1
 		}
+	}
 
-		if strings.EqualFold(line, "quit") || strings.EqualFold(line, "exit") {
-			return
+	switch *scriptFile {
+	case "":
+		if len(commands) == 0 {
+			run("stdin", os.Stdin)
 		}
-
-		req, err := parseLineToRequest(line)
+	case "-":
+		run("stdin", os.Stdin)
+	default:
+		f, err := os.Open(*scriptFile)
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			fmt.Print("> ")
-			continue
-		}
-
-		if err := encoder.Encode(req); err != nil {
-			log.Fatalf("Error encoding request: %v", err)
-		}
-
-		var resp api.Response
-		if err := decoder.Decode(&resp); err != nil {
-			log.Fatalf("Error decoding response: %v", err)
+			fmt.Fprintln(os.Stderr, err)
+			return 2
 		}
-
-		printResponse(resp)
-		fmt.Print("> ")
+		defer f.Close()
+		run(*scriptFile, f)
 	}
-}
 
-func parseLineToRequest(line string) (*api.Request, error) {
-	fields := strings.Fields(line)
-	if len(fields) < 2 {
-		return nil, fmt.Errorf("invalid command format")
+	if !ok {
+		return 1
 	}
+	return 0
+}
 
-	cmd := strings.ToUpper(fields[0])
-	collectionName := fields[1]
-
-	req := &api.Request{
-		Database: collectionName,
-		Command:  strings.ToLower(cmd),
+func runREPL(r *runner) {
+	fmt.Println("\nType HELP for the list of commands, EXIT to quit")
+
+	hist := loadHistory(*historyPath)
+	comp := newCompleter(r.client)
+	r.onWrite = comp.reset
+
+	readLine := plainReader(bufio.NewReader(os.Stdin))
+	if restore, err := makeRaw(os.Stdin.Fd()); err == nil {
+		restore()
+		ed := &editor{in: bufio.NewReader(os.Stdin), out: os.Stdout, history: hist, complete: comp.complete}
+		readLine = func(prompt string) (string, error) {
+			restore, err := makeRaw(os.Stdin.Fd())
+			if err != nil {
+				return "", err
+			}
+			defer restore()
+			return ed.readLine(prompt)
+		}
 	}
 
-	if cmd == "CREATE_INDEX" {
-		if len(fields) < 3 {
-			return nil, fmt.Errorf("usage: CREATE_INDEX <collection> <field_name>")
+	var s splitter
+	for {
+		prompt := "> "
+		if s.pending() {
+			prompt = "... "
 		}
-		fieldName := fields[2]
-		req.Query = map[string]any{
-			fieldName: nil,
+		line, err := readLine(prompt)
+		if errors.Is(err, errInterrupted) {
+			s = splitter{}
+			continue
 		}
-		return req, nil
-	}
-
-	if len(fields) < 3 {
-		return nil, fmt.Errorf("missing JSON payload")
-	}
-
-	jsonPayload := strings.Join(fields[2:], " ")
-
-	if cmd == "INSERT" {
-		doc, err := query.ParseDocument(jsonPayload)
 		if err != nil {
-			return nil, fmt.Errorf("invalid JSON document: %v", err)
+			if err != io.EOF {
+				log.Printf("Error reading input: %v", err)
+			}
+			return
 		}
-		req.Data = []map[string]any{doc}
-		return req, nil
-	}
 
-	q, err := query.Parse(jsonPayload)
-	if err != nil {
-		return nil, fmt.Errorf("invalid JSON query: %v", err)
+		for _, stmt := range s.feed(line) {
+			hist.add(stmt.text)
+			err := r.exec(context.Background(), stmt.text)
+			if errors.Is(err, errExit) {
+				return
+			}
+			// ответы сервера с ошибкой уже напечатаны
+			var serverErr *client.ServerError
+			if err != nil && !errors.As(err, &serverErr) && !errors.Is(err, errRejected) {
+				fmt.Printf("Error: %v\n", err)
+			}
+		}
 	}
-	req.Query = q.Conditions
-	return req, nil
 }
 
-func printResponse(resp api.Response) {
-	if resp.Status == api.StatusError {
-		fmt.Printf("ERROR: %s\n", resp.Message)
-		return
-	}
-
-	fmt.Printf("SUCCESS: %s (Count: %d)\n", resp.Message, resp.Count)
-
-	if len(resp.Data) > 0 {
-		output, err := json.MarshalIndent(resp.Data, "", "  ")
-		if err != nil {
-			fmt.Printf("Warning: Failed to format results: %v\n", err)
+// plainReader построчное чтение без редактирования (терминал без raw-режима)
+func plainReader(reader *bufio.Reader) func(string) (string, error) {
+	return func(prompt string) (string, error) {
+		fmt.Print(prompt)
+		line, err := reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", err
 		}
-		fmt.Println(string(output))
+		return strings.TrimRight(line, "\r\n"), nil
 	}
 }
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"nosql_db/client"
+	"nosql_db/client/clienttest"
+	"nosql_db/internal/api"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseStatement(t *testing.T) {
+	tests := []struct {
+		name     string
+		stmt     string
+		expected api.Request
+		wantErr  bool
+	}{
+		{"find with query", `find events {"msg": "a  b"}`,
+			api.Request{Command: api.CmdFind, Database: "events", Query: map[string]any{"msg": "a  b"}}, false},
+		{"find without query", "FIND events", api.Request{Command: api.CmdFind, Database: "events"}, false},
+		{"insert batch", `INSERT events [{"n": 1}, {"n": 2}]`,
+			api.Request{Command: api.CmdInsert, Database: "events", Data: []map[string]any{{"n": 1.0}, {"n": 2.0}}}, false},
+		{"distinct with filter", `DISTINCT events host {"n": 1}`,
+			api.Request{Command: api.CmdDistinct, Database: "events", Field: "host", Query: map[string]any{"n": 1.0}}, false},
+		{"case-insensitive index", `CREATE_INDEX events user {"collation": "case_insensitive"}`,
+			api.Request{Command: api.CmdCreateIndex, Database: "events", Field: "user",
+				Query: map[string]any{"user": map[string]any{"collation": "case_insensitive"}}}, false},
+		{"drop index", "DROP_INDEX events host", api.Request{Command: api.CmdDropIndex, Database: "events", Field: "host"}, false},
+		{"capped collection", `CREATE_CAPPED debug {"max_docs": 1000}`,
+			api.Request{Command: api.CmdCreateCapped, Database: "debug", Query: map[string]any{"max_docs": 1000.0}}, false},
+		{"capped requires limits", "CREATE_CAPPED debug", api.Request{}, true},
+		{"list collections", "LIST_COLLECTIONS", api.Request{Command: api.CmdListCollections}, false},
+		{"delete requires query", "DELETE events", api.Request{}, true},
+		{"missing field", `DISTINCT events {"n": 1}`, api.Request{}, true},
+		{"extra arguments", "LIST_INDEXES events host", api.Request{}, true},
+		{"unknown command", "UPDATE events {}", api.Request{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, req, err := parseStatement(tt.stmt)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %+v", req)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(req, tt.expected) {
+				t.Errorf("expected %+v, got %+v (%v)", tt.expected, req, err)
+			}
+		})
+	}
+}
+
+func TestSplitter(t *testing.T) {
+	script := strings.Join([]string{
+		"-- comment",
+		`INSERT events {"msg": "a;b", "tags": [`,
+		`  "x", "}"]}; COUNT events`,
+		"",
+		"# another comment",
+		`FIND events {"q": "\"{"}`,
+	}, "\n")
+
+	var s splitter
+	var got []statement
+	for _, line := range strings.Split(script, "\n") {
+		got = append(got, s.feed(line)...)
+	}
+	expected := []statement{
+		{text: "INSERT events {\"msg\": \"a;b\", \"tags\": [\n  \"x\", \"}\"]}", line: 2},
+		{text: "COUNT events", line: 3},
+		{text: `FIND events {"q": "\"{"}`, line: 6},
+	}
+	if !reflect.DeepEqual(got, expected) || s.pending() {
+		t.Errorf("expected %q, got %q (pending %v)", expected, got, s.pending())
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	srv := clienttest.NewServer()
+	defer srv.Close()
+	c := client.New(srv.Addr(), client.Options{PoolSize: 1})
+	defer c.Close()
+
+	var out, errOut bytes.Buffer
+	r := &runner{client: c, out: &out, format: formatJSONL}
+	script := `INSERT events [{"_id": "1", "host": "web"}, {"_id": "2", "host": "db"}]
+FIND events {"host": "web"}
+DISTINCT events host
+CREATE_INDEX events host
+CREATE_INDEX events host
+COUNT events`
+
+	ok, err := r.runScript(context.Background(), "test.nsql", strings.NewReader(script), &errOut, false)
+	if err != nil || ok {
+		t.Fatalf("expected failed script, got ok=%v err=%v", ok, err)
+	}
+	if !strings.HasPrefix(errOut.String(), "test.nsql:5: ") {
+		t.Errorf("expected error on line 5, got %q", errOut.String())
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 6 || lines[1] != `{"_id":"1","host":"web"}` || lines[2] != `"db"` || lines[3] != `"web"` {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+	if len(srv.Requests()) != 5 {
+		t.Errorf("script continued after error: %d requests", len(srv.Requests()))
+	}
+
+	out.Reset()
+	errOut.Reset()
+	r.format = formatTable
+	ok, _ = r.runScript(context.Background(), "-e", strings.NewReader("FIND events; EXIT; COUNT events"), &errOut, false)
+	if !ok || errOut.Len() > 0 {
+		t.Fatalf("expected success, got %q", errOut.String())
+	}
+	expected := "_id  host\n1    web\n2    db\n"
+	if got := out.String(); got != expected && got != "_id  host\n2    db\n1    web\n" {
+		t.Errorf("expected table\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestEditor(t *testing.T) {
+	srv := clienttest.NewServer()
+	defer srv.Close()
+	c := client.New(srv.Addr(), client.Options{PoolSize: 1})
+	defer c.Close()
+	if _, err := c.Insert(context.Background(), "events", map[string]any{"severity": "high"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CreateIndex(context.Background(), "events", "severity"); err != nil {
+		t.Fatal(err)
+	}
+
+	comp := newCompleter(c)
+	hist := &history{entries: []string{"COUNT events"}}
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"command", "fi\t\r", "FIND "},
+		{"collection", "FIND ev\t\r", "FIND events "},
+		{"index name", "DROP_INDEX events s\t\r", "DROP_INDEX events severity "},
+		{"quoted field", "FIND events {\"sev\t: 1}\r", `FIND events {"severity": 1}`},
+		{"history", "\x1b[A\r", "COUNT events"},
+		{"cursor movement", "FIND evnts\x1b[D\x1b[D\x1b[De\r", "FIND events"},
+		{"kill word", "FIND events x\x17\r", "FIND events "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := &editor{in: bufio.NewReader(strings.NewReader(tt.input)), out: io.Discard, history: hist, complete: comp.complete}
+			line, err := ed.readLine("> ")
+			if err != nil || line != tt.expected {
+				t.Errorf("expected %q, got %q (%v)", tt.expected, line, err)
+			}
+		})
+	}
+
+	ed := &editor{in: bufio.NewReader(strings.NewReader("\x04")), out: io.Discard, history: hist}
+	if _, err := ed.readLine("> "); err != io.EOF {
+		t.Errorf("expected EOF on Ctrl-D, got %v", err)
+	}
+}
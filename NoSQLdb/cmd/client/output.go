@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"nosql_db/internal/api"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// outputFormat формат вывода ответов
+type outputFormat string
+
+const (
+	formatPretty outputFormat = "pretty" // статус и JSON с отступами
+	formatJSONL  outputFormat = "jsonl"  // по документу или значению на строку
+	formatTable  outputFormat = "table"  // колонки по полям документов
+)
+
+func parseFormat(name string) (outputFormat, error) {
+	switch f := outputFormat(strings.ToLower(name)); f {
+	case formatPretty, formatJSONL, formatTable:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown output format %q (pretty, jsonl, table)", name)
+}
+
+// maxCellWidth ячейки таблицы длиннее обрезаются
+const maxCellWidth = 60
+
+func writeResponse(w io.Writer, format outputFormat, req api.Request, resp api.Response) error {
+	switch format {
+	case formatJSONL:
+		return writeJSONL(w, resp)
+	case formatTable:
+		return writeTable(w, req, resp)
+	default:
+		return writePretty(w, resp)
+	}
+}
+
+func writePretty(w io.Writer, resp api.Response) error {
+	switch resp.Status {
+	case api.StatusError:
+		_, err := fmt.Fprintf(w, "ERROR: %s\n", resp.Message)
+		return err
+	case api.StatusPartial:
+		fmt.Fprintf(w, "PARTIAL: %s (Count: %d)\n", resp.Message, resp.Count)
+	default:
+		fmt.Fprintf(w, "SUCCESS: %s (Count: %d)\n", resp.Message, resp.Count)
+	}
+
+	for _, part := range []any{resp.Errors, resp.Values, resp.Data} {
+		if isEmpty(part) {
+			continue
+		}
+		output, err := json.MarshalIndent(part, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format results: %w", err)
+		}
+		if _, err := fmt.Fprintln(w, string(output)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isEmpty(part any) bool {
+	switch v := part.(type) {
+	case []api.DocumentError:
+		return len(v) == 0
+	case []any:
+		return len(v) == 0
+	case []map[string]any:
+		return len(v) == 0
+	}
+	return true
+}
+
+// writeJSONL пишет документы или значения по одному на строку,
+// остальные ответы — одной строкой целиком
+func writeJSONL(w io.Writer, resp api.Response) error {
+	var items []any
+	switch {
+	case resp.Status == api.StatusSuccess && len(resp.Data) > 0:
+		for _, doc := range resp.Data {
+			items = append(items, doc)
+		}
+	case resp.Status == api.StatusSuccess && len(resp.Values) > 0:
+		items = resp.Values
+	default:
+		items = []any{resp}
+	}
+
+	enc := json.NewEncoder(w)
+	for _, item := range items {
+		if err := enc.Encode(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTable выводит документы колонками: _id первым, остальные поля по алфавиту
+func writeTable(w io.Writer, req api.Request, resp api.Response) error {
+	var header []string
+	var rows [][]string
+
+	switch {
+	case resp.Status == api.StatusSuccess && len(resp.Data) > 0:
+		header = documentColumns(resp.Data)
+		for _, doc := range resp.Data {
+			row := make([]string, len(header))
+			for i, col := range header {
+				if v, ok := doc[col]; ok {
+					row[i] = formatCell(v)
+				}
+			}
+			rows = append(rows, row)
+		}
+	case resp.Status == api.StatusSuccess && len(resp.Values) > 0:
+		column := req.Field
+		if column == "" {
+			column = "name"
+		}
+		header = []string{column}
+		for _, v := range resp.Values {
+			rows = append(rows, []string{formatCell(v)})
+		}
+	default:
+		header = []string{"status", "count", "message"}
+		rows = append(rows, []string{resp.Status, fmt.Sprint(resp.Count), resp.Message})
+		for _, docErr := range resp.Errors {
+			rows = append(rows, []string{"rejected", fmt.Sprintf("#%d", docErr.Index), strings.Join(docErr.Reasons, "; ")})
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+func documentColumns(docs []map[string]any) []string {
+	seen := make(map[string]bool)
+	var columns []string
+	for _, doc := range docs {
+		for field := range doc {
+			if !seen[field] && field != "_id" {
+				seen[field] = true
+				columns = append(columns, field)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return append([]string{"_id"}, columns...)
+}
+
+// formatCell строки выводятся как есть, остальное — компактным JSON
+func formatCell(v any) string {
+	var s string
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		s = val
+	default:
+		data, err := json.Marshal(val)
+		if err != nil {
+			s = fmt.Sprint(val)
+		} else {
+			s = string(data)
+		}
+	}
+
+	s = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(s)
+	if runes := []rune(s); len(runes) > maxCellWidth {
+		s = string(runes[:maxCellWidth-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"nosql_db/client"
+	"strings"
+	"time"
+)
+
+var (
+	// errExit команда EXIT/QUIT — остановить выполнение
+	errExit = errors.New("exit")
+	// errRejected сервер отклонил часть документов insert
+	errRejected = errors.New("rejected")
+)
+
+// splitter собирает команды из строк: команда заканчивается на ';' или
+// переводе строки вне JSON, поэтому документ можно разнести на несколько строк.
+// Строки, начинающиеся с "--" или "#", — комментарии
+type splitter struct {
+	buf      strings.Builder
+	depth    int // вложенность {} и []
+	inString bool
+	escaped  bool
+	line     int // номер текущей строки
+	start    int // строка, с которой началась незавершённая команда
+}
+
+// statement команда и номер строки, где она началась
+type statement struct {
+	text string
+	line int
+}
+
+// feed добавляет строку и возвращает завершённые ею команды
+func (s *splitter) feed(line string) []statement {
+	s.line++
+	if !s.pending() {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "--") || strings.HasPrefix(trimmed, "#") {
+			return nil
+		}
+	}
+
+	var out []statement
+	for _, r := range line {
+		if s.buf.Len() == 0 && s.depth == 0 && r != ';' {
+			s.start = s.line
+		}
+		if s.inString {
+			s.buf.WriteRune(r)
+			switch {
+			case s.escaped:
+				s.escaped = false
+			case r == '\\':
+				s.escaped = true
+			case r == '"':
+				s.inString = false
+			}
+			continue
+		}
+		switch r {
+		case '"':
+			s.inString = true
+		case '{', '[':
+			s.depth++
+		case '}', ']':
+			if s.depth > 0 {
+				s.depth--
+			}
+		case ';':
+			if s.depth == 0 {
+				out = s.flush(out)
+				continue
+			}
+		}
+		s.buf.WriteRune(r)
+	}
+
+	if s.depth == 0 && !s.inString {
+		return s.flush(out)
+	}
+	s.buf.WriteByte('\n')
+	return out
+}
+
+func (s *splitter) flush(out []statement) []statement {
+	text := strings.TrimSpace(s.buf.String())
+	s.buf.Reset()
+	if text != "" {
+		out = append(out, statement{text: text, line: s.start})
+	}
+	return out
+}
+
+// pending есть незавершённая многострочная команда
+func (s *splitter) pending() bool {
+	return s.depth > 0 || s.inString
+}
+
+// runner выполняет команды и печатает ответы
+type runner struct {
+	client  *client.Client
+	out     io.Writer
+	format  outputFormat
+	timeout time.Duration
+
+	// onWrite вызывается после команд, меняющих данные (сброс кэша автодополнения)
+	onWrite func()
+}
+
+// exec выполняет одну команду. Ошибка — команда не выполнена или сервер
+// отклонил её (в том числе частично)
+func (r *runner) exec(ctx context.Context, stmt string) error {
+	name, _ := nextToken(stmt)
+	switch strings.ToUpper(name) {
+	case cmdExit, cmdQuit:
+		return errExit
+	case cmdHelp:
+		fmt.Fprint(r.out, helpText())
+		return nil
+	}
+
+	spec, req, err := parseStatement(stmt)
+	if err != nil {
+		return err
+	}
+
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+	resp, err := r.client.Do(ctx, req)
+	if err != nil {
+		return err
+	}
+	if spec.write && r.onWrite != nil {
+		r.onWrite()
+	}
+
+	if err := writeResponse(r.out, r.format, req, resp); err != nil {
+		return err
+	}
+	switch resp.Status {
+	case client.StatusError:
+		return &client.ServerError{Message: resp.Message}
+	case client.StatusPartial:
+		return fmt.Errorf("%d document(s) %w", len(resp.Errors), errRejected)
+	}
+	return nil
+}
+
+// runScript выполняет команды из src. Ошибки пишутся в errOut с номером строки;
+// без keepGoing выполнение останавливается на первой ошибке.
+// Возвращает false, если хотя бы одна команда завершилась ошибкой
+func (r *runner) runScript(ctx context.Context, name string, src io.Reader, errOut io.Writer, keepGoing bool) (bool, error) {
+	reader := bufio.NewReader(src)
+	var s splitter
+	ok := true
+
+	for {
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return false, fmt.Errorf("read %s: %w", name, readErr)
+		}
+		if line != "" || readErr == nil {
+			for _, stmt := range s.feed(strings.TrimRight(line, "\r\n")) {
+				err := r.exec(ctx, stmt.text)
+				if errors.Is(err, errExit) {
+					return ok, nil
+				}
+				if err != nil {
+					ok = false
+					fmt.Fprintf(errOut, "%s:%d: %v\n", name, stmt.line, err)
+					if !keepGoing {
+						return false, nil
+					}
+				}
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	if s.pending() {
+		fmt.Fprintf(errOut, "%s:%d: unterminated JSON\n", name, s.start)
+		return false, nil
+	}
+	return ok, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"nosql_db/internal/storage"
+	"strings"
+)
+
+var (
+	collections = flag.String("collection", "", "Comma-separated collection names to convert")
+	all         = flag.Bool("all", false, "Convert every collection in ./data")
+	format      = flag.String("format", string(storage.FormatBinary), "Target storage format: json or binary")
+)
+
+// Конвертер файлов коллекций между форматами хранения.
+// Запускается из рабочей директории сервера (рядом с ./data) при остановленном сервере
+func main() {
+	flag.Parse()
+
+	target, err := storage.ParseFormat(*format)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var names []string
+	switch {
+	case *all:
+		if names, err = storage.ListCollections(); err != nil {
+			log.Fatalf("Failed to list collections: %v", err)
+		}
+	case *collections != "":
+		names = strings.Split(*collections, ",")
+	default:
+		log.Fatal("either -collection or -all is required")
+	}
+
+	failed := 0
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if err := storage.ConvertCollection(name, target); err != nil {
+			log.Printf("Failed to convert %s: %v", name, err)
+			failed++
+			continue
+		}
+		log.Printf("Converted %s to %s", name, target)
+	}
+
+	if failed > 0 {
+		log.Fatalf("%d collection(s) failed to convert", failed)
+	}
+}
@@ -1,25 +1,118 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
+	"log/slog"
+	"net/http"
+	"nosql_db/client"
 	"nosql_db/internal/config"
+	"nosql_db/internal/handlers"
+	"nosql_db/internal/logging"
+	"nosql_db/internal/metrics"
+	"nosql_db/internal/router"
 	"nosql_db/internal/server"
 	"nosql_db/internal/storage"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
-	log.Println("Starting NoSQLdb server...")
 	cfg := config.Load()
 
-	// Загрузка начальных данных
-	loadInitialData()
+	if err := logging.Setup(os.Stderr, cfg.LogLevel, cfg.LogFormat); err != nil {
+		log.Fatal(err)
+	}
+	slog.Info("starting NoSQLdb server")
+
+	format, err := storage.ParseFormat(cfg.StorageFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+	storage.GlobalManager.SetDefaultFormat(format)
+	handlers.SlowQueryThreshold = time.Duration(cfg.SlowQueryMs) * time.Millisecond
+	handlers.MaxResultDocs = cfg.MaxResultDocs
+	handlers.QueryTimeout = cfg.QueryTimeout
+
+	if cfg.MetricsAddr != "" {
+		go serveMetrics(cfg.MetricsAddr)
+	}
 
 	srv := server.New(cfg.Host + ":" + cfg.Port)
+	srv.IdleTimeout = cfg.IdleTimeout
+	srv.ReadTimeout = cfg.ReadTimeout
+	srv.WriteTimeout = cfg.WriteTimeout
+	srv.MaxConnection = cfg.MaxConnections
+	srv.MaxRequestBytes = cfg.MaxRequestBytes
+	srv.MaxResponseBytes = cfg.MaxResponseBytes
+
+	var rt *router.Router
+	if cfg.RouterConfig != "" {
+		// режим маршрутизатора: данные хранятся на шардах, локальное хранилище не используется
+		routerCfg, err := router.LoadConfig(cfg.RouterConfig)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if rt, err = router.New(routerCfg, client.Options{}); err != nil {
+			log.Fatal(err)
+		}
+		rt.QueryTimeout = cfg.QueryTimeout
+		rt.MaxResultDocs = cfg.MaxResultDocs
+		srv.Handler = rt.HandleRequest
+		srv.Watcher = rt.Watch
+		slog.Info("router mode", "shards", routerCfg.Shards, "sharded_collections", len(routerCfg.Collections))
+	} else {
+		// Загрузка начальных данных
+		loadInitialData()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	if err := srv.Run(); err != nil {
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.Run() }()
+
+	select {
+	case err := <-serveErr:
 		log.Fatal(err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	slog.Info("shutting down", "timeout", cfg.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	// сначала дожидаемся ответов на принятые запросы, затем очереди записи
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("connections not drained", "error", err)
+	}
+	if rt != nil {
+		if err := rt.Close(); err != nil {
+			slog.Error("router shutdown failed", "error", err)
+		}
+		slog.Info("router stopped")
+		return
+	}
+	if err := storage.GlobalManager.Shutdown(shutdownCtx); err != nil {
+		slog.Error("storage shutdown failed", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("server stopped")
+}
+
+// serveMetrics отдаёт метрики в формате Prometheus на /metrics
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Default.Handler())
+
+	slog.Info("metrics endpoint running", "address", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		slog.Error("metrics endpoint stopped", "error", err)
 	}
 }
 
@@ -28,52 +121,55 @@ func loadInitialData() {
 
 	// Проверяем существование файла
 	if _, err := os.Stat(dataFile); os.IsNotExist(err) {
-		log.Printf("Initial data file not found: %s, skipping data load", dataFile)
+		slog.Info("initial data file not found, skipping data load", "file", dataFile)
 		return
 	}
 
 	data, err := os.ReadFile(dataFile)
 	if err != nil {
-		log.Printf("Failed to read initial data file: %v", err)
+		slog.Error("failed to read initial data file", "error", err)
 		return
 	}
 
 	var events map[string]map[string]interface{}
 	if err := json.Unmarshal(data, &events); err != nil {
-		log.Printf("Failed to parse initial data: %v", err)
+		slog.Error("failed to parse initial data", "error", err)
 		return
 	}
 
 	if len(events) == 0 {
-		log.Println("No events to load")
+		slog.Info("no initial events to load")
 		return
 	}
 
 	// Загружаем события в коллекцию siem_events
 	coll, err := storage.GlobalManager.GetCollection("siem_events")
 	if err != nil {
-		log.Printf("Failed to get collection: %v", err)
+		slog.Error("failed to get collection", "error", err)
 		return
 	}
 
 	count := 0
 	for _, event := range events {
 		if _, err := coll.Insert(event); err != nil {
-			log.Printf("Failed to insert event: %v", err)
+			// события начальных данных имеют _id и после перезапуска уже лежат в коллекции
+			if !errors.Is(err, storage.ErrAlreadyStored) {
+				slog.Warn("failed to insert initial event", "error", err)
+			}
 			continue
 		}
 		count++
 	}
 
 	if err := coll.Save(); err != nil {
-		log.Printf("Failed to save collection: %v", err)
+		slog.Error("failed to save collection", "error", err)
 		return
 	}
 
 	if err := coll.SaveAllIndexes(); err != nil {
-		log.Printf("Failed to save indexes: %v", err)
+		slog.Error("failed to save indexes", "error", err)
 		return
 	}
 
-	log.Printf("Successfully loaded %d initial events into siem_events collection", count)
+	slog.Info("loaded initial events", "collection", "siem_events", "count", count)
 }
@@ -5,18 +5,39 @@ type Request struct {
 	Command  string           `json:"operation"`       // операция
 	Data     []map[string]any `json:"data,omitempty"`  // данные
 	Query    map[string]any   `json:"query,omitempty"` // условия поиска
+	Field    string           `json:"field,omitempty"` // поле для distinct
+
+	ResumeAfter string `json:"resume_after,omitempty"` // токен, после которого продолжить watch
 }
 
 type Response struct {
 	Status  string           `json:"status"`            // success или error
 	Message string           `json:"message,omitempty"` // сообщение, если есть ошибка
 	Data    []map[string]any `json:"data,omitempty"`    // результат запроса
+	Values  []any            `json:"values,omitempty"`  // уникальные значения поля (distinct)
 	Count   int              `json:"count,omitempty"`   // количество документов
+	IDs     []string         `json:"ids,omitempty"`     // _id документов insert в порядке Data ("" для отклонённых)
+	Errors  []DocumentError  `json:"errors,omitempty"`  // ошибки по отдельным документам
+	Change  *Change          `json:"change,omitempty"`  // событие потока watch
+	Token   string           `json:"token,omitempty"`   // позиция в потоке watch для resume_after
+}
+
+// Change описание изменения документа в потоке watch; сам документ — в Data
+type Change struct {
+	Operation string `json:"operation"` // insert или delete
+	ID        string `json:"id"`        // _id документа
+}
+
+// DocumentError причина отказа для конкретного документа запроса
+type DocumentError struct {
+	Index   int      `json:"index"`   // позиция документа в Data
+	Reasons []string `json:"reasons"` // причины отказа
 }
 
 const (
 	StatusSuccess = "success"
 	StatusError   = "error"
+	StatusPartial = "partial" // часть документов запроса отклонена, подробности в Errors
 )
 
 const (
@@ -24,4 +45,16 @@ const (
 	CmdFind        = "find"
 	CmdDelete      = "delete"
 	CmdCreateIndex = "create_index"
+	CmdSetSchema   = "set_schema"
+	CmdGetSchema   = "get_schema"
+	CmdWatch       = "watch"
+	CmdCount       = "count"
+	CmdDistinct    = "distinct"
+
+	CmdListCollections = "list_collections" // имена коллекций, Database не нужен
+	CmdListIndexes     = "list_indexes"
+	CmdDropIndex       = "drop_index"
+	CmdRebuildIndexes  = "rebuild_indexes"
+	CmdListFields      = "list_fields"   // имена полей верхнего уровня
+	CmdCreateCapped    = "create_capped" // сделать коллекцию capped: пределы в Query
 )
@@ -0,0 +1,50 @@
+// Package collation правила сравнения строк: бинарное (как есть) и
+// без учёта регистра. Одна и та же свёртка регистра используется
+// операторами $ieq/$ilike, ключами case-insensitive индексов и нормализацией при вставке
+package collation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Collation правило сравнения строковых значений индекса
+type Collation byte
+
+const (
+	// Binary строки сравниваются побайтно (по умолчанию)
+	Binary Collation = 0
+	// CaseInsensitive строки сравниваются после свёртки регистра
+	CaseInsensitive Collation = 1
+)
+
+// Parse разбирает имя правила; пустая строка — Binary
+func Parse(name string) (Collation, error) {
+	switch strings.ToLower(name) {
+	case "", "binary":
+		return Binary, nil
+	case "case_insensitive", "ci":
+		return CaseInsensitive, nil
+	}
+	return Binary, fmt.Errorf("unknown collation %q: expected binary or case_insensitive", name)
+}
+
+func (c Collation) String() string {
+	if c == CaseInsensitive {
+		return "case_insensitive"
+	}
+	return "binary"
+}
+
+// Fold свёртка регистра: "Root" и "ROOT" дают "root"
+func Fold(s string) string {
+	return strings.ToLower(s)
+}
+
+// Equal сравнивает строки по правилу
+func (c Collation) Equal(a, b string) bool {
+	if c == CaseInsensitive {
+		return Fold(a) == Fold(b)
+	}
+	return a == b
+}
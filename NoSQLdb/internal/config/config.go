@@ -2,6 +2,7 @@ package config
 
 import (
 	"log"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
@@ -9,6 +10,26 @@ import (
 type Config struct {
 	Host string `env:"DB_HOST" env-default:""`
 	Port string `env:"DB_PORT" env-default:"5140"`
+
+	IdleTimeout     time.Duration `env:"DB_IDLE_TIMEOUT" env-default:"60s"`     // ожидание следующего запроса клиента
+	ReadTimeout     time.Duration `env:"DB_READ_TIMEOUT" env-default:"30s"`     // чтение начатого запроса
+	WriteTimeout    time.Duration `env:"DB_WRITE_TIMEOUT" env-default:"30s"`    // запись ответа
+	MaxConnections  int           `env:"DB_MAX_CONNECTIONS" env-default:"100"`  // соединения сверх лимита получают ошибку
+	ShutdownTimeout time.Duration `env:"DB_SHUTDOWN_TIMEOUT" env-default:"30s"` // ожидание завершения запросов при остановке
+
+	MaxRequestBytes  int64         `env:"DB_MAX_REQUEST_BYTES" env-default:"33554432"`  // размер запроса, 0 — без ограничения
+	MaxResponseBytes int           `env:"DB_MAX_RESPONSE_BYTES" env-default:"67108864"` // размер ответа, 0 — без ограничения
+	MaxResultDocs    int           `env:"DB_MAX_RESULT_DOCS" env-default:"0"`           // документов в результате find, 0 — без ограничения
+	QueryTimeout     time.Duration `env:"DB_QUERY_TIMEOUT" env-default:"30s"`           // время выполнения запроса, 0 — без ограничения
+
+	StorageFormat string `env:"DB_STORAGE_FORMAT" env-default:"json"` // формат новых коллекций: json или binary
+
+	RouterConfig string `env:"DB_ROUTER_CONFIG" env-default:""` // конфигурация шардов; задана — процесс работает маршрутизатором без локального хранилища
+
+	MetricsAddr string `env:"DB_METRICS_ADDR" env-default:":9140"` // адрес HTTP-эндпоинта /metrics, пустой — отключён
+	SlowQueryMs int    `env:"DB_SLOW_QUERY_MS" env-default:"500"`  // порог лога медленных запросов, 0 — отключён
+	LogLevel    string `env:"DB_LOG_LEVEL" env-default:"info"`     // debug, info, warn, error
+	LogFormat   string `env:"DB_LOG_FORMAT" env-default:"text"`    // text или json
 }
 
 func Load() *Config {
@@ -0,0 +1,221 @@
+// Package datetime распознаёт даты в документах и запросах: строки RFC3339,
+// обёртку {"$date": ...} и относительные выражения {"$now": "-1h"}.
+// Даты сравниваются и индексируются как моменты времени в UTC
+package datetime
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DateKey обёртка даты: {"$date": "2024-01-02T15:04:05Z"} или {"$date": <мс с эпохи>}
+	DateKey = "$date"
+	// NowKey относительное время запроса: {"$now": "-1h"}, {"$now": "+7d"}, {"$now": ""}
+	NowKey = "$now"
+)
+
+// Parse распознаёт дату: time.Time, строку RFC3339 или обёртку $date.
+// Результат в UTC
+func Parse(v any) (time.Time, bool) {
+	switch val := v.(type) {
+	case time.Time:
+		return val.UTC(), true
+	case string:
+		return parseString(val)
+	case map[string]any:
+		if len(val) != 1 {
+			return time.Time{}, false
+		}
+		raw, ok := val[DateKey]
+		if !ok {
+			return time.Time{}, false
+		}
+		t, err := parseDate(raw)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+// parseString разбирает RFC3339 с дробными секундами и без; дешёвая проверка
+// формы отсекает обычные строки до вызова time.Parse
+func parseString(s string) (time.Time, bool) {
+	if len(s) < len("2006-01-02T15:04:05Z") || len(s) > len(time.RFC3339Nano)+5 ||
+		s[4] != '-' || s[7] != '-' || (s[10] != 'T' && s[10] != 't') {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t.UTC(), true
+}
+
+// parseDate значение обёртки $date: строка RFC3339 или миллисекунды с эпохи
+func parseDate(raw any) (time.Time, error) {
+	switch v := raw.(type) {
+	case string:
+		if t, ok := parseString(v); ok {
+			return t, nil
+		}
+		return time.Time{}, fmt.Errorf("invalid %s value %q: expected RFC3339", DateKey, v)
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return time.Time{}, fmt.Errorf("invalid %s value %v", DateKey, v)
+		}
+		return time.UnixMilli(int64(v)).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid %s value of type %T", DateKey, raw)
+}
+
+// Format каноническая запись даты при сохранении: RFC3339 в UTC
+func Format(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// Compare сравнивает два значения как даты; false — хотя бы одно не дата
+func Compare(a, b any) (int, bool) {
+	ta, ok := Parse(a)
+	if !ok {
+		return 0, false
+	}
+	tb, ok := Parse(b)
+	if !ok {
+		return 0, false
+	}
+	return ta.Compare(tb), true
+}
+
+// ParseOffset разбирает смещение $now: длительность Go ("-90m", "1h30m")
+// или целое число дней/недель ("-7d", "2w"); пустая строка — ноль
+func ParseOffset(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	unit := s[len(s)-1]
+	if unit == 'd' || unit == 'w' {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s offset %q", NowKey, s)
+		}
+		day := 24 * time.Hour
+		if unit == 'w' {
+			day *= 7
+		}
+		return time.Duration(n) * day, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s offset %q", NowKey, s)
+	}
+	return d, nil
+}
+
+// ResolveQuery заменяет в условиях запроса {"$now": ...} и {"$date": ...}
+// на time.Time. now фиксируется один раз на запрос, чтобы все документы
+// сравнивались с одним моментом
+func ResolveQuery(query map[string]any, now time.Time) (map[string]any, error) {
+	if len(query) == 0 {
+		return query, nil
+	}
+	resolved, err := resolve(query, now)
+	if err != nil {
+		return nil, err
+	}
+	return resolved.(map[string]any), nil
+}
+
+func resolve(v any, now time.Time) (any, error) {
+	switch val := v.(type) {
+	case map[string]any:
+		if len(val) == 1 {
+			if raw, ok := val[NowKey]; ok {
+				offset, isString := raw.(string)
+				if !isString {
+					return nil, fmt.Errorf("%s expects an offset string like \"-1h\", got %T", NowKey, raw)
+				}
+				d, err := ParseOffset(offset)
+				if err != nil {
+					return nil, err
+				}
+				return now.Add(d).UTC(), nil
+			}
+			if raw, ok := val[DateKey]; ok {
+				return parseDate(raw)
+			}
+		}
+		out := make(map[string]any, len(val))
+		for k, item := range val {
+			r, err := resolve(item, now)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = r
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			r, err := resolve(item, now)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = r
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// ErrNowInDocument $now допустим только в запросах
+var ErrNowInDocument = errors.New(NowKey + " is only allowed in queries")
+
+// NormalizeDocument заменяет обёртки {"$date": ...} в документе (в том числе
+// во вложенных объектах и массивах) строками RFC3339 в UTC
+func NormalizeDocument(doc map[string]any) error {
+	for k, v := range doc {
+		normalized, err := normalize(v)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", k, err)
+		}
+		doc[k] = normalized
+	}
+	return nil
+}
+
+func normalize(v any) (any, error) {
+	switch val := v.(type) {
+	case map[string]any:
+		if len(val) == 1 {
+			if raw, ok := val[DateKey]; ok {
+				t, err := parseDate(raw)
+				if err != nil {
+					return nil, err
+				}
+				return Format(t), nil
+			}
+			if _, ok := val[NowKey]; ok {
+				return nil, ErrNowInDocument
+			}
+		}
+		if err := NormalizeDocument(val); err != nil {
+			return nil, err
+		}
+		return val, nil
+	case []any:
+		for i, item := range val {
+			normalized, err := normalize(item)
+			if err != nil {
+				return nil, err
+			}
+			val[i] = normalized
+		}
+		return val, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,116 @@
+package datetime
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	utc := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		value    any
+		expected time.Time
+		ok       bool
+	}{
+		{"utc string", "2024-01-02T12:00:00Z", utc, true},
+		{"offset normalized to utc", "2024-01-02T15:00:00+03:00", utc, true},
+		{"fractional seconds", "2024-01-02T12:00:00.5Z", utc.Add(500 * time.Millisecond), true},
+		{"date wrapper", map[string]any{"$date": "2024-01-02T12:00:00Z"}, utc, true},
+		{"epoch millis wrapper", map[string]any{"$date": float64(utc.UnixMilli())}, utc, true},
+		{"time value", utc.In(time.FixedZone("X", 3600)), utc, true},
+		{"date without time", "2024-01-02", time.Time{}, false},
+		{"plain string", "hello world, this is long", time.Time{}, false},
+		{"number", 1704196800.0, time.Time{}, false},
+		{"wrapper with extra keys", map[string]any{"$date": "2024-01-02T12:00:00Z", "x": 1}, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Parse(tt.value)
+			if ok != tt.ok || !got.Equal(tt.expected) || (ok && got.Location() != time.UTC) {
+				t.Errorf("Parse(%v) = %v, %v; expected %v, %v", tt.value, got, ok, tt.expected, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseOffset(t *testing.T) {
+	tests := []struct {
+		offset   string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{"", 0, false},
+		{"-1h", -time.Hour, false},
+		{"+90m", 90 * time.Minute, false},
+		{"-7d", -7 * 24 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"-1.5d", 0, true},
+		{"yesterday", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseOffset(tt.offset)
+		if (err != nil) != tt.wantErr || got != tt.expected {
+			t.Errorf("ParseOffset(%q) = %v, %v; expected %v", tt.offset, got, err, tt.expected)
+		}
+	}
+}
+
+func TestResolveQuery(t *testing.T) {
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	query := map[string]any{
+		"timestamp": map[string]any{"$gt": map[string]any{"$now": "-1h"}, "$lte": map[string]any{"$date": "2024-01-02T15:00:00+03:00"}},
+		"$or":       []any{map[string]any{"seen": map[string]any{"$now": ""}}},
+		"severity":  "high",
+	}
+	expected := map[string]any{
+		"timestamp": map[string]any{"$gt": now.Add(-time.Hour), "$lte": now},
+		"$or":       []any{map[string]any{"seen": now}},
+		"severity":  "high",
+	}
+
+	got, err := ResolveQuery(query, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+	if _, ok := query["timestamp"].(map[string]any)["$gt"].(map[string]any); !ok {
+		t.Error("original query was modified")
+	}
+
+	for _, bad := range []map[string]any{
+		{"t": map[string]any{"$gt": map[string]any{"$now": "soon"}}},
+		{"t": map[string]any{"$gt": map[string]any{"$now": 5.0}}},
+		{"t": map[string]any{"$date": "2024-01-02"}},
+	} {
+		if _, err := ResolveQuery(bad, now); err == nil {
+			t.Errorf("expected error for %v", bad)
+		}
+	}
+}
+
+func TestNormalizeDocument(t *testing.T) {
+	doc := map[string]any{
+		"timestamp": map[string]any{"$date": "2024-01-02T15:00:00+03:00"},
+		"nested":    map[string]any{"seen": []any{map[string]any{"$date": 0.0}}},
+		"raw":       "2024-01-02T15:00:00+03:00",
+	}
+	if err := NormalizeDocument(doc); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{
+		"timestamp": "2024-01-02T12:00:00Z",
+		"nested":    map[string]any{"seen": []any{"1970-01-01T00:00:00Z"}},
+		"raw":       "2024-01-02T15:00:00+03:00",
+	}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Errorf("expected %v, got %v", expected, doc)
+	}
+
+	if err := NormalizeDocument(map[string]any{"t": map[string]any{"$now": "-1h"}}); err == nil {
+		t.Error("expected error for $now in document")
+	}
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"nosql_db/internal/api"
+	"nosql_db/internal/collation"
+	"nosql_db/internal/index"
+	"nosql_db/internal/operators"
+	"nosql_db/internal/storage"
+	"sort"
+)
+
+// handleCount считает документы, подходящие под запрос. Пустой запрос
+// отвечается размером коллекции, условие на индексированное поле — числом ключей индекса
+func handleCount(ctx context.Context, coll *storage.Collection, req api.Request) (api.Response, string) {
+	if len(req.Query) == 0 {
+		return countResponse(coll.Len()), ""
+	}
+
+	if scan, ok := planIndexScan(coll, req.Query); ok {
+		count, err := scan.count(coll)
+		if !fallBackToFullScan(err) {
+			return countResponse(count), planIndex
+		}
+	}
+
+	count := 0
+	for i, doc := range coll.All() {
+		if i%scanCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return api.Response{Status: api.StatusError, Message: limitError(err)}, planFullScan
+			}
+		}
+		if operators.MatchDocument(doc, req.Query) {
+			count++
+		}
+	}
+	return countResponse(count), planFullScan
+}
+
+func countResponse(count int) api.Response {
+	return api.Response{
+		Status:  api.StatusSuccess,
+		Message: fmt.Sprintf("%d document(s)", count),
+		Count:   count,
+	}
+}
+
+// handleDistinct возвращает уникальные значения поля среди документов,
+// подходящих под запрос. При индексе на поле и фильтре только по этому полю
+// значения берутся из листьев индекса без обхода документов
+func handleDistinct(ctx context.Context, coll *storage.Collection, req api.Request) (api.Response, string) {
+	if req.Field == "" {
+		return api.Response{Status: api.StatusError, Message: "field is required for distinct"}, ""
+	}
+
+	var values []any
+	var err error
+	plan := planFullScan
+
+	if scan, ok := planDistinctScan(coll, req.Field, req.Query); ok {
+		values, err = distinctWithIndex(coll, scan)
+		plan = planIndex
+	}
+	if plan == planFullScan || fallBackToFullScan(err) {
+		values, err = distinctFullScan(ctx, coll, req.Field, req.Query)
+		plan = planFullScan
+	}
+	if err != nil {
+		return api.Response{Status: api.StatusError, Message: limitError(err)}, plan
+	}
+
+	return api.Response{
+		Status: api.StatusSuccess,
+		Values: values,
+		Count:  len(values),
+	}, plan
+}
+
+// planDistinctScan использует индекс поля, если фильтр пустой или задан по этому же
+// полю и не требует перепроверки документов
+func planDistinctScan(coll *storage.Collection, field string, query map[string]any) (indexScan, bool) {
+	if len(query) == 0 {
+		btree, ok := coll.GetIndex(field)
+		return indexScan{field: field, btree: btree, ranges: []keyRange{{}}, exact: true}, ok
+	}
+	scan, ok := planIndexScan(coll, query)
+	return scan, ok && scan.field == field && scan.exact
+}
+
+// distinctWithIndex проходит уникальные ключи индекса; исходное значение
+// (с типом) берётся из первого документа каждого ключа
+func distinctWithIndex(coll *storage.Collection, scan indexScan) ([]any, error) {
+	var values []any
+	for _, r := range scan.ranges {
+		counts, err := scan.btree.KeyCounts(r.start, r.end, r.includeStart, r.includeEnd)
+		if err != nil {
+			return nil, &indexScanError{field: scan.field, err: err}
+		}
+		for _, kc := range counts {
+			doc, ok := coll.GetByID(string(kc.First))
+			if !ok && kc.Count > 1 {
+				doc, ok, err = firstDocument(coll, scan, kc.Key)
+				if err != nil {
+					return nil, err
+				}
+			}
+			if !ok {
+				continue
+			}
+			values = append(values, doc[scan.field])
+			if err := checkResultSize(len(values)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return values, nil
+}
+
+// firstDocument первый существующий документ с ключом key; первая запись
+// ключа в индексе может ссылаться на уже удалённый документ
+func firstDocument(coll *storage.Collection, scan indexScan, key index.Key) (map[string]any, bool, error) {
+	ids, err := scan.btree.Search(key)
+	if err != nil {
+		return nil, false, &indexScanError{field: scan.field, err: err}
+	}
+	for _, id := range ids {
+		if doc, ok := coll.GetByID(string(id)); ok {
+			return doc, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// distinctFullScan собирает значения поля по всем подходящим документам
+// в том же порядке, что и индекс (по ключу индекса). Значения с одним ключом
+// (даты в разных часовых поясах, строки case-insensitive индекса) схлопываются
+// в значение документа с наименьшим _id — как при обходе индекса
+func distinctFullScan(ctx context.Context, coll *storage.Collection, field string, query map[string]any) ([]any, error) {
+	type entry struct {
+		key   index.Key
+		id    string
+		value any
+	}
+	rule := collation.Binary
+	if btree, ok := coll.GetIndex(field); ok {
+		rule = btree.Collation()
+	}
+	seen := make(map[string]int)
+	var entries []entry
+
+	for i, doc := range coll.All() {
+		if i%scanCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		value, exists := doc[field]
+		if !exists || !operators.MatchDocument(doc, query) {
+			continue
+		}
+		key, _ := index.CollatedKey(value, rule)
+		id, _ := doc["_id"].(string)
+		if pos, ok := seen[string(key)]; ok {
+			if id < entries[pos].id {
+				entries[pos].id, entries[pos].value = id, value
+			}
+			continue
+		}
+		seen[string(key)] = len(entries)
+		entries = append(entries, entry{key: key, id: id, value: value})
+		if err := checkResultSize(len(entries)); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+	values := make([]any, len(entries))
+	for i, e := range entries {
+		values[i] = e.value
+	}
+	return values, nil
+}
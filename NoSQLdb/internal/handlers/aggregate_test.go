@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"nosql_db/internal/api"
+	"nosql_db/internal/storage"
+	"reflect"
+	"testing"
+)
+
+func TestCountAndDistinct(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	// host-0..host-9 по 10 документов, n от 0 до 99
+	coll := newTestCollection(t, 100)
+	if err := coll.CreateIndex("host", 8); err != nil {
+		t.Fatal(err)
+	}
+	defer coll.CloseIndexes()
+
+	ctx := context.Background()
+
+	countTests := []struct {
+		name     string
+		query    map[string]any
+		expected int
+		plan     string
+	}{
+		{"empty query", nil, 100, ""},
+		{"indexed equality", map[string]any{"host": "host-3"}, 10, planIndex},
+		{"indexed $in", map[string]any{"host": map[string]any{"$in": []any{"host-1", "host-2", "host-1"}}}, 20, planIndex},
+		{"string range", map[string]any{"host": map[string]any{"$gte": "host-2", "$lt": "host-5"}}, 0, planFullScan},
+		{"full scan", map[string]any{"n": map[string]any{"$lt": 25.0}}, 25, planFullScan},
+		{"no matches", map[string]any{"host": "missing"}, 0, planIndex},
+	}
+	for _, tt := range countTests {
+		t.Run("count "+tt.name, func(t *testing.T) {
+			resp, plan := handleCount(ctx, coll, api.Request{Query: tt.query})
+			if resp.Status != api.StatusSuccess || resp.Count != tt.expected || plan != tt.plan {
+				t.Errorf("expected %d via %q, got %d via %q (%s)", tt.expected, tt.plan, resp.Count, plan, resp.Message)
+			}
+		})
+	}
+
+	distinctTests := []struct {
+		name     string
+		field    string
+		query    map[string]any
+		expected []any
+		plan     string
+	}{
+		{"from index", "host", nil, []any{"host-0", "host-1", "host-2", "host-3", "host-4", "host-5", "host-6", "host-7", "host-8", "host-9"}, planIndex},
+		{"index with filter on same field", "host", map[string]any{"host": map[string]any{"$in": []any{"host-9", "host-8"}}}, []any{"host-8", "host-9"}, planIndex},
+		{"filter on other field", "host", map[string]any{"n": map[string]any{"$lt": 3.0}}, []any{"host-0", "host-1", "host-2"}, planFullScan},
+		{"unindexed field", "n", map[string]any{"host": "host-4", "n": map[string]any{"$lt": 30.0}}, []any{4.0, 14.0, 24.0}, planFullScan},
+	}
+	for _, tt := range distinctTests {
+		t.Run("distinct "+tt.name, func(t *testing.T) {
+			resp, plan := handleDistinct(ctx, coll, api.Request{Field: tt.field, Query: tt.query})
+			if resp.Status != api.StatusSuccess || plan != tt.plan {
+				t.Fatalf("expected success via %q, got %s via %q (%s)", tt.plan, resp.Status, plan, resp.Message)
+			}
+			if !reflect.DeepEqual(resp.Values, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, resp.Values)
+			}
+		})
+	}
+
+	if resp, _ := handleDistinct(ctx, coll, api.Request{}); resp.Status != api.StatusError {
+		t.Errorf("expected error without field, got %+v", resp)
+	}
+}
+
+func TestClosedIndexFallsBackToFullScan(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	coll := newTestCollection(t, 100)
+	if err := coll.CreateIndex("host", 8); err != nil {
+		t.Fatal(err)
+	}
+	defer coll.CloseIndexes()
+
+	// drop_index закрыл дерево, пока запрос уже выбрал план по индексу
+	btree, _ := coll.GetIndex("host")
+	if err := btree.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	query := map[string]any{"host": "host-3"}
+
+	if resp, plan := handleFind(ctx, coll, api.Request{Query: query}); resp.Status != api.StatusSuccess || resp.Count != 10 || plan != planFullScan {
+		t.Errorf("find: expected 10 via full scan, got %d via %q (%s)", resp.Count, plan, resp.Message)
+	}
+	if resp, plan := handleCount(ctx, coll, api.Request{Query: query}); resp.Status != api.StatusSuccess || resp.Count != 10 || plan != planFullScan {
+		t.Errorf("count: expected 10 via full scan, got %d via %q (%s)", resp.Count, plan, resp.Message)
+	}
+	if resp, plan := handleDistinct(ctx, coll, api.Request{Field: "host"}); resp.Status != api.StatusSuccess || resp.Count != 10 || plan != planFullScan {
+		t.Errorf("distinct: expected 10 via full scan, got %d via %q (%s)", resp.Count, plan, resp.Message)
+	}
+}
+
+func TestDistinctSkipsMissingFirstDocument(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	coll := storage.NewCollection("test")
+	for _, doc := range []map[string]any{
+		{"_id": "a", "host": "web-01"},
+		{"_id": "b", "host": "web-01"},
+		{"_id": "c", "host": "web-02"},
+	} {
+		if _, err := coll.Insert(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := coll.CreateIndex("host", 8); err != nil {
+		t.Fatal(err)
+	}
+	defer coll.CloseIndexes()
+
+	// запись индекса осталась, а документа уже нет
+	coll.Data.Remove("a")
+
+	resp, plan := handleDistinct(context.Background(), coll, api.Request{Field: "host"})
+	if expected := []any{"web-01", "web-02"}; !reflect.DeepEqual(resp.Values, expected) || plan != planIndex {
+		t.Errorf("expected %v via index, got %v via %q", expected, resp.Values, plan)
+	}
+}
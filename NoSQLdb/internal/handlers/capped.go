@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"fmt"
+	"nosql_db/internal/api"
+	"nosql_db/internal/storage"
+)
+
+// handleCreateCapped делает коллекцию capped с пределами из req.Query
+// или меняет пределы уже capped-коллекции
+func handleCreateCapped(req api.Request) api.Response {
+	options, err := storage.ParseCappedOptions(req.Query)
+	if err != nil {
+		return api.Response{Status: api.StatusError, Message: fmt.Sprintf("invalid capped options: %v", err)}
+	}
+
+	// Используем очередь: вытеснение при смене пределов — тоже запись
+	result := storage.GlobalManager.Enqueue(req.Database, func(coll *storage.Collection) (storage.WriteResult, error) {
+		if err := coll.SetCapped(options); err != nil {
+			return storage.WriteResult{}, fmt.Errorf("failed to create capped collection: %w", err)
+		}
+		if err := coll.Save(); err != nil {
+			return storage.WriteResult{}, fmt.Errorf("failed to save data: %w", err)
+		}
+		if err := coll.SaveAllIndexes(); err != nil {
+			return storage.WriteResult{}, fmt.Errorf("failed to save indexes: %w", err)
+		}
+		return storage.WriteResult{Message: fmt.Sprintf("Capped collection '%s' (%s)", req.Database, cappedLimits(options))}, nil
+	})
+
+	if result.Error != nil {
+		return api.Response{Status: api.StatusError, Message: result.Error.Error()}
+	}
+	return api.Response{Status: api.StatusSuccess, Message: result.Message}
+}
+
+// cappedLimits описание пределов для сообщений
+func cappedLimits(options storage.CappedOptions) string {
+	switch {
+	case options.MaxDocs > 0 && options.MaxBytes > 0:
+		return fmt.Sprintf("max_docs=%d, max_bytes=%d", options.MaxDocs, options.MaxBytes)
+	case options.MaxDocs > 0:
+		return fmt.Sprintf("max_docs=%d", options.MaxDocs)
+	default:
+		return fmt.Sprintf("max_bytes=%d", options.MaxBytes)
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"nosql_db/internal/api"
+	"nosql_db/internal/storage"
+	"sort"
+)
+
+func handleListCollections() api.Response {
+	names, err := storage.GlobalManager.CollectionNames()
+	if err != nil {
+		return api.Response{Status: api.StatusError, Message: err.Error()}
+	}
+	return namesResponse(names)
+}
+
+// handleListFields собирает имена полей верхнего уровня по всем документам
+func handleListFields(ctx context.Context, coll *storage.Collection) api.Response {
+	seen := make(map[string]bool)
+	for i, doc := range coll.All() {
+		if i%scanCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return api.Response{Status: api.StatusError, Message: limitError(err)}
+			}
+		}
+		for field := range doc {
+			seen[field] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return namesResponse(names)
+}
+
+// namesResponse отдаёт список имён в поле values
+func namesResponse(names []string) api.Response {
+	values := make([]any, len(names))
+	for i, name := range names {
+		values[i] = name
+	}
+	return api.Response{
+		Status:  api.StatusSuccess,
+		Message: fmt.Sprintf("%d name(s)", len(names)),
+		Values:  values,
+		Count:   len(values),
+	}
+}
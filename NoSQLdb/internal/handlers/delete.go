@@ -28,8 +28,9 @@ func handleDelete(req api.Request) api.Response {
 			if err := coll.Save(); err != nil {
 				return storage.WriteResult{}, fmt.Errorf("failed to save changes: %w", err)
 			}
-			if err := coll.RebuildAllIndexes(); err != nil {
-				return storage.WriteResult{}, fmt.Errorf("failed to rebuild indexes: %w", err)
+			// Delete уже убрал записи из индексов, остаётся сбросить изменённые страницы
+			if err := coll.SaveAllIndexes(); err != nil {
+				return storage.WriteResult{}, fmt.Errorf("failed to save indexes: %w", err)
 			}
 		}
 
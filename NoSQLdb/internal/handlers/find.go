@@ -1,35 +1,58 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"nosql_db/internal/api"
+	"nosql_db/internal/collation"
 	"nosql_db/internal/index"
 	"nosql_db/internal/operators"
 	"nosql_db/internal/storage"
+	"sort"
+	"time"
 )
 
-func handleFind(coll *storage.Collection, req api.Request) api.Response {
+// keyRange диапазон ключей индекса; nil-граница — без ограничения
+type keyRange struct {
+	start, end               index.Key
+	includeStart, includeEnd bool
+}
+
+// indexScan план выполнения запроса по индексу. Если ключи индекса свёрнуты
+// по регистру (exact == false), найденные документы перепроверяются запросом
+type indexScan struct {
+	field  string
+	btree  *index.PagedBTree
+	ranges []keyRange
+	exact  bool
+	query  map[string]any
+}
+
+func handleFind(ctx context.Context, coll *storage.Collection, req api.Request) (api.Response, string) {
 	var results []map[string]any
-	usedIndex := false
-
-	if len(req.Query) == 1 && !hasLogicalOperators(req.Query) {
-		for field, condition := range req.Query {
-			if coll.HasIndex(field) {
-				results = findWithIndex(coll, field, condition)
-				usedIndex = true
-				break
-			}
-		}
-	}
+	var err error
+	plan := planFullScan
 
-	if !usedIndex {
-		results = findFullScan(coll, req.Query)
+	if scan, ok := planIndexScan(coll, req.Query); ok {
+		results, err = findWithIndex(coll, scan)
+		plan = planIndex
+	}
+	if plan == planFullScan || fallBackToFullScan(err) {
+		results, err = findFullScan(ctx, coll, req.Query)
+		plan = planFullScan
+	}
+	if err != nil {
+		return api.Response{Status: api.StatusError, Message: limitError(err)}, plan
 	}
 
 	return api.Response{
 		Status: api.StatusSuccess,
 		Data:   results,
 		Count:  len(results),
-	}
+	}, plan
 }
 
 func hasLogicalOperators(conditions map[string]any) bool {
@@ -38,60 +61,289 @@ func hasLogicalOperators(conditions map[string]any) bool {
 	return hasOr || hasAnd
 }
 
-func findFullScan(coll *storage.Collection, queryMap map[string]any) []map[string]any {
+// planIndexScan выбирает индекс для запроса из одного условия на индексированное поле.
+// false — запрос выполняется полным сканом
+func planIndexScan(coll *storage.Collection, query map[string]any) (indexScan, bool) {
+	if len(query) != 1 || hasLogicalOperators(query) {
+		return indexScan{}, false
+	}
+	for field, condition := range query {
+		btree, ok := coll.GetIndex(field)
+		if !ok {
+			return indexScan{}, false
+		}
+		ranges, exact, ok := conditionRanges(condition, btree.Collation())
+		if !ok {
+			return indexScan{}, false
+		}
+		return indexScan{field: field, btree: btree, ranges: ranges, exact: exact, query: query}, true
+	}
+	return indexScan{}, false
+}
+
+// conditionRanges переводит условие на поле в диапазоны ключей индекса с правилом coll.
+// Поддерживаются скаляр, $eq, $ieq, $in, $cidr, $ipRange и сочетания $gt/$gte/$lt/$lte
+// по числам, датам и адресам.
+// exact == false — ключи свёрнуты по регистру и совпадение нужно перепроверить
+func conditionRanges(condition any, coll collation.Collation) ([]keyRange, bool, bool) {
+	if isIndexScalar(condition) {
+		key, folded := index.CollatedKey(condition, coll)
+		return []keyRange{{start: key, end: key, includeStart: true, includeEnd: true}}, !folded, true
+	}
+
+	ops, ok := condition.(map[string]any)
+	if !ok || len(ops) == 0 {
+		return nil, false, false
+	}
+
+	if eqValue, exists := ops["$eq"]; exists && len(ops) == 1 {
+		return conditionRanges(eqValue, coll)
+	}
+
+	if value, exists := ops["$ieq"]; exists && len(ops) == 1 {
+		if _, isString := value.(string); !isString {
+			// для не-строк $ieq совпадает с $eq
+			return conditionRanges(value, coll)
+		}
+		if coll != collation.CaseInsensitive {
+			return nil, false, false
+		}
+		ranges, _, ok := conditionRanges(value, coll)
+		return ranges, true, ok
+	}
+
+	if inValues, exists := ops["$in"]; exists && len(ops) == 1 {
+		inArray, ok := inValues.([]any)
+		if !ok {
+			return nil, false, false
+		}
+		seen := make(map[string]bool, len(inArray))
+		exact := true
+		var ranges []keyRange
+		for _, val := range inArray {
+			if !isIndexScalar(val) {
+				return nil, false, false
+			}
+			key, folded := index.CollatedKey(val, coll)
+			exact = exact && !folded
+			if seen[string(key)] {
+				continue
+			}
+			seen[string(key)] = true
+			ranges = append(ranges, keyRange{start: key, end: key, includeStart: true, includeEnd: true})
+		}
+		// distinct возвращает значения в порядке ключей
+		sort.Slice(ranges, func(i, j int) bool {
+			return bytes.Compare(ranges[i].start, ranges[j].start) < 0
+		})
+		return ranges, exact, true
+	}
+
+	if networks, exists := ops["$cidr"]; exists && len(ops) == 1 {
+		prefixes, err := operators.ParseNetworks(networks)
+		if err != nil {
+			return nil, false, false
+		}
+		ranges := make([]keyRange, len(prefixes))
+		for i, prefix := range prefixes {
+			start, end := index.PrefixRange(prefix)
+			ranges[i] = keyRange{start: start, end: end, includeStart: true, includeEnd: true}
+		}
+		return mergeRanges(ranges), true, true
+	}
+
+	if bounds, exists := ops["$ipRange"]; exists && len(ops) == 1 {
+		from, to, err := operators.ParseIPRange(bounds)
+		if err != nil {
+			return nil, false, false
+		}
+		return []keyRange{{start: index.IPKey(from), end: index.IPKey(to), includeStart: true, includeEnd: true}}, true, true
+	}
+
+	var r keyRange
+	for op, val := range ops {
+		if !isIndexScalar(val) {
+			return nil, false, false
+		}
+		key, folded := index.CollatedKey(val, coll)
+		if folded {
+			// порядок свёрнутых строк не совпадает с порядком исходных
+			return nil, false, false
+		}
+		switch op {
+		case "$gt", "$gte":
+			if r.start != nil {
+				return nil, false, false
+			}
+			r.start, r.includeStart = key, op == "$gte"
+		case "$lt", "$lte":
+			if r.end != nil {
+				return nil, false, false
+			}
+			r.end, r.includeEnd = key, op == "$lte"
+		default:
+			return nil, false, false
+		}
+	}
+
+	// упорядочены только числа, даты и адреса (как в compareOrdered); для строк
+	// и bool диапазон ключей не совпадает с полным сканом, запрос идёт без индекса
+	for _, bound := range []index.Key{r.start, r.end} {
+		if _, _, ok := index.TypeBounds(bound); bound != nil && !ok {
+			return nil, false, false
+		}
+	}
+	// открытая граница ограничивается ключами того же типа
+	if r.start != nil && r.end == nil {
+		_, r.end, _ = index.TypeBounds(r.start)
+		r.includeEnd = true
+	}
+	if r.end != nil && r.start == nil {
+		// нижняя граница — сам маркер типа; он совпадает с ключом bool и не включается
+		r.start, _, _ = index.TypeBounds(r.end)
+		r.includeStart = false
+	}
+	return []keyRange{r}, true, true
+}
+
+// mergeRanges объединяет пересекающиеся диапазоны с включёнными границами,
+// чтобы вложенные подсети не давали один документ дважды
+func mergeRanges(ranges []keyRange) []keyRange {
+	sort.Slice(ranges, func(i, j int) bool {
+		return bytes.Compare(ranges[i].start, ranges[j].start) < 0
+	})
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if bytes.Compare(r.start, last.end) <= 0 {
+			if bytes.Compare(r.end, last.end) > 0 {
+				last.end = r.end
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+func isIndexScalar(v any) bool {
+	switch v.(type) {
+	case float64, int, int64, string, bool, time.Time:
+		return true
+	}
+	return false
+}
+
+// indexScanError индекс не удалось прочитать (ошибка ввода-вывода); его
+// результат неполный, и запрос выполняется полным сканом
+type indexScanError struct {
+	field string
+	err   error
+}
+
+func (e *indexScanError) Error() string {
+	return fmt.Sprintf("index on '%s': %v", e.field, e.err)
+}
+
+func (e *indexScanError) Unwrap() error { return e.err }
+
+// fallBackToFullScan true — err ошибка чтения индекса и запрос нужно повторить полным сканом
+func fallBackToFullScan(err error) bool {
+	var scanErr *indexScanError
+	if !errors.As(err, &scanErr) {
+		return false
+	}
+	slog.Warn("index scan failed, falling back to full scan", "field", scanErr.field, "error", scanErr.err)
+	return true
+}
+
+// ids возвращает _id документов, попавших в диапазоны плана
+func (scan indexScan) ids() ([]string, error) {
+	var values []index.Value
+	for _, r := range scan.ranges {
+		found, err := scan.btree.RangeSearch(r.start, r.end, r.includeStart, r.includeEnd)
+		if err != nil {
+			return nil, &indexScanError{field: scan.field, err: err}
+		}
+		values = append(values, found...)
+	}
+	return index.ValuesToStrings(values), nil
+}
+
+// count считает записи в диапазонах плана; документы читаются только
+// для перепроверки свёрнутых ключей
+func (scan indexScan) count(coll *storage.Collection) (int, error) {
+	if !scan.exact {
+		ids, err := scan.ids()
+		if err != nil {
+			return 0, err
+		}
+		total := 0
+		for _, id := range ids {
+			if doc, ok := coll.GetByID(id); ok && operators.MatchDocument(doc, scan.query) {
+				total++
+			}
+		}
+		return total, nil
+	}
+	total := 0
+	for _, r := range scan.ranges {
+		n, err := scan.btree.Count(r.start, r.end, r.includeStart, r.includeEnd)
+		if err != nil {
+			return 0, &indexScanError{field: scan.field, err: err}
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// findFullScan проверяет все документы коллекции; прерывается по ctx
+// и при превышении MaxResultDocs
+func findFullScan(ctx context.Context, coll *storage.Collection, queryMap map[string]any) ([]map[string]any, error) {
 	var results []map[string]any
 	allDocs := coll.All()
 
-	for _, doc := range allDocs {
+	for i, doc := range allDocs {
+		if i%scanCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
 		if operators.MatchDocument(doc, queryMap) {
 			results = append(results, doc)
+			if err := checkResultSize(len(results)); err != nil {
+				return nil, err
+			}
 		}
 	}
-	return results
+	return results, nil
 }
 
-func findWithIndex(coll *storage.Collection, field string, condition any) []map[string]any {
-	btree, ok := coll.GetIndex(field)
-	if !ok {
-		return nil
-	}
-
-	var docIDs []string
-	switch v := condition.(type) {
-	case float64, int, int64, string, bool:
-		key := index.ValueToKey(v)
-		values := btree.Search(key)
-		docIDs = index.ValuesToStrings(values)
-	case map[string]any:
-		if gtValue, exists := v["$gt"]; exists {
-			key := index.ValueToKey(gtValue)
-			values := btree.SearchGreaterThan(key)
-			docIDs = index.ValuesToStrings(values)
-		} else if ltValue, exists := v["$lt"]; exists {
-			key := index.ValueToKey(ltValue)
-			values := btree.SearchLessThan(key)
-			docIDs = index.ValuesToStrings(values)
-		} else if eqValue, exists := v["$eq"]; exists {
-			key := index.ValueToKey(eqValue)
-			values := btree.Search(key)
-			docIDs = index.ValuesToStrings(values)
-		} else if inValues, exists := v["$in"]; exists {
-			if inArray, ok := inValues.([]any); ok {
-				var keys []index.Key
-				for _, val := range inArray {
-					keys = append(keys, index.ValueToKey(val))
-				}
-				values := btree.SearchIn(keys)
-				docIDs = index.ValuesToStrings(values)
-			}
+func findWithIndex(coll *storage.Collection, scan indexScan) ([]map[string]any, error) {
+	docIDs, err := scan.ids()
+	if err != nil {
+		return nil, err
+	}
+	if scan.exact {
+		if err := checkResultSize(len(docIDs)); err != nil {
+			return nil, err
 		}
 	}
 
 	var results []map[string]any
 	for _, id := range docIDs {
-		if doc, ok := coll.GetByID(id); ok {
-			results = append(results, doc)
+		doc, ok := coll.GetByID(id)
+		if !ok || (!scan.exact && !operators.MatchDocument(doc, scan.query)) {
+			continue
+		}
+		results = append(results, doc)
+		if !scan.exact {
+			if err := checkResultSize(len(results)); err != nil {
+				return nil, err
+			}
 		}
 	}
-	return results
+	// индекс отдаёт документы по ключу, capped-коллекция — в порядке вставки
+	coll.SortByInsertion(results)
+	return results, nil
 }
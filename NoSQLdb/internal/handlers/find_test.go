@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nosql_db/internal/api"
+	"nosql_db/internal/collation"
+	"nosql_db/internal/datetime"
+	"nosql_db/internal/storage"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func newTestCollection(t *testing.T, n int) *storage.Collection {
+	t.Helper()
+	coll := storage.NewCollection("test")
+	for i := 0; i < n; i++ {
+		if _, err := coll.Insert(map[string]any{"n": float64(i), "host": fmt.Sprintf("host-%d", i%10)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return coll
+}
+
+func TestFindFullScanLimits(t *testing.T) {
+	coll := newTestCollection(t, 1000)
+
+	defer func(limit int) { MaxResultDocs = limit }(MaxResultDocs)
+
+	tests := []struct {
+		name     string
+		limit    int
+		cancel   bool
+		query    map[string]any
+		expected int
+		err      error
+	}{
+		{"within limit", 200, false, map[string]any{"host": "host-1"}, 100, nil},
+		{"no limit", 0, false, map[string]any{}, 1000, nil},
+		{"exceeds limit", 50, false, map[string]any{"host": "host-1"}, 0, errTooManyResults},
+		{"cancelled scan", 0, true, map[string]any{}, 0, context.Canceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			MaxResultDocs = tt.limit
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.cancel {
+				cancel()
+			}
+			defer cancel()
+
+			results, err := findFullScan(ctx, coll, tt.query)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if len(results) != tt.expected {
+				t.Errorf("expected %d results, got %d", tt.expected, len(results))
+			}
+		})
+	}
+}
+
+func TestLimitErrorMessages(t *testing.T) {
+	defer func(limit int) { MaxResultDocs = limit }(MaxResultDocs)
+	MaxResultDocs = 10
+
+	if msg := limitError(errTooManyResults); msg != "query matches more than 10 documents, narrow the query" {
+		t.Errorf("unexpected message: %s", msg)
+	}
+	if msg := limitError(context.DeadlineExceeded); msg != fmt.Sprintf("query exceeded time limit of %s", QueryTimeout) {
+		t.Errorf("unexpected message: %s", msg)
+	}
+}
+
+func TestFindNetworkOperatorsWithIndex(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	coll := storage.NewCollection("test")
+	addrs := []string{"10.0.0.5", "10.0.0.20", "10.0.0.100", "10.1.0.1", "172.16.0.1", "192.168.1.7", "2001:db8::1", "unknown"}
+	for _, a := range addrs {
+		if _, err := coll.Insert(map[string]any{"source_ip": a}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		query    map[string]any
+		expected int
+	}{
+		{"single network", map[string]any{"source_ip": map[string]any{"$cidr": "10.0.0.0/8"}}, 4},
+		{"nested networks", map[string]any{"source_ip": map[string]any{"$cidr": []any{"10.0.0.0/8", "10.0.0.0/24", "192.168.0.0/16"}}}, 5},
+		{"ipv6 network", map[string]any{"source_ip": map[string]any{"$cidr": "2001:db8::/32"}}, 1},
+		{"address range", map[string]any{"source_ip": map[string]any{"$ipRange": []any{"10.0.0.10", "10.0.0.100"}}}, 2},
+	}
+
+	// без индекса — полный скан, с индексом — range scan; результаты должны совпасть
+	for _, indexed := range []bool{false, true} {
+		if indexed {
+			if err := coll.CreateIndex("source_ip", 4); err != nil {
+				t.Fatal(err)
+			}
+			defer coll.CloseIndexes()
+		}
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s indexed=%v", tt.name, indexed), func(t *testing.T) {
+				resp, plan := handleFind(context.Background(), coll, api.Request{Query: tt.query})
+				if resp.Count != tt.expected {
+					t.Errorf("expected %d documents, got %d (%s)", tt.expected, resp.Count, resp.Message)
+				}
+				if indexed != (plan == planIndex) {
+					t.Errorf("unexpected plan %q", plan)
+				}
+			})
+		}
+	}
+}
+
+func TestFindDatesWithIndex(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	coll := storage.NewCollection("test")
+	// одни и те же моменты в разных часовых поясах: строковый порядок не совпадает с временным
+	stamps := []string{
+		"2024-01-02T09:00:00Z",
+		"2024-01-02T12:30:00+03:00", // 09:30Z
+		"2024-01-02T10:00:00.5Z",
+		"2024-01-02T06:00:00-05:00", // 11:00Z
+		"2024-01-02T12:00:00Z",
+		"not a date",
+	}
+	for _, s := range stamps {
+		if _, err := coll.Insert(map[string]any{"timestamp": s}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		query    map[string]any
+		expected int
+	}{
+		{"closed range", map[string]any{"timestamp": map[string]any{
+			"$gte": map[string]any{"$date": "2024-01-02T09:30:00Z"}, "$lt": map[string]any{"$date": "2024-01-02T11:00:00Z"}}}, 2},
+		{"relative to now", map[string]any{"timestamp": map[string]any{"$gt": map[string]any{"$now": "-2h"}}}, 3},
+		{"open upper bound", map[string]any{"timestamp": map[string]any{"$lte": map[string]any{"$date": "2024-01-02T12:30:00+03:00"}}}, 2},
+		{"equal instant", map[string]any{"timestamp": map[string]any{"$date": "2024-01-02T14:00:00+03:00"}}, 1},
+	}
+
+	for _, indexed := range []bool{false, true} {
+		if indexed {
+			if err := coll.CreateIndex("timestamp", 4); err != nil {
+				t.Fatal(err)
+			}
+			defer coll.CloseIndexes()
+		}
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s indexed=%v", tt.name, indexed), func(t *testing.T) {
+				query, err := datetime.ResolveQuery(tt.query, now)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp, plan := handleFind(context.Background(), coll, api.Request{Query: query})
+				if resp.Count != tt.expected {
+					t.Errorf("expected %d documents, got %d (%s)", tt.expected, resp.Count, resp.Message)
+				}
+				if indexed != (plan == planIndex) {
+					t.Errorf("unexpected plan %q", plan)
+				}
+			})
+		}
+	}
+}
+
+func TestFindRangesWithIndexMatchFullScan(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	coll := storage.NewCollection("test")
+	// отрицательные числа, целые и значения других типов в одном поле
+	values := []any{-100.5, -3.0, -1.0, 0.0, 2, 2.5, 7.0, 1e9, "5", "text", true, "2024-01-02T09:00:00Z", "10.0.0.1"}
+	for _, v := range values {
+		if _, err := coll.Insert(map[string]any{"score": v}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		condition map[string]any
+		plan      string
+	}{
+		{map[string]any{"$gt": -2.0}, planIndex},
+		{map[string]any{"$gte": -3.0}, planIndex},
+		{map[string]any{"$lt": 0.0}, planIndex},
+		{map[string]any{"$lte": 2.0}, planIndex},
+		{map[string]any{"$gt": -50.0, "$lt": 3.0}, planIndex},
+		{map[string]any{"$gte": -100.5, "$lte": -1.0}, planIndex},
+		{map[string]any{"$gt": 2}, planIndex},
+		{map[string]any{"$lt": -1000.0}, planIndex},
+		{map[string]any{"$lt": map[string]any{"$date": "2025-01-01T00:00:00Z"}}, planIndex},
+		// строки и bool операторы сравнения не упорядочивают
+		{map[string]any{"$gt": "a"}, planFullScan},
+		{map[string]any{"$lt": "z"}, planFullScan},
+		{map[string]any{"$gte": false}, planFullScan},
+		{map[string]any{"$gt": 1.0, "$lt": "z"}, planFullScan},
+	}
+	find := func(condition map[string]any) ([]string, int, string) {
+		query, err := datetime.ResolveQuery(map[string]any{"score": condition}, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, plan := handleFind(context.Background(), coll, api.Request{Query: query})
+		if resp.Status != api.StatusSuccess {
+			t.Fatalf("find %v: %s", query, resp.Message)
+		}
+		ids := make([]string, len(resp.Data))
+		for i, doc := range resp.Data {
+			ids[i] = doc["_id"].(string)
+		}
+		sort.Strings(ids)
+		count, _ := handleCount(context.Background(), coll, api.Request{Query: query})
+		return ids, count.Count, plan
+	}
+
+	expected := make([][]string, len(tests))
+	for i, tt := range tests {
+		expected[i], _, _ = find(tt.condition)
+	}
+	if err := coll.CreateIndex("score", 4); err != nil {
+		t.Fatal(err)
+	}
+	defer coll.CloseIndexes()
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprint(tt.condition), func(t *testing.T) {
+			ids, count, plan := find(tt.condition)
+			if plan != tt.plan {
+				t.Errorf("expected plan %q, got %q", tt.plan, plan)
+			}
+			if !reflect.DeepEqual(ids, expected[i]) || count != len(expected[i]) {
+				t.Errorf("index returned %v (count %d), full scan %v", ids, count, expected[i])
+			}
+		})
+	}
+}
+
+func TestCaseInsensitiveIndex(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	coll := storage.NewCollection("test")
+	for i, user := range []string{"root", "Root", "ROOT", "admin", "Admin"} {
+		if _, err := coll.Insert(map[string]any{"_id": fmt.Sprint(i + 1), "user": user}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		query     map[string]any
+		expected  int
+		indexedCI bool // выполняется по case-insensitive индексу
+		indexedBI bool // выполняется по бинарному индексу
+	}{
+		{"exact value", map[string]any{"user": "Root"}, 1, true, true},
+		{"ieq", map[string]any{"user": map[string]any{"$ieq": "rOOt"}}, 3, true, false},
+		{"in", map[string]any{"user": map[string]any{"$in": []any{"root", "Admin"}}}, 2, true, true},
+		{"ilike", map[string]any{"user": map[string]any{"$ilike": "AD%"}}, 2, false, false},
+		{"string range", map[string]any{"user": map[string]any{"$gte": "a"}}, 0, false, false},
+	}
+
+	// без индекса, с бинарным и с case-insensitive индексом результаты совпадают
+	defer coll.CloseIndexes()
+	for _, rule := range []string{"none", "binary", "case_insensitive"} {
+		if rule != "none" {
+			c, _ := collation.Parse(rule)
+			if err := coll.CreateIndexWithCollation("user", 4, c); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s %s", tt.name, rule), func(t *testing.T) {
+				expectIndex := (rule == "binary" && tt.indexedBI) || (rule == "case_insensitive" && tt.indexedCI)
+				resp, plan := handleFind(ctx, coll, api.Request{Query: tt.query})
+				if resp.Count != tt.expected || (plan == planIndex) != expectIndex {
+					t.Errorf("find: expected %d (index %v), got %d via %q", tt.expected, expectIndex, resp.Count, plan)
+				}
+				resp, _ = handleCount(ctx, coll, api.Request{Query: tt.query})
+				if resp.Count != tt.expected {
+					t.Errorf("count: expected %d, got %d", tt.expected, resp.Count)
+				}
+			})
+		}
+		if rule == "binary" {
+			if err := coll.DropIndex("user"); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// distinct по case-insensitive индексу объединяет написания; значение — документа с наименьшим _id
+	expected := []any{"admin", "root"}
+	for _, query := range []map[string]any{nil, {"user": map[string]any{"$ilike": "%"}}} {
+		resp, _ := handleDistinct(ctx, coll, api.Request{Field: "user", Query: query})
+		if !reflect.DeepEqual(resp.Values, expected) {
+			t.Errorf("distinct %v: expected %v, got %v", query, expected, resp.Values)
+		}
+	}
+}
+
+func TestFindCappedInsertionOrder(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	coll := storage.NewCollection("test")
+	if err := coll.SetCapped(storage.CappedOptions{MaxDocs: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if err := coll.CreateIndex("severity", 4); err != nil {
+		t.Fatal(err)
+	}
+	// _id и значения индекса идут не в порядке вставки
+	for i, severity := range []string{"low", "high", "low", "medium", "high", "low"} {
+		if _, err := coll.Insert(map[string]any{"_id": fmt.Sprintf("e%d", 9-i), "severity": severity}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		query    map[string]any
+		expected []string
+		plan     string
+	}{
+		{"full scan", nil, []string{"e7", "e6", "e5", "e4"}, planFullScan},
+		{"index", map[string]any{"severity": map[string]any{"$in": []any{"low", "high"}}}, []string{"e7", "e5", "e4"}, planIndex},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, plan := handleFind(ctx, coll, api.Request{Query: tt.query})
+			var ids []string
+			for _, doc := range resp.Data {
+				ids = append(ids, doc["_id"].(string))
+			}
+			if !reflect.DeepEqual(ids, tt.expected) || plan != tt.plan {
+				t.Errorf("expected %v via %s, got %v via %s", tt.expected, tt.plan, ids, plan)
+			}
+		})
+	}
+}
@@ -1,35 +1,104 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 	"nosql_db/internal/api"
+	"nosql_db/internal/datetime"
 	"nosql_db/internal/storage"
+	"time"
 )
 
 // HandleRequest — точка входа для обработки запросов
 func HandleRequest(req api.Request) api.Response {
+	start := time.Now()
+
+	ctx := context.Background()
+	if QueryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, QueryTimeout)
+		defer cancel()
+	}
+
+	resp, plan := dispatch(ctx, req)
+	observeRequest(req, resp, plan, time.Since(start))
+	return resp
+}
+
+// dispatch выполняет команду и возвращает ответ и план выполнения (для команд чтения)
+func dispatch(ctx context.Context, req api.Request) (api.Response, string) {
+	if req.Command == api.CmdListCollections {
+		return handleListCollections(), ""
+	}
 	if req.Database == "" {
-		return api.Response{Status: api.StatusError, Message: "database name is required"}
+		return api.Response{Status: api.StatusError, Message: "database name is required"}, ""
+	}
+
+	// $now и $date в условиях вычисляются один раз на запрос
+	switch req.Command {
+	case api.CmdFind, api.CmdCount, api.CmdDistinct, api.CmdDelete:
+		query, err := datetime.ResolveQuery(req.Query, time.Now())
+		if err != nil {
+			return api.Response{Status: api.StatusError, Message: fmt.Sprintf("invalid query: %v", err)}, ""
+		}
+		req.Query = query
 	}
 
 	switch req.Command {
 	case api.CmdInsert:
 		// Write-операция через очередь
-		return handleInsert(req)
+		return handleInsert(req), ""
 	case api.CmdFind:
 		// Read-операция напрямую (не требует очереди)
 		coll, err := storage.GlobalManager.GetCollection(req.Database)
 		if err != nil {
-			return api.Response{Status: api.StatusError, Message: fmt.Sprintf("failed to load database: %v", err)}
+			return api.Response{Status: api.StatusError, Message: fmt.Sprintf("failed to load database: %v", err)}, ""
+		}
+		return handleFind(ctx, coll, req)
+	case api.CmdCount, api.CmdDistinct:
+		// Read-операции напрямую, по возможности только по индексу
+		coll, err := storage.GlobalManager.GetCollection(req.Database)
+		if err != nil {
+			return api.Response{Status: api.StatusError, Message: fmt.Sprintf("failed to load database: %v", err)}, ""
+		}
+		if req.Command == api.CmdCount {
+			return handleCount(ctx, coll, req)
 		}
-		return handleFind(coll, req)
+		return handleDistinct(ctx, coll, req)
 	case api.CmdDelete:
 		// Write-операция через очередь
-		return handleDelete(req)
+		return handleDelete(req), ""
 	case api.CmdCreateIndex:
 		// Write-операция через очередь
-		return handleCreateIndex(req)
+		return handleCreateIndex(req), ""
+	case api.CmdSetSchema:
+		// Write-операция через очередь
+		return handleSetSchema(req), ""
+	case api.CmdGetSchema:
+		coll, err := storage.GlobalManager.GetCollection(req.Database)
+		if err != nil {
+			return api.Response{Status: api.StatusError, Message: fmt.Sprintf("failed to load database: %v", err)}, ""
+		}
+		return handleGetSchema(coll), ""
+	case api.CmdListIndexes, api.CmdListFields:
+		coll, err := storage.GlobalManager.GetCollection(req.Database)
+		if err != nil {
+			return api.Response{Status: api.StatusError, Message: fmt.Sprintf("failed to load database: %v", err)}, ""
+		}
+		if req.Command == api.CmdListIndexes {
+			return handleListIndexes(coll), ""
+		}
+		return handleListFields(ctx, coll), ""
+	case api.CmdDropIndex:
+		// Write-операция через очередь
+		return handleDropIndex(req), ""
+	case api.CmdRebuildIndexes:
+		// Write-операция через очередь
+		return handleRebuildIndexes(req), ""
+	case api.CmdCreateCapped:
+		// Write-операция через очередь
+		return handleCreateCapped(req), ""
 	default:
-		return api.Response{Status: api.StatusError, Message: fmt.Sprintf("unknown command: %s", req.Command)}
+		return api.Response{Status: api.StatusError, Message: fmt.Sprintf("unknown command: %s", req.Command)}, ""
 	}
 }
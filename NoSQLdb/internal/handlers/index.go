@@ -3,28 +3,64 @@ package handlers
 import (
 	"fmt"
 	"nosql_db/internal/api"
+	"nosql_db/internal/collation"
 	"nosql_db/internal/storage"
 )
 
-func handleCreateIndex(req api.Request) api.Response {
-	fieldName := ""
+// indexField имя поля индекса: из Field или из единственного ключа Query
+func indexField(req api.Request) string {
+	if req.Field != "" {
+		return req.Field
+	}
 	for k := range req.Query {
-		fieldName = k
-		break
+		return k
 	}
+	return ""
+}
 
+// indexCollation правило сравнения из параметров индекса в Query:
+// {"user": {"collation": "case_insensitive"}}
+func indexCollation(req api.Request, fieldName string) (collation.Collation, error) {
+	opts, ok := req.Query[fieldName].(map[string]any)
+	if !ok {
+		return collation.Binary, nil
+	}
+	rule := collation.Binary
+	for key, value := range opts {
+		if key != "collation" {
+			return rule, fmt.Errorf("unknown index option: %s", key)
+		}
+		name, ok := value.(string)
+		if !ok {
+			return rule, fmt.Errorf("collation must be a string")
+		}
+		parsed, err := collation.Parse(name)
+		if err != nil {
+			return rule, err
+		}
+		rule = parsed
+	}
+	return rule, nil
+}
+
+func handleCreateIndex(req api.Request) api.Response {
+	fieldName := indexField(req)
 	if fieldName == "" {
 		return api.Response{Status: api.StatusError, Message: "field name required in query"}
 	}
+	rule, err := indexCollation(req, fieldName)
+	if err != nil {
+		return api.Response{Status: api.StatusError, Message: err.Error()}
+	}
 
 	// Используем очередь для write-операции
 	result := storage.GlobalManager.Enqueue(req.Database, func(coll *storage.Collection) (storage.WriteResult, error) {
-		if err := coll.CreateIndex(fieldName, 64); err != nil {
+		if err := coll.CreateIndexWithCollation(fieldName, 64, rule); err != nil {
 			return storage.WriteResult{}, fmt.Errorf("failed to create index: %w", err)
 		}
 
 		return storage.WriteResult{
-			Message: fmt.Sprintf("Index created on field '%s'", fieldName),
+			Message: fmt.Sprintf("Index created on field '%s' (%s)", fieldName, rule),
 		}, nil
 	})
 
@@ -37,3 +73,53 @@ func handleCreateIndex(req api.Request) api.Response {
 		Message: result.Message,
 	}
 }
+
+func handleDropIndex(req api.Request) api.Response {
+	fieldName := indexField(req)
+	if fieldName == "" {
+		return api.Response{Status: api.StatusError, Message: "field name required in query"}
+	}
+
+	result := storage.GlobalManager.Enqueue(req.Database, func(coll *storage.Collection) (storage.WriteResult, error) {
+		if err := coll.DropIndex(fieldName); err != nil {
+			return storage.WriteResult{}, fmt.Errorf("failed to drop index: %w", err)
+		}
+		return storage.WriteResult{
+			Message: fmt.Sprintf("Index dropped on field '%s'", fieldName),
+		}, nil
+	})
+
+	if result.Error != nil {
+		return api.Response{Status: api.StatusError, Message: result.Error.Error()}
+	}
+	return api.Response{Status: api.StatusSuccess, Message: result.Message}
+}
+
+// handleRebuildIndexes пересоздаёт файлы всех индексов коллекции из документов
+func handleRebuildIndexes(req api.Request) api.Response {
+	result := storage.GlobalManager.Enqueue(req.Database, func(coll *storage.Collection) (storage.WriteResult, error) {
+		if err := coll.RebuildAllIndexes(); err != nil {
+			return storage.WriteResult{}, fmt.Errorf("failed to rebuild indexes: %w", err)
+		}
+		return storage.WriteResult{
+			Message: fmt.Sprintf("%d index(es) rebuilt", len(coll.IndexNames())),
+		}, nil
+	})
+
+	if result.Error != nil {
+		return api.Response{Status: api.StatusError, Message: result.Error.Error()}
+	}
+	return api.Response{Status: api.StatusSuccess, Message: result.Message}
+}
+
+// handleListIndexes имена индексированных полей в values, поля и правила сравнения в data
+func handleListIndexes(coll *storage.Collection) api.Response {
+	names := coll.IndexNames()
+	resp := namesResponse(names)
+	for _, name := range names {
+		if btree, ok := coll.GetIndex(name); ok {
+			resp.Data = append(resp.Data, map[string]any{"field": name, "collation": btree.Collation().String()})
+		}
+	}
+	return resp
+}
@@ -1,39 +1,67 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"nosql_db/internal/api"
+	"nosql_db/internal/datetime"
 	"nosql_db/internal/storage"
 )
 
+// handleInsert вставляет документы независимо друг от друга: отклонённые
+// документы попадают в Errors, остальные сохраняются. Документы с _id,
+// уже сохранённые с тем же содержимым, считаются вставленными (повтор батча)
 func handleInsert(req api.Request) api.Response {
 	if len(req.Data) == 0 {
 		return api.Response{Status: api.StatusError, Message: "no data provided for insert"}
 	}
 
+	ids := make([]string, len(req.Data))
+	var docErrors []api.DocumentError
+	alreadyStored := 0
+
 	// Используем очередь для write-операции
 	result := storage.GlobalManager.Enqueue(req.Database, func(coll *storage.Collection) (storage.WriteResult, error) {
 		var insertedIDs []string
 
-		for _, doc := range req.Data {
+		for i, doc := range req.Data {
+			if err := datetime.NormalizeDocument(doc); err != nil {
+				docErrors = append(docErrors, api.DocumentError{Index: i, Reasons: []string{err.Error()}})
+				continue
+			}
+			coll.Normalize(doc)
+			if reasons := coll.Validate(doc); len(reasons) > 0 {
+				docErrors = append(docErrors, api.DocumentError{Index: i, Reasons: reasons})
+				continue
+			}
+
 			id, err := coll.Insert(doc)
-			if err != nil {
-				return storage.WriteResult{}, fmt.Errorf("insert error: %w", err)
+			switch {
+			case errors.Is(err, storage.ErrAlreadyStored):
+				ids[i] = id
+				alreadyStored++
+			case err != nil:
+				docErrors = append(docErrors, api.DocumentError{Index: i, Reasons: []string{err.Error()}})
+			default:
+				ids[i] = id
+				insertedIDs = append(insertedIDs, id)
 			}
-			insertedIDs = append(insertedIDs, id)
 		}
 
-		if err := coll.Save(); err != nil {
-			return storage.WriteResult{}, fmt.Errorf("failed to save data: %w", err)
-		}
+		if len(insertedIDs) > 0 {
+			if err := coll.Save(); err != nil {
+				return storage.WriteResult{}, fmt.Errorf("failed to save data: %w", err)
+			}
 
-		if err := coll.SaveAllIndexes(); err != nil {
-			return storage.WriteResult{}, fmt.Errorf("failed to save indexes: %w", err)
+			if err := coll.SaveAllIndexes(); err != nil {
+				return storage.WriteResult{}, fmt.Errorf("failed to save indexes: %w", err)
+			}
 		}
 
 		return storage.WriteResult{
 			InsertedIDs: insertedIDs,
-			Message:     fmt.Sprintf("Inserted %d document(s)", len(insertedIDs)),
+			Message: fmt.Sprintf("Inserted %d document(s), %d already stored, %d rejected",
+				len(insertedIDs), alreadyStored, len(docErrors)),
 		}, nil
 	})
 
@@ -41,9 +69,19 @@ func handleInsert(req api.Request) api.Response {
 		return api.Response{Status: api.StatusError, Message: result.Error.Error()}
 	}
 
+	status := api.StatusSuccess
+	switch {
+	case len(docErrors) == len(req.Data):
+		status = api.StatusError
+	case len(docErrors) > 0:
+		status = api.StatusPartial
+	}
+
 	return api.Response{
-		Status:  api.StatusSuccess,
+		Status:  status,
 		Message: result.Message,
 		Count:   len(result.InsertedIDs),
+		IDs:     ids,
+		Errors:  docErrors,
 	}
 }
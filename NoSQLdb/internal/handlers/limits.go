@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Ограничения выполнения запросов, 0 — без ограничения
+var (
+	MaxResultDocs = 0                // максимум документов в результате find
+	QueryTimeout  = 30 * time.Second // время выполнения запроса, полный скан прерывается
+)
+
+// scanCheckInterval через сколько документов полный скан проверяет отмену
+const scanCheckInterval = 256
+
+var errTooManyResults = errors.New("too many results")
+
+// limitError описывает нарушение ограничения понятным клиенту сообщением
+func limitError(err error) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Sprintf("query exceeded time limit of %s", QueryTimeout)
+	case errors.Is(err, errTooManyResults):
+		return fmt.Sprintf("query matches more than %d documents, narrow the query", MaxResultDocs)
+	default:
+		return err.Error()
+	}
+}
+
+// checkResultSize проверяет, что результат не превышает MaxResultDocs
+func checkResultSize(n int) error {
+	if MaxResultDocs > 0 && n > MaxResultDocs {
+		return errTooManyResults
+	}
+	return nil
+}
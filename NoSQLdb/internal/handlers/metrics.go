@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"nosql_db/internal/api"
+	"nosql_db/internal/metrics"
+	"nosql_db/internal/storage"
+	"time"
+)
+
+// Планы выполнения запросов чтения
+const (
+	planIndex    = "index"
+	planFullScan = "full_scan"
+)
+
+// SlowQueryThreshold запросы дольше порога пишутся в лог медленных запросов, 0 отключает лог
+var SlowQueryThreshold = 500 * time.Millisecond
+
+var (
+	requestsTotal = metrics.NewCounterVec("nosqldb_requests_total",
+		"Total number of processed requests.", "command", "status")
+	requestDuration = metrics.NewHistogramVec("nosqldb_request_duration_seconds",
+		"Request processing latency in seconds.", metrics.DefaultBuckets, "command")
+	queryPlans = metrics.NewCounterVec("nosqldb_query_plans_total",
+		"Read queries (find, count, distinct) by execution plan: index or full scan.", "command", "plan")
+	slowQueries = metrics.NewCounterVec("nosqldb_slow_queries_total",
+		"Requests slower than the slow query threshold.", "command")
+)
+
+func init() {
+	metrics.NewGaugeFunc("nosqldb_write_queue_depth", "Write jobs waiting in the queue.", func() float64 {
+		return float64(storage.GlobalManager.QueueDepth())
+	})
+	metrics.NewGaugeVecFunc("nosqldb_collection_documents", "Documents in each loaded collection.", "collection",
+		func() map[string]float64 {
+			sizes := storage.GlobalManager.CollectionSizes()
+			values := make(map[string]float64, len(sizes))
+			for name, size := range sizes {
+				values[name] = float64(size)
+			}
+			return values
+		})
+}
+
+// observeRequest учитывает запрос в метриках и пишет его в лог медленных запросов
+func observeRequest(req api.Request, resp api.Response, plan string, elapsed time.Duration) {
+	command := req.Command
+	requestsTotal.Inc(command, resp.Status)
+	requestDuration.Observe(elapsed.Seconds(), command)
+	if plan != "" {
+		queryPlans.Inc(command, plan)
+	}
+
+	if SlowQueryThreshold <= 0 || elapsed < SlowQueryThreshold {
+		return
+	}
+	slowQueries.Inc(command)
+
+	attrs := []any{
+		"command", command,
+		"database", req.Database,
+		"duration_ms", elapsed.Milliseconds(),
+		"status", resp.Status,
+		"count", resp.Count,
+	}
+	if plan != "" {
+		attrs = append(attrs, "plan", plan)
+	}
+	if len(req.Query) > 0 {
+		query, _ := json.Marshal(req.Query)
+		attrs = append(attrs, "query", string(query))
+	}
+	if len(req.Data) > 0 {
+		attrs = append(attrs, "documents", len(req.Data))
+	}
+	slog.Warn("slow query", attrs...)
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"fmt"
+	"nosql_db/internal/api"
+	"nosql_db/internal/schema"
+	"nosql_db/internal/storage"
+)
+
+// handleSetSchema задаёт схему коллекции из req.Query; пустой query снимает схему
+func handleSetSchema(req api.Request) api.Response {
+	var s *schema.Schema
+	if len(req.Query) > 0 {
+		parsed, err := schema.Parse(req.Query)
+		if err != nil {
+			return api.Response{Status: api.StatusError, Message: fmt.Sprintf("invalid schema: %v", err)}
+		}
+		s = parsed
+	}
+
+	// Используем очередь, чтобы схема менялась строго между вставками
+	result := storage.GlobalManager.Enqueue(req.Database, func(coll *storage.Collection) (storage.WriteResult, error) {
+		if err := coll.SetSchema(s); err != nil {
+			return storage.WriteResult{}, err
+		}
+		if s == nil {
+			return storage.WriteResult{Message: "Schema removed"}, nil
+		}
+		return storage.WriteResult{Message: "Schema updated"}, nil
+	})
+
+	if result.Error != nil {
+		return api.Response{Status: api.StatusError, Message: result.Error.Error()}
+	}
+
+	return api.Response{
+		Status:  api.StatusSuccess,
+		Message: result.Message,
+	}
+}
+
+func handleGetSchema(coll *storage.Collection) api.Response {
+	s := coll.GetSchema()
+	if s == nil {
+		return api.Response{Status: api.StatusSuccess, Message: "No schema defined"}
+	}
+
+	return api.Response{
+		Status: api.StatusSuccess,
+		Data:   []map[string]any{s.Raw()},
+		Count:  1,
+	}
+}
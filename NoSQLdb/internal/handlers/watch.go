@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"nosql_db/internal/api"
+	"nosql_db/internal/datetime"
+	"nosql_db/internal/operators"
+	"nosql_db/internal/storage"
+	"time"
+)
+
+// ErrWatchLagged подписчик не успевал читать изменения и был отключён
+var ErrWatchLagged = errors.New("watch stream lagged behind, resume with the last token")
+
+// Watch обслуживает команду watch: отправляет подтверждение с текущим токеном,
+// затем изменения коллекции, подходящие под req.Query, пока не закрыт done.
+// Каждое изменение несёт токен, по которому можно продолжить поток (resume_after)
+func Watch(req api.Request, send func(api.Response) error, done <-chan struct{}) error {
+	if req.Database == "" {
+		return send(api.Response{Status: api.StatusError, Message: "database name is required"})
+	}
+
+	query, err := datetime.ResolveQuery(req.Query, time.Now())
+	if err != nil {
+		return send(api.Response{Status: api.StatusError, Message: fmt.Sprintf("invalid query: %v", err)})
+	}
+	req.Query = query
+
+	sub, backlog, lastSeq, err := storage.GlobalManager.Watch(req.Database, req.ResumeAfter)
+	if err != nil {
+		return send(api.Response{Status: api.StatusError, Message: err.Error()})
+	}
+	defer storage.GlobalManager.Unwatch(sub)
+
+	if err := send(api.Response{
+		Status:  api.StatusSuccess,
+		Message: fmt.Sprintf("Watching %s", req.Database),
+		Token:   storage.GlobalManager.ChangeToken(lastSeq),
+	}); err != nil {
+		return err
+	}
+
+	for _, event := range backlog {
+		if err := sendChange(req, event, send); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case event := <-sub.Events:
+			if err := sendChange(req, event, send); err != nil {
+				return err
+			}
+		case <-sub.Lagged:
+			_ = send(api.Response{Status: api.StatusError, Message: ErrWatchLagged.Error()})
+			return ErrWatchLagged
+		case <-done:
+			return nil
+		}
+	}
+}
+
+func sendChange(req api.Request, event storage.ChangeEvent, send func(api.Response) error) error {
+	if !operators.MatchDocument(event.Document, req.Query) {
+		return nil
+	}
+	return send(api.Response{
+		Status: api.StatusSuccess,
+		Data:   []map[string]any{event.Document},
+		Count:  1,
+		Change: &api.Change{Operation: event.Operation, ID: event.ID},
+		Token:  storage.GlobalManager.ChangeToken(event.Seq),
+	})
+}
@@ -0,0 +1,64 @@
+package index
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// Ключ IP-адреса: маркер, семейство (4 или 6) и адрес в сетевом порядке байт.
+// Адреса одного семейства сортируются численно и занимают непрерывный
+// диапазон ключей, поэтому подсеть ищется одним range scan
+const ipKeyMarker = 0x00
+
+const (
+	ipFamilyV4 = 4
+	ipFamilyV6 = 6
+)
+
+// parseIP распознаёт строку с IPv4 или IPv6 адресом без зоны
+func parseIP(s string) (netip.Addr, bool) {
+	if len(s) < 2 || len(s) > 45 || !strings.ContainsAny(s, ".:") {
+		return netip.Addr{}, false
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil || addr.Zone() != "" {
+		return netip.Addr{}, false
+	}
+	return addr, true
+}
+
+// IPKey ключ индекса для адреса. IPv4-mapped IPv6 остаётся IPv6,
+// как и при сравнении строк в запросах
+func IPKey(addr netip.Addr) Key {
+	if addr.Is4() {
+		a := addr.As4()
+		return append(Key{ipKeyMarker, ipFamilyV4}, a[:]...)
+	}
+	a := addr.As16()
+	return append(Key{ipKeyMarker, ipFamilyV6}, a[:]...)
+}
+
+// PrefixRange границы ключей подсети (обе включительно)
+func PrefixRange(prefix netip.Prefix) (Key, Key) {
+	prefix = prefix.Masked()
+	first := prefix.Addr()
+	bits := first.BitLen()
+	var last netip.Addr
+	if first.Is4() {
+		a := first.As4()
+		setHostBits(a[:], prefix.Bits(), bits)
+		last = netip.AddrFrom4(a)
+	} else {
+		a := first.As16()
+		setHostBits(a[:], prefix.Bits(), bits)
+		last = netip.AddrFrom16(a)
+	}
+	return IPKey(first), IPKey(last)
+}
+
+// setHostBits выставляет в единицу биты адреса после префикса
+func setHostBits(addr []byte, prefixBits, totalBits int) {
+	for bit := prefixBits; bit < totalBits; bit++ {
+		addr[bit/8] |= 0x80 >> (bit % 8)
+	}
+}
@@ -0,0 +1,31 @@
+package index
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Ключ числа: маркер и биты float64, у положительных инвертирован знаковый бит,
+// у отрицательных — все биты. Так ключи сортируются в порядке значений,
+// включая отрицательные, и занимают непрерывный диапазон
+const numberKeyMarker = 0x02
+
+const numberKeySize = 1 + 8
+
+// NumberKey ключ индекса для числа
+func NumberKey(v float64) Key {
+	if v == 0 {
+		// -0 и 0 равны и должны давать один ключ
+		v = 0
+	}
+	bits := math.Float64bits(v)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	key := make(Key, numberKeySize)
+	key[0] = numberKeyMarker
+	binary.BigEndian.PutUint64(key[1:], bits)
+	return key
+}
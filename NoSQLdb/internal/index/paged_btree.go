@@ -0,0 +1,759 @@
+package index
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"nosql_db/internal/collation"
+	"sync"
+)
+
+// DefaultCacheSize сколько узлов держит в памяти буферный пул одного индекса
+const DefaultCacheSize = 512
+
+// PagedBTree — b+ tree, хранящийся в файле постранично.
+// Узлы читаются с диска по требованию и кэшируются в буферном пуле (LRU),
+// изменённые узлы помечаются dirty и пишутся на диск при вытеснении или Flush.
+// Записи в листьях — пары (ключ, значение), поэтому низкокардинальные поля
+// (severity) не превращаются в огромные списки значений в одном узле.
+// Даже чтение меняет буферный пул, поэтому все операции идут под мьютексом
+type PagedBTree struct {
+	mu        sync.Mutex
+	pager     *pager
+	order     int
+	cache     map[pageID]*pagedNode
+	lru       *list.List
+	capacity  int
+	metaDirty bool
+	err       error // первая ошибка ввода-вывода, возвращается из Flush
+	closed    bool
+}
+
+// ErrClosed индекс закрыт (удалён или пересобран), пока запрос держал ссылку на него
+var ErrClosed = errors.New("index is closed")
+
+type pagedNode struct {
+	id       pageID
+	pages    []pageID
+	isLeaf   bool
+	keys     []Key
+	values   []Value // значения записей листа или вторая часть разделителя
+	children []pageID
+	next     pageID
+	dirty    bool
+	elem     *list.Element
+}
+
+// OpenPagedBTree открывает (или создаёт) индекс в файле path.
+// Для существующего файла читается только мета-страница
+func OpenPagedBTree(path string, order int) (*PagedBTree, error) {
+	p, err := openPager(path, order)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := &PagedBTree{
+		pager:    p,
+		order:    p.order,
+		cache:    make(map[pageID]*pagedNode),
+		lru:      list.New(),
+		capacity: DefaultCacheSize,
+	}
+
+	if p.root == nilPage {
+		root, err := tree.newNode(true)
+		if err != nil {
+			p.close()
+			return nil, err
+		}
+		p.root = root.id
+		tree.metaDirty = true
+		if err := tree.flush(); err != nil {
+			p.close()
+			return nil, err
+		}
+	}
+	return tree, nil
+}
+
+// SetCacheSize меняет размер буферного пула
+func (tree *PagedBTree) SetCacheSize(n int) {
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+	tree.capacity = max(n, 8)
+	tree.evict()
+}
+
+// GetOrder возвращает порядок дерева
+func (tree *PagedBTree) GetOrder() int {
+	return tree.order
+}
+
+// Collation правило сравнения строковых ключей, сохранённое в мете индекса
+func (tree *PagedBTree) Collation() collation.Collation {
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+	return tree.pager.collation
+}
+
+// SetCollation задаёт правило сравнения для нового (пустого) индекса;
+// записывается в мету при Flush
+func (tree *PagedBTree) SetCollation(c collation.Collation) {
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+	tree.pager.collation = c
+	tree.metaDirty = true
+}
+
+// Key ключ значения по правилу сравнения индекса
+func (tree *PagedBTree) Key(value any) Key {
+	key, _ := CollatedKey(value, tree.Collation())
+	return key
+}
+
+// Err возвращает первую ошибку ввода-вывода
+func (tree *PagedBTree) Err() error {
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+	return tree.err
+}
+
+// Insert вставляет пару (ключ, значение); повторная вставка той же пары игнорируется.
+// После ошибки ввода-вывода дерево неполное: запись отклоняется, индекс нужно пересобрать
+func (tree *PagedBTree) Insert(key Key, value Value) error {
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+	if err := tree.beginWrite(); err != nil {
+		return err
+	}
+	tree.insert(key, value)
+	tree.evict()
+	return tree.err
+}
+
+func (tree *PagedBTree) insert(key Key, value Value) {
+	path, leaf, err := tree.findLeaf(key, value)
+	if err != nil {
+		tree.fail(err)
+		return
+	}
+
+	pos := lowerBound(leaf, key, value)
+	if pos < len(leaf.keys) && compareEntry(leaf.keys[pos], leaf.values[pos], key, value) == 0 {
+		return
+	}
+
+	leaf.keys = insertAt(leaf.keys, pos, append(Key{}, key...))
+	leaf.values = insertAt(leaf.values, pos, append(Value{}, value...))
+	leaf.dirty = true
+
+	if len(leaf.keys) > tree.order*2-1 {
+		if err := tree.splitLeaf(leaf, path); err != nil {
+			tree.fail(err)
+		}
+	}
+}
+
+// Delete удаляет пару (ключ, значение); false — пары не было.
+// Как и в BTree, узлы не сливаются: пустые листья остаются в цепочке
+func (tree *PagedBTree) Delete(key Key, value Value) (bool, error) {
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+	if err := tree.beginWrite(); err != nil {
+		return false, err
+	}
+	deleted := tree.delete(key, value)
+	tree.evict()
+	return deleted, tree.err
+}
+
+func (tree *PagedBTree) delete(key Key, value Value) bool {
+	_, leaf, err := tree.findLeaf(key, value)
+	if err != nil {
+		tree.fail(err)
+		return false
+	}
+
+	pos := lowerBound(leaf, key, value)
+	if pos >= len(leaf.keys) || compareEntry(leaf.keys[pos], leaf.values[pos], key, value) != 0 {
+		return false
+	}
+
+	leaf.keys = append(leaf.keys[:pos], leaf.keys[pos+1:]...)
+	leaf.values = append(leaf.values[:pos], leaf.values[pos+1:]...)
+	leaf.dirty = true
+	return true
+}
+
+// beginWrite проверяет, что дерево можно менять, и до первой записи после Flush
+// отмечает в мете, что файл изменяется: страницы пишутся на место, и после сбоя
+// посреди записи такой файл не открывается, а пересобирается
+func (tree *PagedBTree) beginWrite() error {
+	if tree.closed {
+		return ErrClosed
+	}
+	if tree.err != nil {
+		return tree.err
+	}
+	if tree.pager.modified {
+		return nil
+	}
+	tree.pager.modified = true
+	if err := tree.pager.writeMeta(); err != nil {
+		tree.fail(err)
+		return err
+	}
+	if err := tree.pager.sync(); err != nil {
+		tree.fail(err)
+		return err
+	}
+	return nil
+}
+
+// Search выполняет точечный поиск по ключу ($eq)
+func (tree *PagedBTree) Search(key Key) ([]Value, error) {
+	return tree.RangeSearch(key, key, true, true)
+}
+
+// RangeSearch выполняет диапазонный поиск; nil-граница означает отсутствие ограничения.
+// При ошибке чтения результат неполный, поэтому возвращается только ошибка
+func (tree *PagedBTree) RangeSearch(start, end Key, includeStart, includeEnd bool) ([]Value, error) {
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+	return tree.rangeSearch(start, end, includeStart, includeEnd)
+}
+
+func (tree *PagedBTree) rangeSearch(start, end Key, includeStart, includeEnd bool) ([]Value, error) {
+	var result []Value
+	err := tree.rangeScan(start, end, includeStart, includeEnd, func(_ Key, value Value) {
+		result = append(result, value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// rangeScan вызывает fn для каждой записи диапазона в порядке ключей
+func (tree *PagedBTree) rangeScan(start, end Key, includeStart, includeEnd bool, fn func(key Key, value Value)) error {
+	return tree.scan(start, func(key Key, value Value) bool {
+		if start != nil {
+			cmp := bytes.Compare(key, start)
+			if cmp < 0 || (cmp == 0 && !includeStart) {
+				return true
+			}
+		}
+		if end != nil {
+			cmp := bytes.Compare(key, end)
+			if cmp > 0 || (cmp == 0 && !includeEnd) {
+				return false
+			}
+		}
+		fn(key, value)
+		return true
+	})
+}
+
+// Count возвращает число значений в диапазоне, не собирая их
+func (tree *PagedBTree) Count(start, end Key, includeStart, includeEnd bool) (int, error) {
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+
+	count := 0
+	err := tree.rangeScan(start, end, includeStart, includeEnd, func(Key, Value) {
+		count++
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// KeyCount уникальный ключ индекса: первое значение (для получения исходного
+// значения поля из документа) и число значений с этим ключом
+type KeyCount struct {
+	Key   Key
+	First Value
+	Count int
+}
+
+// KeyCounts возвращает уникальные ключи диапазона в порядке возрастания
+func (tree *PagedBTree) KeyCounts(start, end Key, includeStart, includeEnd bool) ([]KeyCount, error) {
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+
+	var result []KeyCount
+	err := tree.rangeScan(start, end, includeStart, includeEnd, func(key Key, value Value) {
+		if n := len(result); n > 0 && bytes.Equal(result[n-1].Key, key) {
+			result[n-1].Count++
+			return
+		}
+		result = append(result, KeyCount{Key: key, First: value, Count: 1})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SearchGreaterThan ищет все значения где ключ > key ($gt)
+func (tree *PagedBTree) SearchGreaterThan(key Key) ([]Value, error) {
+	return tree.RangeSearch(key, nil, false, false)
+}
+
+// SearchLessThan ищет все значения где ключ < key ($lt)
+func (tree *PagedBTree) SearchLessThan(key Key) ([]Value, error) {
+	return tree.RangeSearch(nil, key, false, false)
+}
+
+// SearchGreaterThanOrEqual ищет все значения где ключ >= key ($gte)
+func (tree *PagedBTree) SearchGreaterThanOrEqual(key Key) ([]Value, error) {
+	return tree.RangeSearch(key, nil, true, false)
+}
+
+// SearchLessThanOrEqual ищет все значения где ключ <= key ($lte)
+func (tree *PagedBTree) SearchLessThanOrEqual(key Key) ([]Value, error) {
+	return tree.RangeSearch(nil, key, false, true)
+}
+
+// SearchIn выполняет множественный точечный поиск ($in)
+func (tree *PagedBTree) SearchIn(keys []Key) ([]Value, error) {
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+
+	var result []Value
+	for _, key := range keys {
+		values, err := tree.rangeSearch(key, key, true, true)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, values...)
+	}
+	return result, nil
+}
+
+// GetAllValues возвращает все значения из дерева в порядке ключей
+func (tree *PagedBTree) GetAllValues() ([]Value, error) {
+	return tree.RangeSearch(nil, nil, false, false)
+}
+
+// scan обходит записи листьев по цепочке sibling-указателей, начиная
+// с первой записи с ключом >= start (или с самого левого листа при start == nil),
+// пока fn возвращает true. Ошибка чтения прерывает обход; после первой ошибки
+// ввода-вывода дереву нельзя доверять и все обходы возвращают её
+func (tree *PagedBTree) scan(start Key, fn func(key Key, value Value) bool) error {
+	if tree.closed {
+		return ErrClosed
+	}
+	defer tree.evict()
+	if tree.err != nil {
+		return tree.err
+	}
+
+	var leaf *pagedNode
+	var err error
+	pos := 0
+	if start == nil {
+		leaf, err = tree.leftmostLeaf()
+	} else {
+		_, leaf, err = tree.findLeaf(start, nil)
+		if err == nil {
+			pos = lowerBound(leaf, start, nil)
+		}
+	}
+
+	for err == nil {
+		for ; pos < len(leaf.keys); pos++ {
+			if !fn(leaf.keys[pos], leaf.values[pos]) {
+				return nil
+			}
+		}
+		if leaf.next == nilPage {
+			return nil
+		}
+		leaf, err = tree.getNode(leaf.next)
+		pos = 0
+		// узлы, пройденные сканом, больше не нужны: держим пул в пределах capacity
+		tree.evict()
+	}
+	tree.fail(err)
+	return err
+}
+
+// Flush пишет все изменённые узлы и мету на диск
+func (tree *PagedBTree) Flush() error {
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+	if tree.closed {
+		return nil
+	}
+	return tree.flush()
+}
+
+func (tree *PagedBTree) flush() error {
+	if tree.err != nil {
+		return tree.err
+	}
+	for _, node := range tree.cache {
+		if node.dirty {
+			if err := tree.writeNode(node); err != nil {
+				tree.fail(err)
+				return err
+			}
+		}
+	}
+	// отметка о целостности пишется только после того, как узлы на диске
+	if err := tree.pager.sync(); err != nil {
+		tree.fail(err)
+		return err
+	}
+	tree.pager.modified = false
+	if err := tree.pager.writeMeta(); err != nil {
+		tree.fail(err)
+		return err
+	}
+	tree.metaDirty = false
+	if err := tree.pager.sync(); err != nil {
+		tree.fail(err)
+		return err
+	}
+	return nil
+}
+
+// Close сбрасывает изменения на диск и закрывает файл. Обходы держат мьютекс
+// дерева, поэтому Close дожидается начатых; следующие получают ErrClosed
+func (tree *PagedBTree) Close() error {
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+	if tree.closed {
+		return nil
+	}
+
+	flushErr := tree.flush()
+	tree.closed = true
+	tree.cache = make(map[pageID]*pagedNode)
+	tree.lru.Init()
+	if err := tree.pager.close(); err != nil && flushErr == nil {
+		return err
+	}
+	return flushErr
+}
+
+func (tree *PagedBTree) fail(err error) {
+	if err != nil && tree.err == nil {
+		tree.err = err
+	}
+}
+
+// findLeaf спускается от корня к листу для записи (key, value),
+// возвращая путь из внутренних узлов
+func (tree *PagedBTree) findLeaf(key Key, value Value) ([]*pagedNode, *pagedNode, error) {
+	node, err := tree.getNode(tree.pager.root)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var path []*pagedNode
+	for !node.isLeaf {
+		path = append(path, node)
+		// ищем первый разделитель больше записи: записи, равные разделителю, лежат справа
+		i := 0
+		for i < len(node.keys) && compareEntry(node.keys[i], node.values[i], key, value) <= 0 {
+			i++
+		}
+		if node, err = tree.getNode(node.children[i]); err != nil {
+			return nil, nil, err
+		}
+	}
+	return path, node, nil
+}
+
+func (tree *PagedBTree) leftmostLeaf() (*pagedNode, error) {
+	node, err := tree.getNode(tree.pager.root)
+	for err == nil && !node.isLeaf {
+		node, err = tree.getNode(node.children[0])
+	}
+	return node, err
+}
+
+// splitLeaf делит лист пополам и поднимает первую запись правой половины в родителя
+func (tree *PagedBTree) splitLeaf(leaf *pagedNode, path []*pagedNode) error {
+	newLeaf, err := tree.newNode(true)
+	if err != nil {
+		return err
+	}
+
+	mid := len(leaf.keys) / 2
+	newLeaf.keys = append([]Key{}, leaf.keys[mid:]...)
+	newLeaf.values = append([]Value{}, leaf.values[mid:]...)
+	newLeaf.next = leaf.next
+
+	leaf.keys = leaf.keys[:mid:mid]
+	leaf.values = leaf.values[:mid:mid]
+	leaf.next = newLeaf.id
+	leaf.dirty = true
+
+	return tree.insertInParent(path, leaf, newLeaf.keys[0], newLeaf.values[0], newLeaf)
+}
+
+func (tree *PagedBTree) insertInParent(path []*pagedNode, left *pagedNode, key Key, value Value, right *pagedNode) error {
+	if len(path) == 0 {
+		root, err := tree.newNode(false)
+		if err != nil {
+			return err
+		}
+		root.keys = []Key{key}
+		root.values = []Value{value}
+		root.children = []pageID{left.id, right.id}
+		tree.pager.root = root.id
+		tree.metaDirty = true
+		return nil
+	}
+
+	parent := path[len(path)-1]
+	pos := 0
+	for pos < len(parent.children) && parent.children[pos] != left.id {
+		pos++
+	}
+	if pos == len(parent.children) {
+		return fmt.Errorf("node %d is not a child of %d", left.id, parent.id)
+	}
+
+	parent.keys = insertAt(parent.keys, pos, key)
+	parent.values = insertAt(parent.values, pos, value)
+	parent.children = insertAt(parent.children, pos+1, right.id)
+	parent.dirty = true
+
+	if len(parent.keys) > tree.order*2-1 {
+		return tree.splitInternal(parent, path[:len(path)-1])
+	}
+	return nil
+}
+
+func (tree *PagedBTree) splitInternal(node *pagedNode, path []*pagedNode) error {
+	newNode, err := tree.newNode(false)
+	if err != nil {
+		return err
+	}
+
+	mid := len(node.keys) / 2
+	upKey, upValue := node.keys[mid], node.values[mid]
+
+	newNode.keys = append([]Key{}, node.keys[mid+1:]...)
+	newNode.values = append([]Value{}, node.values[mid+1:]...)
+	newNode.children = append([]pageID{}, node.children[mid+1:]...)
+
+	node.keys = node.keys[:mid:mid]
+	node.values = node.values[:mid:mid]
+	node.children = node.children[: mid+1 : mid+1]
+	node.dirty = true
+
+	return tree.insertInParent(path, node, upKey, upValue, newNode)
+}
+
+// newNode выделяет страницу под новый узел и кладёт его в кэш как dirty
+func (tree *PagedBTree) newNode(isLeaf bool) (*pagedNode, error) {
+	id, err := tree.pager.allocate()
+	if err != nil {
+		return nil, err
+	}
+	tree.metaDirty = true
+	node := &pagedNode{id: id, pages: []pageID{id}, isLeaf: isLeaf, dirty: true}
+	node.elem = tree.lru.PushFront(node)
+	tree.cache[id] = node
+	return node, nil
+}
+
+// getNode возвращает узел из кэша или читает его с диска
+func (tree *PagedBTree) getNode(id pageID) (*pagedNode, error) {
+	if node, ok := tree.cache[id]; ok {
+		tree.lru.MoveToFront(node.elem)
+		return node, nil
+	}
+
+	data, pages, err := tree.pager.readChain(id)
+	if err != nil {
+		return nil, err
+	}
+	node, err := decodeNode(data)
+	if err != nil {
+		return nil, fmt.Errorf("decode node %d: %w", id, err)
+	}
+	node.id = id
+	node.pages = pages
+	node.elem = tree.lru.PushFront(node)
+	tree.cache[id] = node
+	return node, nil
+}
+
+// evict вытесняет давно неиспользуемые узлы, записывая dirty на диск.
+// Вызывается только между операциями, чтобы не вытеснить узлы текущего пути
+func (tree *PagedBTree) evict() {
+	for tree.lru.Len() > tree.capacity {
+		node := tree.lru.Back().Value.(*pagedNode)
+		if node.dirty {
+			if err := tree.writeNode(node); err != nil {
+				tree.fail(err)
+				return
+			}
+		}
+		tree.lru.Remove(node.elem)
+		delete(tree.cache, node.id)
+	}
+	if tree.metaDirty && tree.err == nil {
+		if err := tree.pager.writeMeta(); err != nil {
+			tree.fail(err)
+			return
+		}
+		tree.metaDirty = false
+	}
+}
+
+func (tree *PagedBTree) writeNode(node *pagedNode) error {
+	pages, err := tree.pager.writeChain(node.pages, encodeNode(node))
+	if len(pages) != len(node.pages) {
+		tree.metaDirty = true
+	}
+	node.pages = pages
+	if err != nil {
+		return err
+	}
+	node.dirty = false
+	return nil
+}
+
+// Формат узла: isLeaf (1), n (uvarint), next (4, только лист),
+// n записей (ключ и значение с uvarint-длиной), n+1 детей (по 4, только внутренний)
+func encodeNode(node *pagedNode) []byte {
+	var buf bytes.Buffer
+	var tmp [binary.MaxVarintLen64]byte
+	var id [4]byte
+
+	writeBytes := func(b []byte) {
+		n := binary.PutUvarint(tmp[:], uint64(len(b)))
+		buf.Write(tmp[:n])
+		buf.Write(b)
+	}
+
+	if node.isLeaf {
+		buf.WriteByte(1)
+	} else {
+		buf.WriteByte(0)
+	}
+	n := binary.PutUvarint(tmp[:], uint64(len(node.keys)))
+	buf.Write(tmp[:n])
+
+	if node.isLeaf {
+		binary.BigEndian.PutUint32(id[:], uint32(node.next))
+		buf.Write(id[:])
+	}
+	for i := range node.keys {
+		writeBytes(node.keys[i])
+		writeBytes(node.values[i])
+	}
+	if !node.isLeaf {
+		for _, child := range node.children {
+			binary.BigEndian.PutUint32(id[:], uint32(child))
+			buf.Write(id[:])
+		}
+	}
+	return buf.Bytes()
+}
+
+func decodeNode(data []byte) (*pagedNode, error) {
+	r := bytes.NewReader(data)
+
+	flag, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	node := &pagedNode{isLeaf: flag == 1}
+
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if count > uint64(len(data)) {
+		return nil, errors.New("corrupted entry count")
+	}
+
+	readID := func() (pageID, error) {
+		var id [4]byte
+		if _, err := io.ReadFull(r, id[:]); err != nil {
+			return nilPage, err
+		}
+		return pageID(binary.BigEndian.Uint32(id[:])), nil
+	}
+	readBytes := func() ([]byte, error) {
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		if n > uint64(r.Len()) {
+			return nil, errors.New("corrupted entry length")
+		}
+		b := make([]byte, n)
+		_, err = io.ReadFull(r, b)
+		return b, err
+	}
+
+	if node.isLeaf {
+		if node.next, err = readID(); err != nil {
+			return nil, err
+		}
+	}
+
+	node.keys = make([]Key, count)
+	node.values = make([]Value, count)
+	for i := range node.keys {
+		if node.keys[i], err = readBytes(); err != nil {
+			return nil, err
+		}
+		if node.values[i], err = readBytes(); err != nil {
+			return nil, err
+		}
+	}
+
+	if !node.isLeaf {
+		node.children = make([]pageID, count+1)
+		for i := range node.children {
+			if node.children[i], err = readID(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return node, nil
+}
+
+// compareEntry сравнивает записи сначала по ключу, затем по значению
+func compareEntry(k1 Key, v1 Value, k2 Key, v2 Value) int {
+	if cmp := bytes.Compare(k1, k2); cmp != 0 {
+		return cmp
+	}
+	return bytes.Compare(v1, v2)
+}
+
+// lowerBound возвращает позицию первой записи узла, не меньшей (key, value)
+func lowerBound(node *pagedNode, key Key, value Value) int {
+	lo, hi := 0, len(node.keys)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if compareEntry(node.keys[mid], node.values[mid], key, value) < 0 {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+func insertAt[T any](s []T, pos int, v T) []T {
+	var zero T
+	s = append(s, zero)
+	copy(s[pos+1:], s[pos:])
+	s[pos] = v
+	return s
+}
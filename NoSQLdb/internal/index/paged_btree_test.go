@@ -0,0 +1,409 @@
+package index
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"net/netip"
+	"nosql_db/internal/collation"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func openTestTree(t *testing.T, order int) (*PagedBTree, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.pidx")
+	tree, err := OpenPagedBTree(path, order)
+	if err != nil {
+		t.Fatalf("failed to open tree: %v", err)
+	}
+	return tree, path
+}
+
+// mustValues проваливает тест, если поиск вернул ошибку чтения
+func mustValues(t *testing.T) func([]Value, error) []Value {
+	return func(values []Value, err error) []Value {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("index read error: %v", err)
+		}
+		return values
+	}
+}
+
+func TestPagedBTreeInsertAndSearch(t *testing.T) {
+	check := mustValues(t)
+	tree, _ := openTestTree(t, 3)
+	defer tree.Close()
+
+	tree.Insert(Key("apple"), Value("fruit1"))
+	tree.Insert(Key("banana"), Value("fruit2"))
+	tree.Insert(Key("cherry"), Value("fruit3"))
+
+	values := check(tree.Search(Key("banana")))
+	if len(values) != 1 || string(values[0]) != "fruit2" {
+		t.Errorf("expected [fruit2], got %v", values)
+	}
+
+	if values := check(tree.Search(Key("nonexistent"))); len(values) != 0 {
+		t.Errorf("expected empty result, got %v", values)
+	}
+}
+
+func TestPagedBTreeDuplicateKeysAcrossLeaves(t *testing.T) {
+	check := mustValues(t)
+	tree, _ := openTestTree(t, 2)
+	defer tree.Close()
+
+	for i := 0; i < 50; i++ {
+		tree.Insert(Key("high"), Value(fmt.Sprintf("id%03d", i)))
+		tree.Insert(Key("low"), Value(fmt.Sprintf("id%03d", i)))
+	}
+	// повторная вставка той же пары не создаёт дубликат
+	tree.Insert(Key("high"), Value("id000"))
+
+	if values := check(tree.Search(Key("high"))); len(values) != 50 {
+		t.Errorf("expected 50 values, got %d", len(values))
+	}
+	if deleted, err := tree.Delete(Key("high"), Value("id010")); !deleted || err != nil {
+		t.Errorf("expected delete to succeed, got %v, %v", deleted, err)
+	}
+	if deleted, _ := tree.Delete(Key("high"), Value("id010")); deleted {
+		t.Error("expected second delete to fail")
+	}
+	if values := check(tree.Search(Key("high"))); len(values) != 49 {
+		t.Errorf("expected 49 values after delete, got %d", len(values))
+	}
+}
+
+func TestPagedBTreeRangeSearch(t *testing.T) {
+	check := mustValues(t)
+	tree, _ := openTestTree(t, 2)
+	defer tree.Close()
+
+	for i := 0; i < 100; i++ {
+		tree.Insert(Key(fmt.Sprintf("k%03d", i)), Value(fmt.Sprintf("v%d", i)))
+	}
+
+	tests := []struct {
+		name     string
+		values   []Value
+		expected int
+	}{
+		{"gt", check(tree.SearchGreaterThan(Key("k089"))), 10},
+		{"gte", check(tree.SearchGreaterThanOrEqual(Key("k089"))), 11},
+		{"lt", check(tree.SearchLessThan(Key("k010"))), 10},
+		{"lte", check(tree.SearchLessThanOrEqual(Key("k010"))), 11},
+		{"between", check(tree.RangeSearch(Key("k020"), Key("k029"), true, true)), 10},
+		{"in", check(tree.SearchIn([]Key{Key("k001"), Key("k050"), Key("nope")})), 2},
+		{"all", check(tree.GetAllValues()), 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.values) != tt.expected {
+				t.Errorf("expected %d values, got %d", tt.expected, len(tt.values))
+			}
+		})
+	}
+}
+
+func TestPagedBTreeCountAndKeyCounts(t *testing.T) {
+	tree, _ := openTestTree(t, 3)
+	defer tree.Close()
+
+	hosts := map[string]int{"alpha": 5, "beta": 1, "gamma": 12}
+	for host, n := range hosts {
+		for i := 0; i < n; i++ {
+			tree.Insert(Key(host), Value(fmt.Sprintf("%s-%02d", host, i)))
+		}
+	}
+
+	if got, err := tree.Count(nil, nil, false, false); err != nil || got != 18 {
+		t.Errorf("expected total count 18, got %d (%v)", got, err)
+	}
+	if got, err := tree.Count(Key("beta"), nil, false, false); err != nil || got != 12 {
+		t.Errorf("expected 12 values after beta, got %d (%v)", got, err)
+	}
+
+	counts, err := tree.KeyCounts(nil, nil, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 3 {
+		t.Fatalf("expected 3 distinct keys, got %d", len(counts))
+	}
+	for i, host := range []string{"alpha", "beta", "gamma"} {
+		if string(counts[i].Key) != host || counts[i].Count != hosts[host] {
+			t.Errorf("key %d: expected %s x%d, got %s x%d", i, host, hosts[host], counts[i].Key, counts[i].Count)
+		}
+		if string(counts[i].First) != host+"-00" {
+			t.Errorf("key %s: expected first value %s-00, got %s", host, host, counts[i].First)
+		}
+	}
+
+	if counts, _ := tree.KeyCounts(Key("beta"), Key("beta"), true, true); len(counts) != 1 || counts[0].Count != 1 {
+		t.Errorf("expected single beta key, got %+v", counts)
+	}
+}
+
+func TestPagedBTreePersistence(t *testing.T) {
+	check := mustValues(t)
+	tree, path := openTestTree(t, 4)
+	for i := 0; i < 1000; i++ {
+		tree.Insert(Key(fmt.Sprintf("key%04d", i)), Value(fmt.Sprintf("doc%d", i)))
+	}
+	if err := tree.Close(); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+
+	reopened, err := OpenPagedBTree(path, 64)
+	if err != nil {
+		t.Fatalf("reopen error: %v", err)
+	}
+	defer reopened.Close()
+
+	if reopened.GetOrder() != 4 {
+		t.Errorf("expected order from file 4, got %d", reopened.GetOrder())
+	}
+	for i := 0; i < 1000; i += 97 {
+		values := check(reopened.Search(Key(fmt.Sprintf("key%04d", i))))
+		if len(values) != 1 || string(values[0]) != fmt.Sprintf("doc%d", i) {
+			t.Errorf("key%04d: unexpected values %v", i, values)
+		}
+	}
+	if values := check(reopened.GetAllValues()); len(values) != 1000 {
+		t.Errorf("expected 1000 values, got %d", len(values))
+	}
+}
+
+func TestPagedBTreeSmallCacheAndLargeKeys(t *testing.T) {
+	check := mustValues(t)
+	tree, path := openTestTree(t, 8)
+	tree.SetCacheSize(8)
+
+	// ключи больше страницы уходят в цепочку страниц
+	big := strings.Repeat("x", pageSize*2)
+	for i := 0; i < 300; i++ {
+		tree.Insert(Key(fmt.Sprintf("%s%03d", big, i)), Value(fmt.Sprintf("doc%d", i)))
+	}
+	if err := tree.Close(); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+
+	reopened, err := OpenPagedBTree(path, 8)
+	if err != nil {
+		t.Fatalf("reopen error: %v", err)
+	}
+	defer reopened.Close()
+	reopened.SetCacheSize(8)
+
+	values := check(reopened.Search(Key(big + "150")))
+	if len(values) != 1 || string(values[0]) != "doc150" {
+		t.Errorf("unexpected values %v", values)
+	}
+	if values := check(reopened.GetAllValues()); len(values) != 300 {
+		t.Errorf("expected 300 values, got %d", len(values))
+	}
+}
+
+func TestPagedBTreeReadErrorIsReturned(t *testing.T) {
+	tree, _ := openTestTree(t, 4)
+	for i := 0; i < 200; i++ {
+		tree.Insert(Key(fmt.Sprintf("key%03d", i)), Value(fmt.Sprintf("doc%d", i)))
+	}
+	tree.SetCacheSize(8)
+	if err := tree.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// узлы вне пула читаются с диска; закрытый файл — ошибка ввода-вывода
+	tree.pager.file.Close()
+
+	if values, err := tree.GetAllValues(); err == nil {
+		t.Fatalf("expected read error, got %d values", len(values))
+	}
+	if _, err := tree.Count(nil, nil, false, false); err == nil {
+		t.Error("expected read error from Count")
+	}
+	// после ошибки дерево не отдаёт неполные результаты даже из пула
+	if _, err := tree.Search(Key("key000")); err == nil {
+		t.Error("expected sticky error from Search")
+	}
+	// и не принимает записи молча
+	if err := tree.Insert(Key("key999"), Value("doc999")); err == nil {
+		t.Error("expected error from Insert")
+	}
+	if _, err := tree.Delete(Key("key000"), Value("doc0")); err == nil {
+		t.Error("expected error from Delete")
+	}
+}
+
+func TestPagedBTreeInterruptedWriteNeedsRebuild(t *testing.T) {
+	tree, path := openTestTree(t, 4)
+	tree.SetCollation(collation.CaseInsensitive)
+	if err := tree.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.Insert(Key("a"), Value("doc1")); err != nil {
+		t.Fatal(err)
+	}
+	// сбой до Flush: узлы могли быть записаны частично
+	tree.pager.close()
+
+	_, err := OpenPagedBTree(path, 4)
+	var rebuildErr *RebuildError
+	if !errors.As(err, &rebuildErr) {
+		t.Fatalf("expected RebuildError, got %v", err)
+	}
+	if rebuildErr.Collation != collation.CaseInsensitive {
+		t.Errorf("collation lost: %v", rebuildErr.Collation)
+	}
+}
+
+func TestPagedBTreeClosedRejectsReads(t *testing.T) {
+	tree, _ := openTestTree(t, 4)
+	tree.Insert(Key("a"), Value("doc1"))
+	if err := tree.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// индекс удалён или пересобран, пока запрос держал ссылку на дерево
+	if _, err := tree.Search(Key("a")); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed from Search, got %v", err)
+	}
+	if _, err := tree.Count(nil, nil, false, false); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed from Count, got %v", err)
+	}
+	if _, err := tree.KeyCounts(nil, nil, false, false); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed from KeyCounts, got %v", err)
+	}
+	if _, err := tree.Delete(Key("a"), Value("doc1")); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed from Delete, got %v", err)
+	}
+	if err := tree.Insert(Key("b"), Value("doc2")); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed from Insert, got %v", err)
+	}
+	if err := tree.Close(); err != nil {
+		t.Errorf("second close: %v", err)
+	}
+}
+
+func TestPagedBTreeRejectsForeignFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.pidx")
+	tree, err := OpenPagedBTree(path, 3)
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+	tree.pager.file.WriteAt([]byte("garbage!"), pageHeaderSize)
+	tree.pager.close()
+
+	if _, err := OpenPagedBTree(path, 3); err == nil {
+		t.Error("expected error for corrupted meta page")
+	}
+}
+
+func TestPagedBTreeIPPrefixRange(t *testing.T) {
+	tree, _ := openTestTree(t, 4)
+	defer tree.Close()
+
+	// строковый порядок поставил бы 10.0.0.100 перед 10.0.0.20
+	addrs := []string{"9.255.255.255", "10.0.0.20", "10.0.0.100", "10.255.255.255", "11.0.0.0", "::1", "2001:db8::5", "2001:db9::"}
+	for _, a := range addrs {
+		tree.Insert(ValueToKey(a), Value(a))
+	}
+	tree.Insert(ValueToKey("not-an-ip"), Value("not-an-ip"))
+
+	tests := []struct {
+		prefix   string
+		expected []string
+	}{
+		{"10.0.0.0/8", []string{"10.0.0.20", "10.0.0.100", "10.255.255.255"}},
+		{"10.0.0.0/25", []string{"10.0.0.20", "10.0.0.100"}},
+		{"0.0.0.0/0", []string{"9.255.255.255", "10.0.0.20", "10.0.0.100", "10.255.255.255", "11.0.0.0"}},
+		{"2001:db8::/32", []string{"2001:db8::5"}},
+		{"::1/128", []string{"::1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			start, end := PrefixRange(netip.MustParsePrefix(tt.prefix))
+			got := ValuesToStrings(mustValues(t)(tree.RangeSearch(start, end, true, true)))
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNumberKeyOrder(t *testing.T) {
+	// по возрастанию значения, включая отрицательные и целые
+	numbers := []any{math.Inf(-1), -1e9, -100.5, -3, -1.0, -0.001, 0.0, math.Copysign(0, -1), 0.001, 2, 2.5, int64(1 << 40), math.Inf(1)}
+	for i := 1; i < len(numbers); i++ {
+		prev, cur := ValueToKey(numbers[i-1]), ValueToKey(numbers[i])
+		cmp := bytes.Compare(prev, cur)
+		if cmp > 0 || (cmp == 0 && numbers[i-1] != 0.0) {
+			t.Errorf("key of %v must sort before %v", numbers[i-1], numbers[i])
+		}
+	}
+	if !bytes.Equal(ValueToKey(5), ValueToKey(5.0)) {
+		t.Error("int and float64 with the same value must share a key")
+	}
+
+	lo, hi, ok := TypeBounds(ValueToKey(-3.0))
+	if !ok {
+		t.Fatal("expected bounds for number key")
+	}
+	for _, v := range numbers {
+		if key := ValueToKey(v); bytes.Compare(key, lo) < 0 || bytes.Compare(key, hi) > 0 {
+			t.Errorf("number %v outside type bounds", v)
+		}
+	}
+	for _, v := range []any{"text", true, "2024-01-02T09:00:00Z", "10.0.0.1"} {
+		if key := ValueToKey(v); bytes.Compare(key, lo) >= 0 && bytes.Compare(key, hi) <= 0 {
+			t.Errorf("%v inside number bounds", v)
+		}
+	}
+}
+
+func TestPagedBTreeCollation(t *testing.T) {
+	check := mustValues(t)
+	tree, path := openTestTree(t, 4)
+	tree.SetCollation(collation.CaseInsensitive)
+	for _, user := range []string{"root", "Root", "ROOT", "admin"} {
+		tree.Insert(tree.Key(user), Value(user))
+	}
+	// даты и адреса не свёртываются, чтобы сохранить их порядок
+	for _, v := range []string{"2024-01-02T12:00:00Z", "10.0.0.1"} {
+		if key, folded := CollatedKey(v, collation.CaseInsensitive); folded || !reflect.DeepEqual(key, ValueToKey(v)) {
+			t.Errorf("%s: unexpected folded key %v", v, key)
+		}
+	}
+	if err := tree.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenPagedBTree(path, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if reopened.Collation() != collation.CaseInsensitive {
+		t.Fatalf("collation not persisted: %v", reopened.Collation())
+	}
+	got := ValuesToStrings(check(reopened.Search(reopened.Key("rOOt"))))
+	if !reflect.DeepEqual(got, []string{"ROOT", "Root", "root"}) {
+		t.Errorf("expected all spellings of root, got %v", got)
+	}
+}
+
+func BenchmarkPagedBTreeInsert(b *testing.B) {
+	tree, err := OpenPagedBTree(filepath.Join(b.TempDir(), "bench.pidx"), 32)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer tree.Close()
+	for i := 0; i < b.N; i++ {
+		tree.Insert(Key(fmt.Sprintf("key%d", i)), Value(fmt.Sprintf("val%d", i)))
+	}
+}
@@ -0,0 +1,248 @@
+package index
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"nosql_db/internal/collation"
+	"os"
+)
+
+// Файл индекса состоит из страниц фиксированного размера.
+// Страница 0 — мета (корень, число страниц, голова списка свободных страниц,
+// правило сравнения строк, отметка незавершённой записи),
+// остальные — узлы дерева. Узел, не влезающий в одну страницу,
+// продолжается в цепочке страниц через поле next заголовка.
+
+const (
+	pageSize       = 4096
+	pageHeaderSize = 7 // kind (1) + next (4) + used (2)
+	pagePayload    = pageSize - pageHeaderSize
+)
+
+type pageID uint32
+
+// nilPage используется как «нет страницы»: страница 0 всегда занята метой
+const nilPage pageID = 0
+
+const (
+	pageKindMeta byte = 1
+	pageKindNode byte = 2
+	pageKindFree byte = 3
+)
+
+var pagedMagic = []byte("NSIX")
+
+// pagedVersion 2: численные ключи IP-адресов, 3: ключи дат в UTC,
+// 4: упорядоченные ключи чисел. Индексы другой версии не открываются
+// и пересобираются из данных коллекции
+const pagedVersion byte = 4
+
+type pager struct {
+	file      *os.File
+	order     int
+	root      pageID
+	pageCount uint32
+	freeHead  pageID
+	collation collation.Collation
+	modified  bool // на диске есть изменения после последнего Flush
+}
+
+// openPager открывает файл индекса или создаёт новый с пустой метой
+func openPager(path string, order int) (*pager, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	p := &pager{file: file, order: order, pageCount: 1}
+	if info.Size() == 0 {
+		if err := p.writeMeta(); err != nil {
+			file.Close()
+			return nil, err
+		}
+		return p, nil
+	}
+
+	if err := p.readMeta(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// RebuildError файл индекса нельзя использовать: другая версия или запись
+// прервана сбоем. Collation — правило сравнения из его меты, чтобы пересобранный
+// индекс сохранил его
+type RebuildError struct {
+	Reason    string
+	Collation collation.Collation
+}
+
+func (e *RebuildError) Error() string { return e.Reason }
+
+func (p *pager) readMeta() error {
+	kind, _, data, err := p.readPage(0)
+	if err != nil {
+		return fmt.Errorf("read meta page: %w", err)
+	}
+	if kind != pageKindMeta || len(data) < 19 || !bytes.Equal(data[:4], pagedMagic) {
+		return errors.New("not a paged index file")
+	}
+	// байт правила сравнения добавлен позже; в старых файлах его нет — Binary
+	if len(data) > 19 {
+		p.collation = collation.Collation(data[19])
+	}
+	if data[4] != pagedVersion {
+		return &RebuildError{Reason: fmt.Sprintf("unsupported index version %d", data[4]), Collation: p.collation}
+	}
+	if len(data) > 20 && data[20] != 0 {
+		return &RebuildError{Reason: "index was not flushed after last write", Collation: p.collation}
+	}
+	p.order = int(binary.BigEndian.Uint16(data[5:7]))
+	p.root = pageID(binary.BigEndian.Uint32(data[7:11]))
+	p.pageCount = binary.BigEndian.Uint32(data[11:15])
+	p.freeHead = pageID(binary.BigEndian.Uint32(data[15:19]))
+	return nil
+}
+
+func (p *pager) writeMeta() error {
+	data := make([]byte, 21)
+	copy(data, pagedMagic)
+	data[4] = pagedVersion
+	binary.BigEndian.PutUint16(data[5:7], uint16(p.order))
+	binary.BigEndian.PutUint32(data[7:11], uint32(p.root))
+	binary.BigEndian.PutUint32(data[11:15], p.pageCount)
+	binary.BigEndian.PutUint32(data[15:19], uint32(p.freeHead))
+	data[19] = byte(p.collation)
+	if p.modified {
+		data[20] = 1
+	}
+	return p.writePage(0, pageKindMeta, nilPage, data)
+}
+
+// readPage читает страницу и возвращает её тип, следующую страницу цепочки и данные
+func (p *pager) readPage(id pageID) (byte, pageID, []byte, error) {
+	if uint32(id) >= p.pageCount {
+		return 0, nilPage, nil, fmt.Errorf("page %d out of range", id)
+	}
+	buf := make([]byte, pageSize)
+	if _, err := p.file.ReadAt(buf, int64(id)*pageSize); err != nil && !errors.Is(err, io.EOF) {
+		return 0, nilPage, nil, err
+	}
+	used := int(binary.BigEndian.Uint16(buf[5:7]))
+	if used > pagePayload {
+		return 0, nilPage, nil, fmt.Errorf("page %d is corrupted", id)
+	}
+	next := pageID(binary.BigEndian.Uint32(buf[1:5]))
+	return buf[0], next, buf[pageHeaderSize : pageHeaderSize+used], nil
+}
+
+func (p *pager) writePage(id pageID, kind byte, next pageID, data []byte) error {
+	buf := make([]byte, pageSize)
+	buf[0] = kind
+	binary.BigEndian.PutUint32(buf[1:5], uint32(next))
+	binary.BigEndian.PutUint16(buf[5:7], uint16(len(data)))
+	copy(buf[pageHeaderSize:], data)
+	_, err := p.file.WriteAt(buf, int64(id)*pageSize)
+	return err
+}
+
+// allocate выдаёт страницу из списка свободных или дописывает новую в конец файла
+func (p *pager) allocate() (pageID, error) {
+	if p.freeHead != nilPage {
+		id := p.freeHead
+		_, next, _, err := p.readPage(id)
+		if err != nil {
+			return nilPage, err
+		}
+		p.freeHead = next
+		return id, nil
+	}
+	id := pageID(p.pageCount)
+	p.pageCount++
+	return id, nil
+}
+
+// release возвращает страницу в список свободных
+func (p *pager) release(id pageID) error {
+	if err := p.writePage(id, pageKindFree, p.freeHead, nil); err != nil {
+		return err
+	}
+	p.freeHead = id
+	return nil
+}
+
+// readChain собирает данные узла из цепочки страниц
+func (p *pager) readChain(first pageID) ([]byte, []pageID, error) {
+	var data []byte
+	var pages []pageID
+	for id := first; ; {
+		kind, next, chunk, err := p.readPage(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if kind != pageKindNode {
+			return nil, nil, fmt.Errorf("page %d is not a node page", id)
+		}
+		data = append(data, chunk...)
+		pages = append(pages, id)
+		if next == nilPage {
+			return data, pages, nil
+		}
+		if len(pages) > int(p.pageCount) {
+			return nil, nil, fmt.Errorf("page chain from %d is cyclic", first)
+		}
+		id = next
+	}
+}
+
+// writeChain пишет данные узла в его цепочку страниц, докупая или освобождая
+// страницы по необходимости. Первая страница (id узла) не меняется
+func (p *pager) writeChain(pages []pageID, data []byte) ([]pageID, error) {
+	needed := max(1, (len(data)+pagePayload-1)/pagePayload)
+
+	for len(pages) < needed {
+		id, err := p.allocate()
+		if err != nil {
+			return pages, err
+		}
+		pages = append(pages, id)
+	}
+	for len(pages) > needed {
+		last := pages[len(pages)-1]
+		if err := p.release(last); err != nil {
+			return pages, err
+		}
+		pages = pages[:len(pages)-1]
+	}
+
+	for i, id := range pages {
+		start := i * pagePayload
+		end := min(start+pagePayload, len(data))
+		next := nilPage
+		if i+1 < len(pages) {
+			next = pages[i+1]
+		}
+		if err := p.writePage(id, pageKindNode, next, data[start:end]); err != nil {
+			return pages, err
+		}
+	}
+	return pages, nil
+}
+
+func (p *pager) sync() error {
+	return p.file.Sync()
+}
+
+func (p *pager) close() error {
+	return p.file.Close()
+}
@@ -0,0 +1,39 @@
+package index
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+)
+
+// Ключ даты: маркер, секунды с эпохи со сдвигом знака (8 байт) и наносекунды
+// (4 байта). Все даты одной коллекции сортируются хронологически независимо
+// от смещения часового пояса в исходной строке
+const timeKeyMarker = 0x01
+
+const timeKeySize = 1 + 8 + 4
+
+// TimeKey ключ индекса для момента времени
+func TimeKey(t time.Time) Key {
+	key := make(Key, timeKeySize)
+	key[0] = timeKeyMarker
+	binary.BigEndian.PutUint64(key[1:9], uint64(t.Unix())^(1<<63))
+	binary.BigEndian.PutUint32(key[9:], uint32(t.Nanosecond()))
+	return key
+}
+
+// TypeBounds границы пространства ключей того же типа, что key (числа, даты, IPv4, IPv6).
+// Открытый с одной стороны диапазон по числу, дате или адресу ограничивается ими,
+// чтобы не захватить ключи других типов. false — тип без отдельного пространства
+func TypeBounds(key Key) (Key, Key, bool) {
+	switch {
+	case len(key) == numberKeySize && key[0] == numberKeyMarker:
+		return Key{numberKeyMarker}, append(Key{numberKeyMarker}, bytes.Repeat([]byte{0xff}, numberKeySize-1)...), true
+	case len(key) == timeKeySize && key[0] == timeKeyMarker:
+		return Key{timeKeyMarker}, append(Key{timeKeyMarker}, bytes.Repeat([]byte{0xff}, timeKeySize-1)...), true
+	case len(key) == 6 && key[0] == ipKeyMarker && key[1] == ipFamilyV4,
+		len(key) == 18 && key[0] == ipKeyMarker && key[1] == ipFamilyV6:
+		return key[:2:2], append(Key{key[0], key[1]}, bytes.Repeat([]byte{0xff}, len(key)-2)...), true
+	}
+	return nil, nil, false
+}
@@ -1,39 +1,37 @@
 package index
 
 import (
-	"encoding/binary"
 	"fmt"
-	"math"
+	"nosql_db/internal/collation"
+	"nosql_db/internal/datetime"
+	"time"
 )
 
 // ValueToKey конвертирует значение в ключ для b-tree (массив байт)
 func ValueToKey(value any) Key {
 	switch v := value.(type) {
 	case int:
-		buf := make([]byte, 8)
-		binary.BigEndian.PutUint64(buf, uint64(v))
-		return buf
+		return NumberKey(float64(v))
 	case int32:
-		buf := make([]byte, 8)
-		binary.BigEndian.PutUint64(buf, uint64(v))
-		return buf
+		return NumberKey(float64(v))
 	case int64:
-		buf := make([]byte, 8)
-		binary.BigEndian.PutUint64(buf, uint64(v))
-		return buf
+		return NumberKey(float64(v))
 	case float32:
-		// json числа чаще float64
-		buf := make([]byte, 8)
-		binary.BigEndian.PutUint64(buf, math.Float64bits(float64(v)))
-		return buf
+		return NumberKey(float64(v))
 	case float64:
-		// для сортировок float используем побитовое представление
-		buf := make([]byte, 8)
-		binary.BigEndian.PutUint64(buf, math.Float64bits(v))
-		return buf
+		// json числа чаще float64; целые приводятся к нему же, чтобы 5 и 5.0 совпадали
+		return NumberKey(v)
 	case string:
-		// строки просто конвертируем в []byte
+		// даты и IP-адреса кодируются в порядке значений, остальные строки — как []byte
+		if t, ok := datetime.Parse(v); ok {
+			return TimeKey(t)
+		}
+		if addr, ok := parseIP(v); ok {
+			return IPKey(addr)
+		}
 		return []byte(v)
+	case time.Time:
+		return TimeKey(v)
 	case bool:
 		if v {
 			return []byte{1}
@@ -44,6 +42,17 @@ func ValueToKey(value any) Key {
 	}
 }
 
+// CollatedKey ключ значения по правилу сравнения. Для CaseInsensitive строки
+// (кроме дат и адресов) приводятся к свёрнутому регистру; folded — ключ
+// объединяет разные значения, и совпадение по нему нужно перепроверить
+func CollatedKey(value any, c collation.Collation) (Key, bool) {
+	key := ValueToKey(value)
+	if s, ok := value.(string); ok && c == collation.CaseInsensitive && string(key) == s {
+		return Key(collation.Fold(s)), true
+	}
+	return key, false
+}
+
 // ValuesToStrings конвертирует массив value ([]byte) в массив строк (ids)
 func ValuesToStrings(values []Value) []string {
 	result := make([]string, len(values))
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	requests := NewCounterVec("test_requests_total", "Requests.", "command", "status")
+	requests.Inc("find", "success")
+	requests.Inc("find", "success")
+	requests.Add(3, "insert", "error")
+
+	conns := NewGauge("test_open_connections", "Connections.")
+	conns.Inc()
+	conns.Inc()
+	conns.Dec()
+
+	NewGaugeVecFunc("test_collection_documents", "Documents.", "collection", func() map[string]float64 {
+		return map[string]float64{"b": 2, "a": 1}
+	})
+
+	latency := NewHistogramVec("test_duration_seconds", "Latency.", []float64{0.1, 1}, "command")
+	latency.Observe(0.05, "find")
+	latency.Observe(0.5, "find")
+	latency.Observe(3, "find")
+
+	rec := httptest.NewRecorder()
+	Default.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	out := rec.Body.String()
+
+	tests := []string{
+		"# TYPE test_requests_total counter",
+		`test_requests_total{command="find",status="success"} 2`,
+		`test_requests_total{command="insert",status="error"} 3`,
+		"# TYPE test_open_connections gauge",
+		"test_open_connections 1",
+		"test_collection_documents{collection=\"a\"} 1\ntest_collection_documents{collection=\"b\"} 2",
+		"# TYPE test_duration_seconds histogram",
+		`test_duration_seconds_bucket{command="find",le="0.1"} 1`,
+		`test_duration_seconds_bucket{command="find",le="1"} 2`,
+		`test_duration_seconds_bucket{command="find",le="+Inf"} 3`,
+		`test_duration_seconds_sum{command="find"} 3.55`,
+		`test_duration_seconds_count{command="find"} 3`,
+	}
+	for _, want := range tests {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q\n%s", want, out)
+		}
+	}
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	c := NewCounterVec("test_escaped_total", "Escaped.", "collection")
+	c.Inc(`we"ird`)
+
+	if got := c.Value(`we"ird`); got != 1 {
+		t.Errorf("expected 1, got %v", got)
+	}
+
+	var b strings.Builder
+	c.write(&b)
+	if !strings.Contains(b.String(), `test_escaped_total{collection="we\"ird"} 1`) {
+		t.Errorf("label not escaped:\n%s", b.String())
+	}
+}
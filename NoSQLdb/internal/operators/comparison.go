@@ -1,27 +1,42 @@
 package operators
 
 import (
+	"cmp"
 	"fmt"
+	"nosql_db/internal/collation"
+	"nosql_db/internal/datetime"
 	"reflect"
+	"strings"
+	"unicode/utf8"
 )
 
-// CompareEq возвращает true, если fieldValue == queryValue
+// CompareEq сравнивает значения; две даты равны, если совпадают моменты времени
+// (смещение часового пояса не важно)
 func CompareEq(fieldValue, queryValue any) bool {
+	if c, ok := datetime.Compare(fieldValue, queryValue); ok {
+		return c == 0
+	}
 	return reflect.DeepEqual(fieldValue, queryValue)
 }
 
-// CompareGt возвращает true, если fieldValue > queryValue
+// CompareGt возвращает true, если fieldValue > queryValue (числа или даты)
 func CompareGt(fieldValue, queryValue any) bool {
-	return compareNumeric(fieldValue, queryValue, func(a, b float64) bool {
-		return a > b
-	})
+	return compareOrdered(fieldValue, queryValue, func(c int) bool { return c > 0 })
+}
+
+// CompareGte возвращает true, если fieldValue >= queryValue (числа или даты)
+func CompareGte(fieldValue, queryValue any) bool {
+	return compareOrdered(fieldValue, queryValue, func(c int) bool { return c >= 0 })
 }
 
-// CompareLt возвращает true, если fieldValue < queryValue
+// CompareLt возвращает true, если fieldValue < queryValue (числа или даты)
 func CompareLt(fieldValue, queryValue any) bool {
-	return compareNumeric(fieldValue, queryValue, func(a, b float64) bool {
-		return a < b
-	})
+	return compareOrdered(fieldValue, queryValue, func(c int) bool { return c < 0 })
+}
+
+// CompareLte возвращает true, если fieldValue <= queryValue (числа или даты)
+func CompareLte(fieldValue, queryValue any) bool {
+	return compareOrdered(fieldValue, queryValue, func(c int) bool { return c <= 0 })
 }
 
 // CompareLike возвращает true, если fieldValue соответствует шаблону like
@@ -35,6 +50,27 @@ func CompareLike(fieldValue, pattern any) bool {
 	return matchLikePattern(fieldStr, patternStr)
 }
 
+// CompareIEq сравнивает строки без учёта регистра; для остальных типов — как $eq
+func CompareIEq(fieldValue, queryValue any) bool {
+	fieldStr, ok1 := fieldValue.(string)
+	queryStr, ok2 := queryValue.(string)
+	if !ok1 || !ok2 {
+		return CompareEq(fieldValue, queryValue)
+	}
+	return collation.CaseInsensitive.Equal(fieldStr, queryStr)
+}
+
+// CompareILike $like без учёта регистра
+func CompareILike(fieldValue, pattern any) bool {
+	fieldStr, ok1 := fieldValue.(string)
+	patternStr, ok2 := pattern.(string)
+	if !ok1 || !ok2 {
+		return false
+	}
+
+	return matchLikePattern(collation.Fold(fieldStr), collation.Fold(patternStr))
+}
+
 // CompareIn возвращает true, если fieldValue содержится в values
 func CompareIn(fieldValue any, values any) bool {
 	valuesSlice, ok := values.([]any)
@@ -43,21 +79,24 @@ func CompareIn(fieldValue any, values any) bool {
 	}
 
 	for _, v := range valuesSlice {
-		if reflect.DeepEqual(fieldValue, v) {
+		if CompareEq(fieldValue, v) {
 			return true
 		}
 	}
 	return false
 }
 
-// compareNumeric вспомогательная функция для сравнения числовых значений
-func compareNumeric(a, b any, cmp func(float64, float64) bool) bool {
+// compareOrdered сравнивает числа или даты; значения других типов не упорядочены
+func compareOrdered(a, b any, accept func(int) bool) bool {
 	aNum, err1 := toFloat64(a)
 	bNum, err2 := toFloat64(b)
-	if err1 != nil || err2 != nil {
-		return false
+	if err1 == nil && err2 == nil {
+		return accept(cmp.Compare(aNum, bNum))
 	}
-	return cmp(aNum, bNum)
+	if c, ok := datetime.Compare(a, b); ok {
+		return accept(c)
+	}
+	return false
 }
 
 // toFloat64 вспомогательная функция для конвертации в float64
@@ -84,54 +123,84 @@ func toFloat64(val any) (float64, error) {
 	}
 }
 
-// matchLikePattern вспомогательная функция для сопоставления строки с шаблоном like
+// matchLikePattern сопоставляет строку с шаблоном like без возвратов:
+// шаблон делится по % на сегменты, первый сегмент должен совпасть с началом строки,
+// последний — с концом, средние ищутся слева направо по самому раннему вхождению.
+// Сегмент без _ ищется за линейное время, шаблоны вида %a%a%a%b не дают
+// экспоненциального перебора. _ соответствует одному символу (руне)
 func matchLikePattern(str, pattern string) bool {
-	return matchLikeHelper(str, pattern, 0, 0)
-}
-
-// matchLikeHelper функция для сопоставления строки с like
-func matchLikeHelper(str, pattern string, strIdx, patIdx int) bool {
-
-	// strIdx и patIdx - текущие индексы в строке и паттерне
-	if strIdx == len(str) && patIdx == len(pattern) {
-		return true
+	segments := strings.Split(pattern, "%")
+	if len(segments) == 1 {
+		n, ok := matchSegmentPrefix(str, pattern)
+		return ok && n == len(str)
 	}
 
-	if patIdx == len(pattern) {
+	first, last := segments[0], segments[len(segments)-1]
+	n, ok := matchSegmentPrefix(str, first)
+	if !ok {
 		return false
 	}
+	str = str[n:]
 
-	// обработка %
-	if pattern[patIdx] == '%' {
-		for patIdx < len(pattern) && pattern[patIdx] == '%' {
-			patIdx++
+	for _, segment := range segments[1 : len(segments)-1] {
+		if segment == "" {
+			continue
 		}
-
-		if patIdx == len(pattern) {
-			return true
+		end := indexSegment(str, segment)
+		if end < 0 {
+			return false
 		}
+		str = str[end:]
+	}
 
-		for i := strIdx; i <= len(str); i++ {
-			if matchLikeHelper(str, pattern, i, patIdx) {
-				return true
-			}
+	// последний сегмент прикладывается к концу оставшейся строки
+	need := utf8.RuneCountInString(last)
+	start := len(str)
+	for i := 0; i < need; i++ {
+		if start == 0 {
+			return false
 		}
-		return false
+		_, size := utf8.DecodeLastRuneInString(str[:start])
+		start -= size
 	}
+	n, ok = matchSegmentPrefix(str[start:], last)
+	return ok && n == len(str)-start
+}
 
-	if strIdx == len(str) {
-		return false
+// matchSegmentPrefix проверяет, что строка начинается с сегмента (без %),
+// и возвращает длину совпавшего префикса в байтах
+func matchSegmentPrefix(str, segment string) (int, bool) {
+	pos := 0
+	for _, p := range segment {
+		if pos >= len(str) {
+			return 0, false
+		}
+		r, size := utf8.DecodeRuneInString(str[pos:])
+		if p != '_' && p != r {
+			return 0, false
+		}
+		pos += size
 	}
+	return pos, true
+}
 
-	// обработка _
-	if pattern[patIdx] == '_' {
-		return matchLikeHelper(str, pattern, strIdx+1, patIdx+1)
+// indexSegment ищет самое раннее вхождение сегмента и возвращает позицию сразу после него, -1 если нет
+func indexSegment(str, segment string) int {
+	if !strings.Contains(segment, "_") {
+		i := strings.Index(str, segment)
+		if i < 0 {
+			return -1
+		}
+		return i + len(segment)
 	}
 
-	// обработка обычных символов
-	if str[strIdx] == pattern[patIdx] {
-		return matchLikeHelper(str, pattern, strIdx+1, patIdx+1)
+	// сегмент с _ проверяется с каждой позиции: O(len(str)*len(segment))
+	for start := 0; start < len(str); {
+		if n, ok := matchSegmentPrefix(str[start:], segment); ok {
+			return start + n
+		}
+		_, size := utf8.DecodeRuneInString(str[start:])
+		start += size
 	}
-
-	return false
+	return -1
 }
@@ -1,6 +1,10 @@
 package operators
 
-import "testing"
+import (
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestCompareEq(t *testing.T) {
 	tests := []struct {
@@ -118,6 +122,17 @@ func TestCompareLike(t *testing.T) {
 		{"no match", "hello", "world", false},
 		{"non-string value", 123, "123", false},
 		{"non-string pattern", "hello", 123, false},
+		{"only percent", "anything", "%", true},
+		{"empty value and pattern", "", "", true},
+		{"empty value with percent", "", "%%", true},
+		{"underscore needs a char", "", "_", false},
+		{"prefix and suffix overlap", "ab", "ab%b", false},
+		{"middle segments in order", "xaybzc", "x%a%b%c", true},
+		{"middle segments out of order", "xbyazc", "x%a%b%c", false},
+		{"underscore in middle segment", "user=root port=22", "%port=_2", true},
+		{"underscore matches rune", "пароль", "п_роль", true},
+		{"suffix with underscore", "login failed", "%fail_d", true},
+		{"repeated segments", "aaa", "%a%a%a%a%", false},
 	}
 
 	for _, tt := range tests {
@@ -130,6 +145,104 @@ func TestCompareLike(t *testing.T) {
 	}
 }
 
+func TestCompareNetwork(t *testing.T) {
+	tests := []struct {
+		name     string
+		operator string
+		value    any
+		arg      any
+		expected bool
+	}{
+		{"ipv4 in network", "$cidr", "10.1.2.3", "10.0.0.0/8", true},
+		{"ipv4 outside network", "$cidr", "11.0.0.1", "10.0.0.0/8", false},
+		{"one of networks", "$cidr", "192.168.1.7", []any{"10.0.0.0/8", "192.168.1.0/24"}, true},
+		{"single address", "$cidr", "192.168.1.7", []any{"192.168.1.7"}, true},
+		{"ipv6 in network", "$cidr", "2001:db8::1", "2001:db8::/32", true},
+		{"mapped ipv6 not in ipv4 network", "$cidr", "::ffff:10.0.0.1", "10.0.0.0/8", false},
+		{"unmasked network", "$cidr", "10.1.2.3", "10.1.2.200/24", true},
+		{"invalid network", "$cidr", "10.1.2.3", "10.0.0.0/33", false},
+		{"not an address", "$cidr", "host-1", "10.0.0.0/8", false},
+		{"non-string value", "$cidr", 10, "10.0.0.0/8", false},
+		{"within range", "$ipRange", "10.0.0.50", []any{"10.0.0.10", "10.0.0.100"}, true},
+		{"range bounds inclusive", "$ipRange", "10.0.0.100", []any{"10.0.0.10", "10.0.0.100"}, true},
+		{"reversed bounds", "$ipRange", "10.0.0.50", []any{"10.0.0.100", "10.0.0.10"}, true},
+		{"outside range", "$ipRange", "10.0.0.9", []any{"10.0.0.10", "10.0.0.100"}, false},
+		{"numeric not string order", "$ipRange", "10.0.0.99", []any{"10.0.0.10", "10.0.0.100"}, true},
+		{"mixed families", "$ipRange", "10.0.0.50", []any{"10.0.0.10", "::1"}, false},
+		{"malformed range", "$ipRange", "10.0.0.50", []any{"10.0.0.10"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := applyOperator(tt.value, tt.operator, tt.arg); result != tt.expected {
+				t.Errorf("%s(%v, %v) = %v, expected %v", tt.operator, tt.value, tt.arg, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCompareCaseInsensitive(t *testing.T) {
+	tests := []struct {
+		name     string
+		operator string
+		value    any
+		arg      any
+		expected bool
+	}{
+		{"ieq different case", "$ieq", "Root", "root", true},
+		{"ieq unicode", "$ieq", "АДМИН", "админ", true},
+		{"ieq different value", "$ieq", "root", "admin", false},
+		{"ieq number", "$ieq", 5.0, 5.0, true},
+		{"ieq string vs number", "$ieq", "5", 5.0, false},
+		{"eq stays case sensitive", "$eq", "Root", "root", false},
+		{"ilike", "$ilike", "Failed password for ROOT", "%for root%", true},
+		{"ilike pattern case", "$ilike", "sshd", "SSH_", true},
+		{"ilike no match", "$ilike", "sudo", "%SSH%", false},
+		{"ilike non-string", "$ilike", 1.0, "%", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := applyOperator(tt.value, tt.operator, tt.arg); result != tt.expected {
+				t.Errorf("%s(%v, %v) = %v, expected %v", tt.operator, tt.value, tt.arg, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCompareDates(t *testing.T) {
+	ref := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		operator string
+		value    any
+		arg      any
+		expected bool
+	}{
+		{"eq across offsets", "$eq", "2024-01-02T15:00:00+03:00", "2024-01-02T12:00:00Z", true},
+		{"eq resolved date", "$eq", "2024-01-02T12:00:00Z", ref, true},
+		{"eq date wrapper", "$eq", "2024-01-02T12:00:00Z", map[string]any{"$date": "2024-01-02T12:00:00Z"}, true},
+		{"gt by instant not string", "$gt", "2024-01-02T12:30:00+03:00", "2024-01-02T09:00:00Z", true},
+		{"lt across offsets", "$lt", "2024-01-02T14:00:00+03:00", ref, true},
+		{"gte inclusive", "$gte", "2024-01-02T12:00:00Z", ref, true},
+		{"lte inclusive", "$lte", "2024-01-02T12:00:00.000Z", ref, true},
+		{"fractional seconds", "$gt", "2024-01-02T12:00:00.001Z", ref, true},
+		{"in matches instant", "$in", "2024-01-02T15:00:00+03:00", []any{ref}, true},
+		{"date vs number", "$gt", "2024-01-02T12:00:00Z", 5.0, false},
+		{"not a date", "$lt", "yesterday", ref, false},
+		{"gte numbers", "$gte", 5.0, 5.0, true},
+		{"lte numbers", "$lte", 6.0, 5.0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := applyOperator(tt.value, tt.operator, tt.arg); result != tt.expected {
+				t.Errorf("%s(%v, %v) = %v, expected %v", tt.operator, tt.value, tt.arg, result, tt.expected)
+			}
+		})
+	}
+}
+
 func TestToFloat64(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -173,6 +286,23 @@ func BenchmarkCompareEq(b *testing.B) {
 	}
 }
 
+func TestCompareLikePathologicalPattern(t *testing.T) {
+	value := strings.Repeat("a", 10000)
+	pattern := strings.Repeat("%a", 50) + "%b"
+
+	done := make(chan bool)
+	go func() { done <- CompareLike(value, pattern) }()
+
+	select {
+	case matched := <-done:
+		if matched {
+			t.Error("expected no match")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("like matching did not finish in time")
+	}
+}
+
 func BenchmarkCompareLike(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		CompareLike("hello world this is a test string", "%test%")
@@ -1,7 +1,8 @@
 package operators
 
 import (
-	"fmt"
+	"log/slog"
+	"nosql_db/internal/datetime"
 )
 
 // MatchDocument проверяет, соответствует ли документ условиям запроса
@@ -36,6 +37,11 @@ func matchField(doc map[string]any, field string, condition any) bool {
 		return false
 	}
 
+	// обёртка {"$date": ...} — значение, а не операторы
+	if t, ok := datetime.Parse(condition); ok {
+		return CompareEq(fieldValue, t)
+	}
+
 	// если condition - это map, значит это операторы сравнения
 	if condMap, ok := condition.(map[string]any); ok {
 		for operator, value := range condMap {
@@ -56,14 +62,26 @@ func applyOperator(fieldValue any, operator string, queryValue any) bool {
 		return CompareEq(fieldValue, queryValue)
 	case "$gt":
 		return CompareGt(fieldValue, queryValue)
+	case "$gte":
+		return CompareGte(fieldValue, queryValue)
 	case "$lt":
 		return CompareLt(fieldValue, queryValue)
+	case "$lte":
+		return CompareLte(fieldValue, queryValue)
+	case "$ieq":
+		return CompareIEq(fieldValue, queryValue)
 	case "$like":
 		return CompareLike(fieldValue, queryValue)
+	case "$ilike":
+		return CompareILike(fieldValue, queryValue)
 	case "$in":
 		return CompareIn(fieldValue, queryValue)
+	case "$cidr":
+		return CompareCIDR(fieldValue, queryValue)
+	case "$ipRange":
+		return CompareIPRange(fieldValue, queryValue)
 	default:
-		fmt.Printf("Warning: unknown operator %s\n", operator)
+		slog.Warn("unknown operator", "operator", operator)
 		return false
 	}
 }
@@ -0,0 +1,105 @@
+package operators
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// CompareCIDR проверяет, что адрес входит в одну из сетей. Сети задаются
+// строкой или массивом строк в нотации CIDR; адрес без длины — сеть из одного адреса.
+// IPv4 и IPv6 не смешиваются: ::ffff:10.0.0.1 не входит в 10.0.0.0/8
+func CompareCIDR(fieldValue, networks any) bool {
+	addr, ok := parseAddr(fieldValue)
+	if !ok {
+		return false
+	}
+	prefixes, err := ParseNetworks(networks)
+	if err != nil {
+		return false
+	}
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// CompareIPRange проверяет, что адрес лежит между границами [from, to] включительно
+func CompareIPRange(fieldValue, bounds any) bool {
+	addr, ok := parseAddr(fieldValue)
+	if !ok {
+		return false
+	}
+	from, to, err := ParseIPRange(bounds)
+	if err != nil {
+		return false
+	}
+	return addr.BitLen() == from.BitLen() && addr.Compare(from) >= 0 && addr.Compare(to) <= 0
+}
+
+// ParseNetworks разбирает аргумент $cidr в список сетей
+func ParseNetworks(networks any) ([]netip.Prefix, error) {
+	var items []any
+	switch v := networks.(type) {
+	case string:
+		items = []any{v}
+	case []any:
+		items = v
+	default:
+		return nil, fmt.Errorf("$cidr expects a network or an array of networks, got %T", networks)
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("$cidr expects at least one network")
+	}
+
+	prefixes := make([]netip.Prefix, 0, len(items))
+	for _, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("$cidr network must be a string, got %T", item)
+		}
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(s)
+			if addrErr != nil || addr.Zone() != "" {
+				return nil, fmt.Errorf("invalid network %q", s)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// ParseIPRange разбирает аргумент $ipRange: массив из двух адресов одного семейства
+func ParseIPRange(bounds any) (netip.Addr, netip.Addr, error) {
+	pair, ok := bounds.([]any)
+	if !ok || len(pair) != 2 {
+		return netip.Addr{}, netip.Addr{}, fmt.Errorf("$ipRange expects [from, to]")
+	}
+	from, ok1 := parseAddr(pair[0])
+	to, ok2 := parseAddr(pair[1])
+	if !ok1 || !ok2 {
+		return netip.Addr{}, netip.Addr{}, fmt.Errorf("$ipRange bounds must be IP addresses")
+	}
+	if from.BitLen() != to.BitLen() {
+		return netip.Addr{}, netip.Addr{}, fmt.Errorf("$ipRange bounds must be of the same address family")
+	}
+	if from.Compare(to) > 0 {
+		from, to = to, from
+	}
+	return from, to, nil
+}
+
+func parseAddr(value any) (netip.Addr, bool) {
+	s, ok := value.(string)
+	if !ok {
+		return netip.Addr{}, false
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil || addr.Zone() != "" {
+		return netip.Addr{}, false
+	}
+	return addr, true
+}
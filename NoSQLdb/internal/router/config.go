@@ -0,0 +1,93 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Стратегии распределения документов коллекции по шардам
+const (
+	StrategyHash  = "hash"  // хэш значения ключа по модулю числа шардов
+	StrategyRange = "range" // диапазоны значений ключа между границами bounds
+)
+
+// Config описание кластера для режима маршрутизатора (файл DB_ROUTER_CONFIG):
+//
+//	{
+//	  "shards": ["127.0.0.1:5141", "127.0.0.1:5142"],
+//	  "collections": {
+//	    "siem_events": {"key": "agent_id"},
+//	    "archive":     {"key": "timestamp", "strategy": "range", "bounds": ["2025-01-01T00:00:00Z"]}
+//	  }
+//	}
+//
+// Коллекции без описания не делятся и целиком хранятся на одном шарде,
+// выбранном по хэшу имени коллекции
+type Config struct {
+	Shards      []string                    `json:"shards"`
+	Collections map[string]CollectionConfig `json:"collections"`
+}
+
+// CollectionConfig правило шардирования коллекции
+type CollectionConfig struct {
+	Key      string `json:"key"`      // поле-ключ шардирования, обязательно в каждом документе
+	Strategy string `json:"strategy"` // hash (по умолчанию) или range
+	Bounds   []any  `json:"bounds"`   // для range: len(shards)-1 возрастающих границ; шард i хранит значения < bounds[i]
+}
+
+// LoadConfig читает и проверяет конфигурацию маршрутизатора
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read router config: %w", err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse router config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate проверяет список шардов и правила коллекций
+func (c *Config) Validate() error {
+	if len(c.Shards) == 0 {
+		return fmt.Errorf("router config: no shards")
+	}
+	seen := make(map[string]bool, len(c.Shards))
+	for _, addr := range c.Shards {
+		if seen[addr] {
+			return fmt.Errorf("router config: duplicate shard %s", addr)
+		}
+		seen[addr] = true
+	}
+
+	for name, coll := range c.Collections {
+		if coll.Key == "" {
+			return fmt.Errorf("router config: collection %s: key is required", name)
+		}
+		switch coll.Strategy {
+		case "", StrategyHash:
+			if len(coll.Bounds) > 0 {
+				return fmt.Errorf("router config: collection %s: bounds are only used by the range strategy", name)
+			}
+		case StrategyRange:
+			if len(coll.Bounds) != len(c.Shards)-1 {
+				return fmt.Errorf("router config: collection %s: expected %d bounds for %d shards, got %d",
+					name, len(c.Shards)-1, len(c.Shards), len(coll.Bounds))
+			}
+			for i := 1; i < len(coll.Bounds); i++ {
+				order, ok := compareValues(coll.Bounds[i-1], coll.Bounds[i])
+				if !ok || order >= 0 {
+					return fmt.Errorf("router config: collection %s: bounds must be comparable and strictly increasing", name)
+				}
+			}
+		default:
+			return fmt.Errorf("router config: collection %s: unknown strategy %q", name, coll.Strategy)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,462 @@
+// Package router режим маршрутизатора: принимает те же запросы, что и узел
+// NoSQLdb, распределяет документы коллекций по нескольким узлам (шардам)
+// по ключу шардирования, рассылает чтения на нужные шарды и объединяет ответы
+package router
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"nosql_db/client"
+	"nosql_db/internal/api"
+	"nosql_db/internal/datetime"
+	"nosql_db/internal/index"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Router безопасен для использования из нескольких горутин
+type Router struct {
+	// QueryTimeout время выполнения запроса на всех шардах, 0 — без ограничения
+	QueryTimeout time.Duration
+	// MaxResultDocs документов в объединённом результате find, 0 — без ограничения
+	MaxResultDocs int
+
+	addrs       []string
+	shards      []*client.Client
+	collections map[string]*sharding
+}
+
+// New создаёт маршрутизатор; соединения с шардами открываются по требованию
+func New(cfg *Config, opts client.Options) (*Router, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	r := &Router{
+		addrs:       cfg.Shards,
+		collections: make(map[string]*sharding, len(cfg.Collections)),
+	}
+	for _, addr := range cfg.Shards {
+		r.shards = append(r.shards, client.New(addr, opts))
+	}
+	for name, coll := range cfg.Collections {
+		strategy := coll.Strategy
+		if strategy == "" {
+			strategy = StrategyHash
+		}
+		r.collections[name] = &sharding{key: coll.Key, strategy: strategy, bounds: coll.Bounds, shards: len(cfg.Shards)}
+	}
+	return r, nil
+}
+
+// Close закрывает соединения с шардами
+func (r *Router) Close() error {
+	var errs []error
+	for _, c := range r.shards {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// placement шарды, на которых хранится коллекция
+func (r *Router) placement(collection string) []int {
+	if s, ok := r.collections[collection]; ok {
+		return allShards(s.shards)
+	}
+	return []int{homeShard(collection, len(r.shards))}
+}
+
+// targets шарды, которые нужно опросить для запроса к коллекции
+func (r *Router) targets(collection string, query map[string]any) []int {
+	if s, ok := r.collections[collection]; ok {
+		return s.targets(query)
+	}
+	return r.placement(collection)
+}
+
+// HandleRequest выполняет запрос на шардах и возвращает объединённый ответ
+func (r *Router) HandleRequest(req api.Request) api.Response {
+	ctx := context.Background()
+	if r.QueryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.QueryTimeout)
+		defer cancel()
+	}
+
+	resp := r.dispatch(ctx, req)
+	if resp.Status == api.StatusError {
+		slog.Debug("routed request failed", "operation", req.Command, "database", req.Database, "error", resp.Message)
+	}
+	return resp
+}
+
+func (r *Router) dispatch(ctx context.Context, req api.Request) api.Response {
+	if req.Command == api.CmdListCollections {
+		return r.unionNames(ctx, allShards(len(r.shards)), req)
+	}
+	if req.Database == "" {
+		return errorResponse("database name is required")
+	}
+
+	// $now вычисляется один раз, чтобы все шарды сравнивали с одним моментом
+	switch req.Command {
+	case api.CmdFind, api.CmdCount, api.CmdDistinct, api.CmdDelete:
+		query, err := datetime.ResolveQuery(req.Query, time.Now())
+		if err != nil {
+			return errorResponse(fmt.Sprintf("invalid query: %v", err))
+		}
+		req.Query = query
+	}
+
+	switch req.Command {
+	case api.CmdInsert:
+		return r.insert(ctx, req)
+	case api.CmdFind:
+		return r.find(ctx, req)
+	case api.CmdCount, api.CmdDelete:
+		return r.sumCounts(ctx, req)
+	case api.CmdDistinct:
+		return r.distinct(ctx, req)
+	case api.CmdCreateIndex, api.CmdDropIndex, api.CmdRebuildIndexes, api.CmdSetSchema:
+		return r.broadcast(ctx, req)
+	case api.CmdCreateCapped:
+		// пределы действуют на каждом шарде отдельно
+		return r.broadcast(ctx, req)
+	case api.CmdGetSchema, api.CmdListIndexes:
+		// схема и индексы одинаковы на всех шардах коллекции
+		resps, err := r.fanout(ctx, r.placement(req.Database)[:1], req)
+		if err != nil {
+			return errorResponse(err.Error())
+		}
+		return resps[0]
+	case api.CmdListFields:
+		return r.unionNames(ctx, r.placement(req.Database), req)
+	default:
+		return errorResponse("unknown command")
+	}
+}
+
+// fanout выполняет req на шардах ids параллельно; ответы в порядке ids.
+// Ошибка — первый шард, не ответивший или ответивший статусом error
+func (r *Router) fanout(ctx context.Context, ids []int, req api.Request) ([]api.Response, error) {
+	resps := make([]api.Response, len(ids))
+	errs := make([]error, len(ids))
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := r.shards[id].Do(ctx, req)
+			if err == nil && resp.Status == api.StatusError {
+				err = errors.New(resp.Message)
+			}
+			if err != nil {
+				errs[i] = fmt.Errorf("shard %s: %w", r.addrs[id], err)
+			}
+			resps[i] = resp
+		}()
+	}
+	wg.Wait()
+	return resps, errors.Join(errs...)
+}
+
+func (r *Router) find(ctx context.Context, req api.Request) api.Response {
+	resps, err := r.fanout(ctx, r.targets(req.Database, req.Query), req)
+	if err != nil {
+		return errorResponse(err.Error())
+	}
+	var docs []map[string]any
+	for _, resp := range resps {
+		docs = append(docs, resp.Data...)
+	}
+	if r.MaxResultDocs > 0 && len(docs) > r.MaxResultDocs {
+		return errorResponse(fmt.Sprintf("query matches more than %d documents, narrow the query", r.MaxResultDocs))
+	}
+	return api.Response{Status: api.StatusSuccess, Data: docs, Count: len(docs)}
+}
+
+// sumCounts count и delete: счётчики шардов складываются
+func (r *Router) sumCounts(ctx context.Context, req api.Request) api.Response {
+	resps, err := r.fanout(ctx, r.targets(req.Database, req.Query), req)
+	if err != nil {
+		return errorResponse(err.Error())
+	}
+	total := 0
+	for _, resp := range resps {
+		total += resp.Count
+	}
+	message := fmt.Sprintf("%d document(s)", total)
+	if req.Command == api.CmdDelete {
+		message = fmt.Sprintf("Deleted %d document(s)", total)
+	}
+	return api.Response{Status: api.StatusSuccess, Message: message, Count: total}
+}
+
+// distinct объединяет уникальные значения шардов в порядке ключей индекса
+func (r *Router) distinct(ctx context.Context, req api.Request) api.Response {
+	if req.Field == "" {
+		return errorResponse("field is required for distinct")
+	}
+	resps, err := r.fanout(ctx, r.targets(req.Database, req.Query), req)
+	if err != nil {
+		return errorResponse(err.Error())
+	}
+
+	type entry struct {
+		key   index.Key
+		value any
+	}
+	seen := make(map[string]bool)
+	var entries []entry
+	for _, resp := range resps {
+		for _, value := range resp.Values {
+			key := index.ValueToKey(value)
+			if seen[string(key)] {
+				continue
+			}
+			seen[string(key)] = true
+			entries = append(entries, entry{key: key, value: value})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+	values := make([]any, len(entries))
+	for i, e := range entries {
+		values[i] = e.value
+	}
+	return api.Response{Status: api.StatusSuccess, Values: values, Count: len(values)}
+}
+
+// broadcast команды изменения индексов и схемы выполняются на всех шардах коллекции
+func (r *Router) broadcast(ctx context.Context, req api.Request) api.Response {
+	ids := r.placement(req.Database)
+	resps, err := r.fanout(ctx, ids, req)
+	if err != nil {
+		return errorResponse(err.Error())
+	}
+	return api.Response{
+		Status:  api.StatusSuccess,
+		Message: fmt.Sprintf("%s (%d shard(s))", resps[0].Message, len(ids)),
+	}
+}
+
+// unionNames объединяет списки имён (коллекции, поля) с нескольких шардов
+func (r *Router) unionNames(ctx context.Context, ids []int, req api.Request) api.Response {
+	resps, err := r.fanout(ctx, ids, req)
+	if err != nil {
+		return errorResponse(err.Error())
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for _, resp := range resps {
+		for _, v := range resp.Values {
+			if name, ok := v.(string); ok && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	values := make([]any, len(names))
+	for i, name := range names {
+		values[i] = name
+	}
+	return api.Response{
+		Status:  api.StatusSuccess,
+		Message: fmt.Sprintf("%d name(s)", len(names)),
+		Values:  values,
+		Count:   len(values),
+	}
+}
+
+// insert раскладывает документы по шардам и собирает ответы в порядке запроса.
+// Документы без ключа шардирования и документы недоступного шарда попадают в Errors
+func (r *Router) insert(ctx context.Context, req api.Request) api.Response {
+	if len(req.Data) == 0 {
+		return errorResponse("no data provided for insert")
+	}
+
+	ids := make([]string, len(req.Data))
+	var docErrors []api.DocumentError
+	groups := make(map[int][]int) // шард -> позиции документов в req.Data
+
+	s, sharded := r.collections[req.Database]
+	for i, doc := range req.Data {
+		if !sharded {
+			shard := homeShard(req.Database, len(r.shards))
+			groups[shard] = append(groups[shard], i)
+			continue
+		}
+		value, ok := doc[s.key]
+		if !ok {
+			docErrors = append(docErrors, api.DocumentError{Index: i, Reasons: []string{fmt.Sprintf("missing shard key '%s'", s.key)}})
+			continue
+		}
+		shard, err := s.shardOf(value)
+		if err != nil {
+			docErrors = append(docErrors, api.DocumentError{Index: i, Reasons: []string{err.Error()}})
+			continue
+		}
+		groups[shard] = append(groups[shard], i)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	inserted := 0
+	for shard, positions := range groups {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sub := req
+			sub.Data = make([]map[string]any, len(positions))
+			for j, pos := range positions {
+				sub.Data[j] = req.Data[pos]
+			}
+			resp, err := r.shards[shard].Do(ctx, sub)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				reason := fmt.Sprintf("shard %s: %v", r.addrs[shard], err)
+				for _, pos := range positions {
+					docErrors = append(docErrors, api.DocumentError{Index: pos, Reasons: []string{reason}})
+				}
+				return
+			}
+			inserted += resp.Count
+			for j, id := range resp.IDs {
+				if j < len(positions) {
+					ids[positions[j]] = id
+				}
+			}
+			for _, de := range resp.Errors {
+				if de.Index >= 0 && de.Index < len(positions) {
+					docErrors = append(docErrors, api.DocumentError{Index: positions[de.Index], Reasons: de.Reasons})
+				}
+			}
+			if resp.Status == api.StatusError && len(resp.Errors) == 0 {
+				// отказ без разбивки по документам (например, ошибка сохранения)
+				for _, pos := range positions {
+					docErrors = append(docErrors, api.DocumentError{Index: pos, Reasons: []string{resp.Message}})
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(docErrors, func(i, j int) bool { return docErrors[i].Index < docErrors[j].Index })
+	status := api.StatusSuccess
+	switch {
+	case len(docErrors) == len(req.Data):
+		status = api.StatusError
+	case len(docErrors) > 0:
+		status = api.StatusPartial
+	}
+	return api.Response{
+		Status:  status,
+		Message: fmt.Sprintf("Inserted %d document(s) on %d shard(s), %d rejected", inserted, len(groups), len(docErrors)),
+		Count:   inserted,
+		IDs:     ids,
+		Errors:  docErrors,
+	}
+}
+
+func errorResponse(message string) api.Response {
+	return api.Response{Status: api.StatusError, Message: message}
+}
+
+// tokenSeparator разделяет токены шардов в составном токене watch
+const tokenSeparator = ","
+
+// Watch объединяет потоки изменений коллекции со всех её шардов. Токен
+// события — токены всех шардов через запятую; по нему поток продолжается
+// на каждом шарде со своей позиции
+func (r *Router) Watch(req api.Request, send func(api.Response) error, done <-chan struct{}) error {
+	if req.Database == "" {
+		return send(errorResponse("database name is required"))
+	}
+	ids := r.placement(req.Database)
+
+	resume := make([]string, len(ids))
+	if req.ResumeAfter != "" {
+		resume = strings.Split(req.ResumeAfter, tokenSeparator)
+		if len(resume) != len(ids) {
+			return send(errorResponse(fmt.Sprintf("invalid resume token: expected %d shard positions", len(ids))))
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	streams := make([]*client.Stream, len(ids))
+	tokens := make([]string, len(ids))
+	for i, id := range ids {
+		stream, err := r.shards[id].Watch(ctx, req.Database, req.Query, resume[i])
+		if err != nil {
+			return send(errorResponse(fmt.Sprintf("shard %s: %v", r.addrs[id], err)))
+		}
+		defer stream.Close()
+		streams[i] = stream
+		tokens[i] = stream.Token()
+	}
+
+	if err := send(api.Response{
+		Status:  api.StatusSuccess,
+		Message: fmt.Sprintf("Watching %s on %d shard(s)", req.Database, len(ids)),
+		Token:   strings.Join(tokens, tokenSeparator),
+	}); err != nil {
+		return err
+	}
+
+	type shardEvent struct {
+		shard int
+		event client.Event
+		err   error
+	}
+	events := make(chan shardEvent)
+	for i, stream := range streams {
+		go func() {
+			for {
+				event, err := stream.Next()
+				select {
+				case events <- shardEvent{shard: i, event: event, err: err}:
+				case <-ctx.Done():
+					return
+				}
+				if err != nil {
+					return
+				}
+			}
+		}()
+	}
+
+	for {
+		select {
+		case e := <-events:
+			if e.err != nil {
+				_ = send(errorResponse(fmt.Sprintf("shard %s: %v", r.addrs[ids[e.shard]], e.err)))
+				return e.err
+			}
+			tokens[e.shard] = e.event.Token
+			resp := api.Response{
+				Status: api.StatusSuccess,
+				Count:  1,
+				Change: &api.Change{Operation: e.event.Operation, ID: e.event.ID},
+				Token:  strings.Join(tokens, tokenSeparator),
+			}
+			if e.event.Document != nil {
+				resp.Data = []map[string]any{e.event.Document}
+			}
+			if err := send(resp); err != nil {
+				return err
+			}
+		case <-done:
+			return nil
+		}
+	}
+}
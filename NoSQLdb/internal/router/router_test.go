@@ -0,0 +1,290 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"nosql_db/client"
+	"nosql_db/client/clienttest"
+	"nosql_db/internal/api"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startShards запускает n фейковых узлов и маршрутизатор поверх них
+func startShards(t *testing.T, n int, collections map[string]CollectionConfig) (*Router, []*clienttest.Server) {
+	t.Helper()
+	servers := make([]*clienttest.Server, n)
+	cfg := &Config{Collections: collections}
+	for i := range servers {
+		servers[i] = clienttest.NewServer()
+		t.Cleanup(servers[i].Close)
+		cfg.Shards = append(cfg.Shards, servers[i].Addr())
+	}
+	r, err := New(cfg, client.Options{MaxRetries: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r, servers
+}
+
+// requestsOf число запросов команды, полученных шардом
+func requestsOf(srv *clienttest.Server, command string) int {
+	n := 0
+	for _, req := range srv.Requests() {
+		if req.Command == command {
+			n++
+		}
+	}
+	return n
+}
+
+func TestConfigValidate(t *testing.T) {
+	shards := []string{"a:1", "b:1", "c:1"}
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{"hash", Config{Shards: shards, Collections: map[string]CollectionConfig{"events": {Key: "agent_id"}}}, ""},
+		{"range", Config{Shards: shards, Collections: map[string]CollectionConfig{
+			"events": {Key: "ts", Strategy: StrategyRange, Bounds: []any{"2024-01-01T00:00:00Z", "2025-01-01T00:00:00Z"}}}}, ""},
+		{"no shards", Config{}, "no shards"},
+		{"duplicate shard", Config{Shards: []string{"a:1", "a:1"}}, "duplicate"},
+		{"missing key", Config{Shards: shards, Collections: map[string]CollectionConfig{"events": {}}}, "key is required"},
+		{"bounds count", Config{Shards: shards, Collections: map[string]CollectionConfig{
+			"events": {Key: "n", Strategy: StrategyRange, Bounds: []any{10.0}}}}, "expected 2 bounds"},
+		{"unordered bounds", Config{Shards: shards, Collections: map[string]CollectionConfig{
+			"events": {Key: "n", Strategy: StrategyRange, Bounds: []any{10.0, 5.0}}}}, "strictly increasing"},
+		{"mixed bounds", Config{Shards: shards, Collections: map[string]CollectionConfig{
+			"events": {Key: "n", Strategy: StrategyRange, Bounds: []any{10.0, "x"}}}}, "strictly increasing"},
+		{"unknown strategy", Config{Shards: shards, Collections: map[string]CollectionConfig{
+			"events": {Key: "n", Strategy: "list"}}}, "unknown strategy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if (tt.wantErr == "") != (err == nil) || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestShardTargets(t *testing.T) {
+	hash := &sharding{key: "agent_id", strategy: StrategyHash, shards: 4}
+	rng := &sharding{key: "n", strategy: StrategyRange, bounds: []any{10.0, 20.0}, shards: 3}
+	dates := &sharding{key: "ts", strategy: StrategyRange, bounds: []any{"2024-06-01T00:00:00Z"}, shards: 2}
+
+	agentShard := hashShard("agent-1", 4)
+	tests := []struct {
+		name     string
+		s        *sharding
+		query    map[string]any
+		expected []int
+	}{
+		{"no key condition", hash, map[string]any{"host": "a"}, []int{0, 1, 2, 3}},
+		{"hash equality", hash, map[string]any{"agent_id": "agent-1"}, []int{agentShard}},
+		{"hash $eq", hash, map[string]any{"agent_id": map[string]any{"$eq": "agent-1"}}, []int{agentShard}},
+		{"hash range is not pruned", hash, map[string]any{"agent_id": map[string]any{"$gt": "a"}}, []int{0, 1, 2, 3}},
+		{"logical operators", hash, map[string]any{"agent_id": "agent-1", "$or": []any{}}, []int{0, 1, 2, 3}},
+		{"range equality", rng, map[string]any{"n": 15.0}, []int{1}},
+		{"range bound belongs to next shard", rng, map[string]any{"n": 20.0}, []int{2}},
+		{"range $in", rng, map[string]any{"n": map[string]any{"$in": []any{1.0, 25.0, 2.0}}}, []int{0, 2}},
+		{"range lower bound", rng, map[string]any{"n": map[string]any{"$gte": 12.0}}, []int{1, 2}},
+		{"range both bounds", rng, map[string]any{"n": map[string]any{"$gt": 5.0, "$lt": 15.0}}, []int{0, 1}},
+		{"incomparable value", rng, map[string]any{"n": "x"}, []int{0, 1, 2}},
+		{"dates across offsets", dates, map[string]any{"ts": map[string]any{"$gte": "2024-06-01T02:00:00+03:00"}}, []int{0, 1}},
+		{"dates after bound", dates, map[string]any{"ts": map[string]any{"$gte": "2024-07-01T00:00:00Z"}}, []int{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.s.targets(tt.query); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected shards %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestHashShardIsStable(t *testing.T) {
+	// размещение уже записанных документов: значения не должны меняться
+	// вместе с кодировкой ключей индекса
+	tests := []struct {
+		value    any
+		expected int
+	}{
+		{"agent-1", 0},
+		{"users", 3},
+		{22.0, 3},
+		{22, 3},
+		{-3.5, 1},
+		{443.0, 12},
+		{true, 12},
+		{"10.0.0.1", 8},
+		{"2001:db8::1", 10},
+		{"2024-01-02T12:00:00+03:00", 8},
+		{time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC), 8},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.value), func(t *testing.T) {
+			if got := hashShard(tt.value, 16); got != tt.expected {
+				t.Errorf("expected shard %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRouterHashSharding(t *testing.T) {
+	r, servers := startShards(t, 3, map[string]CollectionConfig{"events": {Key: "agent_id"}})
+
+	var docs []map[string]any
+	for i := 0; i < 30; i++ {
+		docs = append(docs, map[string]any{
+			"_id":      fmt.Sprintf("e%02d", i),
+			"agent_id": fmt.Sprintf("agent-%d", i%6),
+			"severity": []string{"low", "high"}[i%2],
+		})
+	}
+	docs = append(docs, map[string]any{"severity": "low"}) // без ключа шардирования
+
+	resp := r.HandleRequest(api.Request{Command: api.CmdInsert, Database: "events", Data: docs})
+	if resp.Status != api.StatusPartial || resp.Count != 30 || len(resp.Errors) != 1 || resp.Errors[0].Index != 30 {
+		t.Fatalf("unexpected insert response: %+v", resp)
+	}
+	if resp.IDs[7] != "e07" || resp.IDs[30] != "" {
+		t.Errorf("ids are not in request order: %v", resp.IDs)
+	}
+
+	// документы одного агента лежат на одном шарде, шарды не пустые
+	for _, srv := range servers {
+		agents := make(map[string]bool)
+		for _, doc := range srv.Documents("events") {
+			agents[doc["agent_id"].(string)] = true
+		}
+		for agent := range agents {
+			for _, other := range servers {
+				if other == srv {
+					continue
+				}
+				for _, doc := range other.Documents("events") {
+					if doc["agent_id"] == agent {
+						t.Errorf("agent %s is split across shards", agent)
+					}
+				}
+			}
+		}
+	}
+
+	resp = r.HandleRequest(api.Request{Command: api.CmdFind, Database: "events", Query: map[string]any{"severity": "high"}})
+	if resp.Count != 15 {
+		t.Errorf("expected 15 documents from all shards, got %d", resp.Count)
+	}
+
+	before := make([]int, len(servers))
+	for i, srv := range servers {
+		before[i] = requestsOf(srv, api.CmdCount)
+	}
+	resp = r.HandleRequest(api.Request{Command: api.CmdCount, Database: "events", Query: map[string]any{"agent_id": "agent-1"}})
+	if resp.Count != 5 {
+		t.Errorf("expected 5 documents of agent-1, got %d", resp.Count)
+	}
+	asked := 0
+	for i, srv := range servers {
+		asked += requestsOf(srv, api.CmdCount) - before[i]
+	}
+	if asked != 1 {
+		t.Errorf("query by shard key should hit one shard, hit %d", asked)
+	}
+
+	resp = r.HandleRequest(api.Request{Command: api.CmdDistinct, Database: "events", Field: "agent_id"})
+	expected := []any{"agent-0", "agent-1", "agent-2", "agent-3", "agent-4", "agent-5"}
+	if !reflect.DeepEqual(resp.Values, expected) {
+		t.Errorf("expected merged distinct %v, got %v", expected, resp.Values)
+	}
+
+	resp = r.HandleRequest(api.Request{Command: api.CmdDelete, Database: "events", Query: map[string]any{"severity": "low"}})
+	if resp.Count != 15 {
+		t.Errorf("expected 15 deleted, got %d (%s)", resp.Count, resp.Message)
+	}
+}
+
+func TestRouterRangeShardingAndBroadcast(t *testing.T) {
+	r, servers := startShards(t, 2, map[string]CollectionConfig{
+		"archive": {Key: "timestamp", Strategy: StrategyRange, Bounds: []any{"2024-06-01T00:00:00Z"}},
+	})
+
+	resp := r.HandleRequest(api.Request{Command: api.CmdInsert, Database: "archive", Data: []map[string]any{
+		{"timestamp": "2024-01-10T00:00:00Z"},
+		{"timestamp": "2024-06-01T01:00:00+03:00"}, // 2024-05-31T22:00Z — первый шард
+		{"timestamp": "2024-08-01T00:00:00Z"},
+		{"timestamp": 5.0},
+	}})
+	if resp.Count != 3 || len(resp.Errors) != 1 || !strings.Contains(resp.Errors[0].Reasons[0], "not comparable") {
+		t.Fatalf("unexpected insert response: %+v", resp)
+	}
+	if len(servers[0].Documents("archive")) != 2 || len(servers[1].Documents("archive")) != 1 {
+		t.Errorf("unexpected placement: %d/%d", len(servers[0].Documents("archive")), len(servers[1].Documents("archive")))
+	}
+
+	resp = r.HandleRequest(api.Request{Command: api.CmdFind, Database: "archive",
+		Query: map[string]any{"timestamp": map[string]any{"$gte": map[string]any{"$date": "2024-07-01T00:00:00Z"}}}})
+	if resp.Count != 1 || requestsOf(servers[0], api.CmdFind) != 0 {
+		t.Errorf("expected one document from the second shard only, got %d", resp.Count)
+	}
+
+	// индексы создаются на всех шардах коллекции
+	resp = r.HandleRequest(api.Request{Command: api.CmdCreateIndex, Database: "archive", Field: "timestamp"})
+	if resp.Status != api.StatusSuccess || requestsOf(servers[0], api.CmdCreateIndex) != 1 || requestsOf(servers[1], api.CmdCreateIndex) != 1 {
+		t.Errorf("create_index was not broadcast: %+v", resp)
+	}
+
+	// коллекция без шардирования целиком живёт на одном шарде
+	r.HandleRequest(api.Request{Command: api.CmdInsert, Database: "users", Data: []map[string]any{{"name": "a"}, {"name": "b"}}})
+	home := homeShard("users", 2)
+	if len(servers[home].Documents("users")) != 2 || len(servers[1-home].Documents("users")) != 0 {
+		t.Error("unsharded collection is not on its home shard")
+	}
+
+	resp = r.HandleRequest(api.Request{Command: api.CmdListCollections})
+	names := make([]string, len(resp.Values))
+	for i, v := range resp.Values {
+		names[i] = v.(string)
+	}
+	if !sort.StringsAreSorted(names) || !reflect.DeepEqual(names, []string{"archive", "users"}) {
+		t.Errorf("unexpected collections: %v", names)
+	}
+}
+
+func TestRouterShardUnavailable(t *testing.T) {
+	r, servers := startShards(t, 2, map[string]CollectionConfig{"events": {Key: "agent_id"}})
+	down := hashShard("agent-down", 2)
+	servers[down].Close()
+
+	var up string
+	for i := 0; up == ""; i++ {
+		if agent := fmt.Sprintf("agent-%d", i); hashShard(agent, 2) != down {
+			up = agent
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	resp := r.dispatch(ctx, api.Request{Command: api.CmdInsert, Database: "events", Data: []map[string]any{
+		{"agent_id": up}, {"agent_id": "agent-down"},
+	}})
+	if resp.Status != api.StatusPartial || resp.Count != 1 || len(resp.Errors) != 1 || resp.Errors[0].Index != 1 {
+		t.Fatalf("expected partial insert, got %+v", resp)
+	}
+
+	resp = r.dispatch(ctx, api.Request{Command: api.CmdFind, Database: "events"})
+	if resp.Status != api.StatusError || !strings.Contains(resp.Message, servers[down].Addr()) {
+		t.Errorf("expected error naming the unavailable shard, got %+v", resp)
+	}
+	resp = r.dispatch(ctx, api.Request{Command: api.CmdFind, Database: "events", Query: map[string]any{"agent_id": up}})
+	if resp.Status != api.StatusSuccess || resp.Count != 1 {
+		t.Errorf("query by key on a live shard should succeed, got %+v", resp)
+	}
+}
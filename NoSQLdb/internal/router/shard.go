@@ -0,0 +1,218 @@
+package router
+
+import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/netip"
+	"nosql_db/internal/datetime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sharding правило размещения коллекции по шардам
+type sharding struct {
+	key      string
+	strategy string
+	bounds   []any
+	shards   int
+}
+
+// shardOf номер шарда для значения ключа
+func (s *sharding) shardOf(value any) (int, error) {
+	if s.strategy != StrategyRange {
+		return hashShard(value, s.shards), nil
+	}
+	for i, bound := range s.bounds {
+		order, ok := compareValues(value, bound)
+		if !ok {
+			return 0, fmt.Errorf("shard key '%s' value %v is not comparable with range bounds", s.key, value)
+		}
+		if order < 0 {
+			return i, nil
+		}
+	}
+	return len(s.bounds), nil
+}
+
+// targets шарды, на которых могут лежать документы, подходящие под query.
+// Условие на ключ (значение, $eq, $in, для range — ещё $gt/$gte/$lt/$lte)
+// сужает список; иначе запрос идёт на все шарды
+func (s *sharding) targets(query map[string]any) []int {
+	condition, ok := query[s.key]
+	_, hasOr := query["$or"]
+	_, hasAnd := query["$and"]
+	if !ok || hasOr || hasAnd {
+		return allShards(s.shards)
+	}
+
+	if values, ok := equalityValues(condition); ok {
+		set := make(map[int]bool, len(values))
+		for _, v := range values {
+			shard, err := s.shardOf(v)
+			if err != nil {
+				return allShards(s.shards)
+			}
+			set[shard] = true
+		}
+		ids := make([]int, 0, len(set))
+		for shard := range set {
+			ids = append(ids, shard)
+		}
+		sort.Ints(ids)
+		return ids
+	}
+
+	if s.strategy == StrategyRange {
+		if from, to, ok := s.rangeShards(condition); ok {
+			ids := make([]int, 0, to-from+1)
+			for shard := from; shard <= to; shard++ {
+				ids = append(ids, shard)
+			}
+			return ids
+		}
+	}
+	return allShards(s.shards)
+}
+
+// rangeShards первый и последний шард для условия $gt/$gte/$lt/$lte
+func (s *sharding) rangeShards(condition any) (int, int, bool) {
+	ops, ok := condition.(map[string]any)
+	if !ok || len(ops) == 0 {
+		return 0, 0, false
+	}
+	from, to := 0, s.shards-1
+	for op, value := range ops {
+		shard, err := s.shardOf(value)
+		if err != nil {
+			return 0, 0, false
+		}
+		switch op {
+		case "$gt", "$gte":
+			from = max(from, shard)
+		case "$lt", "$lte":
+			to = min(to, shard)
+		default:
+			return 0, 0, false
+		}
+	}
+	if from > to {
+		// пустой диапазон: достаточно одного шарда, он вернёт пустой результат
+		to = from
+	}
+	return from, to, true
+}
+
+// equalityValues значения условия-равенства: скаляр, {"$eq": v} или {"$in": [...]}
+func equalityValues(condition any) ([]any, bool) {
+	ops, ok := condition.(map[string]any)
+	if !ok {
+		if _, isArray := condition.([]any); isArray {
+			return nil, false
+		}
+		return []any{condition}, true
+	}
+	if len(ops) != 1 {
+		return nil, false
+	}
+	if v, ok := ops["$eq"]; ok {
+		return equalityValues(v)
+	}
+	if list, ok := ops["$in"].([]any); ok {
+		return list, true
+	}
+	return nil, false
+}
+
+// hashShard шард по хэшу канонического представления значения: даты в разных
+// часовых поясах и одинаковые числа попадают на один шард
+func hashShard(value any, shards int) int {
+	h := fnv.New32a()
+	h.Write(shardKeyV1(value))
+	return int(h.Sum32() % uint32(shards))
+}
+
+// shardKeyV1 представление значения для хэша шардирования. Не зависит от
+// кодировки ключей индекса и не должно меняться: иначе уже размещённые документы
+// окажутся на другом шарде. Новый формат — новая функция и перенос данных.
+// Числа — биты float64, даты — маркер 0x01, секунды UTC со сдвигом знака
+// и наносекунды, IP — маркер 0x00, семейство и адрес, остальные строки — байты
+func shardKeyV1(value any) []byte {
+	switch v := value.(type) {
+	case float64:
+		return binary.BigEndian.AppendUint64(nil, math.Float64bits(v))
+	case int:
+		return shardKeyV1(float64(v))
+	case int64:
+		return shardKeyV1(float64(v))
+	case time.Time:
+		return shardTimeKey(v)
+	case bool:
+		if v {
+			return []byte{1}
+		}
+		return []byte{0}
+	case string:
+		if t, ok := datetime.Parse(v); ok {
+			return shardTimeKey(t)
+		}
+		if addr, err := netip.ParseAddr(v); err == nil && addr.Zone() == "" {
+			if addr.Is4() {
+				a := addr.As4()
+				return append([]byte{0x00, 4}, a[:]...)
+			}
+			a := addr.As16()
+			return append([]byte{0x00, 6}, a[:]...)
+		}
+		return []byte(v)
+	default:
+		return []byte(fmt.Sprintf("%v", v))
+	}
+}
+
+func shardTimeKey(t time.Time) []byte {
+	key := []byte{0x01}
+	key = binary.BigEndian.AppendUint64(key, uint64(t.Unix())^(1<<63))
+	return binary.BigEndian.AppendUint32(key, uint32(t.Nanosecond()))
+}
+
+// homeShard шард для коллекции без шардирования
+func homeShard(collection string, shards int) int {
+	return hashShard(collection, shards)
+}
+
+// compareValues упорядочивает числа, даты и строки; false — значения разных типов
+func compareValues(a, b any) (int, bool) {
+	if x, ok := a.(float64); ok {
+		if y, ok := b.(float64); ok {
+			return cmp.Compare(x, y), true
+		}
+		return 0, false
+	}
+	if order, ok := datetime.Compare(a, b); ok {
+		return order, true
+	}
+	x, ok1 := a.(string)
+	y, ok2 := b.(string)
+	if ok1 && ok2 {
+		if _, isDate := datetime.Parse(x); isDate {
+			return 0, false
+		}
+		if _, isDate := datetime.Parse(y); isDate {
+			return 0, false
+		}
+		return strings.Compare(x, y), true
+	}
+	return 0, false
+}
+
+func allShards(n int) []int {
+	ids := make([]int, n)
+	for i := range ids {
+		ids[i] = i
+	}
+	return ids
+}
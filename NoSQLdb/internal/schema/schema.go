@@ -0,0 +1,383 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"nosql_db/internal/collation"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Schema набор правил проверки документов коллекции (подмножество JSON Schema):
+//
+//	{
+//	  "required": ["timestamp", "severity"],
+//	  "properties": {
+//	    "severity":  {"type": "string", "enum": ["low", "medium", "high"]},
+//	    "timestamp": {"type": "string", "format": "date-time"},
+//	    "user":      {"type": "string", "normalize": ["trim", "lower"]}
+//	  },
+//	  "additionalProperties": true
+//	}
+type Schema struct {
+	Required             []string
+	Properties           map[string]*Property
+	AdditionalProperties bool
+	raw                  map[string]any
+}
+
+// Property правила для одного поля
+type Property struct {
+	Types     []string
+	Enum      []any
+	Format    string
+	Pattern   *regexp.Regexp
+	Minimum   *float64
+	Maximum   *float64
+	MinLength *int
+	MaxLength *int
+	Normalize []string // преобразования строки при вставке, по порядку
+}
+
+// поддерживаемые значения normalize
+var normalizers = map[string]func(string) string{
+	"lower": collation.Fold,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+}
+
+// поддерживаемые значения format
+var formats = map[string]func(string) bool{
+	"date-time": func(s string) bool {
+		_, err := time.Parse(time.RFC3339, s)
+		return err == nil
+	},
+	"ipv4": func(s string) bool {
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() != nil
+	},
+	"ip": func(s string) bool {
+		return net.ParseIP(s) != nil
+	},
+}
+
+// Parse разбирает схему из json-описания
+func Parse(raw map[string]any) (*Schema, error) {
+	s := &Schema{
+		Properties:           make(map[string]*Property),
+		AdditionalProperties: true,
+		raw:                  raw,
+	}
+
+	for key, value := range raw {
+		switch key {
+		case "required":
+			list, ok := value.([]any)
+			if !ok {
+				return nil, fmt.Errorf("required must be an array of field names")
+			}
+			for _, item := range list {
+				name, ok := item.(string)
+				if !ok {
+					return nil, fmt.Errorf("required must be an array of field names")
+				}
+				s.Required = append(s.Required, name)
+			}
+		case "properties":
+			props, ok := value.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("properties must be an object")
+			}
+			for field, def := range props {
+				defMap, ok := def.(map[string]any)
+				if !ok {
+					return nil, fmt.Errorf("property %s must be an object", field)
+				}
+				prop, err := parseProperty(defMap)
+				if err != nil {
+					return nil, fmt.Errorf("property %s: %w", field, err)
+				}
+				s.Properties[field] = prop
+			}
+		case "additionalProperties":
+			allowed, ok := value.(bool)
+			if !ok {
+				return nil, fmt.Errorf("additionalProperties must be a boolean")
+			}
+			s.AdditionalProperties = allowed
+		default:
+			return nil, fmt.Errorf("unknown schema keyword: %s", key)
+		}
+	}
+
+	return s, nil
+}
+
+func parseProperty(def map[string]any) (*Property, error) {
+	p := &Property{}
+
+	for key, value := range def {
+		switch key {
+		case "type":
+			switch v := value.(type) {
+			case string:
+				p.Types = []string{v}
+			case []any:
+				for _, item := range v {
+					name, ok := item.(string)
+					if !ok {
+						return nil, fmt.Errorf("type must be a string or an array of strings")
+					}
+					p.Types = append(p.Types, name)
+				}
+			default:
+				return nil, fmt.Errorf("type must be a string or an array of strings")
+			}
+			for _, t := range p.Types {
+				if !knownType(t) {
+					return nil, fmt.Errorf("unknown type: %s", t)
+				}
+			}
+		case "enum":
+			list, ok := value.([]any)
+			if !ok || len(list) == 0 {
+				return nil, fmt.Errorf("enum must be a non-empty array")
+			}
+			p.Enum = list
+		case "format":
+			name, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("format must be a string")
+			}
+			if _, exists := formats[name]; !exists {
+				return nil, fmt.Errorf("unknown format: %s", name)
+			}
+			p.Format = name
+		case "pattern":
+			expr, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("pattern must be a string")
+			}
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern: %w", err)
+			}
+			p.Pattern = re
+		case "minimum", "maximum":
+			num, ok := value.(float64)
+			if !ok {
+				return nil, fmt.Errorf("%s must be a number", key)
+			}
+			if key == "minimum" {
+				p.Minimum = &num
+			} else {
+				p.Maximum = &num
+			}
+		case "minLength", "maxLength":
+			num, ok := value.(float64)
+			if !ok || num < 0 || num != math.Trunc(num) {
+				return nil, fmt.Errorf("%s must be a non-negative integer", key)
+			}
+			n := int(num)
+			if key == "minLength" {
+				p.MinLength = &n
+			} else {
+				p.MaxLength = &n
+			}
+		case "normalize":
+			switch v := value.(type) {
+			case string:
+				p.Normalize = []string{v}
+			case []any:
+				for _, item := range v {
+					name, ok := item.(string)
+					if !ok {
+						return nil, fmt.Errorf("normalize must be a string or an array of strings")
+					}
+					p.Normalize = append(p.Normalize, name)
+				}
+			default:
+				return nil, fmt.Errorf("normalize must be a string or an array of strings")
+			}
+			for _, name := range p.Normalize {
+				if _, exists := normalizers[name]; !exists {
+					return nil, fmt.Errorf("unknown normalize rule: %s", name)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("unknown property keyword: %s", key)
+		}
+	}
+
+	return p, nil
+}
+
+func knownType(t string) bool {
+	switch t {
+	case "string", "number", "integer", "boolean", "object", "array", "null":
+		return true
+	}
+	return false
+}
+
+// Raw возвращает исходное описание схемы
+func (s *Schema) Raw() map[string]any {
+	return s.raw
+}
+
+// Normalize применяет правила normalize к строковым полям документа
+// (и к строкам в массивах); вызывается перед Validate
+func (s *Schema) Normalize(doc map[string]any) {
+	for field, prop := range s.Properties {
+		if len(prop.Normalize) == 0 {
+			continue
+		}
+		switch v := doc[field].(type) {
+		case string:
+			doc[field] = prop.normalize(v)
+		case []any:
+			for i, item := range v {
+				if str, ok := item.(string); ok {
+					v[i] = prop.normalize(str)
+				}
+			}
+		}
+	}
+}
+
+func (p *Property) normalize(s string) string {
+	for _, name := range p.Normalize {
+		s = normalizers[name](s)
+	}
+	return s
+}
+
+// Validate проверяет документ и возвращает список причин отказа (пустой, если документ валиден)
+func (s *Schema) Validate(doc map[string]any) []string {
+	var reasons []string
+
+	for _, field := range s.Required {
+		if _, exists := doc[field]; !exists {
+			reasons = append(reasons, fmt.Sprintf("missing required field '%s'", field))
+		}
+	}
+
+	fields := make([]string, 0, len(doc))
+	for field := range doc {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		prop, defined := s.Properties[field]
+		if !defined {
+			if !s.AdditionalProperties && field != "_id" {
+				reasons = append(reasons, fmt.Sprintf("field '%s' is not allowed by schema", field))
+			}
+			continue
+		}
+		for _, reason := range prop.validate(doc[field]) {
+			reasons = append(reasons, fmt.Sprintf("field '%s': %s", field, reason))
+		}
+	}
+
+	return reasons
+}
+
+func (p *Property) validate(value any) []string {
+	if len(p.Types) > 0 && !p.matchesType(value) {
+		return []string{fmt.Sprintf("expected type %v, got %s", typeList(p.Types), typeOf(value))}
+	}
+
+	var reasons []string
+
+	if len(p.Enum) > 0 {
+		allowed := false
+		for _, item := range p.Enum {
+			if reflect.DeepEqual(item, value) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			reasons = append(reasons, fmt.Sprintf("value %v is not one of %v", value, p.Enum))
+		}
+	}
+
+	if str, ok := value.(string); ok {
+		if p.Format != "" && !formats[p.Format](str) {
+			reasons = append(reasons, fmt.Sprintf("value %q does not match format %s", str, p.Format))
+		}
+		if p.Pattern != nil && !p.Pattern.MatchString(str) {
+			reasons = append(reasons, fmt.Sprintf("value %q does not match pattern %s", str, p.Pattern))
+		}
+		if p.MinLength != nil && len([]rune(str)) < *p.MinLength {
+			reasons = append(reasons, fmt.Sprintf("length is less than %d", *p.MinLength))
+		}
+		if p.MaxLength != nil && len([]rune(str)) > *p.MaxLength {
+			reasons = append(reasons, fmt.Sprintf("length is greater than %d", *p.MaxLength))
+		}
+	}
+
+	if num, ok := value.(float64); ok {
+		if p.Minimum != nil && num < *p.Minimum {
+			reasons = append(reasons, fmt.Sprintf("value %v is less than %v", num, *p.Minimum))
+		}
+		if p.Maximum != nil && num > *p.Maximum {
+			reasons = append(reasons, fmt.Sprintf("value %v is greater than %v", num, *p.Maximum))
+		}
+	}
+
+	return reasons
+}
+
+func (p *Property) matchesType(value any) bool {
+	actual := typeOf(value)
+	for _, t := range p.Types {
+		if t == actual {
+			return true
+		}
+		if t == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+// typeOf возвращает json-тип значения; целые числа выделяются в integer
+func typeOf(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return "integer"
+		}
+		return "number"
+	case int, int32, int64:
+		return "integer"
+	case float32:
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func typeList(types []string) string {
+	if len(types) == 1 {
+		return types[0]
+	}
+	return fmt.Sprintf("%v", types)
+}
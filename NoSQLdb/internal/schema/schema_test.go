@@ -0,0 +1,114 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+func mustParse(t *testing.T, raw map[string]any) *Schema {
+	t.Helper()
+	s, err := Parse(raw)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	return s
+}
+
+func TestValidateEventSchema(t *testing.T) {
+	s := mustParse(t, map[string]any{
+		"required": []any{"timestamp", "severity"},
+		"properties": map[string]any{
+			"severity":  map[string]any{"type": "string", "enum": []any{"low", "medium", "high"}},
+			"timestamp": map[string]any{"type": "string", "format": "date-time"},
+			"pid":       map[string]any{"type": "integer", "minimum": 0.0},
+			"source_ip": map[string]any{"type": "string", "format": "ip"},
+		},
+	})
+
+	tests := []struct {
+		name    string
+		doc     map[string]any
+		reasons int
+		contain string
+	}{
+		{"valid", map[string]any{"timestamp": "2025-01-02T15:04:05Z", "severity": "high"}, 0, ""},
+		{"missing field", map[string]any{"severity": "high"}, 1, "missing required field 'timestamp'"},
+		{"unix timestamp", map[string]any{"timestamp": 1735830245.0, "severity": "low"}, 1, "expected type string"},
+		{"bad format", map[string]any{"timestamp": "yesterday", "severity": "low"}, 1, "format date-time"},
+		{"enum", map[string]any{"timestamp": "2025-01-02T15:04:05Z", "severity": "urgent"}, 1, "is not one of"},
+		{"integer", map[string]any{"timestamp": "2025-01-02T15:04:05Z", "severity": "low", "pid": 1.5}, 1, "expected type integer"},
+		{"minimum", map[string]any{"timestamp": "2025-01-02T15:04:05Z", "severity": "low", "pid": -1.0}, 1, "less than"},
+		{"ip", map[string]any{"timestamp": "2025-01-02T15:04:05Z", "severity": "low", "source_ip": "300.1.1.1"}, 1, "format ip"},
+		{"extra fields allowed", map[string]any{"timestamp": "2025-01-02T15:04:05Z", "severity": "low", "x": 1.0}, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reasons := s.Validate(tt.doc)
+			if len(reasons) != tt.reasons {
+				t.Fatalf("expected %d reasons, got %v", tt.reasons, reasons)
+			}
+			if tt.contain != "" && !strings.Contains(reasons[0], tt.contain) {
+				t.Errorf("expected reason containing %q, got %q", tt.contain, reasons[0])
+			}
+		})
+	}
+}
+
+func TestValidateAdditionalProperties(t *testing.T) {
+	s := mustParse(t, map[string]any{
+		"properties":           map[string]any{"name": map[string]any{"type": "string"}},
+		"additionalProperties": false,
+	})
+
+	if reasons := s.Validate(map[string]any{"_id": "1", "name": "a"}); len(reasons) != 0 {
+		t.Errorf("expected _id to be allowed, got %v", reasons)
+	}
+	if reasons := s.Validate(map[string]any{"name": "a", "age": 3.0}); len(reasons) != 1 {
+		t.Errorf("expected 1 reason, got %v", reasons)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	s := mustParse(t, map[string]any{
+		"properties": map[string]any{
+			"user":     map[string]any{"type": "string", "normalize": []any{"trim", "lower"}, "enum": []any{"root", "admin"}},
+			"hostname": map[string]any{"normalize": "upper"},
+			"tags":     map[string]any{"normalize": "lower"},
+		},
+	})
+
+	doc := map[string]any{"user": "  Root ", "hostname": "web-01", "tags": []any{"SSH", 1.0}, "msg": "Keep Case"}
+	s.Normalize(doc)
+	if doc["user"] != "root" || doc["hostname"] != "WEB-01" || doc["msg"] != "Keep Case" {
+		t.Errorf("unexpected normalized document: %v", doc)
+	}
+	if tags := doc["tags"].([]any); tags[0] != "ssh" || tags[1] != 1.0 {
+		t.Errorf("unexpected normalized tags: %v", tags)
+	}
+	if reasons := s.Validate(doc); len(reasons) != 0 {
+		t.Errorf("normalized document should pass enum, got %v", reasons)
+	}
+}
+
+func TestParseInvalidSchema(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  map[string]any
+	}{
+		{"unknown keyword", map[string]any{"requird": []any{"a"}}},
+		{"unknown type", map[string]any{"properties": map[string]any{"a": map[string]any{"type": "text"}}}},
+		{"unknown format", map[string]any{"properties": map[string]any{"a": map[string]any{"format": "uuid"}}}},
+		{"bad pattern", map[string]any{"properties": map[string]any{"a": map[string]any{"pattern": "("}}}},
+		{"required not array", map[string]any{"required": "a"}},
+		{"unknown normalize rule", map[string]any{"properties": map[string]any{"a": map[string]any{"normalize": "title"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.raw); err == nil {
+				t.Error("expected parse error")
+			}
+		})
+	}
+}
@@ -1,91 +1,373 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net"
 	"nosql_db/internal/api"
 	"nosql_db/internal/handlers"
+	"nosql_db/internal/metrics"
+	"sync"
+	"syscall"
 	"time"
 )
 
+// ErrServerClosed возвращается Run и Serve после вызова Shutdown
+var ErrServerClosed = errors.New("server closed")
+
+// errRequestTooLarge запрос длиннее MaxRequestBytes
+var errRequestTooLarge = errors.New("request too large")
+
+var (
+	openConnections     = metrics.NewGauge("nosqldb_open_connections", "Currently open client connections.")
+	rejectedConnections = metrics.NewCounterVec("nosqldb_rejected_connections_total",
+		"Connections rejected because the connection limit was reached.")
+)
+
 type TCPServer struct {
 	Address       string
-	Timeout       int
+	IdleTimeout   time.Duration // ожидание следующего запроса от клиента
+	ReadTimeout   time.Duration // чтение уже начатого запроса
+	WriteTimeout  time.Duration // запись ответа
 	MaxConnection int
+
+	MaxRequestBytes  int64 // размер одного запроса, 0 — без ограничения
+	MaxResponseBytes int   // размер одного ответа, 0 — без ограничения
+
+	// Handler и Watcher выполняют запросы и watch; по умолчанию — локальное
+	// хранилище (handlers), в режиме маршрутизатора — router
+	Handler func(api.Request) api.Response
+	Watcher func(req api.Request, send func(api.Response) error, done <-chan struct{}) error
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[*clientConn]struct{}
+	closing  bool
+	wg       sync.WaitGroup
+}
+
+// clientConn соединение клиента; busy — выполняется запрос, такое соединение
+// при остановке сервера не закрывается, пока не будет отправлен ответ
+type clientConn struct {
+	net.Conn
+	busy bool
 }
 
 func New(address string) *TCPServer {
 	return &TCPServer{
 		Address:       address,
-		Timeout:       60,
+		IdleTimeout:   60 * time.Second,
+		ReadTimeout:   30 * time.Second,
+		WriteTimeout:  30 * time.Second,
 		MaxConnection: 100,
+
+		MaxRequestBytes:  32 << 20,
+		MaxResponseBytes: 64 << 20,
+
+		Handler: handlers.HandleRequest,
+		Watcher: handlers.Watch,
 	}
 }
 
+// Run слушает Address и обслуживает соединения до вызова Shutdown
 func (s *TCPServer) Run() error {
 	listener, err := net.Listen("tcp", s.Address)
 	if err != nil {
 		return err
 	}
-	defer listener.Close()
+	return s.Serve(listener)
+}
 
-	log.Printf("server running on %s", s.Address)
+// Serve принимает соединения на listener до вызова Shutdown
+func (s *TCPServer) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		listener.Close()
+		return ErrServerClosed
+	}
+	s.listener = listener
+	s.conns = make(map[*clientConn]struct{})
+	s.mu.Unlock()
+	defer listener.Close()
 
-	maxOpenConntecion := make(chan any, s.MaxConnection)
+	slog.Info("server running", "address", listener.Addr().String())
 
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
-			log.Printf("conn error: %v", err)
+			if s.isClosing() {
+				return ErrServerClosed
+			}
+			slog.Error("accept failed", "error", err)
+			time.Sleep(10 * time.Millisecond)
 			continue
 		}
 
-		maxOpenConntecion <- struct{}{}
+		c, ok := s.track(conn)
+		if !ok {
+			rejectedConnections.Inc()
+			go s.reject(conn)
+			continue
+		}
 
 		go func() {
-			s.handleConnection(conn)
-
-			<-maxOpenConntecion
+			defer s.untrack(c)
+			s.handleConnection(c)
 		}()
 	}
 }
 
-func (s *TCPServer) handleConnection(conn net.Conn) {
+// Shutdown прекращает приём соединений, закрывает простаивающие и ждёт,
+// пока выполняющиеся запросы получат ответ. По истечении ctx оставшиеся
+// соединения закрываются принудительно
+func (s *TCPServer) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closing = true
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	for c := range s.conns {
+		if !c.busy {
+			c.Close()
+		}
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		for c := range s.conns {
+			c.Close()
+		}
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (s *TCPServer) isClosing() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closing
+}
+
+// track регистрирует соединение, если сервер работает и лимит соединений не исчерпан
+func (s *TCPServer) track(conn net.Conn) (*clientConn, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closing || (s.MaxConnection > 0 && len(s.conns) >= s.MaxConnection) {
+		return nil, false
+	}
+	c := &clientConn{Conn: conn}
+	s.conns[c] = struct{}{}
+	s.wg.Add(1)
+	return c, true
+}
+
+func (s *TCPServer) untrack(c *clientConn) {
+	s.mu.Lock()
+	delete(s.conns, c)
+	s.mu.Unlock()
+	s.wg.Done()
+}
+
+// setBusy отмечает начало или конец обработки запроса.
+// Возвращает false, если сервер останавливается и соединение нужно закрыть
+func (s *TCPServer) setBusy(c *clientConn, busy bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closing {
+		c.busy = false
+		return false
+	}
+	c.busy = busy
+	return true
+}
+
+// reject отвечает ошибкой соединению сверх лимита и закрывает его
+func (s *TCPServer) reject(conn net.Conn) {
 	defer conn.Close()
 
-	timeoutDuration := time.Duration(s.Timeout) * time.Second
+	message := "too many connections"
+	if s.isClosing() {
+		message = "server is shutting down"
+	}
+	_ = conn.SetWriteDeadline(time.Now().Add(time.Second))
+	_ = json.NewEncoder(conn).Encode(api.Response{Status: api.StatusError, Message: message})
+	slog.Warn("connection rejected", "client", conn.RemoteAddr().String(), "reason", message)
+}
 
-	_ = conn.SetDeadline(time.Now().Add(timeoutDuration))
+func (s *TCPServer) handleConnection(c *clientConn) {
+	defer c.Close()
 
-	clientAddr := conn.RemoteAddr().String()
-	log.Printf("client connected: %s", clientAddr)
+	openConnections.Inc()
+	defer openConnections.Dec()
 
-	decoder := json.NewDecoder(conn)
-	encoder := json.NewEncoder(conn)
+	clientAddr := c.RemoteAddr().String()
+	slog.Debug("client connected", "client", clientAddr)
+
+	reader := &deadlineReader{conn: c.Conn, idle: s.IdleTimeout, read: s.ReadTimeout, limit: s.MaxRequestBytes}
+	decoder := json.NewDecoder(reader)
 
 	for {
-		_ = conn.SetDeadline(time.Now().Add(timeoutDuration))
+		reader.nextRequest()
 
 		var req api.Request
-		err := decoder.Decode(&req)
-		if err != nil {
-			if err == io.EOF {
-				log.Printf("client disconnected: %s", clientAddr)
-			} else {
-				log.Printf("decode error from %s: %v", clientAddr, err)
+		if err := decoder.Decode(&req); err != nil {
+			if errors.Is(err, errRequestTooLarge) {
+				// остаток запроса не дочитан, продолжить разбор потока нельзя
+				message := fmt.Sprintf("request exceeds limit of %d bytes", s.MaxRequestBytes)
+				_ = s.send(c, api.Response{Status: api.StatusError, Message: message})
+				slog.Warn("request too large", "client", clientAddr, "limit", s.MaxRequestBytes)
+				return
 			}
+			s.logReadError(clientAddr, err)
 			return
 		}
 
-		resp := handlers.HandleRequest(req)
+		if req.Command == api.CmdWatch {
+			s.serveWatch(c, reader, decoder, req)
+			return
+		}
+
+		if !s.setBusy(c, true) {
+			// запрос пришёл во время остановки и не выполняется
+			_ = s.send(c, api.Response{Status: api.StatusError, Message: "server is shutting down"})
+			return
+		}
 
-		_ = conn.SetDeadline(time.Now().Add(timeoutDuration))
+		resp := s.Handler(req)
 
-		if err := encoder.Encode(resp); err != nil {
-			log.Printf("encode error to %s: %v", clientAddr, err)
+		err := s.send(c, resp)
+		if !s.setBusy(c, false) {
 			return
 		}
+		if err != nil {
+			slog.Warn("encode failed", "client", clientAddr, "error", err)
+			return
+		}
+	}
+}
+
+// send пишет ответ одной строкой JSON; ответ больше MaxResponseBytes заменяется ошибкой
+func (s *TCPServer) send(conn net.Conn, resp api.Response) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	if s.MaxResponseBytes > 0 && len(data) > s.MaxResponseBytes {
+		message := fmt.Sprintf("response of %d bytes exceeds limit of %d bytes, narrow the query", len(data), s.MaxResponseBytes)
+		if data, err = json.Marshal(api.Response{Status: api.StatusError, Message: message}); err != nil {
+			return err
+		}
+	}
+
+	if s.WriteTimeout > 0 {
+		_ = conn.SetWriteDeadline(time.Now().Add(s.WriteTimeout))
+	}
+	_, err = conn.Write(append(data, '\n'))
+	return err
+}
+
+func (s *TCPServer) logReadError(clientAddr string, err error) {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, io.EOF), errors.Is(err, syscall.ECONNRESET):
+		slog.Debug("client disconnected", "client", clientAddr)
+	case errors.Is(err, net.ErrClosed) && s.isClosing():
+		slog.Debug("connection closed on shutdown", "client", clientAddr)
+	case errors.As(err, &netErr) && netErr.Timeout():
+		slog.Debug("connection timed out", "client", clientAddr)
+	default:
+		slog.Warn("decode failed", "client", clientAddr, "error", err)
+	}
+}
+
+// serveWatch держит соединение открытым и отправляет изменения коллекции.
+// Поток завершается, когда клиент закрывает соединение или присылает любой запрос.
+// Соединение с watch считается простаивающим и закрывается при остановке сервера
+func (s *TCPServer) serveWatch(c *clientConn, reader *deadlineReader, decoder *json.Decoder, req api.Request) {
+	clientAddr := c.RemoteAddr().String()
+
+	// читать больше нечего, ждём только закрытия соединения клиентом
+	reader.idle, reader.read, reader.limit = 0, 0, 0
+
+	done := make(chan struct{})
+	go func() {
+		var next api.Request
+		_ = decoder.Decode(&next)
+		close(done)
+	}()
+
+	send := func(resp api.Response) error {
+		return s.send(c, resp)
+	}
+
+	slog.Info("watch started", "client", clientAddr, "database", req.Database)
+	if err := s.Watcher(req, send, done); err != nil && !s.isClosing() {
+		slog.Warn("watch failed", "client", clientAddr, "database", req.Database, "error", err)
+	}
+	slog.Info("watch stopped", "client", clientAddr, "database", req.Database)
+}
+
+// deadlineReader выставляет таймаут чтения перед каждым чтением из соединения:
+// IdleTimeout, пока ждём начала следующего запроса, и ReadTimeout после его начала.
+// Также ограничивает число байт, читаемых на один запрос.
+// Нулевые таймауты и limit снимают ограничения
+type deadlineReader struct {
+	conn      net.Conn
+	idle      time.Duration
+	read      time.Duration
+	limit     int64
+	remaining int64
+	waiting   bool
+}
+
+// nextRequest начинает ожидание следующего запроса
+func (r *deadlineReader) nextRequest() {
+	r.waiting = true
+	r.remaining = r.limit
+}
+
+func (r *deadlineReader) Read(p []byte) (int, error) {
+	if r.limit > 0 {
+		if r.remaining <= 0 {
+			return 0, errRequestTooLarge
+		}
+		if int64(len(p)) > r.remaining {
+			p = p[:r.remaining]
+		}
+	}
+
+	timeout := r.read
+	if r.waiting {
+		timeout = r.idle
+	}
+
+	deadline := time.Time{}
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	_ = r.conn.SetReadDeadline(deadline)
+
+	n, err := r.conn.Read(p)
+	if n > 0 {
+		r.waiting = false
 	}
+	r.remaining -= int64(n)
+	return n, err
 }
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"nosql_db/internal/api"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startServer(t *testing.T, s *TCPServer) (string, chan error) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(listener) }()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		s.Shutdown(ctx)
+	})
+	return listener.Addr().String(), done
+}
+
+// roundTrip отправляет запрос без базы: сервер отвечает ошибкой, не трогая хранилище
+func roundTrip(t *testing.T, conn net.Conn, reader *bufio.Reader) api.Response {
+	t.Helper()
+	if err := json.NewEncoder(conn).Encode(api.Request{Command: api.CmdFind}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	return readResponse(t, conn, reader)
+}
+
+func readResponse(t *testing.T, conn net.Conn, reader *bufio.Reader) api.Response {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	var resp api.Response
+	if err := json.Unmarshal(line, &resp); err != nil {
+		t.Fatalf("bad response %q: %v", line, err)
+	}
+	return resp
+}
+
+func dial(t *testing.T, addr string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, bufio.NewReader(conn)
+}
+
+// expectClosed ждёт, что сервер закроет соединение
+func expectClosed(t *testing.T, conn net.Conn, reader *bufio.Reader) {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := reader.ReadByte(); err == nil {
+		t.Fatal("expected connection to be closed by server")
+	} else if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		t.Fatal("connection was not closed by server")
+	}
+}
+
+func TestMaxConnectionsRejects(t *testing.T) {
+	s := New("")
+	s.MaxConnection = 1
+	addr, _ := startServer(t, s)
+
+	first, firstReader := dial(t, addr)
+	roundTrip(t, first, firstReader)
+
+	second, secondReader := dial(t, addr)
+	resp := readResponse(t, second, secondReader)
+	if resp.Status != api.StatusError || resp.Message != "too many connections" {
+		t.Errorf("expected rejection, got %+v", resp)
+	}
+	expectClosed(t, second, secondReader)
+
+	// первое соединение продолжает работать
+	if resp := roundTrip(t, first, firstReader); resp.Message != "database name is required" {
+		t.Errorf("unexpected response on accepted connection: %+v", resp)
+	}
+}
+
+func TestIdleTimeoutClosesConnection(t *testing.T) {
+	s := New("")
+	s.IdleTimeout = 100 * time.Millisecond
+	addr, _ := startServer(t, s)
+
+	conn, reader := dial(t, addr)
+	roundTrip(t, conn, reader)
+	expectClosed(t, conn, reader)
+}
+
+func TestShutdownClosesIdleConnections(t *testing.T) {
+	s := New("")
+	addr, done := startServer(t, s)
+
+	conn, reader := dial(t, addr)
+	roundTrip(t, conn, reader)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown error: %v", err)
+	}
+
+	expectClosed(t, conn, reader)
+	if err := <-done; !errors.Is(err, ErrServerClosed) {
+		t.Errorf("expected ErrServerClosed, got %v", err)
+	}
+	if _, err := net.DialTimeout("tcp", addr, 200*time.Millisecond); err == nil {
+		t.Error("expected listener to be closed")
+	}
+}
+
+func TestRequestTooLarge(t *testing.T) {
+	s := New("")
+	s.MaxRequestBytes = 64
+	addr, _ := startServer(t, s)
+
+	conn, reader := dial(t, addr)
+	big := api.Request{Command: api.CmdInsert, Data: []map[string]any{{"raw_log": strings.Repeat("x", 200)}}}
+	if err := json.NewEncoder(conn).Encode(big); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := readResponse(t, conn, reader)
+	if resp.Status != api.StatusError || !strings.Contains(resp.Message, "exceeds limit of 64 bytes") {
+		t.Errorf("expected request size error, got %+v", resp)
+	}
+	expectClosed(t, conn, reader)
+}
+
+func TestResponseTooLarge(t *testing.T) {
+	s := New("")
+	s.MaxResponseBytes = 10
+	addr, _ := startServer(t, s)
+
+	conn, reader := dial(t, addr)
+	resp := roundTrip(t, conn, reader)
+	if resp.Status != api.StatusError || !strings.Contains(resp.Message, "exceeds limit of 10 bytes") {
+		t.Errorf("expected response size error, got %+v", resp)
+	}
+
+	// соединение остаётся рабочим
+	roundTrip(t, conn, reader)
+}
@@ -0,0 +1,439 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// Бинарный формат коллекции:
+//
+//	header: "NSDB" + версия (1 байт)
+//	блоки:  uint32 длина + flate(payload)
+//
+// payload блока: количество документов, словарь строк (имена полей и
+// повторяющиеся значения вроде severity/agent_id), затем документы,
+// где строки из словаря записаны номером.
+
+var binaryMagic = []byte("NSDB")
+
+const (
+	binaryVersion    byte = 1
+	docsPerBlock          = 1000 // документов в одном сжатом блоке
+	maxDictStringLen      = 128  // длинные строки (raw_log) в словарь не попадают
+	maxBlockSize          = 256 << 20
+)
+
+// теги типов значений
+const (
+	tagNull byte = iota
+	tagFalse
+	tagTrue
+	tagNumber
+	tagString
+	tagDictString
+	tagArray
+	tagObject
+)
+
+// encodeBinary пишет документы коллекции в бинарном формате
+func encodeBinary(w io.Writer, items map[string]any) error {
+	ids := make([]string, 0, len(items))
+	for id := range items {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(binaryMagic); err != nil {
+		return err
+	}
+	if err := bw.WriteByte(binaryVersion); err != nil {
+		return err
+	}
+
+	for start := 0; start < len(ids); start += docsPerBlock {
+		end := min(start+docsPerBlock, len(ids))
+		if err := writeBlock(bw, ids[start:end], items); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// writeBlock сжимает и записывает один блок документов
+func writeBlock(w io.Writer, ids []string, items map[string]any) error {
+	docs := make([]map[string]any, len(ids))
+	for i, id := range ids {
+		doc, err := normalizeDoc(items[id])
+		if err != nil {
+			return fmt.Errorf("document %s: %w", id, err)
+		}
+		docs[i] = doc
+	}
+
+	enc := newBlockEncoder(docs)
+
+	var payload bytes.Buffer
+	enc.writeUvarint(&payload, uint64(len(docs)))
+	enc.writeUvarint(&payload, uint64(len(enc.dict)))
+	for _, s := range enc.dict {
+		enc.writeRawString(&payload, s)
+	}
+	for i, doc := range docs {
+		enc.writeRawString(&payload, ids[i])
+		enc.writeObject(&payload, doc)
+	}
+
+	var compressed bytes.Buffer
+	fw, err := flate.NewWriter(&compressed, flate.DefaultCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := fw.Write(payload.Bytes()); err != nil {
+		return err
+	}
+	if err := fw.Close(); err != nil {
+		return err
+	}
+
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(compressed.Len()))
+	if _, err := w.Write(size[:]); err != nil {
+		return err
+	}
+	_, err = w.Write(compressed.Bytes())
+	return err
+}
+
+// normalizeDoc приводит документ к типам, которые умеет кодировать формат
+// (как после json.Unmarshal: float64, string, bool, nil, []any, map[string]any)
+func normalizeDoc(v any) (map[string]any, error) {
+	if doc, ok := v.(map[string]any); ok && isPlain(doc) {
+		return doc, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// isPlain проверяет, что значение состоит только из json-типов
+func isPlain(v any) bool {
+	switch val := v.(type) {
+	case nil, bool, float64, string:
+		return true
+	case []any:
+		for _, item := range val {
+			if !isPlain(item) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		for _, item := range val {
+			if !isPlain(item) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+type blockEncoder struct {
+	dict  []string
+	index map[string]int
+	tmp   [binary.MaxVarintLen64]byte
+}
+
+// newBlockEncoder строит словарь блока: все имена полей и строки,
+// встречающиеся в блоке больше одного раза
+func newBlockEncoder(docs []map[string]any) *blockEncoder {
+	enc := &blockEncoder{index: make(map[string]int)}
+	counts := make(map[string]int)
+
+	var walk func(v any)
+	walk = func(v any) {
+		switch val := v.(type) {
+		case string:
+			if len(val) <= maxDictStringLen {
+				counts[val]++
+			}
+		case []any:
+			for _, item := range val {
+				walk(item)
+			}
+		case map[string]any:
+			for k, item := range val {
+				enc.add(k)
+				walk(item)
+			}
+		}
+	}
+	for _, doc := range docs {
+		walk(doc)
+	}
+
+	repeated := make([]string, 0, len(counts))
+	for s, n := range counts {
+		if n > 1 {
+			repeated = append(repeated, s)
+		}
+	}
+	sort.Strings(repeated)
+	for _, s := range repeated {
+		enc.add(s)
+	}
+	return enc
+}
+
+func (e *blockEncoder) add(s string) {
+	if _, ok := e.index[s]; ok {
+		return
+	}
+	e.index[s] = len(e.dict)
+	e.dict = append(e.dict, s)
+}
+
+func (e *blockEncoder) writeUvarint(buf *bytes.Buffer, v uint64) {
+	n := binary.PutUvarint(e.tmp[:], v)
+	buf.Write(e.tmp[:n])
+}
+
+func (e *blockEncoder) writeRawString(buf *bytes.Buffer, s string) {
+	e.writeUvarint(buf, uint64(len(s)))
+	buf.WriteString(s)
+}
+
+func (e *blockEncoder) writeObject(buf *bytes.Buffer, obj map[string]any) {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	e.writeUvarint(buf, uint64(len(keys)))
+	for _, k := range keys {
+		e.writeUvarint(buf, uint64(e.index[k]))
+		e.writeValue(buf, obj[k])
+	}
+}
+
+func (e *blockEncoder) writeValue(buf *bytes.Buffer, v any) {
+	switch val := v.(type) {
+	case nil:
+		buf.WriteByte(tagNull)
+	case bool:
+		if val {
+			buf.WriteByte(tagTrue)
+		} else {
+			buf.WriteByte(tagFalse)
+		}
+	case float64:
+		buf.WriteByte(tagNumber)
+		var num [8]byte
+		binary.BigEndian.PutUint64(num[:], math.Float64bits(val))
+		buf.Write(num[:])
+	case string:
+		if idx, ok := e.index[val]; ok {
+			buf.WriteByte(tagDictString)
+			e.writeUvarint(buf, uint64(idx))
+			return
+		}
+		buf.WriteByte(tagString)
+		e.writeRawString(buf, val)
+	case []any:
+		buf.WriteByte(tagArray)
+		e.writeUvarint(buf, uint64(len(val)))
+		for _, item := range val {
+			e.writeValue(buf, item)
+		}
+	case map[string]any:
+		buf.WriteByte(tagObject)
+		e.writeObject(buf, val)
+	}
+}
+
+// decodeBinary читает документы коллекции из бинарного формата
+func decodeBinary(r io.Reader) (map[string]any, error) {
+	br := bufio.NewReader(r)
+
+	header := make([]byte, len(binaryMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	if !bytes.Equal(header[:len(binaryMagic)], binaryMagic) {
+		return nil, errors.New("not a binary collection file")
+	}
+	if header[len(binaryMagic)] != binaryVersion {
+		return nil, fmt.Errorf("unsupported binary format version %d", header[len(binaryMagic)])
+	}
+
+	items := make(map[string]any)
+	var size [4]byte
+	for {
+		if _, err := io.ReadFull(br, size[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return items, nil
+			}
+			return nil, fmt.Errorf("read block size: %w", err)
+		}
+		n := binary.BigEndian.Uint32(size[:])
+		if n > maxBlockSize {
+			return nil, fmt.Errorf("block too large: %d bytes", n)
+		}
+
+		fr := flate.NewReader(io.LimitReader(br, int64(n)))
+		payload, err := io.ReadAll(fr)
+		fr.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decompress block: %w", err)
+		}
+
+		if err := readBlock(bytes.NewReader(payload), items); err != nil {
+			return nil, err
+		}
+	}
+}
+
+type blockDecoder struct {
+	r    *bytes.Reader
+	dict []string
+}
+
+func readBlock(r *bytes.Reader, items map[string]any) error {
+	dec := &blockDecoder{r: r}
+
+	docCount, err := binary.ReadUvarint(r)
+	if err != nil {
+		return fmt.Errorf("read document count: %w", err)
+	}
+	dictCount, err := binary.ReadUvarint(r)
+	if err != nil {
+		return fmt.Errorf("read dictionary size: %w", err)
+	}
+	if dictCount > uint64(r.Len()) {
+		return errors.New("corrupted dictionary size")
+	}
+	dec.dict = make([]string, dictCount)
+	for i := range dec.dict {
+		if dec.dict[i], err = dec.readRawString(); err != nil {
+			return fmt.Errorf("read dictionary: %w", err)
+		}
+	}
+
+	for i := uint64(0); i < docCount; i++ {
+		id, err := dec.readRawString()
+		if err != nil {
+			return fmt.Errorf("read document id: %w", err)
+		}
+		doc, err := dec.readObject()
+		if err != nil {
+			return fmt.Errorf("read document %s: %w", id, err)
+		}
+		items[id] = doc
+	}
+	return nil
+}
+
+func (d *blockDecoder) readRawString() (string, error) {
+	n, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return "", err
+	}
+	if n > uint64(d.r.Len()) {
+		return "", io.ErrUnexpectedEOF
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(d.r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func (d *blockDecoder) readDictRef() (string, error) {
+	idx, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return "", err
+	}
+	if idx >= uint64(len(d.dict)) {
+		return "", fmt.Errorf("dictionary index %d out of range", idx)
+	}
+	return d.dict[idx], nil
+}
+
+func (d *blockDecoder) readObject() (map[string]any, error) {
+	n, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return nil, err
+	}
+	obj := make(map[string]any, min(n, 64))
+	for i := uint64(0); i < n; i++ {
+		key, err := d.readDictRef()
+		if err != nil {
+			return nil, err
+		}
+		if obj[key], err = d.readValue(); err != nil {
+			return nil, err
+		}
+	}
+	return obj, nil
+}
+
+func (d *blockDecoder) readValue() (any, error) {
+	tag, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch tag {
+	case tagNull:
+		return nil, nil
+	case tagFalse:
+		return false, nil
+	case tagTrue:
+		return true, nil
+	case tagNumber:
+		var num [8]byte
+		if _, err := io.ReadFull(d.r, num[:]); err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(num[:])), nil
+	case tagString:
+		return d.readRawString()
+	case tagDictString:
+		return d.readDictRef()
+	case tagArray:
+		n, err := binary.ReadUvarint(d.r)
+		if err != nil {
+			return nil, err
+		}
+		if n > uint64(d.r.Len()) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		arr := make([]any, n)
+		for i := range arr {
+			if arr[i], err = d.readValue(); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	case tagObject:
+		return d.readObject()
+	default:
+		return nil, fmt.Errorf("unknown value tag %d", tag)
+	}
+}
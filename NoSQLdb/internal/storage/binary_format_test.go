@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestBinaryFormatRoundTrip(t *testing.T) {
+	items := map[string]any{
+		"1": map[string]any{
+			"_id":      "1",
+			"severity": "high",
+			"agent_id": "agent-01",
+			"count":    42.0,
+			"ok":       true,
+			"missing":  nil,
+			"tags":     []any{"ssh", "auth", 1.5},
+			"nested":   map[string]any{"severity": "high", "depth": 2.0},
+		},
+		"2": map[string]any{
+			"_id":      "2",
+			"severity": "low",
+			"agent_id": "agent-01",
+			"raw_log":  "Jan  1 00:00:00 host sshd[1]: Accepted password for root",
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := encodeBinary(&buf, items); err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+
+	decoded, err := decodeBinary(&buf)
+	if err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+
+	if !reflect.DeepEqual(items, decoded) {
+		t.Errorf("round trip mismatch:\nexpected %v\ngot      %v", items, decoded)
+	}
+}
+
+func TestBinaryFormatManyBlocks(t *testing.T) {
+	items := make(map[string]any)
+	for i := 0; i < docsPerBlock*2+10; i++ {
+		id := fmt.Sprintf("id-%05d", i)
+		items[id] = map[string]any{"_id": id, "severity": "medium", "n": float64(i)}
+	}
+
+	var buf bytes.Buffer
+	if err := encodeBinary(&buf, items); err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+
+	decoded, err := decodeBinary(&buf)
+	if err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if len(decoded) != len(items) {
+		t.Errorf("expected %d documents, got %d", len(items), len(decoded))
+	}
+}
+
+func TestBinaryFormatSmallerThanJSON(t *testing.T) {
+	items := make(map[string]any)
+	for i := 0; i < 500; i++ {
+		id := fmt.Sprintf("id-%05d", i)
+		items[id] = map[string]any{
+			"_id":        id,
+			"severity":   "high",
+			"agent_id":   "agent-ubuntu-01",
+			"event_type": "auth_failure",
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := encodeBinary(&buf, items); err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	jsonData, _ := json.MarshalIndent(items, "", "  ")
+
+	if buf.Len() >= len(jsonData)/4 {
+		t.Errorf("binary size %d is not much smaller than json size %d", buf.Len(), len(jsonData))
+	}
+}
+
+func TestBinaryFormatNormalizesTypes(t *testing.T) {
+	items := map[string]any{
+		"1": map[string]any{"_id": "1", "n": 7, "list": []string{"a", "b"}},
+	}
+
+	var buf bytes.Buffer
+	if err := encodeBinary(&buf, items); err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	decoded, err := decodeBinary(&buf)
+	if err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+
+	doc := decoded["1"].(map[string]any)
+	if doc["n"] != 7.0 {
+		t.Errorf("expected n=7.0, got %v (%T)", doc["n"], doc["n"])
+	}
+	if !reflect.DeepEqual(doc["list"], []any{"a", "b"}) {
+		t.Errorf("expected list [a b], got %v", doc["list"])
+	}
+}
+
+func TestBinaryFormatRejectsGarbage(t *testing.T) {
+	if _, err := decodeBinary(bytes.NewReader([]byte("{\"1\": {}}"))); err == nil {
+		t.Error("expected error for non-binary input")
+	}
+}
@@ -0,0 +1,260 @@
+package storage
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// CappedOptions ограничения capped-коллекции; 0 — без ограничения.
+// При превышении любого из них вытесняются самые старые документы
+type CappedOptions struct {
+	MaxDocs  int   `json:"max_docs,omitempty"`  // максимум документов
+	MaxBytes int64 `json:"max_bytes,omitempty"` // максимум суммарного размера документов в JSON
+}
+
+// Validate проверяет, что задан хотя бы один положительный предел
+func (o CappedOptions) Validate() error {
+	if o.MaxDocs < 0 || o.MaxBytes < 0 {
+		return errors.New("capped limits must not be negative")
+	}
+	if o.MaxDocs == 0 && o.MaxBytes == 0 {
+		return errors.New("max_docs or max_bytes is required")
+	}
+	return nil
+}
+
+// ParseCappedOptions разбирает пределы из запроса create_capped:
+// {"max_docs": 10000, "max_bytes": 1048576}
+func ParseCappedOptions(raw map[string]any) (CappedOptions, error) {
+	var options CappedOptions
+	for key, value := range raw {
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			return options, fmt.Errorf("%s must be an integer", key)
+		}
+		switch key {
+		case "max_docs":
+			options.MaxDocs = int(n)
+		case "max_bytes":
+			options.MaxBytes = int64(n)
+		default:
+			return options, fmt.Errorf("unknown capped option: %s", key)
+		}
+	}
+	return options, options.Validate()
+}
+
+// ErrDocumentTooLarge документ больше max_bytes capped-коллекции и не может быть сохранён
+var ErrDocumentTooLarge = errors.New("document exceeds max_bytes of capped collection")
+
+// cappedState порядок вставки и размеры документов capped-коллекции
+type cappedState struct {
+	options CappedOptions
+	order   *list.List               // cappedEntry от старых к новым
+	entries map[string]*list.Element // _id -> элемент order
+	bytes   int64
+	seq     uint64
+}
+
+type cappedEntry struct {
+	id   string
+	size int64
+	seq  uint64 // позиция вставки, растёт монотонно
+}
+
+// cappedFile формат data/capped/<name>.json: ограничения и порядок вставки
+type cappedFile struct {
+	CappedOptions
+	Order []string `json:"order"`
+}
+
+func newCappedState(options CappedOptions) *cappedState {
+	return &cappedState{options: options, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (s *cappedState) push(id string, size int64) {
+	s.seq++
+	s.entries[id] = s.order.PushBack(cappedEntry{id: id, size: size, seq: s.seq})
+	s.bytes += size
+}
+
+func (s *cappedState) remove(id string) {
+	elem, ok := s.entries[id]
+	if !ok {
+		return
+	}
+	s.bytes -= elem.Value.(cappedEntry).size
+	s.order.Remove(elem)
+	delete(s.entries, id)
+}
+
+// overflow превышен ли какой-либо из пределов
+func (s *cappedState) overflow() bool {
+	return (s.options.MaxDocs > 0 && s.order.Len() > s.options.MaxDocs) ||
+		(s.options.MaxBytes > 0 && s.bytes > s.options.MaxBytes)
+}
+
+func (s *cappedState) ids() []string {
+	ids := make([]string, 0, s.order.Len())
+	for elem := s.order.Front(); elem != nil; elem = elem.Next() {
+		ids = append(ids, elem.Value.(cappedEntry).id)
+	}
+	return ids
+}
+
+// documentSize размер документа в JSON, по нему считается max_bytes
+func documentSize(doc map[string]any) int64 {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return 0
+	}
+	return int64(len(data))
+}
+
+// cappedPath путь к файлу ограничений capped-коллекции
+func cappedPath(name string) string {
+	return filepath.Join("data", "capped", name+".json")
+}
+
+// SetCapped делает пустую коллекцию capped или меняет пределы уже capped-коллекции;
+// лишние документы вытесняются сразу. На диск ограничения попадают при Save
+func (c *Collection) SetCapped(options CappedOptions) error {
+	if err := options.Validate(); err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.capped == nil {
+		if c.Data.Size > 0 {
+			return fmt.Errorf("collection %s is not empty", c.Name)
+		}
+		c.capped = newCappedState(options)
+		return nil
+	}
+	c.capped.options = options
+	c.evictOverflow()
+	c.rebuildFailedIndexes()
+	return nil
+}
+
+// Capped возвращает ограничения коллекции; false — коллекция не capped
+func (c *Collection) Capped() (CappedOptions, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if c.capped == nil {
+		return CappedOptions{}, false
+	}
+	return c.capped.options, true
+}
+
+// SortByInsertion упорядочивает документы capped-коллекции по порядку вставки;
+// для обычных коллекций порядок не меняется
+func (c *Collection) SortByInsertion(docs []map[string]any) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if c.capped == nil {
+		return
+	}
+	position := func(doc map[string]any) uint64 {
+		id, _ := doc["_id"].(string)
+		if elem, ok := c.capped.entries[id]; ok {
+			return elem.Value.(cappedEntry).seq
+		}
+		return 0
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		return position(docs[i]) < position(docs[j])
+	})
+}
+
+// evictOverflow вытесняет самые старые документы, пока коллекция превышает пределы.
+// Вытеснение — обычное удаление: индексы обновляются, в watch уходит delete
+func (c *Collection) evictOverflow() {
+	for c.capped.overflow() {
+		oldest := c.capped.order.Front()
+		if oldest == nil {
+			return
+		}
+		id := oldest.Value.(cappedEntry).id
+		if val, ok := c.Data.Get(id); ok {
+			doc := val.(map[string]any)
+			c.updateIndexesOnDelete(id, doc)
+			c.recordChange(OpDelete, id, doc)
+			c.Data.Remove(id)
+		}
+		c.capped.remove(id)
+	}
+}
+
+// loadCapped восстанавливает ограничения и порядок вставки capped-коллекции.
+// Документы, которых нет в сохранённом порядке, считаются самыми новыми
+func (c *Collection) loadCapped() error {
+	data, err := os.ReadFile(cappedPath(c.Name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read capped options: %w", err)
+	}
+
+	var file cappedFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to unmarshal capped options: %w", err)
+	}
+	if err := file.CappedOptions.Validate(); err != nil {
+		return fmt.Errorf("invalid capped options: %w", err)
+	}
+
+	state := newCappedState(file.CappedOptions)
+	items := c.Data.Items()
+	for _, id := range file.Order {
+		if doc, ok := items[id].(map[string]any); ok {
+			if _, seen := state.entries[id]; !seen {
+				state.push(id, documentSize(doc))
+			}
+		}
+	}
+	var rest []string
+	for id := range items {
+		if _, ok := state.entries[id]; !ok {
+			rest = append(rest, id)
+		}
+	}
+	sort.Strings(rest)
+	for _, id := range rest {
+		if doc, ok := items[id].(map[string]any); ok {
+			state.push(id, documentSize(doc))
+		}
+	}
+
+	c.capped = state
+	return nil
+}
+
+// saveCapped пишет ограничения и порядок вставки рядом с файлом коллекции
+func (c *Collection) saveCapped() error {
+	data, err := json.Marshal(cappedFile{CappedOptions: c.capped.options, Order: c.capped.ids()})
+	if err != nil {
+		return fmt.Errorf("marshal capped options: %w", err)
+	}
+	path := cappedPath(c.Name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("mkdir error: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write file error: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("rename error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+// changeLogSize сколько последних изменений хранится для возобновления watch по токену
+const changeLogSize = 10000
+
+// subscriberBuffer размер очереди подписчика; отставший подписчик отключается
+const subscriberBuffer = 1024
+
+// ChangeEvent изменение документа, зафиксированное write-операцией
+type ChangeEvent struct {
+	Seq        uint64
+	Collection string
+	Operation  string
+	ID         string
+	Document   map[string]any
+}
+
+// Subscription подписка на изменения коллекции
+type Subscription struct {
+	Events     chan ChangeEvent
+	Lagged     chan struct{} // закрывается, если подписчик не успевал читать и был отключён
+	collection string
+}
+
+// changeLog кольцевой буфер последних изменений и список подписчиков.
+// Позиция в потоке — токен "<epoch>-<seq>", epoch меняется при перезапуске сервера
+type changeLog struct {
+	mu     sync.Mutex
+	epoch  string
+	seq    uint64
+	ring   []ChangeEvent
+	next   int
+	filled bool
+	subs   map[*Subscription]struct{}
+}
+
+func newChangeLog() *changeLog {
+	return &changeLog{
+		epoch: strconv.FormatInt(time.Now().UnixNano(), 36),
+		ring:  make([]ChangeEvent, changeLogSize),
+		subs:  make(map[*Subscription]struct{}),
+	}
+}
+
+// Token возвращает токен позиции после события с номером seq
+func (l *changeLog) Token(seq uint64) string {
+	return fmt.Sprintf("%s-%d", l.epoch, seq)
+}
+
+func (l *changeLog) parseToken(token string) (uint64, error) {
+	epoch, seqStr, ok := strings.Cut(token, "-")
+	if !ok {
+		return 0, fmt.Errorf("invalid resume token: %s", token)
+	}
+	if epoch != l.epoch {
+		return 0, fmt.Errorf("resume token is from a previous server run")
+	}
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid resume token: %s", token)
+	}
+	return seq, nil
+}
+
+func (l *changeLog) publish(events []ChangeEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, event := range events {
+		l.seq++
+		event.Seq = l.seq
+		l.ring[l.next] = event
+		l.next = (l.next + 1) % len(l.ring)
+		if l.next == 0 {
+			l.filled = true
+		}
+
+		for sub := range l.subs {
+			if sub.collection != event.Collection {
+				continue
+			}
+			select {
+			case sub.Events <- event:
+			default:
+				close(sub.Lagged)
+				delete(l.subs, sub)
+			}
+		}
+	}
+}
+
+// subscribe регистрирует подписчика и возвращает изменения после resumeToken,
+// которые ещё есть в буфере. Пустой токен — только новые изменения
+func (l *changeLog) subscribe(collection, resumeToken string) (*Subscription, []ChangeEvent, uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var backlog []ChangeEvent
+	if resumeToken != "" {
+		after, err := l.parseToken(resumeToken)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		if after > l.seq {
+			return nil, nil, 0, fmt.Errorf("resume token is ahead of the change stream")
+		}
+
+		oldest := uint64(1)
+		if l.filled {
+			oldest = l.seq - uint64(len(l.ring)) + 1
+		}
+		if after+1 < oldest {
+			return nil, nil, 0, fmt.Errorf("resume token expired: changes were evicted from the log")
+		}
+
+		for seq := after + 1; seq <= l.seq; seq++ {
+			event := l.ring[int((seq-1)%uint64(len(l.ring)))]
+			if event.Collection == collection {
+				backlog = append(backlog, event)
+			}
+		}
+	}
+
+	sub := &Subscription{
+		Events:     make(chan ChangeEvent, subscriberBuffer),
+		Lagged:     make(chan struct{}),
+		collection: collection,
+	}
+	l.subs[sub] = struct{}{}
+	return sub, backlog, l.seq, nil
+}
+
+func (l *changeLog) unsubscribe(sub *Subscription) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.subs, sub)
+}
+
+// Watch подписывает на изменения коллекции. Возвращает изменения после
+// resumeToken (если он задан) и номер последнего изменения на момент подписки
+func (m *CollectionMng) Watch(collection, resumeToken string) (*Subscription, []ChangeEvent, uint64, error) {
+	return m.changes.subscribe(collection, resumeToken)
+}
+
+// Unwatch отменяет подписку
+func (m *CollectionMng) Unwatch(sub *Subscription) {
+	m.changes.unsubscribe(sub)
+}
+
+// ChangeToken возвращает токен позиции в потоке изменений после события seq
+func (m *CollectionMng) ChangeToken(seq uint64) string {
+	return m.changes.Token(seq)
+}
+
+// startRecording включает запись изменений коллекции на время write-операции
+func (c *Collection) startRecording() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.recording = true
+	c.pending = nil
+}
+
+// stopRecording выключает запись и возвращает накопленные изменения
+func (c *Collection) stopRecording() []ChangeEvent {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	events := c.pending
+	c.recording = false
+	c.pending = nil
+	return events
+}
+
+// recordChange (Приватный) - вызывается внутри Insert/Delete под мьютексом
+func (c *Collection) recordChange(op, id string, doc map[string]any) {
+	if !c.recording {
+		return
+	}
+	c.pending = append(c.pending, ChangeEvent{
+		Collection: c.Name,
+		Operation:  op,
+		ID:         id,
+		Document:   doc,
+	})
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestChangeLogSubscribeAndPublish(t *testing.T) {
+	log := newChangeLog()
+
+	sub, backlog, _, err := log.subscribe("events", "")
+	if err != nil {
+		t.Fatalf("subscribe error: %v", err)
+	}
+	if len(backlog) != 0 {
+		t.Errorf("expected empty backlog, got %d", len(backlog))
+	}
+
+	log.publish([]ChangeEvent{
+		{Collection: "events", Operation: OpInsert, ID: "1"},
+		{Collection: "other", Operation: OpInsert, ID: "2"},
+		{Collection: "events", Operation: OpDelete, ID: "1"},
+	})
+
+	first := <-sub.Events
+	second := <-sub.Events
+	if first.ID != "1" || first.Operation != OpInsert || second.Operation != OpDelete {
+		t.Errorf("unexpected events: %+v, %+v", first, second)
+	}
+	if len(sub.Events) != 0 {
+		t.Errorf("expected events from other collections to be skipped")
+	}
+}
+
+func TestChangeLogResume(t *testing.T) {
+	log := newChangeLog()
+
+	for i := 0; i < 5; i++ {
+		log.publish([]ChangeEvent{{Collection: "events", Operation: OpInsert, ID: fmt.Sprint(i)}})
+	}
+
+	_, backlog, last, err := log.subscribe("events", log.Token(2))
+	if err != nil {
+		t.Fatalf("subscribe error: %v", err)
+	}
+	if last != 5 {
+		t.Errorf("expected last seq 5, got %d", last)
+	}
+	if len(backlog) != 3 || backlog[0].ID != "2" {
+		t.Errorf("expected backlog of events 2..4, got %+v", backlog)
+	}
+}
+
+func TestChangeLogResumeErrors(t *testing.T) {
+	log := newChangeLog()
+	for i := 0; i < changeLogSize+10; i++ {
+		log.publish([]ChangeEvent{{Collection: "events", Operation: OpInsert}})
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"expired", log.Token(1)},
+		{"ahead", log.Token(changeLogSize + 100)},
+		{"other epoch", "abc-1"},
+		{"garbage", "garbage"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, _, err := log.subscribe("events", tt.token); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+
+	if _, backlog, _, err := log.subscribe("events", log.Token(20)); err != nil || len(backlog) != changeLogSize-10 {
+		t.Errorf("expected %d events in backlog, got %d (%v)", changeLogSize-10, len(backlog), err)
+	}
+}
+
+func TestChangeLogDropsLaggingSubscriber(t *testing.T) {
+	log := newChangeLog()
+	sub, _, _, _ := log.subscribe("events", "")
+
+	for i := 0; i < subscriberBuffer+1; i++ {
+		log.publish([]ChangeEvent{{Collection: "events", Operation: OpInsert}})
+	}
+
+	select {
+	case <-sub.Lagged:
+	default:
+		t.Error("expected lagging subscriber to be dropped")
+	}
+}
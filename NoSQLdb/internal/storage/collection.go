@@ -1,10 +1,14 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
-	"math/rand"
+	"nosql_db/internal/collation"
 	"nosql_db/internal/index"
+	"nosql_db/internal/schema"
+	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -12,34 +16,115 @@ type Collection struct {
 	mutex   sync.RWMutex
 	Name    string
 	Data    *HashMap
-	Indexes map[string]*index.BTree
+	Indexes map[string]*index.PagedBTree
+	Format  StorageFormat
+	Schema  *schema.Schema // правила проверки документов, nil — без проверки
+
+	capped *cappedState // порядок вставки и пределы capped-коллекции, nil — обычная коллекция
+
+	recording bool          // пишутся ли изменения для watch (только внутри write-операции)
+	pending   []ChangeEvent // изменения текущей write-операции
+
+	failedIndexes map[string]collation.Collation // индексы, снятые после ошибки записи, до пересборки
 }
 
 func NewCollection(name string) *Collection {
 	return &Collection{
 		Name:    name,
 		Data:    NewHashMap(),
-		Indexes: make(map[string]*index.BTree),
+		Indexes: make(map[string]*index.PagedBTree),
+		Format:  FormatJSON,
 	}
 }
 
+// ErrAlreadyStored документ с таким _id уже сохранён с тем же содержимым
+// (повтор батча после потерянного ответа) — вставка считается выполненной
+var ErrAlreadyStored = errors.New("document already stored")
+
+// ErrIDConflict документ с таким _id уже есть, но с другим содержимым
+var ErrIDConflict = errors.New("document with this _id already exists")
+
+var idCounter atomic.Uint64
+
+// generateID генерирует _id: время в наносекундах и счётчик процесса,
+// поэтому два id, выданных одним сервером, не совпадают
 func generateID() string {
-	return fmt.Sprintf("%d-%d", time.Now().UnixNano(), rand.Intn(1000000))
+	return fmt.Sprintf("%d-%d", time.Now().UnixNano(), idCounter.Add(1))
 }
 
+// Insert сохраняет документ. Если клиент передал строковый _id, он используется
+// как есть, что делает повторную вставку того же документа идемпотентной
 func (c *Collection) Insert(doc map[string]any) (string, error) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
+	defer c.rebuildFailedIndexes()
+
+	id, err := c.documentID(doc)
+	if err != nil {
+		return "", err
+	}
+
+	if existing, ok := c.Data.Get(id); ok {
+		if reflect.DeepEqual(existing, withID(doc, id)) {
+			return id, ErrAlreadyStored
+		}
+		return id, fmt.Errorf("%w: %s", ErrIDConflict, id)
+	}
+
+	var size int64
+	if c.capped != nil {
+		size = documentSize(withID(doc, id))
+		if limit := c.capped.options.MaxBytes; limit > 0 && size > limit {
+			return "", fmt.Errorf("%w: %d > %d bytes", ErrDocumentTooLarge, size, limit)
+		}
+	}
 
-	id := generateID()
 	doc["_id"] = id
 	c.Data.Put(id, doc)
 
 	c.updateIndexesOnInsert(id, doc)
+	c.recordChange(OpInsert, id, doc)
+
+	if c.capped != nil {
+		c.capped.push(id, size)
+		c.evictOverflow()
+	}
 
 	return id, nil
 }
 
+// documentID возвращает _id, переданный клиентом, или генерирует новый
+func (c *Collection) documentID(doc map[string]any) (string, error) {
+	raw, exists := doc["_id"]
+	if !exists {
+		for {
+			id := generateID()
+			if _, taken := c.Data.Get(id); !taken {
+				return id, nil
+			}
+		}
+	}
+
+	id, ok := raw.(string)
+	if !ok || id == "" {
+		return "", fmt.Errorf("_id must be a non-empty string, got %v", raw)
+	}
+	return id, nil
+}
+
+// withID возвращает документ с заданным _id, не меняя исходный
+func withID(doc map[string]any, id string) map[string]any {
+	if current, ok := doc["_id"].(string); ok && current == id {
+		return doc
+	}
+	copied := make(map[string]any, len(doc)+1)
+	for k, v := range doc {
+		copied[k] = v
+	}
+	copied["_id"] = id
+	return copied
+}
+
 // GetByID получает документ по _id
 func (c *Collection) GetByID(id string) (map[string]any, bool) {
 	c.mutex.RLock()
@@ -61,6 +146,7 @@ func (c *Collection) GetByID(id string) (map[string]any, bool) {
 func (c *Collection) Delete(id string) bool {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
+	defer c.rebuildFailedIndexes()
 
 	val, ok := c.Data.Get(id)
 	if !ok {
@@ -69,14 +155,29 @@ func (c *Collection) Delete(id string) bool {
 	doc := val.(map[string]any)
 
 	c.updateIndexesOnDelete(id, doc)
+	c.recordChange(OpDelete, id, doc)
+	if c.capped != nil {
+		c.capped.remove(id)
+	}
 
 	return c.Data.Remove(id)
 }
 
+// All возвращает документы коллекции; capped-коллекция отдаёт их в порядке вставки
 func (c *Collection) All() []map[string]any {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 
+	if c.capped != nil {
+		docs := make([]map[string]any, 0, c.capped.order.Len())
+		for _, id := range c.capped.ids() {
+			if val, ok := c.Data.Get(id); ok {
+				docs = append(docs, val.(map[string]any))
+			}
+		}
+		return docs
+	}
+
 	items := c.Data.Items()
 	docs := make([]map[string]any, 0, len(items))
 	for _, v := range items {
@@ -86,3 +187,10 @@ func (c *Collection) All() []map[string]any {
 	}
 	return docs
 }
+
+// Len возвращает количество документов в коллекции
+func (c *Collection) Len() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.Data.Size
+}
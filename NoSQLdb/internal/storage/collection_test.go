@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"nosql_db/internal/index"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCollectionInsertGeneratesUniqueIDs(t *testing.T) {
+	coll := NewCollection("test")
+
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id, err := coll.Insert(map[string]any{"n": float64(i)})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if seen[id] {
+			t.Fatalf("duplicate id %s", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestCollectionInsertClientID(t *testing.T) {
+	coll := NewCollection("test")
+
+	id, err := coll.Insert(map[string]any{"_id": "agent-1-42", "severity": "high"})
+	if err != nil || id != "agent-1-42" {
+		t.Fatalf("expected client id, got %q (%v)", id, err)
+	}
+
+	// повтор того же документа — no-op
+	_, err = coll.Insert(map[string]any{"_id": "agent-1-42", "severity": "high"})
+	if !errors.Is(err, ErrAlreadyStored) {
+		t.Errorf("expected ErrAlreadyStored, got %v", err)
+	}
+
+	// тот же _id с другим содержимым — конфликт
+	_, err = coll.Insert(map[string]any{"_id": "agent-1-42", "severity": "low"})
+	if !errors.Is(err, ErrIDConflict) {
+		t.Errorf("expected ErrIDConflict, got %v", err)
+	}
+
+	if coll.Data.Size != 1 {
+		t.Errorf("expected 1 document, got %d", coll.Data.Size)
+	}
+	doc, _ := coll.GetByID("agent-1-42")
+	if doc["severity"] != "high" {
+		t.Errorf("expected original document to be kept, got %v", doc)
+	}
+}
+
+func TestCollectionInsertInvalidID(t *testing.T) {
+	coll := NewCollection("test")
+
+	for _, id := range []any{"", 42.0, nil} {
+		if _, err := coll.Insert(map[string]any{"_id": id}); err == nil {
+			t.Errorf("expected error for _id %v", id)
+		}
+	}
+}
+
+func TestCappedCollection(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	coll := NewCollection("debug")
+	if err := coll.SetCapped(CappedOptions{MaxDocs: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := coll.CreateIndex("host", 4); err != nil {
+		t.Fatal(err)
+	}
+	coll.startRecording()
+	for i, host := range []string{"a", "b", "c", "d", "e"} {
+		if _, err := coll.Insert(map[string]any{"_id": fmt.Sprintf("id%d", 9-i), "host": host}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	changes := coll.stopRecording()
+
+	// _id убывают, но порядок — порядок вставки
+	if got := docIDs(coll.All()); !reflect.DeepEqual(got, []string{"id7", "id6", "id5"}) {
+		t.Errorf("expected newest 3 in insertion order, got %v", got)
+	}
+	btree, _ := coll.GetIndex("host")
+	if values, err := btree.Search(index.ValueToKey("a")); err != nil || len(values) != 0 {
+		t.Errorf("evicted document still indexed: %v", values)
+	}
+	if deletes := countOps(changes, OpDelete); deletes != 2 {
+		t.Errorf("expected 2 eviction events, got %d", deletes)
+	}
+
+	// удаление из середины не ломает порядок
+	coll.Delete("id6")
+	if _, err := coll.Insert(map[string]any{"_id": "id0", "host": "f"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := docIDs(coll.All()); !reflect.DeepEqual(got, []string{"id7", "id5", "id0"}) {
+		t.Errorf("unexpected order after delete: %v", got)
+	}
+
+	// предел по байтам: документ больше предела отклоняется
+	size := documentSize(map[string]any{"_id": "id0", "host": "f"})
+	if err := coll.SetCapped(CappedOptions{MaxBytes: 2 * size}); err != nil {
+		t.Fatal(err)
+	}
+	if got := docIDs(coll.All()); !reflect.DeepEqual(got, []string{"id5", "id0"}) {
+		t.Errorf("expected eviction on smaller limit, got %v", got)
+	}
+	if _, err := coll.Insert(map[string]any{"host": strings.Repeat("x", int(size)*2)}); !errors.Is(err, ErrDocumentTooLarge) {
+		t.Errorf("expected ErrDocumentTooLarge, got %v", err)
+	}
+
+	if err := NewCollection("regular").SetCapped(CappedOptions{}); err == nil {
+		t.Error("expected error for empty limits")
+	}
+	regular := NewCollection("regular")
+	regular.Insert(map[string]any{"n": 1.0})
+	if err := regular.SetCapped(CappedOptions{MaxDocs: 1}); err == nil {
+		t.Error("expected error for non-empty collection")
+	}
+
+	// порядок вставки переживает перезагрузку
+	if err := coll.Save(); err != nil {
+		t.Fatal(err)
+	}
+	coll.CloseIndexes()
+	m := NewManager()
+	defer m.Stop()
+	loaded, err := m.GetCollection("debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options, ok := loaded.Capped(); !ok || options.MaxBytes != 2*size {
+		t.Errorf("capped options not restored: %+v %v", options, ok)
+	}
+	if got := docIDs(loaded.All()); !reflect.DeepEqual(got, []string{"id5", "id0"}) {
+		t.Errorf("insertion order not restored: %v", got)
+	}
+	loaded.CloseIndexes()
+}
+
+func TestParseCappedOptions(t *testing.T) {
+	tests := []struct {
+		raw      map[string]any
+		expected CappedOptions
+		wantErr  bool
+	}{
+		{map[string]any{"max_docs": 100.0}, CappedOptions{MaxDocs: 100}, false},
+		{map[string]any{"max_docs": 10.0, "max_bytes": 4096.0}, CappedOptions{MaxDocs: 10, MaxBytes: 4096}, false},
+		{map[string]any{}, CappedOptions{}, true},
+		{map[string]any{"max_docs": 1.5}, CappedOptions{}, true},
+		{map[string]any{"max_docs": -1.0}, CappedOptions{}, true},
+		{map[string]any{"max_size": 10.0}, CappedOptions{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseCappedOptions(tt.raw)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && got != tt.expected) {
+			t.Errorf("%v: expected %+v (error %v), got %+v (%v)", tt.raw, tt.expected, tt.wantErr, got, err)
+		}
+	}
+}
+
+func docIDs(docs []map[string]any) []string {
+	ids := make([]string, len(docs))
+	for i, doc := range docs {
+		ids[i], _ = doc["_id"].(string)
+	}
+	return ids
+}
+
+func countOps(events []ChangeEvent, op string) int {
+	n := 0
+	for _, e := range events {
+		if e.Operation == op {
+			n++
+		}
+	}
+	return n
+}
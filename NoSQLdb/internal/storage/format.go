@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// StorageFormat формат файла коллекции на диске
+type StorageFormat string
+
+const (
+	FormatJSON   StorageFormat = "json"   // json.MarshalIndent, совместимый со старыми файлами
+	FormatBinary StorageFormat = "binary" // блоки length-prefixed записей со сжатием
+)
+
+// ParseFormat разбирает имя формата из конфига
+func ParseFormat(name string) (StorageFormat, error) {
+	switch StorageFormat(name) {
+	case FormatJSON:
+		return FormatJSON, nil
+	case FormatBinary:
+		return FormatBinary, nil
+	default:
+		return "", fmt.Errorf("unknown storage format: %s", name)
+	}
+}
+
+// extension возвращает расширение файла коллекции
+func (f StorageFormat) extension() string {
+	if f == FormatBinary {
+		return ".nsdb"
+	}
+	return ".json"
+}
+
+// collectionPath путь к файлу коллекции в заданном формате
+func collectionPath(name string, format StorageFormat) string {
+	return filepath.Join("data", name+format.extension())
+}
+
+// detectFormat определяет формат существующей коллекции по файлу на диске.
+// Если файлов нет, возвращает false
+func detectFormat(name string) (StorageFormat, bool) {
+	for _, format := range []StorageFormat{FormatBinary, FormatJSON} {
+		if _, err := os.Stat(collectionPath(name, format)); err == nil {
+			return format, true
+		}
+	}
+	return "", false
+}
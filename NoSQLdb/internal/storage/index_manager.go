@@ -1,22 +1,66 @@
 package storage
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"nosql_db/internal/collation"
 	"nosql_db/internal/index"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 )
 
+const (
+	indexOrder     = 64
+	indexExtension = ".pidx"
+	legacyIndexExt = ".idx" // старые json-индексы, пересобираются при загрузке
+)
+
+// indexPath путь к файлу индекса поля коллекции
+func indexPath(collName, fieldName, ext string) string {
+	return filepath.Join("data", "indexes", fmt.Sprintf("%s_%s%s", collName, fieldName, ext))
+}
+
 // CreateIndex создает индекс на указанном поле
 func (c *Collection) CreateIndex(fieldName string, order int) error {
+	return c.CreateIndexWithCollation(fieldName, order, collation.Binary)
+}
+
+// CreateIndexWithCollation создаёт индекс с правилом сравнения строк;
+// для CaseInsensitive ключи строк хранятся в свёрнутом регистре
+func (c *Collection) CreateIndexWithCollation(fieldName string, order int, coll collation.Collation) error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
 	if _, exists := c.Indexes[fieldName]; exists {
 		return fmt.Errorf("index on field '%s' already exists", fieldName)
 	}
-	btree := index.NewBPlusTree(order)
+
+	btree, err := c.buildIndex(fieldName, order, coll)
+	if err != nil {
+		return err
+	}
+	c.Indexes[fieldName] = btree
+	return nil
+}
+
+// buildIndex создаёт файл индекса заново и заполняет его документами коллекции
+func (c *Collection) buildIndex(fieldName string, order int, coll collation.Collation) (*index.PagedBTree, error) {
+	path := indexPath(c.Name, fieldName, indexExtension)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create index directory: %w", err)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove old index file: %w", err)
+	}
+
+	btree, err := index.OpenPagedBTree(path, order)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create index file: %w", err)
+	}
+	btree.SetCollation(coll)
 
 	items := c.Data.Items()
 	for _, v := range items {
@@ -26,27 +70,34 @@ func (c *Collection) CreateIndex(fieldName string, order int) error {
 		}
 		if fieldValue, exists := doc[fieldName]; exists {
 			docID := doc["_id"].(string)
-			key := index.ValueToKey(fieldValue)
-			btree.Insert(key, []byte(docID))
+			if err := btree.Insert(btree.Key(fieldValue), []byte(docID)); err != nil {
+				btree.Close()
+				return nil, fmt.Errorf("failed to write index: %w", err)
+			}
 		}
 	}
-	c.Indexes[fieldName] = btree
 
-	return c.saveIndexInternal(fieldName)
+	if err := btree.Flush(); err != nil {
+		btree.Close()
+		return nil, fmt.Errorf("failed to write index: %w", err)
+	}
+	return btree, nil
 }
 
 // HasIndex проверяет существование индекса на поле
 func (c *Collection) HasIndex(fieldName string) bool {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
+
 	_, exists := c.Indexes[fieldName]
 	return exists
 }
 
 // GetIndex возвращает индекс для поля
-func (c *Collection) GetIndex(fieldName string) (*index.BTree, bool) {
+func (c *Collection) GetIndex(fieldName string) (*index.PagedBTree, bool) {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
+
 	btree, exists := c.Indexes[fieldName]
 	return btree, exists
 }
@@ -58,21 +109,53 @@ func (c *Collection) LoadIndex(fieldName string) error {
 	return c.loadIndexInternal(fieldName)
 }
 
-// loadIndexInternal - приватная версия без блокировок
+// loadIndexInternal - приватная версия без блокировок.
+// Открывается только мета-страница, узлы читаются лениво при поиске.
+// Повреждённый файл индекса пересобирается из данных коллекции
 func (c *Collection) loadIndexInternal(fieldName string) error {
-	indexPath := filepath.Join("data", "indexes", fmt.Sprintf("%s_%s.idx", c.Name, fieldName))
-	if _, err := os.Stat(indexPath); os.IsNotExist(err) {
-		return nil
+	path := indexPath(c.Name, fieldName, indexExtension)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return c.migrateLegacyIndex(fieldName)
 	}
-	jsonData, err := os.ReadFile(indexPath)
+
+	btree, err := index.OpenPagedBTree(path, indexOrder)
 	if err != nil {
+		// индекс прежней версии или прерванный сбоем сохраняет правило сравнения;
+		// в повреждённой мете оно теряется
+		coll := collation.Binary
+		var rebuildErr *index.RebuildError
+		if errors.As(err, &rebuildErr) {
+			coll = rebuildErr.Collation
+		}
+		if btree, err = c.buildIndex(fieldName, indexOrder, coll); err != nil {
+			return fmt.Errorf("failed to rebuild index on '%s': %w", fieldName, err)
+		}
+	}
+	c.Indexes[fieldName] = btree
+	return nil
+}
+
+// migrateLegacyIndex заменяет json-индекс старого формата постраничным файлом.
+// Ключи в .idx построены прежней кодировкой значений и не совпадают с текущими,
+// поэтому индекс строится заново из документов коллекции
+func (c *Collection) migrateLegacyIndex(fieldName string) error {
+	legacyPath := indexPath(c.Name, fieldName, legacyIndexExt)
+	if _, err := os.Stat(legacyPath); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
 		return fmt.Errorf("failed to read index file: %w", err)
 	}
-	var indexData IndexFile
-	if err := json.Unmarshal(jsonData, &indexData); err != nil {
-		return fmt.Errorf("failed to unmarshal index: %w", err)
+
+	btree, err := c.buildIndex(fieldName, indexOrder, collation.Binary)
+	if err != nil {
+		return fmt.Errorf("failed to rebuild index on '%s': %w", fieldName, err)
+	}
+	if err := os.Remove(legacyPath); err != nil {
+		btree.Close()
+		return fmt.Errorf("failed to remove legacy index: %w", err)
 	}
-	btree := deserializeBTree(&indexData)
+
 	c.Indexes[fieldName] = btree
 	return nil
 }
@@ -86,49 +169,51 @@ func (c *Collection) LoadAllIndexes() error {
 	if _, err := os.Stat(indexDir); os.IsNotExist(err) {
 		return nil
 	}
+
 	entries, err := os.ReadDir(indexDir)
 	if err != nil {
 		return fmt.Errorf("failed to read index directory: %w", err)
 	}
+
 	prefix := c.Name + "_"
 	for _, entry := range entries {
 		if entry.IsDir() {
 			continue
 		}
+
 		name := entry.Name()
-		if len(name) > len(prefix) && name[:len(prefix)] == prefix && filepath.Ext(name) == ".idx" {
-			fieldName := name[len(prefix) : len(name)-4]
-			if err := c.loadIndexInternal(fieldName); err != nil {
-				return err
-			}
+		ext := filepath.Ext(name)
+		if !strings.HasPrefix(name, prefix) || (ext != indexExtension && ext != legacyIndexExt) {
+			continue
+		}
+
+		fieldName := strings.TrimSuffix(name[len(prefix):], ext)
+		if _, loaded := c.Indexes[fieldName]; loaded {
+			continue
+		}
+		if err := c.loadIndexInternal(fieldName); err != nil {
+			return err
 		}
 	}
+
 	return nil
 }
 
-// SaveIndex сохраняет индекс на диск (Публичный метод)
+// SaveIndex сохраняет изменённые страницы индекса на диск (Публичный метод)
 func (c *Collection) SaveIndex(fieldName string) error {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 	return c.saveIndexInternal(fieldName)
 }
 
-// saveIndexInternal - сохранение без блокировок (для использования внутри CreateIndex)
+// saveIndexInternal - сохранение без блокировок
 func (c *Collection) saveIndexInternal(fieldName string) error {
 	btree, exists := c.Indexes[fieldName]
 	if !exists {
 		return fmt.Errorf("index on field '%s' does not exist", fieldName)
 	}
-	indexPath := filepath.Join("data", "indexes", fmt.Sprintf("%s_%s.idx", c.Name, fieldName))
-	if err := os.MkdirAll(filepath.Dir(indexPath), 0755); err != nil {
-		return fmt.Errorf("failed to create index directory: %w", err)
-	}
-	indexData := serializeBTree(btree, fieldName, 64)
-	jsonData, err := json.MarshalIndent(indexData, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal index: %w", err)
-	}
-	if err := os.WriteFile(indexPath, jsonData, 0644); err != nil {
+
+	if err := btree.Flush(); err != nil {
 		return fmt.Errorf("failed to write index file: %w", err)
 	}
 	return nil
@@ -152,42 +237,45 @@ func (c *Collection) RebuildAllIndexes() error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	fields := make([]string, 0, len(c.Indexes))
-	for fieldName := range c.Indexes {
-		fields = append(fields, fieldName)
+	var errs []error
+	for fieldName, old := range c.Indexes {
+		coll := old.Collation()
+		if err := old.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		btree, err := c.buildIndex(fieldName, indexOrder, coll)
+		if err != nil {
+			delete(c.Indexes, fieldName)
+			errs = append(errs, err)
+			continue
+		}
+		c.Indexes[fieldName] = btree
 	}
-	c.Indexes = make(map[string]*index.BTree)
-
-	items := c.Data.Items()
+	return errors.Join(errs...)
+}
 
-	for _, fieldName := range fields {
-		btree := index.NewBPlusTree(64)
-		for _, v := range items {
-			doc, ok := v.(map[string]any)
-			if !ok {
-				continue
-			}
+// CloseIndexes сбрасывает и закрывает файлы всех индексов
+func (c *Collection) CloseIndexes() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 
-			if fieldValue, exists := doc[fieldName]; exists {
-				docID := doc["_id"].(string)
-				key := index.ValueToKey(fieldValue)
-				btree.Insert(key, []byte(docID))
-			}
-		}
-		c.Indexes[fieldName] = btree
-		if err := c.saveIndexInternal(fieldName); err != nil {
-			return err
+	var errs []error
+	for fieldName, btree := range c.Indexes {
+		if err := btree.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("index '%s': %w", fieldName, err))
 		}
 	}
-	return nil
+	c.Indexes = make(map[string]*index.PagedBTree)
+	return errors.Join(errs...)
 }
 
 // updateIndexesOnInsert (Приватный) - вызывается внутри Insert, мьютексы не нужны
 func (c *Collection) updateIndexesOnInsert(docID string, doc map[string]any) {
 	for fieldName, btree := range c.Indexes {
 		if fieldValue, exists := doc[fieldName]; exists {
-			key := index.ValueToKey(fieldValue)
-			btree.Insert(key, []byte(docID))
+			if err := btree.Insert(btree.Key(fieldValue), []byte(docID)); err != nil {
+				c.indexFailed(fieldName, btree, err)
+			}
 		}
 	}
 }
@@ -196,8 +284,67 @@ func (c *Collection) updateIndexesOnInsert(docID string, doc map[string]any) {
 func (c *Collection) updateIndexesOnDelete(docID string, doc map[string]any) {
 	for fieldName, btree := range c.Indexes {
 		if fieldValue, exists := doc[fieldName]; exists {
-			key := index.ValueToKey(fieldValue)
-			btree.Delete(key, []byte(docID))
+			if _, err := btree.Delete(btree.Key(fieldValue), []byte(docID)); err != nil {
+				c.indexFailed(fieldName, btree, err)
+			}
+		}
+	}
+}
+
+// indexFailed снимает индекс, который не удалось обновить: до пересборки
+// запросы по полю идут полным сканом
+func (c *Collection) indexFailed(fieldName string, btree *index.PagedBTree, err error) {
+	slog.Error("index update failed, rebuilding", "collection", c.Name, "field", fieldName, "error", err)
+	delete(c.Indexes, fieldName)
+	if c.failedIndexes == nil {
+		c.failedIndexes = make(map[string]collation.Collation)
+	}
+	c.failedIndexes[fieldName] = btree.Collation()
+	btree.Close()
+}
+
+// rebuildFailedIndexes строит снятые индексы заново из документов; вызывается
+// под блокировкой после изменения данных. Не пересобранный индекс удаляется,
+// его файл пересоберётся при следующей загрузке
+func (c *Collection) rebuildFailedIndexes() {
+	for fieldName, coll := range c.failedIndexes {
+		delete(c.failedIndexes, fieldName)
+		btree, err := c.buildIndex(fieldName, indexOrder, coll)
+		if err != nil {
+			slog.Error("index rebuild failed", "collection", c.Name, "field", fieldName, "error", err)
+			continue
 		}
+		c.Indexes[fieldName] = btree
+	}
+}
+
+// IndexNames возвращает отсортированные имена индексированных полей
+func (c *Collection) IndexNames() []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	names := make([]string, 0, len(c.Indexes))
+	for fieldName := range c.Indexes {
+		names = append(names, fieldName)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DropIndex закрывает индекс и удаляет его файл
+func (c *Collection) DropIndex(fieldName string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	btree, exists := c.Indexes[fieldName]
+	if !exists {
+		return fmt.Errorf("index on field '%s' does not exist", fieldName)
+	}
+	delete(c.Indexes, fieldName)
+
+	closeErr := btree.Close()
+	if err := os.Remove(indexPath(c.Name, fieldName, indexExtension)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove index file: %w", err)
 	}
+	return closeErr
 }
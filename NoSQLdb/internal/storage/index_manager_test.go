@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"nosql_db/internal/collation"
+	"nosql_db/internal/index"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLegacyIndexIsRebuilt(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	coll := NewCollection("events")
+	for _, port := range []float64{22, 443, 22} {
+		if _, err := coll.Insert(map[string]any{"port": port}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// ключи .idx построены прежней кодировкой и не должны попасть в новый индекс
+	legacyPath := indexPath("events", "port", legacyIndexExt)
+	if err := os.MkdirAll(filepath.Dir(legacyPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	legacy := `{"field":"port","order":4,"nodes":[{"is_leaf":true,"keys":["QDYAAAAAAAA="],"values":[["b2xk"]]}]}`
+	if err := os.WriteFile(legacyPath, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := coll.LoadAllIndexes(); err != nil {
+		t.Fatal(err)
+	}
+	defer coll.CloseIndexes()
+
+	btree, ok := coll.GetIndex("port")
+	if !ok {
+		t.Fatal("legacy index not loaded")
+	}
+	tests := []struct {
+		value    float64
+		expected int
+	}{
+		{22, 2},
+		{443, 1},
+		{80, 0},
+	}
+	for _, tt := range tests {
+		values, err := btree.Search(btree.Key(tt.value))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(values) != tt.expected {
+			t.Errorf("port %v: expected %d documents, got %d", tt.value, tt.expected, len(values))
+		}
+	}
+	if _, err := os.Stat(legacyPath); !os.IsNotExist(err) {
+		t.Errorf("legacy index file not removed: %v", err)
+	}
+}
+
+func TestOldVersionIndexKeepsCollation(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	coll := NewCollection("events")
+	for _, user := range []string{"Alice", "alice", "bob"} {
+		if _, err := coll.Insert(map[string]any{"user": user}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := coll.CreateIndexWithCollation("user", 8, collation.CaseInsensitive); err != nil {
+		t.Fatal(err)
+	}
+	if err := coll.CloseIndexes(); err != nil {
+		t.Fatal(err)
+	}
+
+	// версия 3 в мете: байт 4 данных мета-страницы после заголовка страницы (7 байт)
+	file, err := os.OpenFile(indexPath("events", "user", indexExtension), os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteAt([]byte{3}, 7+4); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	if err := coll.LoadIndex("user"); err != nil {
+		t.Fatal(err)
+	}
+	defer coll.CloseIndexes()
+
+	btree, _ := coll.GetIndex("user")
+	if btree.Collation() != collation.CaseInsensitive {
+		t.Fatalf("expected case-insensitive index after rebuild, got %v", btree.Collation())
+	}
+	values, err := btree.Search(btree.Key("ALICE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 {
+		t.Errorf("expected 2 documents for folded key, got %d", len(values))
+	}
+}
+
+func TestFailedIndexIsRebuilt(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	coll := NewCollection("events")
+	if _, err := coll.Insert(map[string]any{"_id": "a", "host": "web-01"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := coll.CreateIndex("host", 8); err != nil {
+		t.Fatal(err)
+	}
+	defer coll.CloseIndexes()
+
+	// дерево, которое отклоняет записи, как после ошибки ввода-вывода
+	broken, _ := coll.GetIndex("host")
+	broken.Close()
+
+	if _, err := coll.Insert(map[string]any{"_id": "b", "host": "web-01"}); err != nil {
+		t.Fatal(err)
+	}
+	btree, ok := coll.GetIndex("host")
+	if !ok || btree == broken {
+		t.Fatal("index not rebuilt after failed write")
+	}
+	values, err := btree.Search(btree.Key("web-01"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 {
+		t.Errorf("expected both documents in rebuilt index, got %d", len(values))
+	}
+
+	btree.Close()
+	coll.Delete("a")
+	btree, _ = coll.GetIndex("host")
+	if values, err := btree.Search(btree.Key("web-01")); err != nil || len(values) != 1 {
+		t.Errorf("expected deleted document gone from rebuilt index, got %d, %v", len(values), err)
+	}
+}
+
+func TestInterruptedIndexIsRebuiltOnLoad(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	coll := NewCollection("events")
+	if err := coll.CreateIndexWithCollation("user", 8, collation.CaseInsensitive); err != nil {
+		t.Fatal(err)
+	}
+	defer coll.CloseIndexes()
+	for _, user := range []string{"Alice", "bob"} {
+		if _, err := coll.Insert(map[string]any{"user": user}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// процесс упал без Flush: та же коллекция загружает индекс заново
+	restarted := &Collection{Name: "events", Data: coll.Data, Indexes: make(map[string]*index.PagedBTree)}
+	if err := restarted.LoadIndex("user"); err != nil {
+		t.Fatal(err)
+	}
+	defer restarted.CloseIndexes()
+
+	btree, _ := restarted.GetIndex("user")
+	if btree.Collation() != collation.CaseInsensitive {
+		t.Errorf("collation lost on rebuild: %v", btree.Collation())
+	}
+	if values, err := btree.Search(btree.Key("ALICE")); err != nil || len(values) != 1 {
+		t.Errorf("expected rebuilt index to find Alice, got %d, %v", len(values), err)
+	}
+}
@@ -1,10 +1,18 @@
 package storage
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
 	"sync"
 )
 
+// ErrManagerClosed очередь записи остановлена, новые задачи не принимаются
+var ErrManagerClosed = errors.New("write queue is closed")
+
 // WriteJob — задача в очереди модификации
 type WriteJob struct {
 	DBName     string                                      // имя базы/коллекции
@@ -25,6 +33,13 @@ type CollectionMng struct {
 	collections map[string]*Collection
 	writeQueue  chan WriteJob
 	stopChan    chan struct{}
+	workerDone  chan struct{}
+	changes     *changeLog
+
+	queueMu sync.RWMutex // закрытие очереди ждёт уже начатых Enqueue
+	closed  bool
+
+	format StorageFormat // формат новых коллекций, у которых ещё нет файла на диске
 }
 
 const writeQueueSize = 100
@@ -34,11 +49,22 @@ func NewManager() *CollectionMng {
 		collections: make(map[string]*Collection),
 		writeQueue:  make(chan WriteJob, writeQueueSize),
 		stopChan:    make(chan struct{}),
+		workerDone:  make(chan struct{}),
+		changes:     newChangeLog(),
+		format:      FormatJSON,
 	}
 	go m.worker()
 	return m
 }
 
+// SetDefaultFormat задаёт формат новых коллекций; уже загруженные и
+// существующие на диске коллекции сохраняют свой
+func (m *CollectionMng) SetDefaultFormat(format StorageFormat) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.format = format
+}
+
 var GlobalManager = NewManager()
 
 func (m *CollectionMng) GetCollection(name string) (*Collection, error) {
@@ -49,28 +75,45 @@ func (m *CollectionMng) GetCollection(name string) (*Collection, error) {
 		return coll, nil
 	}
 
-	coll, err := LoadCollection(name)
+	coll, err := LoadCollection(name, m.format)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := coll.loadCapped(); err != nil {
+		return nil, err
+	}
+
 	if err := coll.LoadAllIndexes(); err != nil {
 		return nil, fmt.Errorf("failed to load index %w", err)
 	}
 
+	if coll.Schema, err = loadSchema(name); err != nil {
+		return nil, err
+	}
+
 	m.collections[name] = coll
 
 	return coll, nil
 }
 
 func (m *CollectionMng) worker() {
+	defer close(m.workerDone)
 	for {
 		select {
 		case job := <-m.writeQueue:
 			result := m.processJob(job)
 			job.ResultChan <- result
 		case <-m.stopChan:
-			return
+			// дорабатываем задачи, поставленные до остановки
+			for {
+				select {
+				case job := <-m.writeQueue:
+					job.ResultChan <- m.processJob(job)
+				default:
+					return
+				}
+			}
 		}
 	}
 }
@@ -81,10 +124,14 @@ func (m *CollectionMng) processJob(job WriteJob) WriteResult {
 		return WriteResult{Error: fmt.Errorf("failed to get collection: %w", err)}
 	}
 
+	// изменения уходят подписчикам watch только после успешного завершения операции
+	coll.startRecording()
 	result, err := job.Operation(coll)
+	changes := coll.stopRecording()
 	if err != nil {
 		return WriteResult{Error: err}
 	}
+	m.changes.publish(changes)
 	return result
 }
 
@@ -95,10 +142,107 @@ func (m *CollectionMng) Enqueue(dbName string, operation func(coll *Collection)
 		Operation:  operation,
 		ResultChan: resultChan,
 	}
+
+	m.queueMu.RLock()
+	if m.closed {
+		m.queueMu.RUnlock()
+		return WriteResult{Error: ErrManagerClosed}
+	}
 	m.writeQueue <- job
+	m.queueMu.RUnlock()
+
 	return <-resultChan
 }
 
+// Stop закрывает очередь: новые задачи отклоняются, уже поставленные выполняются
 func (m *CollectionMng) Stop() {
-	close(m.stopChan)
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+
+	if !m.closed {
+		m.closed = true
+		close(m.stopChan)
+	}
+}
+
+// Shutdown закрывает очередь, дожидается выполнения поставленных задач
+// и сбрасывает коллекции и индексы на диск
+func (m *CollectionMng) Shutdown(ctx context.Context) error {
+	m.Stop()
+
+	select {
+	case <-m.workerDone:
+	case <-ctx.Done():
+		return fmt.Errorf("write queue not drained: %w", ctx.Err())
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs []error
+	for name, coll := range m.collections {
+		if err := coll.Save(); err != nil {
+			errs = append(errs, fmt.Errorf("save %s: %w", name, err))
+		}
+		if err := coll.CloseIndexes(); err != nil {
+			errs = append(errs, fmt.Errorf("close indexes of %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// QueueDepth возвращает число задач, ожидающих в очереди записи
+func (m *CollectionMng) QueueDepth() int {
+	return len(m.writeQueue)
+}
+
+// CollectionSizes возвращает количество документов в каждой загруженной коллекции
+func (m *CollectionMng) CollectionSizes() map[string]int {
+	m.mu.Lock()
+	collections := make([]*Collection, 0, len(m.collections))
+	for _, coll := range m.collections {
+		collections = append(collections, coll)
+	}
+	m.mu.Unlock()
+
+	sizes := make(map[string]int, len(collections))
+	for _, coll := range collections {
+		sizes[coll.Name] = coll.Len()
+	}
+	return sizes
+}
+
+// CollectionNames возвращает отсортированные имена загруженных коллекций
+// и коллекций, сохранённых на диске
+func (m *CollectionMng) CollectionNames() ([]string, error) {
+	seen := make(map[string]bool)
+
+	m.mu.Lock()
+	for name := range m.collections {
+		seen[name] = true
+	}
+	m.mu.Unlock()
+
+	entries, err := os.ReadDir("data")
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read data directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		for _, format := range []StorageFormat{FormatJSON, FormatBinary} {
+			if base, ok := strings.CutSuffix(name, format.extension()); ok && base != "" {
+				seen[base] = true
+			}
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
 }
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestManagerShutdownDrainsQueueAndFlushes(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.MkdirAll("data", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewManager()
+	insert := func(coll *Collection) (WriteResult, error) {
+		id, err := coll.Insert(map[string]any{"severity": "high"})
+		return WriteResult{InsertedIDs: []string{id}}, err
+	}
+
+	// первая задача держит воркер, остальные ждут в очереди
+	started, release := make(chan struct{}), make(chan struct{})
+	results := make(chan WriteResult, 6)
+	go func() {
+		results <- m.Enqueue("events", func(coll *Collection) (WriteResult, error) {
+			close(started)
+			<-release
+			return insert(coll)
+		})
+	}()
+	<-started
+	for i := 0; i < 5; i++ {
+		go func() { results <- m.Enqueue("events", insert) }()
+	}
+	waitFor(t, func() bool { return m.QueueDepth() == 5 })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- m.Shutdown(ctx) }()
+
+	waitFor(t, func() bool {
+		m.queueMu.RLock()
+		defer m.queueMu.RUnlock()
+		return m.closed
+	})
+	if res := m.Enqueue("events", insert); !errors.Is(res.Error, ErrManagerClosed) {
+		t.Errorf("expected ErrManagerClosed, got %v", res.Error)
+	}
+	close(release)
+
+	if err := <-shutdown; err != nil {
+		t.Fatalf("shutdown error: %v", err)
+	}
+	for i := 0; i < 6; i++ {
+		if r := <-results; r.Error != nil {
+			t.Errorf("queued job failed: %v", r.Error)
+		}
+	}
+
+	coll, err := LoadCollection("events", FormatJSON)
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if coll.Len() != 6 {
+		t.Errorf("expected 6 documents on disk, got %d", coll.Len())
+	}
+}
+
+func TestCollectionNamesAndIndexAdmin(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.MkdirAll("data", 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"auth.json", "syslog.nsdb", "syslog.json.tmp"} {
+		if err := os.WriteFile(filepath.Join("data", name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := NewManager()
+	defer m.Stop()
+	coll, err := m.GetCollection("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := m.CollectionNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"auth", "events", "syslog"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected collections %v, got %v", expected, names)
+	}
+
+	for _, field := range []string{"user", "host"} {
+		if err := coll.CreateIndex(field, 8); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if expected := []string{"host", "user"}; !reflect.DeepEqual(coll.IndexNames(), expected) {
+		t.Errorf("expected indexes %v, got %v", expected, coll.IndexNames())
+	}
+
+	if err := coll.DropIndex("user"); err != nil {
+		t.Fatalf("drop error: %v", err)
+	}
+	if coll.HasIndex("user") {
+		t.Error("index still registered after drop")
+	}
+	if _, err := os.Stat(indexPath("events", "user", indexExtension)); !os.IsNotExist(err) {
+		t.Errorf("index file not removed: %v", err)
+	}
+	if err := coll.DropIndex("user"); err == nil {
+		t.Error("expected error when dropping missing index")
+	}
+	coll.CloseIndexes()
+}
+
+func TestManagerDefaultFormat(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	binary := NewManager()
+	defer binary.Stop()
+	binary.SetDefaultFormat(FormatBinary)
+	plain := NewManager()
+	defer plain.Stop()
+
+	// формат задаётся менеджеру, а не всему пакету
+	tests := []struct {
+		m        *CollectionMng
+		name     string
+		expected StorageFormat
+	}{
+		{binary, "events", FormatBinary},
+		{plain, "users", FormatJSON},
+	}
+	for _, tt := range tests {
+		coll, err := tt.m.GetCollection(tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if coll.Format != tt.expected {
+			t.Errorf("%s: expected format %s, got %s", tt.name, tt.expected, coll.Format)
+		}
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -8,50 +9,146 @@ import (
 	"strings"
 )
 
-// LoadCollection загружает коллекцию из базы данных
-func LoadCollection(name string) (*Collection, error) {
-	path := filepath.Join("data", name+".json")
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return NewCollection(name), nil
+// LoadCollection загружает коллекцию из базы данных; новая коллекция
+// (без файла на диске) будет сохраняться в формате newFormat
+func LoadCollection(name string, newFormat StorageFormat) (*Collection, error) {
+	format, exists := detectFormat(name)
+	if !exists {
+		coll := NewCollection(name)
+		coll.Format = newFormat
+		return coll, nil
 	}
-	bytes, err := os.ReadFile(path)
+
+	raw, err := readCollectionFile(name, format)
 	if err != nil {
 		return nil, err
 	}
-
-	// Файл может существовать, но быть пустым или содержать только пробелы
-	if len(strings.TrimSpace(string(bytes))) == 0 {
-		return NewCollection(name), nil
-	}
-	var raw map[string]any
-	if err := json.Unmarshal(bytes, &raw); err != nil {
-		return nil, fmt.Errorf("unmarshal error: %w", err)
-	}
 	hmap := NewHashMap()
 	for k, v := range raw {
 		hmap.Put(k, v)
 	}
 	coll := NewCollection(name)
 	coll.Data = hmap
+	coll.Format = format
 	return coll, nil
 }
 
-// Save сохраняет данные в json в базе данных
+// readCollectionFile читает документы коллекции из файла в заданном формате
+func readCollectionFile(name string, format StorageFormat) (map[string]any, error) {
+	content, err := os.ReadFile(collectionPath(name, format))
+	if err != nil {
+		return nil, err
+	}
+
+	// Файл может существовать, но быть пустым или содержать только пробелы
+	if len(bytes.TrimSpace(content)) == 0 {
+		return map[string]any{}, nil
+	}
+
+	var raw map[string]any
+	switch format {
+	case FormatBinary:
+		if raw, err = decodeBinary(bytes.NewReader(content)); err != nil {
+			return nil, fmt.Errorf("decode error: %w", err)
+		}
+	default:
+		if err := json.Unmarshal(content, &raw); err != nil {
+			return nil, fmt.Errorf("unmarshal error: %w", err)
+		}
+	}
+	return raw, nil
+}
+
+// Save сохраняет данные коллекции на диск в её формате,
+// для capped-коллекции — ещё и порядок вставки
 func (c *Collection) Save() error {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 
-	items := c.Data.Items()
-	data, err := json.MarshalIndent(items, "", "  ")
-	if err != nil {
-		return fmt.Errorf("marshal error: %w", err)
+	if err := writeCollectionFile(c.Name, c.Format, c.Data.Items()); err != nil {
+		return err
+	}
+	if c.capped != nil {
+		return c.saveCapped()
+	}
+	return nil
+}
+
+// writeCollectionFile пишет документы во временный файл и атомарно
+// подменяет им файл коллекции
+func writeCollectionFile(name string, format StorageFormat, items map[string]any) error {
+	var data []byte
+	switch format {
+	case FormatBinary:
+		var buf bytes.Buffer
+		if err := encodeBinary(&buf, items); err != nil {
+			return fmt.Errorf("encode error: %w", err)
+		}
+		data = buf.Bytes()
+	default:
+		var err error
+		if data, err = json.MarshalIndent(items, "", "  "); err != nil {
+			return fmt.Errorf("marshal error: %w", err)
+		}
 	}
+
 	if err := os.MkdirAll("data", 0755); err != nil {
 		return fmt.Errorf("mkdir error: %w", err)
 	}
-	path := filepath.Join("data", c.Name+".json")
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	path := collectionPath(name, format)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
 		return fmt.Errorf("write file error: %w", err)
 	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("rename error: %w", err)
+	}
 	return nil
 }
+
+// ConvertCollection переводит файл коллекции в другой формат.
+// Старый файл удаляется только после успешной записи нового
+func ConvertCollection(name string, to StorageFormat) error {
+	from, exists := detectFormat(name)
+	if !exists {
+		return fmt.Errorf("collection %s not found", name)
+	}
+	if from == to {
+		return nil
+	}
+
+	raw, err := readCollectionFile(name, from)
+	if err != nil {
+		return err
+	}
+	if err := writeCollectionFile(name, to, raw); err != nil {
+		return err
+	}
+	if err := os.Remove(collectionPath(name, from)); err != nil {
+		return fmt.Errorf("remove old file: %w", err)
+	}
+	return nil
+}
+
+// ListCollections возвращает имена коллекций, сохранённых на диске
+func ListCollections() ([]string, error) {
+	entries, err := os.ReadDir("data")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		ext := filepath.Ext(entry.Name())
+		if ext == FormatJSON.extension() || ext == FormatBinary.extension() {
+			names = append(names, strings.TrimSuffix(entry.Name(), ext))
+		}
+	}
+	return names, nil
+}
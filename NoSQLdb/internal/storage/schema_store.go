@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"nosql_db/internal/schema"
+	"os"
+	"path/filepath"
+)
+
+// schemaPath путь к файлу схемы коллекции
+func schemaPath(name string) string {
+	return filepath.Join("data", "schemas", name+".json")
+}
+
+// loadSchema читает схему коллекции с диска, если она задана
+func loadSchema(name string) (*schema.Schema, error) {
+	data, err := os.ReadFile(schemaPath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal schema: %w", err)
+	}
+	return schema.Parse(raw)
+}
+
+// SetSchema задаёт схему коллекции и сохраняет её на диск; nil снимает проверку
+func (c *Collection) SetSchema(s *schema.Schema) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	path := schemaPath(c.Name)
+	if s == nil {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove schema: %w", err)
+		}
+		c.Schema = nil
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.Raw(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal schema: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create schema directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write schema: %w", err)
+	}
+
+	c.Schema = s
+	return nil
+}
+
+// GetSchema возвращает текущую схему коллекции (nil, если не задана)
+func (c *Collection) GetSchema() *schema.Schema {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.Schema
+}
+
+// Validate проверяет документ по схеме коллекции
+func (c *Collection) Validate(doc map[string]any) []string {
+	s := c.GetSchema()
+	if s == nil {
+		return nil
+	}
+	return s.Validate(doc)
+}
+
+// Normalize применяет к документу правила normalize схемы коллекции
+func (c *Collection) Normalize(doc map[string]any) {
+	if s := c.GetSchema(); s != nil {
+		s.Normalize(doc)
+	}
+}
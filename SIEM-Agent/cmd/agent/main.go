@@ -1,9 +1,11 @@
 package main
 
 import (
+	"crypto/tls"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 
 	"github.com/Narotan/SIEM-Agent/internal/config"
@@ -85,7 +87,6 @@ func main() {
 	})
 
 	pipeline.Start(parsedEvents)
-	defer pipeline.Stop()
 
 	logger.Info("Pipeline started (batch_size: %d, interval: %s)",
 		cfg.Logging.BatchSize, cfg.Logging.SendInterval)
@@ -94,39 +95,121 @@ func main() {
 	syslogParser := parser.NewSyslogParser()
 	bashParser := parser.NewBashParser()
 
-	router := parser.NewRouter(auditParser, syslogParser, bashParser)
+	journalParser := parser.NewJournalParser()
 
-	readers := make([]*reader.Reader, 0)
+	router := parser.NewRouter(journalParser, auditParser, syslogParser, bashParser)
+
+	registryFile := cfg.Registry.File
+	if registryFile == "" {
+		registryFile = filepath.Join(cfg.Buffer.Directory, "registry", "offsets.json")
+	}
+	registry, err := reader.OpenRegistry(registryFile)
+	if err != nil {
+		logger.Error("Failed to open offset registry: %v", err)
+		log.Fatalf("Failed to open offset registry: %v", err)
+	}
+	logger.Info("Offset registry: %s", registryFile)
 
 	predefinedSources := map[string]string{
 		"auditd":       "/var/log/audit/audit.log",
-		"syslog":       "/var/log/syslog",
 		"bash_history": os.Getenv("HOME") + "/.bash_history",
 	}
 
+	patterns := make([]string, 0, len(cfg.Logging.Sources))
+	useJournal := false
 	for _, source := range cfg.Logging.Sources {
-		path, ok := predefinedSources[source]
-		if !ok {
-			path = source
+		if source == "syslog" {
+			source = syslogSource()
 		}
-
-		r, err := reader.New(path)
-		if err != nil {
-			logger.Warn("Failed to create reader for %s: %v", path, err)
-			log.Printf("Warning: failed to create reader for %s: %v", path, err)
+		if source == reader.JournalSource {
+			useJournal = true
 			continue
 		}
+		if path, ok := predefinedSources[source]; ok {
+			source = path
+		}
+		patterns = append(patterns, source)
+	}
 
-		readers = append(readers, r)
+	discovery, err := reader.NewDiscovery(reader.DiscoveryConfig{
+		Patterns:         patterns,
+		Exclude:          cfg.Logging.Exclude,
+		MaxOpenFiles:     cfg.Logging.MaxOpenFiles,
+		ScanInterval:     cfg.Logging.ScanInterval,
+		IngestCompressed: cfg.Logging.IngestCompressed,
+		Registry:         registry,
+	})
+	if err != nil {
+		logger.Error("Failed to configure sources: %v", err)
+		log.Fatalf("Failed to configure sources: %v", err)
+	}
+	rules := make([]reader.MultilineRule, 0, len(cfg.Logging.Multiline))
+	for _, rule := range cfg.Logging.Multiline {
+		rules = append(rules, reader.MultilineRule(rule))
+	}
+	multiline, err := reader.NewMultiline(rules)
+	if err != nil {
+		logger.Error("Failed to configure multiline rules: %v", err)
+		log.Fatalf("Failed to configure multiline rules: %v", err)
+	}
 
-		if err := r.Start(); err != nil {
-			logger.Warn("Failed to start reader for %s: %v", path, err)
-			log.Printf("Warning: failed to start reader for %s: %v", path, err)
-			continue
+	discovery.Start()
+	events := []<-chan reader.RawEvent{discovery.Events()}
+	readerErrors := []<-chan error{discovery.Errors()}
+
+	var journal *reader.Journal
+	if useJournal {
+		journal = reader.NewJournal(reader.JournalConfig{
+			Command:   cfg.Journal.Command,
+			Directory: cfg.Journal.Directory,
+			Units:     cfg.Journal.Units,
+			Registry:  registry,
+		})
+		if err := journal.Start(); err != nil {
+			logger.Error("Failed to start journal reader: %v", err)
+			log.Fatalf("Failed to start journal reader: %v", err)
+		}
+		events = append(events, journal.Events())
+		readerErrors = append(readerErrors, journal.Errors())
+		logger.Info("Monitoring: journald")
+		log.Printf("Started monitoring: journald")
+	}
+
+	var listener *reader.SyslogListener
+	if l := cfg.Listener; l.UDP != "" || l.TCP != "" || l.TLS != "" {
+		listenerConfig := reader.SyslogListenerConfig{
+			UDP:            l.UDP,
+			TCP:            l.TCP,
+			TLS:            l.TLS,
+			MaxMessageSize: l.MaxMessageSize,
+		}
+		if l.TLS != "" {
+			cert, err := tls.LoadX509KeyPair(l.TLSCert, l.TLSKey)
+			if err != nil {
+				logger.Error("Failed to load syslog TLS certificate: %v", err)
+				log.Fatalf("Failed to load syslog TLS certificate: %v", err)
+			}
+			listenerConfig.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+		}
+
+		listener = reader.NewSyslogListener(listenerConfig)
+		if err := listener.Start(); err != nil {
+			logger.Error("Failed to start syslog listener: %v", err)
+			log.Fatalf("Failed to start syslog listener: %v", err)
 		}
+		events = append(events, listener.Events())
+		readerErrors = append(readerErrors, listener.Errors())
+		for _, transport := range []string{"udp", "tcp", "tls"} {
+			if addr := listener.Addr(transport); addr != nil {
+				logger.Info("Listening for syslog over %s on %s", transport, addr)
+				log.Printf("Listening for syslog over %s on %s", transport, addr)
+			}
+		}
+	}
 
-		go router.Start(r.Events(), r.Errors(), parsedEvents)
+	router.Start(multiline.Run(reader.Merge(events...)), reader.Merge(readerErrors...), parsedEvents)
 
+	for _, path := range discovery.Sources() {
 		logger.Info("Monitoring: %s", path)
 		log.Printf("Started monitoring: %s", path)
 	}
@@ -141,16 +224,32 @@ func main() {
 	<-sigChan
 	log.Println("Shutting down...")
 
-	for _, r := range readers {
-		r.Stop()
+	discovery.Stop()
+	if journal != nil {
+		journal.Stop()
+	}
+	if listener != nil {
+		listener.Stop()
 	}
 
 	router.Stop()
 	close(parsedEvents)
+	// последний батч подтверждает позиции источников
+	pipeline.Stop()
 
 	log.Println("SIEM Agent stopped")
 }
 
+// syslogSource файл системного журнала; на хостах только с journald — сам journald
+func syslogSource() string {
+	for _, path := range []string{"/var/log/syslog", "/var/log/messages"} {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return reader.JournalSource
+}
+
 func parseLogLevel(level string) logger.Level {
 	switch level {
 	case "debug":
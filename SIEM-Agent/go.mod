@@ -1,10 +1,14 @@
 module github.com/Narotan/SIEM-Agent
 
-go 1.23
+go 1.25.3
 
 require (
 	github.com/fsnotify/fsnotify v1.9.0
+	github.com/klauspost/compress v1.18.0
 	gopkg.in/yaml.v3 v3.0.1
+	nosql_db v0.0.0
 )
 
 require golang.org/x/sys v0.13.0 // indirect
+
+replace nosql_db => ../NoSQLdb
@@ -12,6 +12,9 @@ type Config struct {
 	Logging      LoggingConfig  `yaml:"logging"`
 	AgentLogging AgentLogConfig `yaml:"agent_logging"`
 	Buffer       BufferConfig   `yaml:"buffer"`
+	Registry     RegistryConfig `yaml:"registry"`
+	Journal      JournalConfig  `yaml:"journal"`
+	Listener     ListenerConfig `yaml:"syslog_listener"`
 	Filters      FilterConfig   `yaml:"filters"`
 	Retry        RetryConfig    `yaml:"retry"`
 }
@@ -22,10 +25,24 @@ type ServerConfig struct {
 }
 
 type LoggingConfig struct {
-	AgentID      string        `yaml:"agent_id"`
-	Sources      []string      `yaml:"sources"`
-	SendInterval time.Duration `yaml:"send_interval"`
-	BatchSize    int           `yaml:"batch_size"`
+	AgentID          string            `yaml:"agent_id"`
+	Sources          []string          `yaml:"sources"`           // пути, glob-шаблоны или auditd/syslog/bash_history
+	Exclude          []string          `yaml:"exclude"`           // исключаемые файлы (glob по имени или пути)
+	MaxOpenFiles     int               `yaml:"max_open_files"`    // 0 — без ограничения
+	ScanInterval     time.Duration     `yaml:"scan_interval"`     // пересчёт шаблонов, по умолчанию 10s
+	IngestCompressed bool              `yaml:"ingest_compressed"` // один раз прочитать .gz/.bz2/.zst архивы источников
+	Multiline        []MultilineConfig `yaml:"multiline"`
+	SendInterval     time.Duration     `yaml:"send_interval"`
+	BatchSize        int               `yaml:"batch_size"`
+}
+
+// MultilineConfig склейка многострочных событий источника
+type MultilineConfig struct {
+	Source   string        `yaml:"source"`    // glob по имени файла или пути
+	Start    string        `yaml:"start"`     // регулярка первой строки события
+	Continue string        `yaml:"continue"`  // регулярка строки-продолжения
+	MaxLines int           `yaml:"max_lines"` // по умолчанию 500
+	Timeout  time.Duration `yaml:"timeout"`   // по умолчанию 1s
 }
 
 type AgentLogConfig struct {
@@ -40,6 +57,28 @@ type BufferConfig struct {
 	MaxSize   int64  `yaml:"max_size"`
 }
 
+// RegistryConfig файл с подтверждёнными позициями чтения источников
+type RegistryConfig struct {
+	File string `yaml:"file"` // по умолчанию <buffer.directory>/registry/offsets.json
+}
+
+// JournalConfig источник journald (journald в logging.sources)
+type JournalConfig struct {
+	Command   string   `yaml:"command"`   // по умолчанию journalctl
+	Directory string   `yaml:"directory"` // каталог файлов журнала, пусто — системный журнал
+	Units     []string `yaml:"units"`     // пусто — все юниты
+}
+
+// ListenerConfig приём syslog по сети; пустой адрес — транспорт выключен
+type ListenerConfig struct {
+	UDP            string `yaml:"udp"` // например ":514"
+	TCP            string `yaml:"tcp"`
+	TLS            string `yaml:"tls"` // требует tls_cert и tls_key
+	TLSCert        string `yaml:"tls_cert"`
+	TLSKey         string `yaml:"tls_key"`
+	MaxMessageSize int    `yaml:"max_message_size"` // по умолчанию 64KB
+}
+
 type FilterConfig struct {
 	ExcludePatterns   []string `yaml:"exclude_patterns"`
 	IncludePatterns   []string `yaml:"include_patterns"`
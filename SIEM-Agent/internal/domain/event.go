@@ -15,6 +15,31 @@ type Event struct {
 	User      string    `json:"user,omitempty"`
 	Process   string    `json:"process,omitempty"`
 	Command   string    `json:"command,omitempty"`
+	Unit      string    `json:"unit,omitempty"` // юнит systemd (journald)
+	PID       int       `json:"pid,omitempty"`
+	Facility  string    `json:"facility,omitempty"` // из <PRI> syslog: auth, daemon, local0...
+	Level     string    `json:"level,omitempty"`    // уровень syslog: err, warning, info...
+
+	// поля аутентификации (sshd, PAM, sudo, su, useradd...)
+	SrcIP       string `json:"src_ip,omitempty"`
+	SrcPort     int    `json:"src_port,omitempty"`
+	AuthMethod  string `json:"auth_method,omitempty"`  // password, publickey, keyboard-interactive/pam...
+	TargetUser  string `json:"target_user,omitempty"`  // учётная запись, в которую переключаются или которую меняют
+	TTY         string `json:"tty,omitempty"`          // pts/0
+	SudoCommand string `json:"sudo_command,omitempty"` // команда, запущенная через sudo
+	Result      string `json:"result,omitempty"`       // success, failure
+
+	Remote         string                       `json:"remote_addr,omitempty"`     // отправитель сообщения, принятого по сети
+	StructuredData map[string]map[string]string `json:"structured_data,omitempty"` // SD-элементы RFC 5424
+
+	Ack *Ack `json:"-"` // подтверждение доставки источнику, nil — без позиции
+}
+
+// Ack сдвигает позицию чтения источника до события. Подтверждения одного
+// источника применяются по порядку, поэтому достаточно вызвать последнее
+type Ack struct {
+	Source string
+	Commit func() error
 }
 
 // Batch набор событий
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"encoding/hex"
 	"regexp"
 	"strings"
 	"time"
@@ -9,6 +10,15 @@ import (
 	"github.com/Narotan/SIEM-Agent/internal/reader"
 )
 
+// auditSerial идентификатор события auditd: все его записи несут одинаковый msg=audit(ts:serial)
+var (
+	auditSerial = regexp.MustCompile(`msg=audit\((\d+\.\d+:\d+)\)`)
+	auditType   = regexp.MustCompile(`^type=(\w+)`)
+	execveArg   = regexp.MustCompile(`\ba(\d+)=(?:"([^"]*)"|([0-9A-Fa-f]+))`)
+)
+
+// AuditParser разбирает записи auditd. Записи одного события (SYSCALL, EXECVE,
+// CWD, PATH, PROCTITLE, EOE) Router собирает в одно событие по msg=audit(ts:serial)
 type AuditParser struct{}
 
 func NewAuditParser() *AuditParser {
@@ -20,6 +30,19 @@ func (p *AuditParser) Match(event reader.RawEvent) bool {
 		strings.HasPrefix(event.Data, "type=")
 }
 
+// GroupKey записи группируются по msg=audit(ts:serial)
+func (p *AuditParser) GroupKey(event reader.RawEvent) (string, bool) {
+	if m := auditSerial.FindStringSubmatch(event.Data); m != nil {
+		return m[1], true
+	}
+	return "", false
+}
+
+// GroupEnd запись EOE завершает многозаписное событие
+func (p *AuditParser) GroupEnd(event reader.RawEvent) bool {
+	return strings.HasPrefix(event.Data, "type=EOE ")
+}
+
 func (p *AuditParser) Parse(event reader.RawEvent) (domain.Event, error) {
 	result := domain.Event{
 		Timestamp: event.Timestamp,
@@ -55,16 +78,61 @@ func (p *AuditParser) Parse(event reader.RawEvent) (domain.Event, error) {
 		result.Command = commMatch[1]
 	}
 
-	switch result.EventType {
+	// из EXECVE берётся полная командная строка вместо comm
+	records := strings.Split(event.Data, "\n")
+	for _, record := range records {
+		if strings.HasPrefix(record, "type=EXECVE ") {
+			if command := execveCommand(record); command != "" {
+				result.Command = command
+			}
+		}
+	}
+
+	// тяжесть группы — наибольшая из её записей
+	result.Severity = "low"
+	for _, record := range records {
+		if typeMatch := auditType.FindStringSubmatch(record); len(typeMatch) > 1 {
+			if severity := auditSeverity(strings.ToLower(typeMatch[1])); severityRank[severity] > severityRank[result.Severity] {
+				result.Severity = severity
+			}
+		}
+	}
+
+	return result, nil
+}
+
+var severityRank = map[string]int{"low": 0, "medium": 1, "high": 2}
+
+func auditSeverity(eventType string) string {
+	switch eventType {
 	case "user_auth", "user_login", "user_acct", "cred_acq":
-		result.Severity = "high"
+		return "high"
 	case "syscall", "execve":
-		result.Severity = "medium"
+		return "medium"
 	default:
-		result.Severity = "low"
+		return "low"
 	}
+}
 
-	return result, nil
+// execveCommand собирает аргументы a0, a1, ... записи EXECVE; аргументы с
+// пробелами и спецсимволами auditd пишет в hex
+func execveCommand(record string) string {
+	var args []string
+	for _, m := range execveArg.FindAllStringSubmatch(record, -1) {
+		if int(parseInt64(m[1])) != len(args) {
+			break
+		}
+		arg := m[2]
+		if m[3] != "" {
+			decoded, err := hex.DecodeString(m[3])
+			if err != nil {
+				break
+			}
+			arg = string(decoded)
+		}
+		args = append(args, arg)
+	}
+	return strings.Join(args, " ")
 }
 
 func parseInt64(s string) int64 {
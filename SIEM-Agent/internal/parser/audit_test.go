@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Narotan/SIEM-Agent/internal/domain"
+	"github.com/Narotan/SIEM-Agent/internal/reader"
+)
+
+// routeAll прогоняет записи через Router и возвращает разобранные события
+func routeAll(t *testing.T, source string, lines ...string) []domain.Event {
+	t.Helper()
+	raws := make([]reader.RawEvent, 0, len(lines))
+	for _, line := range lines {
+		raws = append(raws, reader.RawEvent{Source: source, Timestamp: time.Now(), Data: line})
+	}
+	return routeEvents(t, raws...)
+}
+
+func routeEvents(t *testing.T, raws ...reader.RawEvent) []domain.Event {
+	t.Helper()
+	router := NewRouter(NewJournalParser(), NewAuditParser(), NewSyslogParser())
+
+	raw := make(chan reader.RawEvent, len(raws))
+	errs := make(chan error)
+	out := make(chan domain.Event, len(raws))
+	for _, evt := range raws {
+		raw <- evt
+	}
+	close(raw)
+	close(errs)
+
+	router.Start(raw, errs, out)
+	router.Stop()
+	close(out)
+
+	var events []domain.Event
+	for evt := range out {
+		events = append(events, evt)
+	}
+	return events
+}
+
+func TestAuditRecordsGrouped(t *testing.T) {
+	events := routeAll(t, "/var/log/audit/audit.log",
+		`type=SYSCALL msg=audit(1700000000.123:42): arch=c000003e syscall=59 success=yes exit=0 ppid=1 pid=100 auid=1000 uid=0 comm="rm" exe="/usr/bin/rm" key="delete"`,
+		`type=EXECVE msg=audit(1700000000.123:42): argc=3 a0="rm" a1="-rf" a2=2F746D702F6D7920646972`,
+		`type=CWD msg=audit(1700000000.123:42): cwd="/root"`,
+		`type=PATH msg=audit(1700000000.123:42): item=0 name="/tmp/my dir" inode=1`,
+		`type=EOE msg=audit(1700000000.123:42):`,
+		`type=USER_LOGIN msg=audit(1700000001.000:43): pid=200 uid=0 auid=1000 msg='op=login acct="root" exe="/usr/sbin/sshd" res=failed'`,
+		`type=CRED_ACQ msg=audit(1700000002.000:44): pid=201 uid=0 msg='op=PAM:setcred acct="root" exe="/usr/sbin/sshd" res=success'`,
+	)
+
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d: %+v", len(events), events)
+	}
+
+	syscall := events[0]
+	if syscall.EventType != "syscall" || syscall.Severity != "medium" {
+		t.Errorf("unexpected type/severity %q/%q", syscall.EventType, syscall.Severity)
+	}
+	if syscall.Command != "rm -rf /tmp/my dir" {
+		t.Errorf("expected command from EXECVE, got %q", syscall.Command)
+	}
+	if syscall.Process != "/usr/bin/rm" {
+		t.Errorf("unexpected process %q", syscall.Process)
+	}
+	if n := strings.Count(syscall.RawLog, "\n"); n != 4 {
+		t.Errorf("expected 5 records in raw log, got %d", n+1)
+	}
+	if !syscall.Timestamp.Equal(time.Unix(1700000000, 123000000)) {
+		t.Errorf("unexpected timestamp %v", syscall.Timestamp)
+	}
+
+	types := []string{events[1].EventType, events[2].EventType}
+	if !reflect.DeepEqual(types, []string{"user_login", "cred_acq"}) {
+		t.Errorf("single-record events must stay separate, got %v", types)
+	}
+}
+
+func TestAuditGroupAcksLastRecord(t *testing.T) {
+	router := NewRouter(NewAuditParser(), NewSyslogParser())
+	raw := make(chan reader.RawEvent, 3)
+	errs := make(chan error)
+	out := make(chan domain.Event, 3)
+
+	var committed []string
+	commit := func(name string) func() error {
+		return func() error {
+			committed = append(committed, name)
+			return nil
+		}
+	}
+	raw <- reader.RawEvent{Source: "audit.log", Data: `type=SYSCALL msg=audit(1.000:1): comm="ls"`, Commit: commit("syscall")}
+	raw <- reader.RawEvent{Source: "audit.log", Data: `type=PATH msg=audit(1.000:1): name="/etc"`, Commit: commit("path")}
+	raw <- reader.RawEvent{Source: "audit.log", Data: `type=SYSCALL msg=audit(2.000:2): comm="cat"`, Commit: commit("next")}
+	close(raw)
+	close(errs)
+
+	router.Start(raw, errs, out)
+	router.Stop()
+	close(out)
+
+	var events []domain.Event
+	for evt := range out {
+		events = append(events, evt)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	// подтверждение группы — от её последней записи
+	if err := events[0].Ack.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(committed, []string{"path"}) {
+		t.Errorf("expected ack of the last record, got %v", committed)
+	}
+}
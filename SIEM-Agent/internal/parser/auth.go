@@ -0,0 +1,273 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Narotan/SIEM-Agent/internal/domain"
+)
+
+// Результат события аутентификации (domain.Event.Result)
+const (
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+// authParsers разбор сообщений по имени процесса; sshd-session — процесс
+// сессии в OpenSSH 9.8+
+var authParsers = map[string]func(*domain.Event, string) bool{
+	"sshd":         parseSSHD,
+	"sshd-session": parseSSHD,
+	"sudo":         parseSudo,
+	"su":           parseSu,
+	"useradd":      parseAccount,
+	"usermod":      parseAccount,
+	"userdel":      parseAccount,
+	"groupadd":     parseAccount,
+	"groupdel":     parseAccount,
+	"gpasswd":      parseAccount,
+}
+
+// privilegedGroups группы, членство в которых даёт права администратора
+var privilegedGroups = map[string]bool{
+	"root": true, "sudo": true, "wheel": true, "admin": true, "adm": true, "docker": true,
+}
+
+var (
+	sshdLogin      = regexp.MustCompile(`^(Accepted|Failed) (\S+) for (?:invalid user )?(\S*) from (\S+) port (\d+)`)
+	sshdInvalid    = regexp.MustCompile(`^Invalid user (\S*) from (\S+)(?: port (\d+))?`)
+	sshdMaxTries   = regexp.MustCompile(`^(?:error: )?maximum authentication attempts exceeded for (?:invalid user )?(\S*) from (\S+) port (\d+)`)
+	sshdPreauth    = regexp.MustCompile(`^(?:Connection closed|Disconnected) by (?:invalid|authenticating) user (\S*) (\S+) port (\d+)`)
+	sshdDisconnect = regexp.MustCompile(`^Disconnected from user (\S+) (\S+) port (\d+)`)
+
+	pamPrefix  = regexp.MustCompile(`^pam_\w+\(([^:()]+):(\w+)\): (.*)$`)
+	pamSession = regexp.MustCompile(`^session (opened|closed) for user ([^\s(]+)(?:\(uid=\d+\))?(?: by ([^\s(]*)\(uid=\d+\))?`)
+	pamPasswd  = regexp.MustCompile(`^password changed for (\S+)`)
+	pamField   = regexp.MustCompile(`(\w+)=(\S*)`)
+
+	sudoLine = regexp.MustCompile(`^\s*(\S+) : (?:(.*?) ; )?TTY=(\S+) ; PWD=.*? ; USER=(\S+) ; (?:.*? ; )?COMMAND=(.*)$`)
+
+	suResult  = regexp.MustCompile(`^(Successful|FAILED) su for (\S+) by (\S+)`)
+	suSession = regexp.MustCompile(`^(FAILED SU )?\(to (\S+)\) (\S+) on (\S+)`)
+	suShort   = regexp.MustCompile(`^([+-]) (\S+) (\S+):(\S+)$`)
+
+	accountNewUser  = regexp.MustCompile(`^new user: name=([^,]+),`)
+	accountFrom     = regexp.MustCompile(`, from=(\S+)`)
+	accountNewGroup = regexp.MustCompile(`^(?:new group|group added to /etc/group): name=`)
+	accountAddGroup = regexp.MustCompile(`^add '([^']+)' to (?:shadow )?group '([^']+)'`)
+	accountGpasswd  = regexp.MustCompile(`^user (\S+) added by (\S+) to group (\S+)`)
+	accountChange   = regexp.MustCompile(`^(?:change|lock|unlock) user '([^']+)'`)
+	accountDelete   = regexp.MustCompile(`^delete user '([^']+)'`)
+	accountDelGroup = regexp.MustCompile(`^(?:group '[^']+' removed$|removed group ')`)
+)
+
+// parseAuth разбирает сообщения sshd, PAM, sudo, su и утилит управления
+// учётными записями; false — сообщение не относится к аутентификации
+func parseAuth(result *domain.Event) bool {
+	message := result.Command
+	if m := pamPrefix.FindStringSubmatch(message); m != nil {
+		return parsePAM(result, m[1], m[2], m[3])
+	}
+	if parse, ok := authParsers[result.Process]; ok {
+		return parse(result, message)
+	}
+	return false
+}
+
+func parseSSHD(result *domain.Event, message string) bool {
+	if m := sshdLogin.FindStringSubmatch(message); m != nil {
+		result.User = m[3]
+		result.AuthMethod = m[2]
+		setRemote(result, m[4], m[5])
+		if m[1] == "Accepted" {
+			setAuth(result, "user_login", "medium", resultSuccess)
+		} else {
+			setAuth(result, "auth_failure", "high", resultFailure)
+		}
+		return true
+	}
+	if m := sshdInvalid.FindStringSubmatch(message); m != nil {
+		result.User = m[1]
+		setRemote(result, m[2], m[3])
+		setAuth(result, "auth_failure", "high", resultFailure)
+		return true
+	}
+	for _, re := range []*regexp.Regexp{sshdMaxTries, sshdPreauth} {
+		if m := re.FindStringSubmatch(message); m != nil {
+			result.User = m[1]
+			setRemote(result, m[2], m[3])
+			setAuth(result, "auth_failure", "high", resultFailure)
+			return true
+		}
+	}
+	if m := sshdDisconnect.FindStringSubmatch(message); m != nil {
+		result.User = m[1]
+		setRemote(result, m[2], m[3])
+		setAuth(result, "session_close", "low", resultSuccess)
+		return true
+	}
+	return false
+}
+
+// parsePAM pam_unix(service:type): сообщение. Для su и sudo user — тот, кто
+// переключается (ruser, by), target_user — учётная запись, в которую он входит
+func parsePAM(result *domain.Event, service, kind, message string) bool {
+	if result.Process == "" {
+		result.Process = service
+	}
+
+	if m := pamSession.FindStringSubmatch(message); m != nil {
+		setUsers(result, m[3], m[2])
+		if m[1] == "opened" {
+			setAuth(result, "session_open", "low", resultSuccess)
+		} else {
+			setAuth(result, "session_close", "low", resultSuccess)
+		}
+		return true
+	}
+	if m := pamPasswd.FindStringSubmatch(message); m != nil {
+		result.TargetUser = m[1]
+		setAuth(result, "password_changed", "medium", resultSuccess)
+		return true
+	}
+	if kind == "auth" && (strings.HasPrefix(message, "authentication failure") || strings.HasPrefix(message, "check pass; user unknown")) {
+		fields := make(map[string]string)
+		for _, m := range pamField.FindAllStringSubmatch(message, -1) {
+			fields[m[1]] = m[2]
+		}
+		setUsers(result, fields["ruser"], fields["user"])
+		setTTY(result, fields["tty"])
+		if rhost := fields["rhost"]; rhost != "" {
+			result.SrcIP = rhost
+		}
+		setAuth(result, "auth_failure", "high", resultFailure)
+		return true
+	}
+	return false
+}
+
+// parseSudo user : [причина ;] TTY=pts/0 ; PWD=/home/user ; USER=root ; COMMAND=/bin/ls
+func parseSudo(result *domain.Event, message string) bool {
+	m := sudoLine.FindStringSubmatch(message)
+	if m == nil {
+		return false
+	}
+	setUsers(result, m[1], m[4])
+	setTTY(result, m[3])
+	result.SudoCommand = m[5]
+
+	switch reason := m[2]; {
+	case reason == "":
+		setAuth(result, "sudo_command", "medium", resultSuccess)
+	case strings.Contains(reason, "incorrect password"):
+		setAuth(result, "auth_failure", "high", resultFailure)
+	default:
+		// user NOT in sudoers, command not allowed
+		setAuth(result, "sudo_denied", "high", resultFailure)
+	}
+	return true
+}
+
+// parseSu форматы shadow-utils (Successful su for root by bob, + pts/0 bob:root)
+// и util-linux ((to root) bob on pts/0)
+func parseSu(result *domain.Event, message string) bool {
+	var ok bool
+	if m := suResult.FindStringSubmatch(message); m != nil {
+		setUsers(result, m[3], m[2])
+		ok = m[1] == "Successful"
+	} else if m := suSession.FindStringSubmatch(message); m != nil {
+		setUsers(result, m[3], m[2])
+		setTTY(result, m[4])
+		ok = m[1] == ""
+	} else if m := suShort.FindStringSubmatch(message); m != nil {
+		setUsers(result, m[3], m[4])
+		setTTY(result, m[2])
+		ok = m[1] == "+"
+	} else {
+		return false
+	}
+
+	if ok {
+		setAuth(result, "user_switch", "medium", resultSuccess)
+	} else {
+		setAuth(result, "auth_failure", "high", resultFailure)
+	}
+	return true
+}
+
+// parseAccount useradd, usermod, userdel, groupadd, groupdel, gpasswd; кто
+// выполнил команду, пишет только gpasswd, обычно заполняется только target_user
+func parseAccount(result *domain.Event, message string) bool {
+	switch {
+	case accountNewUser.MatchString(message):
+		result.TargetUser = accountNewUser.FindStringSubmatch(message)[1]
+		if m := accountFrom.FindStringSubmatch(message); m != nil {
+			setTTY(result, m[1])
+		}
+		setAuth(result, "user_created", "medium", resultSuccess)
+	case accountNewGroup.MatchString(message):
+		setAuth(result, "group_created", "low", resultSuccess)
+	case accountAddGroup.MatchString(message):
+		m := accountAddGroup.FindStringSubmatch(message)
+		result.TargetUser = m[1]
+		setAuth(result, "user_modified", groupSeverity(m[2]), resultSuccess)
+	case accountGpasswd.MatchString(message):
+		m := accountGpasswd.FindStringSubmatch(message)
+		setUsers(result, m[2], m[1])
+		setAuth(result, "user_modified", groupSeverity(m[3]), resultSuccess)
+	case accountChange.MatchString(message):
+		result.TargetUser = accountChange.FindStringSubmatch(message)[1]
+		setAuth(result, "user_modified", "medium", resultSuccess)
+	case accountDelete.MatchString(message):
+		result.TargetUser = accountDelete.FindStringSubmatch(message)[1]
+		setAuth(result, "user_deleted", "medium", resultSuccess)
+	case accountDelGroup.MatchString(message):
+		setAuth(result, "group_deleted", "low", resultSuccess)
+	default:
+		return false
+	}
+	return true
+}
+
+// groupSeverity добавление в привилегированную группу — high
+func groupSeverity(group string) string {
+	if privilegedGroups[group] {
+		return "high"
+	}
+	return "medium"
+}
+
+func setAuth(result *domain.Event, eventType, severity, outcome string) {
+	result.EventType = eventType
+	result.Severity = severity
+	result.Result = outcome
+}
+
+// setUsers user — кто действует, target — над какой учётной записью;
+// без user действующим считается target
+func setUsers(result *domain.Event, user, target string) {
+	switch {
+	case user == "" || user == target:
+		result.User = target
+	default:
+		result.User = user
+		result.TargetUser = target
+	}
+}
+
+func setRemote(result *domain.Event, ip, port string) {
+	result.SrcIP = ip
+	if n, err := strconv.Atoi(port); err == nil {
+		result.SrcPort = n
+	}
+}
+
+// setTTY pts/0 вместо /dev/pts/0; unknown и ssh (PAM для sshd) не сохраняются
+func setTTY(result *domain.Event, tty string) {
+	tty = strings.TrimPrefix(tty, "/dev/")
+	if tty == "unknown" || tty == "ssh" {
+		return
+	}
+	result.TTY = tty
+}
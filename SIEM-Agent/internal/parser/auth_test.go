@@ -0,0 +1,236 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Narotan/SIEM-Agent/internal/domain"
+)
+
+func TestParseAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		process  string
+		message  string
+		expected domain.Event
+	}{
+		{
+			name:    "sshd accepted publickey",
+			process: "sshd",
+			message: "Accepted publickey for bob from 10.0.0.5 port 52000 ssh2: ED25519 SHA256:abc",
+			expected: domain.Event{
+				EventType: "user_login", Severity: "medium", Result: "success",
+				User: "bob", SrcIP: "10.0.0.5", SrcPort: 52000, AuthMethod: "publickey",
+			},
+		},
+		{
+			name:    "sshd failed password for invalid user",
+			process: "sshd-session",
+			message: "Failed password for invalid user admin from 2001:db8::1 port 40022 ssh2",
+			expected: domain.Event{
+				EventType: "auth_failure", Severity: "high", Result: "failure",
+				User: "admin", SrcIP: "2001:db8::1", SrcPort: 40022, AuthMethod: "password",
+			},
+		},
+		{
+			name:    "sshd invalid user",
+			process: "sshd",
+			message: "Invalid user oracle from 203.0.113.7 port 41000",
+			expected: domain.Event{
+				EventType: "auth_failure", Severity: "high", Result: "failure",
+				User: "oracle", SrcIP: "203.0.113.7", SrcPort: 41000,
+			},
+		},
+		{
+			name:    "sshd preauth disconnect",
+			process: "sshd",
+			message: "Connection closed by authenticating user root 203.0.113.7 port 41002 [preauth]",
+			expected: domain.Event{
+				EventType: "auth_failure", Severity: "high", Result: "failure",
+				User: "root", SrcIP: "203.0.113.7", SrcPort: 41002,
+			},
+		},
+		{
+			name:    "sshd disconnected from user",
+			process: "sshd",
+			message: "Disconnected from user bob 10.0.0.5 port 52000",
+			expected: domain.Event{
+				EventType: "session_close", Severity: "low", Result: "success",
+				User: "bob", SrcIP: "10.0.0.5", SrcPort: 52000,
+			},
+		},
+		{
+			name:    "pam authentication failure is not an error",
+			process: "sshd",
+			message: "pam_unix(sshd:auth): authentication failure; logname= uid=0 euid=0 tty=ssh ruser= rhost=10.0.0.9  user=root",
+			expected: domain.Event{
+				EventType: "auth_failure", Severity: "high", Result: "failure",
+				User: "root", SrcIP: "10.0.0.9",
+			},
+		},
+		{
+			name:    "pam su authentication failure",
+			process: "su",
+			message: "pam_unix(su:auth): authentication failure; logname=bob uid=1000 euid=0 tty=/dev/pts/1 ruser=bob rhost=  user=root",
+			expected: domain.Event{
+				EventType: "auth_failure", Severity: "high", Result: "failure",
+				User: "bob", TargetUser: "root", TTY: "pts/1",
+			},
+		},
+		{
+			name:    "pam sudo session opened",
+			process: "sudo",
+			message: "pam_unix(sudo:session): session opened for user root(uid=0) by bob(uid=1000)",
+			expected: domain.Event{
+				EventType: "session_open", Severity: "low", Result: "success",
+				User: "bob", TargetUser: "root",
+			},
+		},
+		{
+			name:    "pam sshd session closed",
+			process: "sshd",
+			message: "pam_unix(sshd:session): session closed for user bob",
+			expected: domain.Event{
+				EventType: "session_close", Severity: "low", Result: "success",
+				User: "bob",
+			},
+		},
+		{
+			name:    "pam password changed",
+			process: "passwd",
+			message: "pam_unix(passwd:chauthtok): password changed for alice",
+			expected: domain.Event{
+				EventType: "password_changed", Severity: "medium", Result: "success",
+				TargetUser: "alice",
+			},
+		},
+		{
+			name:    "sudo command",
+			process: "sudo",
+			message: "     bob : TTY=pts/0 ; PWD=/home/bob ; USER=root ; COMMAND=/usr/bin/apt update",
+			expected: domain.Event{
+				EventType: "sudo_command", Severity: "medium", Result: "success",
+				User: "bob", TargetUser: "root", TTY: "pts/0", SudoCommand: "/usr/bin/apt update",
+			},
+		},
+		{
+			name:    "sudo incorrect password",
+			process: "sudo",
+			message: "bob : 3 incorrect password attempts ; TTY=pts/0 ; PWD=/home/bob ; USER=root ; COMMAND=/bin/ls",
+			expected: domain.Event{
+				EventType: "auth_failure", Severity: "high", Result: "failure",
+				User: "bob", TargetUser: "root", TTY: "pts/0", SudoCommand: "/bin/ls",
+			},
+		},
+		{
+			name:    "sudo not in sudoers",
+			process: "sudo",
+			message: "eve : user NOT in sudoers ; TTY=pts/2 ; PWD=/tmp ; USER=root ; ENV=A=1 ; COMMAND=/bin/sh",
+			expected: domain.Event{
+				EventType: "sudo_denied", Severity: "high", Result: "failure",
+				User: "eve", TargetUser: "root", TTY: "pts/2", SudoCommand: "/bin/sh",
+			},
+		},
+		{
+			name:    "su successful",
+			process: "su",
+			message: "Successful su for root by bob",
+			expected: domain.Event{
+				EventType: "user_switch", Severity: "medium", Result: "success",
+				User: "bob", TargetUser: "root",
+			},
+		},
+		{
+			name:    "su failed util-linux",
+			process: "su",
+			message: "FAILED SU (to root) bob on pts/3",
+			expected: domain.Event{
+				EventType: "auth_failure", Severity: "high", Result: "failure",
+				User: "bob", TargetUser: "root", TTY: "pts/3",
+			},
+		},
+		{
+			name:    "su short format",
+			process: "su",
+			message: "+ pts/0 bob:root",
+			expected: domain.Event{
+				EventType: "user_switch", Severity: "medium", Result: "success",
+				User: "bob", TargetUser: "root", TTY: "pts/0",
+			},
+		},
+		{
+			name:    "useradd",
+			process: "useradd",
+			message: "new user: name=alice, UID=1001, GID=1001, home=/home/alice, shell=/bin/bash, from=/dev/pts/0",
+			expected: domain.Event{
+				EventType: "user_created", Severity: "medium", Result: "success",
+				TargetUser: "alice", TTY: "pts/0",
+			},
+		},
+		{
+			name:    "usermod adds to sudo group",
+			process: "usermod",
+			message: "add 'alice' to group 'sudo'",
+			expected: domain.Event{
+				EventType: "user_modified", Severity: "high", Result: "success",
+				TargetUser: "alice",
+			},
+		},
+		{
+			name:    "gpasswd adds to group",
+			process: "gpasswd",
+			message: "user alice added by root to group video",
+			expected: domain.Event{
+				EventType: "user_modified", Severity: "medium", Result: "success",
+				User: "root", TargetUser: "alice",
+			},
+		},
+		{
+			name:    "userdel",
+			process: "userdel",
+			message: "delete user 'alice'",
+			expected: domain.Event{
+				EventType: "user_deleted", Severity: "medium", Result: "success",
+				TargetUser: "alice",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := domain.Event{Process: tt.process, Command: tt.message}
+			if !parseAuth(&got) {
+				t.Fatal("message not recognized")
+			}
+			tt.expected.Process = tt.process
+			tt.expected.Command = tt.message
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseAuthIgnoresOtherMessages(t *testing.T) {
+	tests := []struct {
+		process string
+		message string
+	}{
+		{"sshd", "Server listening on 0.0.0.0 port 22."},
+		{"sudo", "pam_systemd(sudo:session): New sd-bus connection"},
+		{"cron", "(root) CMD (run-parts /etc/cron.hourly)"},
+	}
+	for _, tt := range tests {
+		event := domain.Event{Process: tt.process, Command: tt.message}
+		if parseAuth(&event) {
+			t.Errorf("%s: %q should not be parsed as auth, got %+v", tt.process, tt.message, event)
+		}
+	}
+}
+
+func TestClassifySyslogAuthFailureBeforeError(t *testing.T) {
+	event := domain.Event{Process: "login", Command: "FAILED LOGIN (1) on '/dev/tty1' FOR 'root', Authentication failure"}
+	classifySyslog(&event)
+	if event.EventType != "auth_failure" || event.Result != "failure" {
+		t.Errorf("expected auth_failure, got %q/%q", event.EventType, event.Result)
+	}
+}
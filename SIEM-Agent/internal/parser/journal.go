@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Narotan/SIEM-Agent/internal/domain"
+	"github.com/Narotan/SIEM-Agent/internal/reader"
+)
+
+// JournalParser разбирает записи journald в формате journalctl -o json
+type JournalParser struct{}
+
+func NewJournalParser() *JournalParser {
+	return &JournalParser{}
+}
+
+func (p *JournalParser) Match(event reader.RawEvent) bool {
+	return event.Source == reader.JournalSource
+}
+
+func (p *JournalParser) Parse(event reader.RawEvent) (domain.Event, error) {
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(event.Data), &fields); err != nil {
+		return domain.Event{}, fmt.Errorf("invalid journal entry: %w", err)
+	}
+
+	message := journalField(fields, "MESSAGE")
+	result := domain.Event{
+		Timestamp: event.Timestamp,
+		Hostname:  journalField(fields, "_HOSTNAME"),
+		Source:    "journald",
+		RawLog:    message,
+		Command:   message,
+		Unit:      journalField(fields, "_SYSTEMD_UNIT"),
+		Process:   journalField(fields, "SYSLOG_IDENTIFIER"),
+	}
+	if result.Process == "" {
+		result.Process = journalField(fields, "_COMM")
+	}
+	if usec, err := strconv.ParseInt(journalField(fields, "__REALTIME_TIMESTAMP"), 10, 64); err == nil {
+		result.Timestamp = time.UnixMicro(usec)
+	}
+	if pid, err := strconv.Atoi(journalField(fields, "_PID")); err == nil {
+		result.PID = pid
+	}
+	// auth и authpriv
+	switch journalField(fields, "SYSLOG_FACILITY") {
+	case "4", "10":
+		result.Source = "auth"
+	}
+
+	classifySyslog(&result)
+	if result.User == "" {
+		result.User = journalField(fields, "_UID")
+	}
+	if result.EventType == "system_event" {
+		result.Severity = prioritySeverity(journalField(fields, "PRIORITY"), result.Severity)
+	}
+
+	return result, nil
+}
+
+// prioritySeverity severity по syslog-приоритету 0 (emerg) — 7 (debug)
+func prioritySeverity(priority, fallback string) string {
+	switch priority {
+	case "0", "1", "2", "3":
+		return "high"
+	case "4":
+		return "medium"
+	case "5", "6":
+		return "info"
+	case "7":
+		return "low"
+	default:
+		return fallback
+	}
+}
+
+// journalField строковое значение поля; бинарные поля journalctl выводит массивом байт
+func journalField(fields map[string]any, name string) string {
+	switch v := fields[name].(type) {
+	case string:
+		return v
+	case []any:
+		buf := make([]byte, 0, len(v))
+		for _, b := range v {
+			if n, ok := b.(float64); ok {
+				buf = append(buf, byte(n))
+			}
+		}
+		return string(buf)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Narotan/SIEM-Agent/internal/domain"
+	"github.com/Narotan/SIEM-Agent/internal/reader"
+)
+
+func TestJournalParser(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected domain.Event
+	}{
+		{
+			name: "sshd failure",
+			data: `{"__CURSOR":"c1","__REALTIME_TIMESTAMP":"1700000000123456","_HOSTNAME":"web-01","_SYSTEMD_UNIT":"ssh.service","SYSLOG_IDENTIFIER":"sshd","SYSLOG_FACILITY":"4","_PID":"812","_UID":"0","PRIORITY":"6","MESSAGE":"Failed password for root from 10.0.0.5 port 52000 ssh2"}`,
+			expected: domain.Event{
+				Timestamp:  time.UnixMicro(1700000000123456),
+				Hostname:   "web-01",
+				Source:     "auth",
+				RawLog:     "Failed password for root from 10.0.0.5 port 52000 ssh2",
+				EventType:  "auth_failure",
+				Severity:   "high",
+				User:       "root",
+				Process:    "sshd",
+				Command:    "Failed password for root from 10.0.0.5 port 52000 ssh2",
+				Unit:       "ssh.service",
+				PID:        812,
+				SrcIP:      "10.0.0.5",
+				SrcPort:    52000,
+				AuthMethod: "password",
+				Result:     "failure",
+			},
+		},
+		{
+			name: "priority and binary message",
+			data: `{"__CURSOR":"c2","__REALTIME_TIMESTAMP":"1700000001000000","_HOSTNAME":"web-01","_SYSTEMD_UNIT":"app.service","_COMM":"app","_PID":"90","_UID":"1000","PRIORITY":"4","MESSAGE":[100,105,115,107,32,108,111,119]}`,
+			expected: domain.Event{
+				Timestamp: time.UnixMicro(1700000001000000),
+				Hostname:  "web-01",
+				Source:    "journald",
+				RawLog:    "disk low",
+				EventType: "system_event",
+				Severity:  "medium",
+				User:      "1000",
+				Process:   "app",
+				Command:   "disk low",
+				Unit:      "app.service",
+				PID:       90,
+			},
+		},
+	}
+	p := NewJournalParser()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := reader.RawEvent{Source: reader.JournalSource, Data: tt.data}
+			if !p.Match(raw) {
+				t.Fatal("journal entry not matched")
+			}
+			got, err := p.Parse(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Timestamp.Equal(tt.expected.Timestamp) {
+				t.Errorf("expected timestamp %v, got %v", tt.expected.Timestamp, got.Timestamp)
+			}
+			got.Timestamp = tt.expected.Timestamp
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRouterKeepsJournalHostname(t *testing.T) {
+	events := routeAll(t, reader.JournalSource, `{"__CURSOR":"c1","_HOSTNAME":"db-01","MESSAGE":"hello"}`)
+	if len(events) != 1 || events[0].Hostname != "db-01" {
+		t.Errorf("expected hostname from the journal entry, got %+v", events)
+	}
+}
@@ -3,7 +3,9 @@ package parser
 import (
 	"log"
 	"os"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/Narotan/SIEM-Agent/internal/domain"
 	"github.com/Narotan/SIEM-Agent/internal/reader"
@@ -14,10 +16,35 @@ type Parser interface {
 	Parse(event reader.RawEvent) (domain.Event, error)
 }
 
+// Grouper парсер, несколько записей которого описывают одно событие (записи
+// auditd с общим msg=audit(ts:serial)). Router собирает такие записи и передаёт
+// в Parse одно событие со строками, разделёнными \n
+type Grouper interface {
+	// GroupKey ключ группы записи; false — запись не группируется
+	GroupKey(event reader.RawEvent) (string, bool)
+	// GroupEnd последняя ли это запись группы
+	GroupEnd(event reader.RawEvent) bool
+}
+
+const (
+	groupTimeout    = time.Second // группа отправляется, если новых записей нет дольше
+	maxGroupRecords = 256
+)
+
+// group записи одного события, ожидающие завершения
+type group struct {
+	parser  Parser
+	key     string
+	event   reader.RawEvent
+	records int
+	updated time.Time
+}
+
 // Router маршрутизирует события кратко
 type Router struct {
 	parsers  []Parser
 	hostname string
+	pending  map[string]*group // по источнику
 	wg       sync.WaitGroup
 }
 
@@ -31,6 +58,7 @@ func NewRouter(parsers ...Parser) *Router {
 	return &Router{
 		parsers:  parsers,
 		hostname: hostname,
+		pending:  make(map[string]*group),
 	}
 }
 
@@ -40,16 +68,23 @@ func (r *Router) Start(rawEvents <-chan reader.RawEvent, errors <-chan error, ou
 
 	go func() {
 		defer r.wg.Done()
-		for rawEvent := range rawEvents {
-			event, err := r.route(rawEvent)
-			if err != nil {
-				log.Printf("parser-router: failed to parse event: %v", err)
-				continue
-			}
+		ticker := time.NewTicker(groupTimeout / 2)
+		defer ticker.Stop()
 
-			event.Hostname = r.hostname
+		for {
+			select {
+			case rawEvent, ok := <-rawEvents:
+				if !ok {
+					r.flushGroups(output, func(*group) bool { return true })
+					return
+				}
+				r.handle(rawEvent, output)
 
-			output <- event
+			case now := <-ticker.C:
+				r.flushGroups(output, func(g *group) bool {
+					return now.Sub(g.updated) >= groupTimeout
+				})
+			}
 		}
 	}()
 
@@ -65,12 +100,94 @@ func (r *Router) Stop() {
 	r.wg.Wait()
 }
 
-func (r *Router) route(event reader.RawEvent) (domain.Event, error) {
+// handle разбирает событие или добавляет его в группу. Незавершённая группа
+// источника отправляется раньше следующих его событий, чтобы подтверждения шли по порядку
+func (r *Router) handle(rawEvent reader.RawEvent, output chan<- domain.Event) {
+	p := r.match(rawEvent)
+	pending := r.pending[rawEvent.Source]
+
+	grouper, ok := p.(Grouper)
+	if !ok {
+		r.flushGroup(rawEvent.Source, output)
+		r.emit(p, rawEvent, output)
+		return
+	}
+	key, ok := grouper.GroupKey(rawEvent)
+	if !ok {
+		r.flushGroup(rawEvent.Source, output)
+		r.emit(p, rawEvent, output)
+		return
+	}
+
+	if pending != nil && pending.parser == p && pending.key == key && pending.records < maxGroupRecords {
+		pending.event.Data += "\n" + rawEvent.Data
+		pending.event.Position = rawEvent.Position
+		pending.event.Commit = rawEvent.Commit
+		pending.records++
+		pending.updated = time.Now()
+	} else {
+		r.flushGroup(rawEvent.Source, output)
+		r.pending[rawEvent.Source] = &group{parser: p, key: key, event: rawEvent, records: 1, updated: time.Now()}
+	}
+
+	if grouper.GroupEnd(rawEvent) {
+		r.flushGroup(rawEvent.Source, output)
+	}
+}
+
+func (r *Router) flushGroup(source string, output chan<- domain.Event) {
+	if g := r.pending[source]; g != nil {
+		delete(r.pending, source)
+		r.emit(g.parser, g.event, output)
+	}
+}
+
+// flushGroups отправляет группы, для которых done вернул true
+func (r *Router) flushGroups(output chan<- domain.Event, done func(*group) bool) {
+	sources := make([]string, 0, len(r.pending))
+	for source, g := range r.pending {
+		if done(g) {
+			sources = append(sources, source)
+		}
+	}
+	sort.Strings(sources)
+	for _, source := range sources {
+		r.flushGroup(source, output)
+	}
+}
+
+func (r *Router) emit(p Parser, rawEvent reader.RawEvent, output chan<- domain.Event) {
+	event, err := r.parse(p, rawEvent)
+	if err != nil {
+		log.Printf("parser-router: failed to parse event: %v", err)
+		return
+	}
+
+	// хост из самой записи (journald, syslog по сети) сохраняется
+	if event.Hostname == "" {
+		event.Hostname = r.hostname
+	}
+	event.Remote = rawEvent.Remote
+	if rawEvent.Commit != nil {
+		event.Ack = &domain.Ack{Source: rawEvent.Source, Commit: rawEvent.Commit}
+	}
+
+	output <- event
+}
+
+func (r *Router) match(event reader.RawEvent) Parser {
 	for _, p := range r.parsers {
 		if p.Match(event) {
-			return p.Parse(event)
+			return p
 		}
 	}
+	return nil
+}
+
+func (r *Router) parse(p Parser, event reader.RawEvent) (domain.Event, error) {
+	if p != nil {
+		return p.Parse(event)
+	}
 
 	return domain.Event{
 		Timestamp: event.Timestamp,
@@ -1,7 +1,9 @@
 package parser
 
 import (
+	"net"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -21,34 +23,65 @@ func (p *SyslogParser) Match(event reader.RawEvent) bool {
 		strings.Contains(event.Source, "messages")
 }
 
+// Parse разбирает строки RFC 3164 (Jan 2 15:04:05 host proc[pid]: msg), RFC 5424
+// и строки с меткой времени ISO8601 (формат rsyslog по умолчанию), с <PRI> или
+// без. Хост из строки сохраняется; у сообщений, принятых по сети без имени
+// хоста, хостом считается адрес отправителя
 func (p *SyslogParser) Parse(event reader.RawEvent) (domain.Event, error) {
+	now := event.Timestamp
+	if now.IsZero() {
+		now = time.Now()
+	}
+	msg, ok := parseSyslogMessage(event.Data, now)
+
 	result := domain.Event{
-		Timestamp: event.Timestamp,
-		Source:    "syslog",
-		RawLog:    event.Data,
-		Severity:  "info",
+		Timestamp:      event.Timestamp,
+		Hostname:       msg.Hostname,
+		Source:         "syslog",
+		RawLog:         event.Data,
+		Process:        msg.AppName,
+		Command:        msg.Message,
+		StructuredData: msg.StructuredData,
+	}
+	if !ok {
+		result.Command = event.Data
+	}
+	if !msg.Timestamp.IsZero() {
+		result.Timestamp = msg.Timestamp
+	}
+	if result.Hostname == "" && event.Remote != "" {
+		if host, _, err := net.SplitHostPort(event.Remote); err == nil {
+			result.Hostname = host
+		}
+	}
+	if pid, err := strconv.Atoi(msg.ProcID); err == nil {
+		result.PID = pid
 	}
 
 	if strings.Contains(event.Source, "auth.log") {
 		result.Source = "auth"
-		result.Severity = "medium"
+	}
+	if msg.Priority >= 0 {
+		result.Facility = facilityName(msg.Facility())
+		result.Level = levelNames[msg.Severity()]
+		if result.Facility == "auth" || result.Facility == "authpriv" {
+			result.Source = "auth"
+		}
 	}
 
-	syslogPattern := regexp.MustCompile(`^(\w+\s+\d+\s+\d+:\d+:\d+)\s+(\S+)\s+(\S+?)(?:\[(\d+)\])?:\s*(.*)$`)
+	classifySyslog(&result)
+	if result.EventType == "system_event" && msg.Priority >= 0 {
+		result.Severity = prioritySeverity(strconv.Itoa(msg.Severity()), result.Severity)
+	}
 
-	if matches := syslogPattern.FindStringSubmatch(event.Data); len(matches) > 5 {
-		timestamp, err := time.Parse("Jan 2 15:04:05", matches[1])
-		if err == nil {
-			now := time.Now()
-			timestamp = time.Date(now.Year(), timestamp.Month(), timestamp.Day(),
-				timestamp.Hour(), timestamp.Minute(), timestamp.Second(), 0, time.Local)
-			result.Timestamp = timestamp
-		}
+	return result, nil
+}
 
-		result.Process = matches[3]
-		result.Command = matches[5]
-	} else {
-		result.Command = event.Data
+// classifySyslog определяет пользователя, тип и severity по тексту сообщения
+// (result.Command); сообщения аутентификации разбирает parseAuth
+func classifySyslog(result *domain.Event) {
+	if parseAuth(result) {
+		return
 	}
 
 	if userMatch := regexp.MustCompile(`user[=\s]+(\w+)`).FindStringSubmatch(strings.ToLower(result.Command)); len(userMatch) > 1 {
@@ -60,25 +93,19 @@ func (p *SyslogParser) Parse(event reader.RawEvent) (domain.Event, error) {
 
 	lowerCmd := strings.ToLower(result.Command)
 	switch {
+	case strings.Contains(lowerCmd, "authentication failure") || strings.Contains(lowerCmd, "invalid user"):
+		setAuth(result, "auth_failure", "high", resultFailure)
 	case strings.Contains(lowerCmd, "failed") || strings.Contains(lowerCmd, "error"):
 		result.EventType = "error"
 		result.Severity = "high"
-	case strings.Contains(lowerCmd, "authentication failure") || strings.Contains(lowerCmd, "invalid user"):
-		result.EventType = "auth_failure"
-		result.Severity = "high"
-	case strings.Contains(lowerCmd, "accepted") || strings.Contains(lowerCmd, "session opened"):
-		result.EventType = "user_login"
-		result.Severity = "medium"
-	case strings.Contains(lowerCmd, "sudo"):
-		result.EventType = "sudo_command"
-		result.Severity = "medium"
+	case strings.Contains(lowerCmd, "accepted"):
+		setAuth(result, "user_login", "medium", resultSuccess)
+	case strings.Contains(lowerCmd, "session opened"):
+		setAuth(result, "session_open", "low", resultSuccess)
 	case strings.Contains(lowerCmd, "session closed"):
-		result.EventType = "user_logout"
-		result.Severity = "low"
+		setAuth(result, "session_close", "low", resultSuccess)
 	default:
 		result.EventType = "system_event"
 		result.Severity = "info"
 	}
-
-	return result, nil
 }
@@ -0,0 +1,255 @@
+package parser
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// syslogMessage заголовок сообщения syslog
+type syslogMessage struct {
+	Priority       int // -1 — без <PRI>
+	Timestamp      time.Time
+	Hostname       string
+	AppName        string
+	ProcID         string
+	MsgID          string
+	StructuredData map[string]map[string]string
+	Message        string
+}
+
+// Facility 4 — auth, 10 — authpriv
+func (m syslogMessage) Facility() int { return m.Priority / 8 }
+
+// Severity 0 (emerg) — 7 (debug)
+func (m syslogMessage) Severity() int { return m.Priority % 8 }
+
+var (
+	facilityNames = []string{
+		"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+		"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	}
+	levelNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+)
+
+func facilityName(facility int) string {
+	if facility < len(facilityNames) {
+		return facilityNames[facility]
+	}
+	return "local" + strconv.Itoa(facility-16)
+}
+
+// rfc3164Stamp Mmm dd hh:mm:ss, день дополняется пробелом или нет
+var rfc3164Stamp = regexp.MustCompile(`^([A-Z][a-z]{2}) +(\d{1,2}) (\d{2}:\d{2}:\d{2}) `)
+
+// parseSyslogMessage разбирает сообщение RFC 5424 (<PRI>1 TIMESTAMP HOST APP
+// PROCID MSGID SD MSG), RFC 3164 (<PRI>Mmm dd hh:mm:ss HOST TAG[PID]: MSG) или
+// строку с меткой времени ISO8601 вместо RFC 3164 (2024-01-02T15:04:05+03:00
+// HOST TAG: MSG); <PRI> необязателен. now нужен, чтобы определить год RFC 3164.
+// false — заголовок не распознан, в Message остаётся текст после <PRI>
+func parseSyslogMessage(data string, now time.Time) (syslogMessage, bool) {
+	msg := syslogMessage{Priority: -1}
+	rest := data
+	if pri, after, ok := parsePriority(data); ok {
+		msg.Priority = pri
+		rest = after
+	}
+
+	switch {
+	case strings.HasPrefix(rest, "1 "):
+		if parseRFC5424(&msg, rest[2:]) == nil {
+			return msg, true
+		}
+	case parseISO8601(&msg, rest):
+		return msg, true
+	case parseRFC3164(&msg, rest, now):
+		return msg, true
+	}
+
+	msg.Message = rest
+	return msg, false
+}
+
+// parsePriority <PRI>: от 0 до 191, до трёх цифр
+func parsePriority(data string) (int, string, bool) {
+	if !strings.HasPrefix(data, "<") {
+		return 0, data, false
+	}
+	end := strings.IndexByte(data, '>')
+	if end < 2 || end > 4 {
+		return 0, data, false
+	}
+	pri, err := strconv.Atoi(data[1:end])
+	if err != nil || pri < 0 || pri > 191 {
+		return 0, data, false
+	}
+	return pri, data[end+1:], true
+}
+
+func parseRFC5424(msg *syslogMessage, rest string) error {
+	var fields [5]string
+	for i := range fields {
+		field, after, ok := strings.Cut(rest, " ")
+		if !ok || field == "" {
+			return errors.New("truncated header")
+		}
+		fields[i], rest = field, after
+	}
+
+	if fields[0] != "-" {
+		ts, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return err
+		}
+		msg.Timestamp = ts
+	}
+	msg.Hostname = nilValue(fields[1])
+	msg.AppName = nilValue(fields[2])
+	msg.ProcID = nilValue(fields[3])
+	msg.MsgID = nilValue(fields[4])
+
+	sd, rest, err := parseStructuredData(rest)
+	if err != nil {
+		return err
+	}
+	msg.StructuredData = sd
+	rest = strings.TrimPrefix(rest, " ")
+	msg.Message = strings.TrimPrefix(rest, "\ufeff") // BOM перед UTF-8 сообщением
+	return nil
+}
+
+// parseStructuredData [id param="value" ...][id2 ...] или "-"; в значениях
+// экранируются \", \\ и \]
+func parseStructuredData(rest string) (map[string]map[string]string, string, error) {
+	if strings.HasPrefix(rest, "-") {
+		return nil, rest[1:], nil
+	}
+	if !strings.HasPrefix(rest, "[") {
+		return nil, rest, errors.New("invalid structured data")
+	}
+
+	sd := make(map[string]map[string]string)
+	for strings.HasPrefix(rest, "[") {
+		rest = rest[1:]
+		end := strings.IndexAny(rest, " ]")
+		if end <= 0 {
+			return nil, rest, errors.New("invalid SD-ID")
+		}
+		id := rest[:end]
+		params := make(map[string]string)
+		rest = rest[end:]
+
+		for strings.HasPrefix(rest, " ") {
+			rest = rest[1:]
+			eq := strings.Index(rest, `="`)
+			if eq <= 0 {
+				return nil, rest, errors.New("invalid SD-PARAM")
+			}
+			name := rest[:eq]
+			rest = rest[eq+2:]
+
+			var value strings.Builder
+			closed := false
+			for i := 0; i < len(rest); i++ {
+				c := rest[i]
+				if c == '\\' && i+1 < len(rest) && strings.IndexByte(`"\]`, rest[i+1]) >= 0 {
+					value.WriteByte(rest[i+1])
+					i++
+					continue
+				}
+				if c == '"' {
+					rest = rest[i+1:]
+					closed = true
+					break
+				}
+				value.WriteByte(c)
+			}
+			if !closed {
+				return nil, rest, errors.New("unterminated SD-PARAM value")
+			}
+			params[name] = value.String()
+		}
+
+		if !strings.HasPrefix(rest, "]") {
+			return nil, rest, errors.New("unterminated SD-ELEMENT")
+		}
+		rest = rest[1:]
+		sd[id] = params
+	}
+	return sd, rest, nil
+}
+
+// parseRFC3164 Mmm dd hh:mm:ss HOST TAG[PID]: MSG
+func parseRFC3164(msg *syslogMessage, rest string, now time.Time) bool {
+	m := rfc3164Stamp.FindStringSubmatch(rest)
+	if m == nil {
+		return false
+	}
+	ts, err := time.ParseInLocation("Jan 2 15:04:05", m[1]+" "+m[2]+" "+m[3], time.Local)
+	if err != nil {
+		return false
+	}
+	msg.Timestamp = inferYear(ts, now)
+	parseTag(msg, rest[len(m[0]):])
+	return true
+}
+
+// parseISO8601 2024-01-02T15:04:05.123456+03:00 HOST TAG[PID]: MSG
+func parseISO8601(msg *syslogMessage, rest string) bool {
+	stamp, after, ok := strings.Cut(rest, " ")
+	if !ok || len(stamp) < len("2006-01-02T15:04:05Z") || stamp[4] != '-' {
+		return false
+	}
+	ts, err := time.Parse(time.RFC3339Nano, stamp)
+	if err != nil {
+		return false
+	}
+	msg.Timestamp = ts
+	parseTag(msg, after)
+	return true
+}
+
+// inferYear в RFC 3164 нет года: берётся самый поздний год, при котором метка
+// не больше чем на сутки впереди now. Декабрьские строки, прочитанные в
+// январе, попадают в прошлый год, январские при часах отправителя, спешащих
+// на несколько секунд в конце декабря, — в следующий
+func inferYear(ts, now time.Time) time.Time {
+	now = now.In(time.Local)
+	limit := now.Add(24 * time.Hour)
+	for year := now.Year() + 1; ; year-- {
+		t := time.Date(year, ts.Month(), ts.Day(), ts.Hour(), ts.Minute(), ts.Second(), 0, time.Local)
+		if !t.After(limit) {
+			return t
+		}
+	}
+}
+
+// parseTag HOST TAG[PID]: MSG; HOST может отсутствовать
+func parseTag(msg *syslogMessage, rest string) {
+	// токен, оканчивающийся на ":" или содержащий "[", — уже тег
+	if token, after, ok := strings.Cut(rest, " "); ok && !strings.HasSuffix(token, ":") && !strings.Contains(token, "[") {
+		msg.Hostname = token
+		rest = after
+	}
+
+	tag, message, ok := strings.Cut(rest, ": ")
+	if !ok || strings.Contains(tag, " ") {
+		msg.Message = rest
+		return
+	}
+	if open := strings.IndexByte(tag, '['); open > 0 && strings.HasSuffix(tag, "]") {
+		msg.ProcID = tag[open+1 : len(tag)-1]
+		tag = tag[:open]
+	}
+	msg.AppName = tag
+	msg.Message = message
+}
+
+func nilValue(field string) string {
+	if field == "-" {
+		return ""
+	}
+	return field
+}
@@ -0,0 +1,209 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Narotan/SIEM-Agent/internal/reader"
+)
+
+func TestParseSyslogMessage(t *testing.T) {
+	now := time.Date(2024, 11, 1, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		name     string
+		data     string
+		ok       bool
+		expected syslogMessage
+	}{
+		{
+			name: "rfc5424 with structured data",
+			data: `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog 8710 ID47 [exampleSDID@32473 iut="3" eventSource="Application \"x\" \]"][origin ip="10.0.0.1"] ` + "\ufeff" + `An application event`,
+			ok:   true,
+			expected: syslogMessage{
+				Priority:  165,
+				Timestamp: time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC),
+				Hostname:  "mymachine.example.com",
+				AppName:   "evntslog",
+				ProcID:    "8710",
+				MsgID:     "ID47",
+				StructuredData: map[string]map[string]string{
+					"exampleSDID@32473": {"iut": "3", "eventSource": `Application "x" ]`},
+					"origin":            {"ip": "10.0.0.1"},
+				},
+				Message: "An application event",
+			},
+		},
+		{
+			name:     "rfc5424 with nil values",
+			data:     "<13>1 - - - - - -",
+			ok:       true,
+			expected: syslogMessage{Priority: 13},
+		},
+		{
+			name: "rfc3164",
+			data: "<34>Oct 11 22:14:15 mymachine su[123]: 'su root' failed for lonvick on /dev/pts/8",
+			ok:   true,
+			expected: syslogMessage{
+				Priority:  34,
+				Timestamp: time.Date(2024, 10, 11, 22, 14, 15, 0, time.Local),
+				Hostname:  "mymachine",
+				AppName:   "su",
+				ProcID:    "123",
+				Message:   "'su root' failed for lonvick on /dev/pts/8",
+			},
+		},
+		{
+			name: "rfc3164 without hostname",
+			data: "<38>Feb  5 01:02:03 sshd: Accepted password for bob",
+			ok:   true,
+			expected: syslogMessage{
+				Priority:  38,
+				Timestamp: time.Date(2024, 2, 5, 1, 2, 3, 0, time.Local),
+				AppName:   "sshd",
+				Message:   "Accepted password for bob",
+			},
+		},
+		{
+			name: "iso8601 without priority",
+			data: "2024-03-01T10:00:00.123456+03:00 web-01 sshd[812]: Accepted publickey for bob",
+			ok:   true,
+			expected: syslogMessage{
+				Priority:  -1,
+				Timestamp: time.Date(2024, 3, 1, 7, 0, 0, 123456000, time.UTC),
+				Hostname:  "web-01",
+				AppName:   "sshd",
+				ProcID:    "812",
+				Message:   "Accepted publickey for bob",
+			},
+		},
+		{
+			name: "rfc3164 without priority",
+			data: "Oct  1 08:00:00 web-01 CRON[77]: (root) CMD (run-parts /etc/cron.hourly)",
+			ok:   true,
+			expected: syslogMessage{
+				Priority:  -1,
+				Timestamp: time.Date(2024, 10, 1, 8, 0, 0, 0, time.Local),
+				Hostname:  "web-01",
+				AppName:   "CRON",
+				ProcID:    "77",
+				Message:   "(root) CMD (run-parts /etc/cron.hourly)",
+			},
+		},
+		{
+			name:     "unknown format",
+			data:     "<13>just text",
+			ok:       false,
+			expected: syslogMessage{Priority: 13, Message: "just text"},
+		},
+		{
+			name:     "broken structured data",
+			data:     `<13>1 - host app - - [id key="value`,
+			ok:       false,
+			expected: syslogMessage{Priority: 13, Message: `1 - host app - - [id key="value`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseSyslogMessage(tt.data, now)
+			if ok != tt.ok {
+				t.Fatalf("expected ok=%v, got %v", tt.ok, ok)
+			}
+			if !ok {
+				// при ошибке разбора важен только текст
+				got = syslogMessage{Priority: got.Priority, Message: got.Message}
+			}
+			if !got.Timestamp.Equal(tt.expected.Timestamp) {
+				t.Errorf("expected timestamp %v, got %v", tt.expected.Timestamp, got.Timestamp)
+			}
+			got.Timestamp = tt.expected.Timestamp
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestInferYear(t *testing.T) {
+	tests := []struct {
+		name     string
+		stamp    time.Time
+		now      time.Time
+		expected int
+	}{
+		{
+			name:     "same year",
+			stamp:    time.Date(0, 3, 1, 10, 0, 0, 0, time.Local),
+			now:      time.Date(2024, 3, 2, 0, 0, 0, 0, time.Local),
+			expected: 2024,
+		},
+		{
+			name:     "december line read in january",
+			stamp:    time.Date(0, 12, 31, 23, 59, 59, 0, time.Local),
+			now:      time.Date(2025, 1, 1, 0, 0, 5, 0, time.Local),
+			expected: 2024,
+		},
+		{
+			name:     "sender clock slightly ahead",
+			stamp:    time.Date(0, 1, 1, 0, 0, 10, 0, time.Local),
+			now:      time.Date(2024, 12, 31, 23, 59, 59, 0, time.Local),
+			expected: 2025,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inferYear(tt.stamp, tt.now).Year(); got != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSyslogParserFileLine(t *testing.T) {
+	events := routeEvents(t, reader.RawEvent{
+		Source:    "/var/log/syslog",
+		Timestamp: time.Now(),
+		Data:      "<11>2024-03-01T10:00:00Z db-01 kernel: Out of memory: Killed process 123 (java)",
+	})
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	event := events[0]
+	if event.Hostname != "db-01" {
+		t.Errorf("expected hostname from the line, got %q", event.Hostname)
+	}
+	if event.Process != "kernel" || event.Facility != "user" || event.Level != "err" {
+		t.Errorf("unexpected process/facility/level %q/%q/%q", event.Process, event.Facility, event.Level)
+	}
+	if event.Severity != "high" {
+		t.Errorf("expected severity from PRI, got %q", event.Severity)
+	}
+}
+
+func TestSyslogParserNetworkMessage(t *testing.T) {
+	events := routeEvents(t, reader.RawEvent{
+		Source:    reader.SyslogUDPSource,
+		Remote:    "10.0.0.7:514",
+		Timestamp: time.Now(),
+		Data:      `<86>1 2024-03-01T10:00:00Z - sshd 4242 - [meta seq="7"] Failed password for root from 10.0.0.9 port 50000 ssh2`,
+	})
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	event := events[0]
+	if event.Source != "auth" || event.Process != "sshd" || event.PID != 4242 {
+		t.Errorf("unexpected source/process/pid %q/%q/%d", event.Source, event.Process, event.PID)
+	}
+	if event.Facility != "authpriv" || event.Level != "info" {
+		t.Errorf("unexpected facility/level %q/%q", event.Facility, event.Level)
+	}
+	if event.Hostname != "10.0.0.7" || event.Remote != "10.0.0.7:514" {
+		t.Errorf("expected sender as hostname and remote, got %q/%q", event.Hostname, event.Remote)
+	}
+	if !event.Timestamp.Equal(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected timestamp %v", event.Timestamp)
+	}
+	if event.StructuredData["meta"]["seq"] != "7" {
+		t.Errorf("structured data lost: %v", event.StructuredData)
+	}
+}
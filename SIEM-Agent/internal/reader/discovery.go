@@ -0,0 +1,300 @@
+package reader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// defaultScanInterval период пересчёта glob-шаблонов: каталоги с * в пути
+// (/home/*/.bash_history) нельзя отследить через fsnotify
+const defaultScanInterval = 10 * time.Second
+
+// DiscoveryConfig источники: точные пути или glob-шаблоны
+type DiscoveryConfig struct {
+	Patterns     []string      // /var/log/auth.log, /var/log/nginx/*.log
+	Exclude      []string      // шаблоны без "/" сравниваются с именем файла, иначе с полным путём
+	MaxOpenFiles int           // 0 — без ограничения
+	ScanInterval time.Duration // 0 — defaultScanInterval
+	Registry     *Registry
+
+	// IngestCompressed один раз прочитать сжатые ротированные файлы каждого источника
+	IngestCompressed bool
+}
+
+// Discovery запускает Reader для каждого файла, подходящего под шаблоны,
+// подхватывает новые файлы и останавливает читатели удалённых. События всех
+// читателей приходят в один канал
+type Discovery struct {
+	cfg     DiscoveryConfig
+	watcher *fsnotify.Watcher
+
+	mu       sync.Mutex
+	readers  map[string]*Reader
+	failed   map[string]bool // ошибка запуска уже сообщена
+	skipped  map[string]bool // не открыт из-за MaxOpenFiles, предупреждение уже отправлено
+	watched  map[string]bool
+	started  bool // первый проход: существующие файлы читаются с конца
+	stopping bool
+
+	events  chan RawEvent
+	errors  chan error
+	stop    chan struct{}
+	wg      sync.WaitGroup
+	forward sync.WaitGroup
+}
+
+func NewDiscovery(cfg DiscoveryConfig) (*Discovery, error) {
+	for _, pattern := range append(append([]string(nil), cfg.Patterns...), cfg.Exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	if cfg.ScanInterval <= 0 {
+		cfg.ScanInterval = defaultScanInterval
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Discovery{
+		cfg:     cfg,
+		watcher: w,
+		readers: make(map[string]*Reader),
+		failed:  make(map[string]bool),
+		skipped: make(map[string]bool),
+		watched: make(map[string]bool),
+		events:  make(chan RawEvent, 100),
+		errors:  make(chan error, 10),
+		stop:    make(chan struct{}),
+	}, nil
+}
+
+func (d *Discovery) Events() <-chan RawEvent { return d.events }
+func (d *Discovery) Errors() <-chan error    { return d.errors }
+
+// Start открывает уже существующие файлы и начинает следить за новыми
+func (d *Discovery) Start() {
+	d.scan()
+
+	d.wg.Add(1)
+	go d.loop()
+}
+
+// Stop останавливает все читатели и закрывает каналы событий
+func (d *Discovery) Stop() {
+	close(d.stop)
+	d.wg.Wait()
+	d.watcher.Close()
+
+	d.mu.Lock()
+	d.stopping = true
+	readers := d.readers
+	d.readers = make(map[string]*Reader)
+	d.mu.Unlock()
+
+	for _, r := range readers {
+		r.Stop()
+	}
+	d.forward.Wait()
+	close(d.events)
+	close(d.errors)
+}
+
+// Sources возвращает отсортированные пути открытых файлов
+func (d *Discovery) Sources() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	paths := make([]string, 0, len(d.readers))
+	for path := range d.readers {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (d *Discovery) loop() {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(d.cfg.ScanInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.stop:
+			return
+
+		case evt, ok := <-d.watcher.Events:
+			if !ok {
+				return
+			}
+			if evt.Op&(fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
+				d.scan()
+			}
+
+		case err, ok := <-d.watcher.Errors:
+			if !ok {
+				return
+			}
+			d.report(err)
+
+		case <-ticker.C:
+			d.scan()
+		}
+	}
+}
+
+// scan сверяет открытые читатели с файлами, подходящими под шаблоны
+func (d *Discovery) scan() {
+	matched := d.match()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stopping {
+		return
+	}
+
+	for path, r := range d.readers {
+		if !matched[path] {
+			delete(d.readers, path)
+			go r.Stop()
+		}
+	}
+
+	paths := make([]string, 0, len(matched))
+	for path := range matched {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		d.watchDir(filepath.Dir(path))
+		if _, open := d.readers[path]; open {
+			continue
+		}
+		if d.cfg.MaxOpenFiles > 0 && len(d.readers) >= d.cfg.MaxOpenFiles {
+			if !d.skipped[path] {
+				d.skipped[path] = true
+				d.report(fmt.Errorf("max_open_files (%d) reached, not reading %s", d.cfg.MaxOpenFiles, path))
+			}
+			continue
+		}
+		d.startReader(path)
+	}
+
+	for _, pattern := range d.cfg.Patterns {
+		if dir := filepath.Dir(pattern); !hasMeta(dir) {
+			d.watchDir(dir)
+		}
+	}
+	for path := range d.failed {
+		if !matched[path] {
+			delete(d.failed, path)
+		}
+	}
+	for path := range d.skipped {
+		if !matched[path] {
+			delete(d.skipped, path)
+		}
+	}
+	d.started = true
+}
+
+// startReader открывает файл; новые файлы, появившиеся после запуска, читаются с начала
+func (d *Discovery) startReader(path string) {
+	r, err := New(path)
+	if err != nil {
+		d.reportOnce(path, err)
+		return
+	}
+	r.SetRegistry(d.cfg.Registry)
+	r.SetReadFromStart(d.started)
+	r.SetIngestCompressed(d.cfg.IngestCompressed)
+	if err := r.Start(); err != nil {
+		d.reportOnce(path, err)
+		return
+	}
+
+	delete(d.failed, path)
+	delete(d.skipped, path)
+	d.readers[path] = r
+
+	d.forward.Add(2)
+	go func() {
+		defer d.forward.Done()
+		for evt := range r.Events() {
+			d.events <- evt
+		}
+	}()
+	go func() {
+		defer d.forward.Done()
+		for err := range r.Errors() {
+			d.errors <- err
+		}
+	}()
+}
+
+// match возвращает обычные файлы, подходящие под шаблоны и не исключённые.
+// Сжатые ротированные файлы не читаются как источники
+func (d *Discovery) match() map[string]bool {
+	matched := make(map[string]bool)
+	for _, pattern := range d.cfg.Patterns {
+		paths, _ := filepath.Glob(pattern)
+		for _, path := range paths {
+			abs, err := filepath.Abs(path)
+			if err != nil || IsCompressed(abs) || d.excluded(abs) {
+				continue
+			}
+			if info, err := os.Stat(abs); err == nil && info.Mode().IsRegular() {
+				matched[abs] = true
+			}
+		}
+	}
+	return matched
+}
+
+func (d *Discovery) excluded(path string) bool {
+	for _, pattern := range d.cfg.Exclude {
+		if ok, _ := matchPath(pattern, path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Discovery) watchDir(dir string) {
+	if d.watched[dir] {
+		return
+	}
+	if err := d.watcher.Add(dir); err == nil {
+		d.watched[dir] = true
+	}
+}
+
+func (d *Discovery) reportOnce(path string, err error) {
+	if d.failed[path] {
+		return
+	}
+	d.failed[path] = true
+	d.report(fmt.Errorf("failed to start reader for %s: %w", path, err))
+}
+
+// report не блокирует сканирование, если ошибки никто не читает
+func (d *Discovery) report(err error) {
+	select {
+	case d.errors <- err:
+	default:
+	}
+}
+
+func hasMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[\`)
+}
@@ -0,0 +1,128 @@
+package reader
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func waitEvent(t *testing.T, d *Discovery) RawEvent {
+	t.Helper()
+	select {
+	case evt := <-d.Events():
+		return evt
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout waiting for event")
+	}
+	return RawEvent{}
+}
+
+func waitSources(t *testing.T, d *Discovery, expected []string) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !reflect.DeepEqual(d.Sources(), expected) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected sources %v, got %v", expected, d.Sources())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestDiscoveryGlobAndExclude(t *testing.T) {
+	dir := t.TempDir()
+	appendLines(t, filepath.Join(dir, "access.log"), "before start")
+	appendLines(t, filepath.Join(dir, "notes.txt"), "ignored")
+
+	d, err := NewDiscovery(DiscoveryConfig{
+		Patterns: []string{filepath.Join(dir, "*.log")},
+		Exclude:  []string{"debug-*.log"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Start()
+	defer d.Stop()
+	waitSources(t, d, []string{filepath.Join(dir, "access.log")})
+
+	// существующий файл читается с конца
+	appendLines(t, filepath.Join(dir, "access.log"), "after start")
+	if evt := waitEvent(t, d); evt.Data != "after start" {
+		t.Errorf("expected tail of existing file, got %q", evt.Data)
+	}
+
+	// новый файл читается с начала, исключённый пропускается
+	appendLines(t, filepath.Join(dir, "debug-1.log"), "excluded")
+	appendLines(t, filepath.Join(dir, "error.log"), "first line")
+	if evt := waitEvent(t, d); evt.Data != "first line" || evt.Source != filepath.Join(dir, "error.log") {
+		t.Errorf("expected first line of new file, got %q from %s", evt.Data, evt.Source)
+	}
+	waitSources(t, d, []string{filepath.Join(dir, "access.log"), filepath.Join(dir, "error.log")})
+
+	// удалённый файл перестаёт читаться
+	if err := os.Remove(filepath.Join(dir, "access.log")); err != nil {
+		t.Fatal(err)
+	}
+	waitSources(t, d, []string{filepath.Join(dir, "error.log")})
+}
+
+func TestDiscoveryMaxOpenFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.log", "b.log"} {
+		appendLines(t, filepath.Join(dir, name), "x")
+	}
+
+	d, err := NewDiscovery(DiscoveryConfig{
+		Patterns:     []string{filepath.Join(dir, "*.log")},
+		MaxOpenFiles: 1,
+		ScanInterval: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Start()
+	defer d.Stop()
+
+	waitSources(t, d, []string{filepath.Join(dir, "a.log")})
+	select {
+	case err := <-d.Errors():
+		t.Logf("limit reported: %v", err)
+	case <-time.After(time.Second):
+		t.Error("expected max_open_files warning")
+	}
+
+	// освободившийся слот занимает следующий файл
+	if err := os.Remove(filepath.Join(dir, "a.log")); err != nil {
+		t.Fatal(err)
+	}
+	waitSources(t, d, []string{filepath.Join(dir, "b.log")})
+}
+
+func TestDiscoveryRejectsBadPattern(t *testing.T) {
+	if _, err := NewDiscovery(DiscoveryConfig{Patterns: []string{"/var/log/[.log"}}); err == nil {
+		t.Error("expected error for malformed pattern")
+	}
+}
+
+func TestDiscoveryWildcardDirectory(t *testing.T) {
+	home := t.TempDir()
+	d, err := NewDiscovery(DiscoveryConfig{
+		Patterns:     []string{filepath.Join(home, "*", ".bash_history")},
+		ScanInterval: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Start()
+	defer d.Stop()
+
+	// каталог пользователя создан после запуска — файл находит периодический пересчёт
+	if err := os.Mkdir(filepath.Join(home, "alice"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	appendLines(t, filepath.Join(home, "alice", ".bash_history"), "sudo -i")
+	if evt := waitEvent(t, d); evt.Data != "sudo -i" {
+		t.Errorf("expected command from new history file, got %q", evt.Data)
+	}
+}
@@ -16,15 +16,32 @@ type RawEvent struct {
 	Source    string
 	Timestamp time.Time
 	Data      string
+	Remote    string       // адрес отправителя для сообщений, принятых по сети
+	Position  Position     // позиция после строки
+	Commit    func() error // сохраняет Position в реестре после доставки события
 }
 
 type Reader struct {
-	path string
+	path             string
+	registry         *Registry
+	fromStart        bool // без записи в реестре читать файл с начала, а не с конца
+	ingestCompressed bool // один раз прочитать сжатые ротированные файлы источника
 
 	watcher *fsnotify.Watcher
 	file    *os.File
 	offset  int64
 
+	// отпечаток начала текущего файла: по нему после усечения находится копия
+	fingerprint     string
+	fingerprintSize int
+
+	// fileID и lineEnd читаются из Commit и Position в других горутинах
+	mu      sync.Mutex
+	fileID  FileID
+	lineEnd int64
+
+	draining []*rotated // ротированные файлы, которые ещё дочитываются
+
 	events chan RawEvent
 	errors chan error
 
@@ -56,11 +73,28 @@ func New(path string) (*Reader, error) {
 	return r, nil
 }
 
+// SetRegistry включает продолжение чтения с подтверждённой позиции; вызывать до Start
+func (r *Reader) SetRegistry(registry *Registry) {
+	r.registry = registry
+}
+
+// SetReadFromStart файл без записи в реестре читается с начала, например
+// созданный уже после запуска агента; вызывать до Start
+func (r *Reader) SetReadFromStart(fromStart bool) {
+	r.fromStart = fromStart
+}
+
+// SetIngestCompressed при запуске один раз прочитать сжатые ротированные файлы
+// источника (auth.log.2.gz, .bz2, .zst); требует реестр; вызывать до Start
+func (r *Reader) SetIngestCompressed(ingest bool) {
+	r.ingestCompressed = ingest
+}
+
 func (r *Reader) Events() <-chan RawEvent { return r.events }
 func (r *Reader) Errors() <-chan error    { return r.errors }
 
 func (r *Reader) Start() error {
-	if err := r.openFile(true); err != nil {
+	if err := r.resume(); err != nil {
 		return err
 	}
 
@@ -81,8 +115,54 @@ func (r *Reader) Stop() {
 	close(r.errors)
 }
 
+// Position позиция после последней прочитанной строки текущего файла
+func (r *Reader) Position() Position {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return Position{File: r.fileID, Offset: r.lineEnd}
+}
+
+// Commit сохраняет в реестре позицию, до которой события доставлены.
+// Позиции уже ротированного файла пропускаются: его остаток после
+// перезапуска найдётся по inode
+func (r *Reader) Commit(pos Position) error {
+	if r.registry == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil || pos.File != r.fileID {
+		return nil
+	}
+
+	fp, size, err := fingerprint(r.file)
+	if err != nil {
+		return err
+	}
+	return r.registry.Set(RegistryEntry{
+		Path:            r.path,
+		FileID:          pos.File,
+		Offset:          pos.Offset,
+		Fingerprint:     fp,
+		FingerprintSize: size,
+		UpdatedAt:       time.Now(),
+	})
+}
+
 func (r *Reader) loop() {
 	defer r.wg.Done()
+	defer r.closeRotated()
+
+	// события, записанные пока агент не работал
+	if r.ingestCompressed {
+		r.ingestHistory()
+	}
+	r.drainRotated()
+	r.readNew()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
 
 	for {
 		select {
@@ -94,6 +174,9 @@ func (r *Reader) loop() {
 
 		case err := <-r.watcher.Errors:
 			r.errors <- err
+
+		case <-ticker.C:
+			r.poll()
 		}
 	}
 }
@@ -105,86 +188,317 @@ func (r *Reader) handleFsEvent(evt fsnotify.Event) {
 	}
 
 	if evt.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
-		if err := r.reopen(); err != nil {
-			r.errors <- err
+		// дочитываем то, что успели записать в старый файл до ротации
+		r.readNew()
+		r.rotate()
+	}
+}
+
+// poll подстраховывает fsnotify: дочитывает ротированные файлы, открывает файл,
+// появившийся после ротации, и замечает подмену файла без событий
+func (r *Reader) poll() {
+	if r.file == nil {
+		r.openNext()
+	} else if r.replaced() {
+		r.readNew()
+		r.rotate()
+	}
+	r.drainRotated()
+	r.readNew()
+}
+
+// replaced указывает ли путь источника на другой файл, чем открытый
+func (r *Reader) replaced() bool {
+	info, err := os.Stat(r.path)
+	if err != nil || r.fileID == (FileID{}) {
+		return false
+	}
+	return fileID(info) != r.fileID
+}
+
+// rotate переключается на новый файл по пути источника. Старый файл не
+// закрывается, а дочитывается по дескриптору, пока в него пишут
+func (r *Reader) rotate() {
+	info, err := os.Stat(r.path)
+	if err == nil && r.file != nil && fileID(info) == r.fileID && r.fileID != (FileID{}) {
+		return // путь по-прежнему указывает на открытый файл
+	}
+
+	r.retire()
+	if err == nil {
+		r.openNext()
+	}
+	r.drainRotated()
+	r.readNew()
+}
+
+// retire переносит текущий файл в дочитываемые
+func (r *Reader) retire() {
+	if r.file == nil {
+		return
+	}
+	_ = r.watcher.Remove(r.path)
+
+	r.draining = append(r.draining, &rotated{
+		file:     r.file,
+		reader:   r.file,
+		closer:   r.file,
+		id:       r.fileID,
+		offset:   r.offset,
+		partial:  r.buffer,
+		lastRead: time.Now(),
+	})
+	r.markRead(r.fingerprint, r.fingerprintSize)
+
+	r.mu.Lock()
+	r.file = nil
+	r.mu.Unlock()
+}
+
+// openNext открывает файл, появившийся по пути источника. Новый файл читается
+// с начала; если это прежний файл, переписанный целиком (начало и длина не
+// меньше прочитанного совпадают), чтение продолжается с прежней позиции
+func (r *Reader) openNext() {
+	f, err := os.Open(r.path)
+	if err != nil {
+		return // файла ещё нет, повторим при следующей проверке
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return
+	}
+
+	var offset int64
+	if last := len(r.draining) - 1; last >= 0 && r.fingerprintSize > 0 {
+		prev := r.draining[last]
+		lineEnd := prev.offset - int64(len(prev.partial))
+		entry := RegistryEntry{Fingerprint: r.fingerprint, FingerprintSize: r.fingerprintSize}
+		if prev.file != nil && info.Size() >= lineEnd && sameContent(f, entry) {
+			offset = lineEnd
+			prev.closer.Close()
+			r.draining = r.draining[:last]
 		}
 	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		r.errors <- err
+		return
+	}
+	if err := r.watcher.Add(r.path); err != nil {
+		r.errors <- err
+	}
+	r.setFile(f, fileID(info), offset)
+	r.fingerprint, r.fingerprintSize = "", 0
+	r.updateFingerprint()
 }
 
-func (r *Reader) openFile(seekEnd bool) error {
+// resume открывает файл с позиции из реестра. Без записи в реестре чтение
+// начинается с конца файла (или с начала при SetReadFromStart). Если файл
+// подменён (другой inode или начало) или усечён, он читается с начала, а
+// недочитанный остаток ищется рядом: переименованный файл, сжатый или копия
+// после copytruncate
+func (r *Reader) resume() error {
+	var entry RegistryEntry
+	var known bool
+	if r.registry != nil {
+		entry, known = r.registry.Get(r.path)
+	}
+
 	f, err := os.Open(r.path)
 	if err != nil {
 		return err
 	}
-
 	info, err := f.Stat()
 	if err != nil {
 		f.Close()
 		return err
 	}
+	id := fileID(info)
 
-	if seekEnd {
-		r.offset = info.Size()
-		_, err = f.Seek(r.offset, io.SeekStart)
-		if err != nil {
-			f.Close()
-			return err
+	offset := info.Size()
+	if r.fromStart {
+		offset = 0
+	}
+	if known {
+		if id == entry.FileID && entry.Offset <= info.Size() && sameContent(f, entry) {
+			offset = entry.Offset
+		} else {
+			offset = 0
+			if id == entry.FileID {
+				// тот же inode: остаток может быть только в копии
+				entry.FileID = FileID{}
+			}
+			if rot, ok := findRotated(r.path, entry, id); ok {
+				r.draining = append(r.draining, rot)
+			}
+			r.markRead(entry.Fingerprint, entry.FingerprintSize)
 		}
-	} else {
-		r.offset = 0
 	}
 
-	r.file = f
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	r.setFile(f, id, offset)
+	r.updateFingerprint()
 	return nil
 }
 
-func (r *Reader) reopen() error {
-	if r.file != nil {
-		_ = r.file.Close()
+func (r *Reader) setFile(f *os.File, id FileID, offset int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.file = f
+	r.fileID = id
+	r.offset = offset
+	r.lineEnd = offset
+	r.buffer = ""
+}
+
+// markRead запоминает прочитанный файл, чтобы не загружать его архив повторно
+func (r *Reader) markRead(fp string, size int) {
+	if r.registry == nil {
+		return
+	}
+	if err := r.registry.MarkRead(r.path, fp, size); err != nil {
+		r.errors <- err
 	}
+}
 
+// updateFingerprint пока файл короче fingerprintSize, отпечаток растёт вместе с ним
+func (r *Reader) updateFingerprint() {
+	if r.file == nil || r.fingerprintSize >= fingerprintSize {
+		return
+	}
+	if fp, size, err := fingerprint(r.file); err == nil {
+		r.fingerprint, r.fingerprintSize = fp, size
+	}
+}
+
+// drainRotated дочитывает ротированные файлы. Файл закрывается вместе с
+// последней строкой без перевода строки, когда в него перестали писать
+func (r *Reader) drainRotated() {
+	kept := r.draining[:0]
+	for _, rot := range r.draining {
+		if r.drain(rot) {
+			kept = append(kept, rot)
+		}
+	}
+	r.draining = kept
+}
+
+// drain возвращает false, когда файл дочитан и закрыт
+func (r *Reader) drain(rot *rotated) bool {
+	reader := bufio.NewReader(rot.reader)
 	for {
-		select {
-		case <-r.stop:
-			return nil
-		default:
-			if err := r.openFile(false); err == nil {
-				return nil
+		line, err := reader.ReadString('\n')
+		rot.offset += int64(len(line))
+		if line != "" {
+			rot.lastRead = time.Now()
+		}
+		if err != nil {
+			rot.partial += line
+			if !errors.Is(err, io.EOF) {
+				r.errors <- err
+				break
 			}
-			time.Sleep(200 * time.Millisecond)
+			if rot.file != nil && time.Since(rot.lastRead) < rotatedGrace {
+				return true
+			}
+			break
 		}
+
+		full := rot.partial + line
+		rot.partial = ""
+		r.emit(trimNewline(full), Position{File: rot.id, Offset: rot.offset})
+	}
+
+	if rot.partial != "" {
+		r.emit(rot.partial, Position{File: rot.id, Offset: rot.offset})
 	}
+	rot.closer.Close()
+	return false
+}
+
+// closeRotated закрывает недочитанные файлы при остановке: их остаток после
+// перезапуска найдётся по реестру
+func (r *Reader) closeRotated() {
+	for _, rot := range r.draining {
+		rot.closer.Close()
+	}
+	r.draining = nil
 }
 
 func (r *Reader) readNew() {
 	if r.file == nil {
-		r.errors <- errors.New("file is not open")
-		return
+		return // ждём появления файла после ротации
+	}
+
+	if info, err := r.file.Stat(); err == nil && info.Size() < r.offset {
+		r.truncated()
 	}
 
 	reader := bufio.NewReader(r.file)
 
 	for {
 		line, err := reader.ReadString('\n')
+		r.offset += int64(len(line))
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				r.buffer += line
-				return
+				break
 			}
 			r.errors <- err
-			return
+			break
 		}
 
 		full := r.buffer + line
 		r.buffer = ""
 
-		r.offset += int64(len(line))
+		r.mu.Lock()
+		r.lineEnd = r.offset
+		pos := Position{File: r.fileID, Offset: r.offset}
+		r.mu.Unlock()
 
-		r.events <- RawEvent{
-			Source:    r.path,
-			Timestamp: time.Now(),
-			Data:      trimNewline(full),
-		}
+		r.emit(trimNewline(full), pos)
+	}
+	r.updateFingerprint()
+}
+
+// truncated файл усечён (copytruncate): недочитанные строки берутся из копии,
+// которую logrotate сделал перед усечением, а сам файл читается с начала
+func (r *Reader) truncated() {
+	r.mu.Lock()
+	entry := RegistryEntry{
+		Path:            r.path,
+		Offset:          r.lineEnd,
+		Fingerprint:     r.fingerprint,
+		FingerprintSize: r.fingerprintSize,
+	}
+	r.mu.Unlock()
+
+	if rot, ok := findRotated(r.path, entry, r.fileID); ok {
+		r.draining = append(r.draining, rot)
+		r.drainRotated()
+	}
+	r.markRead(r.fingerprint, r.fingerprintSize)
+
+	if _, err := r.file.Seek(0, io.SeekStart); err != nil {
+		r.errors <- err
+		return
+	}
+	r.setFile(r.file, r.fileID, 0)
+	r.fingerprint, r.fingerprintSize = "", 0
+}
+
+func (r *Reader) emit(data string, pos Position) {
+	r.events <- RawEvent{
+		Source:    r.path,
+		Timestamp: time.Now(),
+		Data:      data,
+		Position:  pos,
+		Commit:    func() error { return r.Commit(pos) },
 	}
 }
 
@@ -0,0 +1,362 @@
+package reader
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func appendLines(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for _, line := range lines {
+		if _, err := f.WriteString(line + "\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// compress пишет lines в сжатый файл path (.gz или .zst)
+func compress(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch filepath.Ext(path) {
+	case ".gz":
+		w = gzip.NewWriter(&buf)
+	case ".zst":
+		enc, err := zstd.NewWriter(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w = enc
+	default:
+		t.Fatalf("unsupported compression: %s", path)
+	}
+	for _, line := range lines {
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// runReader запускает читатель, собирает n событий и подтверждает их
+func runReader(t *testing.T, path string, registry *Registry, n int, options ...func(*Reader)) []string {
+	t.Helper()
+	r, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetRegistry(registry)
+	for _, option := range options {
+		option(r)
+	}
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	var commits []func() error
+	timeout := time.After(3 * time.Second)
+	for len(lines) < n {
+		select {
+		case evt := <-r.Events():
+			lines = append(lines, evt.Data)
+			if evt.Commit != nil {
+				commits = append(commits, evt.Commit)
+			}
+		case err := <-r.Errors():
+			t.Fatalf("reader error: %v", err)
+		case <-timeout:
+			t.Fatalf("expected %d events, got %v", n, lines)
+		}
+	}
+	for _, commit := range commits {
+		if err := commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// лишних событий быть не должно
+	select {
+	case evt := <-r.Events():
+		t.Errorf("unexpected event %q", evt.Data)
+	case <-time.After(100 * time.Millisecond):
+	}
+	r.Stop()
+	return lines
+}
+
+func TestReaderResumesFromRegistry(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "auth.log")
+	appendLines(t, path, "old 1", "old 2")
+
+	registry, err := OpenRegistry(filepath.Join(dir, "registry", "offsets.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// без записи в реестре чтение начинается с конца файла
+	r, _ := New(path)
+	r.SetRegistry(registry)
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Commit(r.Position()); err != nil {
+		t.Fatal(err)
+	}
+	r.Stop()
+
+	appendLines(t, path, "while stopped 1", "while stopped 2")
+	reopened, err := OpenRegistry(filepath.Join(dir, "registry", "offsets.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := runReader(t, path, reopened, 2)
+	if !reflect.DeepEqual(got, []string{"while stopped 1", "while stopped 2"}) {
+		t.Errorf("unexpected events after restart: %v", got)
+	}
+
+	// подтверждённая позиция сдвинулась
+	appendLines(t, path, "next")
+	if got := runReader(t, path, reopened, 1); !reflect.DeepEqual(got, []string{"next"}) {
+		t.Errorf("expected only the new line, got %v", got)
+	}
+}
+
+func TestReaderDetectsTruncationAndRotation(t *testing.T) {
+	tests := []struct {
+		name     string
+		change   func(t *testing.T, path string)
+		expected []string
+	}{
+		{
+			name: "truncated",
+			change: func(t *testing.T, path string) {
+				if err := os.Truncate(path, 0); err != nil {
+					t.Fatal(err)
+				}
+				appendLines(t, path, "fresh")
+			},
+			expected: []string{"fresh"},
+		},
+		{
+			name: "rewritten with same length",
+			change: func(t *testing.T, path string) {
+				if err := os.WriteFile(path, []byte("line X\nline Y\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			expected: []string{"line X", "line Y"},
+		},
+		{
+			name: "rotated",
+			change: func(t *testing.T, path string) {
+				appendLines(t, path, "tail of old file")
+				if err := os.Rename(path, path+".1"); err != nil {
+					t.Fatal(err)
+				}
+				appendLines(t, path, "first in new file")
+			},
+			expected: []string{"tail of old file", "first in new file"},
+		},
+		{
+			name: "rotated and compressed",
+			change: func(t *testing.T, path string) {
+				compress(t, path+".1.gz", "line 1", "line 2", "tail of old file")
+				if err := os.Remove(path); err != nil {
+					t.Fatal(err)
+				}
+				appendLines(t, path, "first in new file")
+			},
+			expected: []string{"tail of old file", "first in new file"},
+		},
+		{
+			name: "copytruncate",
+			change: func(t *testing.T, path string) {
+				appendLines(t, path, "tail of old file")
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path+".1", data, 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Truncate(path, 0); err != nil {
+					t.Fatal(err)
+				}
+				appendLines(t, path, "first in new file")
+			},
+			expected: []string{"tail of old file", "first in new file"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "syslog")
+			appendLines(t, path, "line 1", "line 2")
+			registry, err := OpenRegistry(filepath.Join(dir, "offsets.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// агент остановился, подтвердив обе строки
+			f, _ := os.Open(path)
+			info, _ := f.Stat()
+			entry := RegistryEntry{Path: path, FileID: fileID(info), Offset: info.Size()}
+			entry.Fingerprint, entry.FingerprintSize, _ = fingerprint(f)
+			f.Close()
+			if err := registry.Set(entry); err != nil {
+				t.Fatal(err)
+			}
+
+			tt.change(t, path)
+			if got := runReader(t, path, registry, len(tt.expected)); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestReaderFollowsRotatedFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		rotate func(t *testing.T, path string)
+	}{
+		{
+			name: "rename",
+			rotate: func(t *testing.T, path string) {
+				if err := os.Rename(path, path+".1"); err != nil {
+					t.Fatal(err)
+				}
+				// писатель ещё не переоткрыл лог
+				appendLines(t, path+".1", "late")
+				appendLines(t, path, "new")
+			},
+		},
+		{
+			name: "copytruncate",
+			rotate: func(t *testing.T, path string) {
+				appendLines(t, path, "late")
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path+".1", data, 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Truncate(path, 0); err != nil {
+					t.Fatal(err)
+				}
+				appendLines(t, path, "new")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "auth.log")
+			appendLines(t, path, "existing")
+
+			r, err := New(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := r.Start(); err != nil {
+				t.Fatal(err)
+			}
+			defer r.Stop()
+
+			next := func() string {
+				t.Helper()
+				select {
+				case evt := <-r.Events():
+					return evt.Data
+				case err := <-r.Errors():
+					t.Fatalf("reader error: %v", err)
+				case <-time.After(3 * time.Second):
+					t.Fatal("timed out waiting for event")
+				}
+				return ""
+			}
+
+			appendLines(t, path, "before")
+			if got := next(); got != "before" {
+				t.Fatalf("expected %q, got %q", "before", got)
+			}
+
+			tt.rotate(t, path)
+			got := []string{next(), next()}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, []string{"late", "new"}) {
+				t.Errorf("expected tail of rotated file and new file, got %v", got)
+			}
+		})
+	}
+}
+
+func TestReaderIngestsCompressedHistory(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "auth.log")
+	appendLines(t, path, "current")
+	compress(t, path+".2.gz", "old 1", "old 2")
+	compress(t, path+".1.zst", "newer")
+	// архив с содержимым, которое агент уже читал до ротации
+	compress(t, path+".3.gz", "seen before")
+
+	now := time.Now()
+	for i, name := range []string{".3.gz", ".2.gz", ".1.zst"} {
+		mtime := now.Add(time.Duration(i-3) * time.Hour)
+		if err := os.Chtimes(path+name, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	registry, err := OpenRegistry(filepath.Join(dir, "offsets.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := sha256.Sum256([]byte("seen before\n"))
+	if err := registry.MarkRead(path, hex.EncodeToString(seen[:]), len("seen before\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	ingest := func(r *Reader) { r.SetIngestCompressed(true) }
+	got := runReader(t, path, registry, 3, ingest)
+	if !reflect.DeepEqual(got, []string{"old 1", "old 2", "newer"}) {
+		t.Errorf("expected archives from oldest to newest, got %v", got)
+	}
+
+	// logrotate сдвинул номера: прочитанные архивы не загружаются повторно
+	if err := os.Rename(path+".2.gz", path+".3.gz"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(path+".1.zst", path+".2.zst"); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := OpenRegistry(filepath.Join(dir, "offsets.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	runReader(t, path, reopened, 0, ingest)
+}
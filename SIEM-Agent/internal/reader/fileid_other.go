@@ -0,0 +1,10 @@
+//go:build !unix
+
+package reader
+
+import "os"
+
+// fileID без inode ротация распознаётся только по отпечатку начала файла
+func fileID(os.FileInfo) FileID {
+	return FileID{}
+}
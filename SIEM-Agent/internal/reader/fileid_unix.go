@@ -0,0 +1,15 @@
+//go:build unix
+
+package reader
+
+import (
+	"os"
+	"syscall"
+)
+
+func fileID(info os.FileInfo) FileID {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return FileID{Device: uint64(st.Dev), Inode: uint64(st.Ino)}
+	}
+	return FileID{}
+}
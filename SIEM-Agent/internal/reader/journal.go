@@ -0,0 +1,218 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JournalSource значение RawEvent.Source для записей journald
+const JournalSource = "journald"
+
+const (
+	journalRestartDelay = time.Second
+	maxJournalEntry     = 1 << 20 // запись journalctl -o json, включая бинарные поля
+)
+
+// JournalConfig чтение journald через journalctl -o json
+type JournalConfig struct {
+	Command   string   // по умолчанию journalctl
+	Directory string   // каталог файлов журнала (journalctl -D); пусто — системный журнал
+	Units     []string // только записи этих юнитов
+	Registry  *Registry
+}
+
+// Journal читает записи journald. Каждая запись — одно событие с JSON-объектом
+// полей в Data; курсор подтверждённой записи хранится в реестре, после
+// перезапуска чтение продолжается со следующей записи. Если journalctl
+// завершился, он перезапускается с последней прочитанной записи
+type Journal struct {
+	cfg JournalConfig
+
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	stopped bool
+
+	cursor string // последняя прочитанная запись
+
+	events chan RawEvent
+	errors chan error
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewJournal(cfg JournalConfig) *Journal {
+	if cfg.Command == "" {
+		cfg.Command = "journalctl"
+	}
+	return &Journal{
+		cfg:    cfg,
+		events: make(chan RawEvent, 100),
+		errors: make(chan error, 10),
+		stop:   make(chan struct{}),
+	}
+}
+
+func (j *Journal) Events() <-chan RawEvent { return j.events }
+func (j *Journal) Errors() <-chan error    { return j.errors }
+
+// Start запускает journalctl с курсора из реестра; без курсора читаются только новые записи
+func (j *Journal) Start() error {
+	if _, err := exec.LookPath(j.cfg.Command); err != nil {
+		return fmt.Errorf("journal reader: %w", err)
+	}
+	if j.cfg.Registry != nil {
+		if entry, ok := j.cfg.Registry.Get(j.key()); ok {
+			j.cursor = entry.Cursor
+		}
+	}
+
+	j.wg.Add(1)
+	go j.loop()
+	return nil
+}
+
+func (j *Journal) Stop() {
+	close(j.stop)
+
+	j.mu.Lock()
+	j.stopped = true
+	if j.cmd != nil {
+		_ = j.cmd.Process.Kill()
+	}
+	j.mu.Unlock()
+
+	j.wg.Wait()
+	close(j.events)
+	close(j.errors)
+}
+
+func (j *Journal) loop() {
+	defer j.wg.Done()
+
+	for {
+		err := j.run()
+
+		select {
+		case <-j.stop:
+			return
+		default:
+		}
+		j.report(err)
+
+		select {
+		case <-j.stop:
+			return
+		case <-time.After(journalRestartDelay):
+		}
+	}
+}
+
+// run читает вывод одного запуска journalctl до его завершения
+func (j *Journal) run() error {
+	cmd := exec.Command(j.cfg.Command, j.args()...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", j.cfg.Command, err)
+	}
+
+	j.mu.Lock()
+	j.cmd = cmd
+	if j.stopped {
+		_ = cmd.Process.Kill()
+	}
+	j.mu.Unlock()
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxJournalEntry)
+	for scanner.Scan() {
+		line := scanner.Text()
+		var entry struct {
+			Cursor string `json:"__CURSOR"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil || entry.Cursor == "" {
+			j.report(fmt.Errorf("invalid journal entry: %.100s", line))
+			continue
+		}
+		j.cursor = entry.Cursor
+		if !j.emit(line, entry.Cursor) {
+			break
+		}
+	}
+	scanErr := scanner.Err()
+
+	_ = cmd.Process.Kill()
+	waitErr := cmd.Wait()
+
+	j.mu.Lock()
+	j.cmd = nil
+	j.mu.Unlock()
+
+	if scanErr != nil {
+		return fmt.Errorf("failed to read journal: %w", scanErr)
+	}
+	return fmt.Errorf("%s exited: %v %s", j.cfg.Command, waitErr, strings.TrimSpace(stderr.String()))
+}
+
+func (j *Journal) args() []string {
+	args := []string{"--output=json", "--follow", "--no-pager"}
+	if j.cfg.Directory != "" {
+		args = append(args, "--directory="+j.cfg.Directory)
+	}
+	for _, unit := range j.cfg.Units {
+		args = append(args, "--unit="+unit)
+	}
+	if j.cursor != "" {
+		args = append(args, "--after-cursor="+j.cursor)
+	} else {
+		args = append(args, "--lines=0")
+	}
+	return args
+}
+
+// key запись реестра: у каждого каталога журнала свой курсор
+func (j *Journal) key() string {
+	if j.cfg.Directory != "" {
+		return JournalSource + ":" + j.cfg.Directory
+	}
+	return JournalSource
+}
+
+// emit возвращает false, если журнал останавливается
+func (j *Journal) emit(data, cursor string) bool {
+	evt := RawEvent{
+		Source:    JournalSource,
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+	if j.cfg.Registry != nil {
+		evt.Commit = func() error {
+			return j.cfg.Registry.Set(RegistryEntry{Path: j.key(), Cursor: cursor, UpdatedAt: time.Now()})
+		}
+	}
+
+	select {
+	case j.events <- evt:
+		return true
+	case <-j.stop:
+		return false
+	}
+}
+
+// report не блокирует чтение, если ошибки никто не читает
+func (j *Journal) report(err error) {
+	select {
+	case j.errors <- err:
+	default:
+	}
+}
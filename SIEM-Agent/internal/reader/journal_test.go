@@ -0,0 +1,120 @@
+package reader
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeJournalctl пишет аргументы запуска в args и выдаёт записи в зависимости от курсора
+const fakeJournalctl = `#!/bin/sh
+echo "$@" >> "$(dirname "$0")/args"
+case "$*" in
+*--after-cursor=c2*)
+	echo '{"__CURSOR":"c3","MESSAGE":"third"}'
+	;;
+*)
+	echo '{"__CURSOR":"c1","MESSAGE":"first"}'
+	echo 'not json'
+	echo '{"__CURSOR":"c2","MESSAGE":"second"}'
+	;;
+esac
+exec sleep 60
+`
+
+func runJournal(t *testing.T, cfg JournalConfig, n int) ([]string, []error) {
+	t.Helper()
+	j := NewJournal(cfg)
+	if err := j.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer j.Stop()
+
+	var data []string
+	var errs []error
+	timeout := time.After(3 * time.Second)
+	for len(data) < n {
+		select {
+		case evt := <-j.Events():
+			if evt.Source != JournalSource {
+				t.Errorf("unexpected source %q", evt.Source)
+			}
+			data = append(data, evt.Data)
+			if err := evt.Commit(); err != nil {
+				t.Fatal(err)
+			}
+		case err := <-j.Errors():
+			errs = append(errs, err)
+		case <-timeout:
+			t.Fatalf("expected %d entries, got %v", n, data)
+		}
+	}
+	// ошибки отправляются раньше следующих записей
+	for {
+		select {
+		case err := <-j.Errors():
+			errs = append(errs, err)
+		default:
+			return data, errs
+		}
+	}
+}
+
+func TestJournalResumesFromCursor(t *testing.T) {
+	dir := t.TempDir()
+	command := filepath.Join(dir, "journalctl")
+	if err := os.WriteFile(command, []byte(fakeJournalctl), 0755); err != nil {
+		t.Fatal(err)
+	}
+	registry, err := OpenRegistry(filepath.Join(dir, "offsets.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := JournalConfig{Command: command, Units: []string{"ssh.service"}, Registry: registry}
+
+	data, errs := runJournal(t, cfg, 2)
+	if !reflect.DeepEqual(data, []string{
+		`{"__CURSOR":"c1","MESSAGE":"first"}`,
+		`{"__CURSOR":"c2","MESSAGE":"second"}`,
+	}) {
+		t.Errorf("unexpected entries %v", data)
+	}
+	if len(errs) != 1 {
+		t.Errorf("expected error for the invalid line, got %v", errs)
+	}
+	if entry, _ := registry.Get(JournalSource); entry.Cursor != "c2" {
+		t.Errorf("expected committed cursor c2, got %q", entry.Cursor)
+	}
+
+	reopened, err := OpenRegistry(filepath.Join(dir, "offsets.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Registry = reopened
+	if data, _ := runJournal(t, cfg, 1); !reflect.DeepEqual(data, []string{`{"__CURSOR":"c3","MESSAGE":"third"}`}) {
+		t.Errorf("expected entry after cursor, got %v", data)
+	}
+
+	args, err := os.ReadFile(filepath.Join(dir, "args"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs := strings.Split(strings.TrimSpace(string(args)), "\n")
+	expected := []string{
+		"--output=json --follow --no-pager --unit=ssh.service --lines=0",
+		"--output=json --follow --no-pager --unit=ssh.service --after-cursor=c2",
+	}
+	if !reflect.DeepEqual(runs, expected) {
+		t.Errorf("expected journalctl runs %q, got %q", expected, runs)
+	}
+}
+
+func TestJournalMissingCommand(t *testing.T) {
+	j := NewJournal(JournalConfig{Command: filepath.Join(t.TempDir(), "journalctl")})
+	if err := j.Start(); err == nil {
+		t.Error("expected error for missing journalctl")
+	}
+}
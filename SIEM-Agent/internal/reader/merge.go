@@ -0,0 +1,23 @@
+package reader
+
+import "sync"
+
+// Merge объединяет каналы источников в один; он закрывается после закрытия всех входных
+func Merge[T any](inputs ...<-chan T) <-chan T {
+	out := make(chan T, 100)
+	var wg sync.WaitGroup
+	wg.Add(len(inputs))
+	for _, in := range inputs {
+		go func() {
+			defer wg.Done()
+			for v := range in {
+				out <- v
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
@@ -0,0 +1,185 @@
+package reader
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMultilineMaxLines = 500
+	defaultMultilineTimeout  = time.Second
+)
+
+// MultilineRule склейка строк источника в одно событие (стектрейсы, oops ядра).
+// Строка дописывается к текущему событию, если подходит под Continue или, при
+// заданном Start, не подходит под Start
+type MultilineRule struct {
+	Source   string        // glob: без "/" сравнивается с именем файла, иначе с полным путём
+	Start    string        // регулярка первой строки события
+	Continue string        // регулярка строки-продолжения
+	MaxLines int           // 0 — defaultMultilineMaxLines
+	Timeout  time.Duration // событие отправляется, если новых строк нет дольше; 0 — defaultMultilineTimeout
+}
+
+type multilineRule struct {
+	MultilineRule
+	start *regexp.Regexp
+	cont  *regexp.Regexp
+}
+
+// pendingEvent событие, к которому ещё могут дописываться строки
+type pendingEvent struct {
+	rule    *multilineRule
+	event   RawEvent
+	lines   int
+	updated time.Time
+}
+
+// Multiline склеивает многострочные события между читателями и парсерами.
+// Позиция и подтверждение склеенного события — от последней строки
+type Multiline struct {
+	rules   []*multilineRule
+	pending map[string]*pendingEvent // по источнику
+}
+
+func NewMultiline(rules []MultilineRule) (*Multiline, error) {
+	m := &Multiline{pending: make(map[string]*pendingEvent)}
+	for _, rule := range rules {
+		if rule.Source == "" {
+			return nil, fmt.Errorf("multiline rule without source")
+		}
+		if _, err := matchPath(rule.Source, ""); err != nil {
+			return nil, fmt.Errorf("invalid multiline source %q: %w", rule.Source, err)
+		}
+		if rule.Start == "" && rule.Continue == "" {
+			return nil, fmt.Errorf("multiline rule for %s needs start or continue pattern", rule.Source)
+		}
+		if rule.MaxLines <= 0 {
+			rule.MaxLines = defaultMultilineMaxLines
+		}
+		if rule.Timeout <= 0 {
+			rule.Timeout = defaultMultilineTimeout
+		}
+
+		compiled := &multilineRule{MultilineRule: rule}
+		var err error
+		if rule.Start != "" {
+			if compiled.start, err = regexp.Compile(rule.Start); err != nil {
+				return nil, fmt.Errorf("invalid multiline start pattern %q: %w", rule.Start, err)
+			}
+		}
+		if rule.Continue != "" {
+			if compiled.cont, err = regexp.Compile(rule.Continue); err != nil {
+				return nil, fmt.Errorf("invalid multiline continue pattern %q: %w", rule.Continue, err)
+			}
+		}
+		m.rules = append(m.rules, compiled)
+	}
+	return m, nil
+}
+
+// Run склеивает события из in; выходной канал закрывается после in, недописанные
+// события при этом отправляются. Без правил события проходят как есть
+func (m *Multiline) Run(in <-chan RawEvent) <-chan RawEvent {
+	if len(m.rules) == 0 {
+		return in
+	}
+	out := make(chan RawEvent, 100)
+	go m.loop(in, out)
+	return out
+}
+
+func (m *Multiline) loop(in <-chan RawEvent, out chan<- RawEvent) {
+	defer close(out)
+
+	tick := m.rules[0].Timeout
+	for _, rule := range m.rules {
+		tick = min(tick, rule.Timeout)
+	}
+	ticker := time.NewTicker(max(tick/2, 10*time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case evt, ok := <-in:
+			if !ok {
+				m.flushAll(out, func(*pendingEvent) bool { return true })
+				return
+			}
+			m.add(evt, out)
+
+		case now := <-ticker.C:
+			m.flushAll(out, func(p *pendingEvent) bool {
+				return now.Sub(p.updated) >= p.rule.Timeout
+			})
+		}
+	}
+}
+
+func (m *Multiline) add(evt RawEvent, out chan<- RawEvent) {
+	p := m.pending[evt.Source]
+	rule := m.rule(evt.Source)
+	if rule == nil {
+		out <- evt
+		return
+	}
+
+	if p != nil && p.lines < rule.MaxLines && rule.appends(evt.Data) {
+		p.event.Data += "\n" + evt.Data
+		p.event.Position = evt.Position
+		p.event.Commit = evt.Commit
+		p.lines++
+		p.updated = time.Now()
+		return
+	}
+
+	if p != nil {
+		out <- p.event
+	}
+	m.pending[evt.Source] = &pendingEvent{rule: rule, event: evt, lines: 1, updated: time.Now()}
+}
+
+// flushAll отправляет ожидающие события, для которых done вернул true
+func (m *Multiline) flushAll(out chan<- RawEvent, done func(*pendingEvent) bool) {
+	sources := make([]string, 0, len(m.pending))
+	for source := range m.pending {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	for _, source := range sources {
+		if p := m.pending[source]; done(p) {
+			out <- p.event
+			delete(m.pending, source)
+		}
+	}
+}
+
+func (m *Multiline) rule(source string) *multilineRule {
+	for _, rule := range m.rules {
+		if ok, _ := matchPath(rule.Source, source); ok {
+			return rule
+		}
+	}
+	return nil
+}
+
+// appends дописывается ли строка к текущему событию
+func (r *multilineRule) appends(line string) bool {
+	if r.cont != nil && r.cont.MatchString(line) {
+		return true
+	}
+	return r.start != nil && !r.start.MatchString(line)
+}
+
+// matchPath шаблон без "/" сравнивается с именем файла, иначе с полным путём
+func matchPath(pattern, path string) (bool, error) {
+	if !strings.Contains(pattern, "/") {
+		path = filepath.Base(path)
+	}
+	return filepath.Match(pattern, path)
+}
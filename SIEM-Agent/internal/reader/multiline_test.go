@@ -0,0 +1,151 @@
+package reader
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// collectMultiline прогоняет строки через склейку и возвращает события
+func collectMultiline(t *testing.T, rules []MultilineRule, source string, lines ...string) []RawEvent {
+	t.Helper()
+	m, err := NewMultiline(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := make(chan RawEvent, len(lines))
+	for i, line := range lines {
+		offset := int64(i + 1)
+		in <- RawEvent{Source: source, Data: line, Position: Position{Offset: offset}}
+	}
+	close(in)
+
+	var events []RawEvent
+	for evt := range m.Run(in) {
+		events = append(events, evt)
+	}
+	return events
+}
+
+func TestMultiline(t *testing.T) {
+	trace := []string{
+		"2024-01-01 10:00:00 ERROR request failed",
+		"java.lang.NullPointerException: null",
+		"\tat com.example.Service.handle(Service.java:42)",
+		"\tat com.example.Server.run(Server.java:7)",
+		"2024-01-01 10:00:01 INFO recovered",
+	}
+
+	tests := []struct {
+		name     string
+		rule     MultilineRule
+		source   string
+		lines    []string
+		expected []string
+	}{
+		{
+			name:   "start pattern",
+			rule:   MultilineRule{Source: "app.log", Start: `^\d{4}-\d{2}-\d{2} `},
+			source: "/var/log/app.log",
+			lines:  trace,
+			expected: []string{
+				"2024-01-01 10:00:00 ERROR request failed\njava.lang.NullPointerException: null\n\tat com.example.Service.handle(Service.java:42)\n\tat com.example.Server.run(Server.java:7)",
+				"2024-01-01 10:00:01 INFO recovered",
+			},
+		},
+		{
+			name:   "continue pattern",
+			rule:   MultilineRule{Source: "/var/log/*.log", Continue: `^\s`},
+			source: "/var/log/app.log",
+			lines:  trace,
+			expected: []string{
+				"2024-01-01 10:00:00 ERROR request failed",
+				"java.lang.NullPointerException: null\n\tat com.example.Service.handle(Service.java:42)\n\tat com.example.Server.run(Server.java:7)",
+				"2024-01-01 10:00:01 INFO recovered",
+			},
+		},
+		{
+			name:   "max lines",
+			rule:   MultilineRule{Source: "app.log", Continue: `^\s`, MaxLines: 2},
+			source: "/var/log/app.log",
+			lines:  []string{"head", " 1", " 2", " 3"},
+			expected: []string{
+				"head\n 1",
+				" 2\n 3",
+			},
+		},
+		{
+			name:     "other source untouched",
+			rule:     MultilineRule{Source: "app.log", Continue: `^\s`},
+			source:   "/var/log/syslog",
+			lines:    []string{"head", " tail"},
+			expected: []string{"head", " tail"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := collectMultiline(t, []MultilineRule{tt.rule}, tt.source, tt.lines...)
+			var got []string
+			for _, evt := range events {
+				got = append(got, evt.Data)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestMultilinePositionOfLastLine(t *testing.T) {
+	events := collectMultiline(t,
+		[]MultilineRule{{Source: "app.log", Continue: `^\s`}},
+		"/var/log/app.log", "head", " 1", " 2", "next")
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	if events[0].Position.Offset != 3 {
+		t.Errorf("merged event must carry position of its last line, got %d", events[0].Position.Offset)
+	}
+}
+
+func TestMultilineFlushTimeout(t *testing.T) {
+	m, err := NewMultiline([]MultilineRule{{Source: "app.log", Continue: `^\s`, Timeout: 50 * time.Millisecond}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := make(chan RawEvent)
+	out := m.Run(in)
+	defer close(in)
+
+	in <- RawEvent{Source: "/var/log/app.log", Data: "head"}
+	in <- RawEvent{Source: "/var/log/app.log", Data: " tail"}
+
+	select {
+	case evt := <-out:
+		if evt.Data != "head\n tail" {
+			t.Errorf("unexpected event %q", evt.Data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("pending event was not flushed after timeout")
+	}
+}
+
+func TestNewMultilineRejectsBadRules(t *testing.T) {
+	tests := []struct {
+		name string
+		rule MultilineRule
+	}{
+		{"no source", MultilineRule{Start: "^x"}},
+		{"no patterns", MultilineRule{Source: "app.log"}},
+		{"bad regexp", MultilineRule{Source: "app.log", Start: "("}},
+		{"bad glob", MultilineRule{Source: "[", Start: "^x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewMultiline([]MultilineRule{tt.rule}); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package reader
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// fingerprintSize сколько первых байт файла входит в отпечаток
+const fingerprintSize = 1024
+
+// FileID устройство и inode файла: по ним ротация отличается от дописывания
+type FileID struct {
+	Device uint64 `json:"device"`
+	Inode  uint64 `json:"inode"`
+}
+
+// Position позиция в файле источника сразу после прочитанной строки
+type Position struct {
+	File   FileID
+	Offset int64
+}
+
+// RegistryEntry подтверждённая позиция чтения одного файла
+type RegistryEntry struct {
+	Path string `json:"path"`
+	FileID
+	Offset          int64     `json:"offset"`
+	Fingerprint     string    `json:"fingerprint"`      // sha256 первых FingerprintSize байт
+	FingerprintSize int       `json:"fingerprint_size"` // меньше fingerprintSize, если файл был короче
+	Cursor          string    `json:"cursor,omitempty"` // позиция journald вместо смещения
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// ReadEntry отпечаток ротированного файла, который уже прочитан целиком или
+// частично: такие архивы не загружаются повторно (SetIngestCompressed)
+type ReadEntry struct {
+	Source          string    `json:"source"`
+	Fingerprint     string    `json:"fingerprint"`
+	FingerprintSize int       `json:"fingerprint_size"`
+	ReadAt          time.Time `json:"read_at"`
+}
+
+// maxReadPerSource сколько отпечатков прочитанных файлов хранится на источник:
+// logrotate всё равно удаляет старые архивы
+const maxReadPerSource = 64
+
+// registryFile формат файла реестра
+type registryFile struct {
+	Files []RegistryEntry `json:"files"`
+	Read  []ReadEntry     `json:"read,omitempty"`
+}
+
+// Registry позиции чтения файлов, переживающие перезапуск агента
+type Registry struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]RegistryEntry
+	read    []ReadEntry
+}
+
+// OpenRegistry загружает реестр из файла; отсутствующий файл — пустой реестр
+func OpenRegistry(path string) (*Registry, error) {
+	reg := &Registry{path: path, entries: make(map[string]RegistryEntry)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return reg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read registry: %w", err)
+	}
+
+	var file registryFile
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		// прежний формат: только позиции файлов
+		err = json.Unmarshal(data, &file.Files)
+	} else {
+		err = json.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal registry: %w", err)
+	}
+	for _, entry := range file.Files {
+		reg.entries[entry.Path] = entry
+	}
+	reg.read = file.Read
+	return reg, nil
+}
+
+// Get возвращает сохранённую позицию файла
+func (r *Registry) Get(path string) (RegistryEntry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.entries[path]
+	return entry, ok
+}
+
+// Set обновляет позицию файла и сразу сохраняет реестр на диск
+func (r *Registry) Set(entry RegistryEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[entry.Path] = entry
+	return r.save()
+}
+
+// MarkRead запоминает отпечаток прочитанного ротированного файла источника
+func (r *Registry) MarkRead(source, fp string, size int) error {
+	if size == 0 {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kept := make([]ReadEntry, 0, len(r.read)+1)
+	count := 0
+	for i := len(r.read) - 1; i >= 0; i-- {
+		entry := r.read[i]
+		if entry.Source == source {
+			if entry.Fingerprint == fp || count >= maxReadPerSource-1 {
+				continue
+			}
+			count++
+		}
+		kept = append(kept, entry)
+	}
+	slices.Reverse(kept)
+	r.read = append(kept, ReadEntry{Source: source, Fingerprint: fp, FingerprintSize: size, ReadAt: time.Now()})
+	return r.save()
+}
+
+// WasRead начинается ли файл с head одним из прочитанных файлов источника
+func (r *Registry) WasRead(source string, head []byte) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, entry := range r.read {
+		if entry.Source == source && matchesHead(head, entry.Fingerprint, entry.FingerprintSize) {
+			return true
+		}
+	}
+	return false
+}
+
+// save пишет реестр во временный файл и атомарно подменяет им старый
+func (r *Registry) save() error {
+	file := registryFile{Files: make([]RegistryEntry, 0, len(r.entries)), Read: r.read}
+	for _, entry := range r.entries {
+		file.Files = append(file.Files, entry)
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal registry: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return fmt.Errorf("failed to create registry directory: %w", err)
+	}
+	tmp := r.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create registry file: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write registry: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync registry: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close registry file: %w", err)
+	}
+	return os.Rename(tmp, r.path)
+}
+
+// fingerprint хэш первых байт файла; size — сколько байт вошло в хэш
+func fingerprint(f *os.File) (string, int, error) {
+	buf := make([]byte, fingerprintSize)
+	n, err := f.ReadAt(buf, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", 0, err
+	}
+	sum := sha256.Sum256(buf[:n])
+	return hex.EncodeToString(sum[:]), n, nil
+}
+
+// sameContent начинается ли файл с тех же байт, что и при сохранении позиции
+func sameContent(f *os.File, entry RegistryEntry) bool {
+	buf := make([]byte, entry.FingerprintSize)
+	n, err := f.ReadAt(buf, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false
+	}
+	return matchesHead(buf[:n], entry.Fingerprint, entry.FingerprintSize)
+}
+
+// matchesHead совпадают ли первые size байт head с отпечатком
+func matchesHead(head []byte, fp string, size int) bool {
+	if len(head) < size {
+		return false
+	}
+	sum := sha256.Sum256(head[:size])
+	return hex.EncodeToString(sum[:]) == fp
+}
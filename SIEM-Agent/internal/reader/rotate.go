@@ -0,0 +1,318 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// rotatedGrace сколько ротированный файл дочитывается после последней записи в
+// него: писатель продолжает писать в старый файл, пока не переоткроет лог
+const rotatedGrace = 5 * time.Second
+
+// pollInterval период проверки ротированных файлов и пути источника: после
+// переименования fsnotify уже не сообщает о записи в старый файл
+const pollInterval = time.Second
+
+// decompressors распаковщики сжатых ротированных файлов по расширению
+var decompressors = map[string]func(io.Reader) (io.ReadCloser, error){
+	".gz": func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	},
+	".bz2": func(r io.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	},
+	".zst": func(r io.Reader) (io.ReadCloser, error) {
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	},
+}
+
+// IsCompressed сжат ли файл: такие файлы не читаются как источники, а только
+// дочитываются после ротации или загружаются как история
+func IsCompressed(path string) bool {
+	_, ok := decompressors[filepath.Ext(path)]
+	return ok
+}
+
+// compressedFile распакованное содержимое сжатого файла
+type compressedFile struct {
+	io.ReadCloser
+	file *os.File
+}
+
+func (c *compressedFile) Close() error {
+	err := c.ReadCloser.Close()
+	if ferr := c.file.Close(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+func openCompressed(path string) (io.ReadCloser, error) {
+	open, ok := decompressors[filepath.Ext(path)]
+	if !ok {
+		return nil, fmt.Errorf("unknown compression: %s", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := open(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to decompress %s: %w", path, err)
+	}
+	return &compressedFile{ReadCloser: stream, file: f}, nil
+}
+
+// rotated ротированный файл, который дочитывается с последней позиции. Обычный
+// файл читается по открытому дескриптору, пока в него пишут; сжатый — сразу до конца
+type rotated struct {
+	file     *os.File // nil для сжатого
+	reader   io.Reader
+	closer   io.Closer
+	id       FileID
+	offset   int64
+	partial  string
+	lastRead time.Time
+}
+
+// findRotated ищет рядом с path файл с содержимым из записи реестра и открывает
+// его на сохранённой позиции: переименованный файл находится по inode, копия
+// после copytruncate и сжатый файл — по отпечатку начала. Текущий файл
+// источника (current) пропускается
+func findRotated(path string, entry RegistryEntry, current FileID) (*rotated, bool) {
+	candidates := siblings(path)
+
+	if entry.FileID != (FileID{}) {
+		for _, candidate := range candidates {
+			info, err := os.Stat(candidate)
+			if err != nil || fileID(info) != entry.FileID {
+				continue
+			}
+			if rot, ok := openRotatedFile(candidate, entry); ok {
+				return rot, true
+			}
+		}
+	}
+
+	if entry.FingerprintSize == 0 {
+		return nil, false
+	}
+	for _, candidate := range candidates {
+		if IsCompressed(candidate) {
+			if rot, ok := openRotatedCompressed(candidate, entry); ok {
+				return rot, true
+			}
+			continue
+		}
+		info, err := os.Stat(candidate)
+		if err != nil || fileID(info) == current && current != (FileID{}) {
+			continue
+		}
+		if rot, ok := openRotatedFile(candidate, entry); ok {
+			return rot, true
+		}
+	}
+	return nil, false
+}
+
+func openRotatedFile(path string, entry RegistryEntry) (*rotated, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	info, err := f.Stat()
+	if err != nil || info.Size() < entry.Offset || !sameContent(f, entry) {
+		f.Close()
+		return nil, false
+	}
+	if _, err := f.Seek(entry.Offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, false
+	}
+	return &rotated{
+		file:     f,
+		reader:   f,
+		closer:   f,
+		id:       fileID(info),
+		offset:   entry.Offset,
+		lastRead: time.Now(),
+	}, true
+}
+
+// openRotatedCompressed распаковывает файл и пропускает уже прочитанные байты
+func openRotatedCompressed(path string, entry RegistryEntry) (*rotated, bool) {
+	stream, err := openCompressed(path)
+	if err != nil {
+		return nil, false
+	}
+	head := make([]byte, fingerprintSize)
+	n, err := io.ReadFull(stream, head)
+	head = head[:n]
+	if (err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF)) ||
+		!matchesHead(head, entry.Fingerprint, entry.FingerprintSize) {
+		stream.Close()
+		return nil, false
+	}
+
+	var reader io.Reader = stream
+	if entry.Offset <= int64(len(head)) {
+		reader = io.MultiReader(bytes.NewReader(head[entry.Offset:]), stream)
+	} else if skip := entry.Offset - int64(len(head)); skip > 0 {
+		if copied, _ := io.CopyN(io.Discard, stream, skip); copied < skip {
+			stream.Close()
+			return nil, false
+		}
+	}
+
+	var id FileID
+	if info, err := os.Stat(path); err == nil {
+		id = fileID(info)
+	}
+	return &rotated{reader: reader, closer: stream, id: id, offset: entry.Offset}, true
+}
+
+// siblings файлы рядом с path, имена которых начинаются с имени источника:
+// auth.log.1, auth.log.2.gz, auth.log-20240101
+func siblings(path string) []string {
+	dir, base := filepath.Split(path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, e := range entries {
+		if e.IsDir() || e.Name() == base || !strings.HasPrefix(e.Name(), base) {
+			continue
+		}
+		paths = append(paths, filepath.Join(dir, e.Name()))
+	}
+	return paths
+}
+
+// compressedHistory сжатые ротированные файлы источника от старых к новым
+func compressedHistory(path string) []string {
+	type archive struct {
+		path    string
+		modTime time.Time
+	}
+	var archives []archive
+	for _, candidate := range siblings(path) {
+		if !IsCompressed(candidate) {
+			continue
+		}
+		if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() {
+			archives = append(archives, archive{candidate, info.ModTime()})
+		}
+	}
+	// при равном времени auth.log.3.gz старше auth.log.2.gz
+	sort.Slice(archives, func(i, j int) bool {
+		if !archives[i].modTime.Equal(archives[j].modTime) {
+			return archives[i].modTime.Before(archives[j].modTime)
+		}
+		return archives[i].path > archives[j].path
+	})
+
+	paths := make([]string, len(archives))
+	for i, a := range archives {
+		paths[i] = a.path
+	}
+	return paths
+}
+
+// ingestHistory один раз читает сжатые ротированные файлы источника. Архив
+// запоминается в реестре по отпечатку после доставки последней строки,
+// поэтому переименование logrotate'ом (.2.gz → .3.gz) не вызывает повторного
+// чтения. Архивы, содержимое которых агент уже читал до ротации, пропускаются
+func (r *Reader) ingestHistory() {
+	if r.registry == nil {
+		return
+	}
+	for _, path := range compressedHistory(r.path) {
+		select {
+		case <-r.stop:
+			return
+		default:
+		}
+		if err := r.ingest(path); err != nil {
+			r.errors <- err
+		}
+	}
+}
+
+func (r *Reader) ingest(path string) error {
+	stream, err := openCompressed(path)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	reader := bufio.NewReader(stream)
+	head, _ := reader.Peek(fingerprintSize)
+	if len(head) == 0 || r.registry.WasRead(r.path, head) {
+		return nil
+	}
+	sum := sha256.Sum256(head)
+	fp, size := hex.EncodeToString(sum[:]), len(head)
+
+	// строка отправляется после чтения следующей, чтобы последняя несла подтверждение
+	var pending string
+	var hasPending bool
+	for {
+		select {
+		case <-r.stop:
+			return nil
+		default:
+		}
+
+		line, err := reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if line != "" {
+			if hasPending {
+				r.emitArchived(path, pending, nil)
+			}
+			pending, hasPending = trimNewline(line), true
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	commit := func() error { return r.registry.MarkRead(r.path, fp, size) }
+	if !hasPending {
+		return commit()
+	}
+	r.emitArchived(path, pending, commit)
+	return nil
+}
+
+// emitArchived событие из архива; источник — имя архива, чтобы подтверждение
+// его последней строки не заменялось подтверждениями текущего файла
+func (r *Reader) emitArchived(path, data string, commit func() error) {
+	r.events <- RawEvent{
+		Source:    path,
+		Timestamp: time.Now(),
+		Data:      data,
+		Commit:    commit,
+	}
+}
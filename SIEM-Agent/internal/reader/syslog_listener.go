@@ -0,0 +1,306 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Значения RawEvent.Source для сообщений, принятых по сети
+const (
+	SyslogUDPSource = "syslog+udp"
+	SyslogTCPSource = "syslog+tcp"
+	SyslogTLSSource = "syslog+tls"
+)
+
+const defaultMaxMessageSize = 64 * 1024
+
+// SyslogListenerConfig адреса приёма syslog; пустой адрес — транспорт выключен
+type SyslogListenerConfig struct {
+	UDP            string      // :514
+	TCP            string      // :514
+	TLS            string      // :6514
+	TLSConfig      *tls.Config // сертификат сервера, обязателен для TLS
+	MaxMessageSize int         // 0 — defaultMaxMessageSize, длиннее обрезаются
+}
+
+// SyslogListener принимает сообщения syslog по UDP, TCP и TLS. По TCP
+// поддерживаются обе схемы кадрирования RFC 6587: octet-counting
+// ("LEN SP MSG") и сообщения, разделённые переводом строки. Адрес
+// отправителя записывается в RawEvent.Remote
+type SyslogListener struct {
+	cfg SyslogListenerConfig
+
+	udp  net.PacketConn
+	tcp  net.Listener
+	tls  net.Listener
+	mu   sync.Mutex
+	conn map[net.Conn]struct{}
+
+	events chan RawEvent
+	errors chan error
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewSyslogListener(cfg SyslogListenerConfig) *SyslogListener {
+	if cfg.MaxMessageSize <= 0 {
+		cfg.MaxMessageSize = defaultMaxMessageSize
+	}
+	return &SyslogListener{
+		cfg:    cfg,
+		conn:   make(map[net.Conn]struct{}),
+		events: make(chan RawEvent, 100),
+		errors: make(chan error, 10),
+		stop:   make(chan struct{}),
+	}
+}
+
+func (l *SyslogListener) Events() <-chan RawEvent { return l.events }
+func (l *SyslogListener) Errors() <-chan error    { return l.errors }
+
+// Start открывает порты; при ошибке уже открытые закрываются
+func (l *SyslogListener) Start() error {
+	if err := l.listen(); err != nil {
+		l.closeListeners()
+		return err
+	}
+
+	if l.udp != nil {
+		l.wg.Add(1)
+		go l.serveUDP()
+	}
+	if l.tcp != nil {
+		l.wg.Add(1)
+		go l.serveStream(l.tcp, SyslogTCPSource)
+	}
+	if l.tls != nil {
+		l.wg.Add(1)
+		go l.serveStream(l.tls, SyslogTLSSource)
+	}
+	return nil
+}
+
+func (l *SyslogListener) listen() error {
+	var err error
+	if l.cfg.UDP != "" {
+		if l.udp, err = net.ListenPacket("udp", l.cfg.UDP); err != nil {
+			return fmt.Errorf("failed to listen syslog udp %s: %w", l.cfg.UDP, err)
+		}
+	}
+	if l.cfg.TCP != "" {
+		if l.tcp, err = net.Listen("tcp", l.cfg.TCP); err != nil {
+			return fmt.Errorf("failed to listen syslog tcp %s: %w", l.cfg.TCP, err)
+		}
+	}
+	if l.cfg.TLS != "" {
+		if l.cfg.TLSConfig == nil {
+			return errors.New("syslog tls listener requires a certificate")
+		}
+		if l.tls, err = tls.Listen("tcp", l.cfg.TLS, l.cfg.TLSConfig); err != nil {
+			return fmt.Errorf("failed to listen syslog tls %s: %w", l.cfg.TLS, err)
+		}
+	}
+	return nil
+}
+
+// Addr фактический адрес транспорта (udp, tcp, tls) или nil, если он выключен
+func (l *SyslogListener) Addr(transport string) net.Addr {
+	switch {
+	case transport == "udp" && l.udp != nil:
+		return l.udp.LocalAddr()
+	case transport == "tcp" && l.tcp != nil:
+		return l.tcp.Addr()
+	case transport == "tls" && l.tls != nil:
+		return l.tls.Addr()
+	}
+	return nil
+}
+
+// Stop закрывает порты и соединения и закрывает каналы событий
+func (l *SyslogListener) Stop() {
+	close(l.stop)
+	l.closeListeners()
+
+	l.mu.Lock()
+	for conn := range l.conn {
+		conn.Close()
+	}
+	l.mu.Unlock()
+
+	l.wg.Wait()
+	close(l.events)
+	close(l.errors)
+}
+
+func (l *SyslogListener) closeListeners() {
+	if l.udp != nil {
+		l.udp.Close()
+	}
+	if l.tcp != nil {
+		l.tcp.Close()
+	}
+	if l.tls != nil {
+		l.tls.Close()
+	}
+}
+
+// serveUDP одна датаграмма — одно сообщение
+func (l *SyslogListener) serveUDP() {
+	defer l.wg.Done()
+
+	buf := make([]byte, l.cfg.MaxMessageSize)
+	for {
+		n, addr, err := l.udp.ReadFrom(buf)
+		if err != nil {
+			if l.stopping() {
+				return
+			}
+			l.report(fmt.Errorf("syslog udp: %w", err))
+			continue
+		}
+		if msg := trimMessage(buf[:n]); len(msg) > 0 {
+			l.emit(SyslogUDPSource, addr, string(msg))
+		}
+	}
+}
+
+func (l *SyslogListener) serveStream(listener net.Listener, source string) {
+	defer l.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if l.stopping() {
+				return
+			}
+			l.report(fmt.Errorf("%s: %w", source, err))
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		l.mu.Lock()
+		if l.stopping() {
+			l.mu.Unlock()
+			conn.Close()
+			return
+		}
+		l.conn[conn] = struct{}{}
+		l.wg.Add(1)
+		l.mu.Unlock()
+
+		go l.serveConn(conn, source)
+	}
+}
+
+func (l *SyslogListener) serveConn(conn net.Conn, source string) {
+	defer l.wg.Done()
+	defer func() {
+		l.mu.Lock()
+		delete(l.conn, conn)
+		l.mu.Unlock()
+		conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	for {
+		msg, err := l.readFrame(reader)
+		if len(msg) > 0 {
+			if !l.emit(source, conn.RemoteAddr(), string(msg)) {
+				return
+			}
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !l.stopping() {
+				l.report(fmt.Errorf("%s %s: %w", source, conn.RemoteAddr(), err))
+			}
+			return
+		}
+	}
+}
+
+// readFrame читает одно сообщение: с цифры начинается octet-counting кадр,
+// иначе сообщение длится до перевода строки
+func (l *SyslogListener) readFrame(r *bufio.Reader) ([]byte, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] >= '1' && first[0] <= '9' {
+		prefix, err := r.ReadString(' ')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(prefix[:len(prefix)-1])
+		if err != nil || len(prefix) > 10 {
+			return nil, fmt.Errorf("invalid octet count %q", prefix)
+		}
+		msg := make([]byte, min(size, l.cfg.MaxMessageSize))
+		if _, err := io.ReadFull(r, msg); err != nil {
+			return nil, err
+		}
+		if size > len(msg) {
+			if _, err := io.CopyN(io.Discard, r, int64(size-len(msg))); err != nil {
+				return nil, err
+			}
+		}
+		return trimMessage(msg), nil
+	}
+
+	var msg []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if room := l.cfg.MaxMessageSize - len(msg); room > 0 {
+			msg = append(msg, chunk[:min(len(chunk), room)]...)
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		return trimMessage(msg), err
+	}
+}
+
+func (l *SyslogListener) emit(source string, addr net.Addr, data string) bool {
+	evt := RawEvent{
+		Source:    source,
+		Remote:    addr.String(),
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+	select {
+	case l.events <- evt:
+		return true
+	case <-l.stop:
+		return false
+	}
+}
+
+func (l *SyslogListener) stopping() bool {
+	select {
+	case <-l.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// report не блокирует приём, если ошибки никто не читает
+func (l *SyslogListener) report(err error) {
+	select {
+	case l.errors <- err:
+	default:
+	}
+}
+
+// trimMessage убирает завершающие перевод строки и NUL, которые добавляют некоторые отправители
+func trimMessage(msg []byte) []byte {
+	return bytes.TrimRight(msg, "\r\n\x00")
+}
@@ -0,0 +1,155 @@
+package reader
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// selfSigned сертификат сервера для 127.0.0.1
+func selfSigned(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "siem-agent"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func receive(t *testing.T, l *SyslogListener, n int) []RawEvent {
+	t.Helper()
+	var events []RawEvent
+	timeout := time.After(3 * time.Second)
+	for len(events) < n {
+		select {
+		case evt := <-l.Events():
+			events = append(events, evt)
+		case err := <-l.Errors():
+			t.Fatalf("listener error: %v", err)
+		case <-timeout:
+			t.Fatalf("expected %d messages, got %d", n, len(events))
+		}
+	}
+	return events
+}
+
+func TestSyslogListener(t *testing.T) {
+	cert := selfSigned(t)
+	l := NewSyslogListener(SyslogListenerConfig{
+		UDP:            "127.0.0.1:0",
+		TCP:            "127.0.0.1:0",
+		TLS:            "127.0.0.1:0",
+		TLSConfig:      &tls.Config{Certificates: []tls.Certificate{cert}},
+		MaxMessageSize: 64,
+	})
+	if err := l.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Stop()
+
+	tests := []struct {
+		name     string
+		dial     func() (net.Conn, error)
+		source   string
+		payload  string
+		expected []string
+	}{
+		{
+			name:     "udp datagram",
+			dial:     func() (net.Conn, error) { return net.Dial("udp", l.Addr("udp").String()) },
+			source:   SyslogUDPSource,
+			payload:  "<34>Oct 11 22:14:15 router su: 'su root' failed\n",
+			expected: []string{"<34>Oct 11 22:14:15 router su: 'su root' failed"},
+		},
+		{
+			name:    "tcp octet counting and newline framing",
+			dial:    func() (net.Conn, error) { return net.Dial("tcp", l.Addr("tcp").String()) },
+			source:  SyslogTCPSource,
+			payload: "29 <13>1 - host app - - - first\n" + "<13>1 - host app - - - second\r\n" + "<13>third\x00\n",
+			expected: []string{
+				"<13>1 - host app - - - first",
+				"<13>1 - host app - - - second",
+				"<13>third",
+			},
+		},
+		{
+			name:    "tcp oversized messages are truncated",
+			dial:    func() (net.Conn, error) { return net.Dial("tcp", l.Addr("tcp").String()) },
+			source:  SyslogTCPSource,
+			payload: "100 " + strings.Repeat("a", 100) + strings.Repeat("b", 100) + "\n" + "<13>next\n",
+			expected: []string{
+				strings.Repeat("a", 64),
+				strings.Repeat("b", 64),
+				"<13>next",
+			},
+		},
+		{
+			name: "tls",
+			dial: func() (net.Conn, error) {
+				pool := x509.NewCertPool()
+				leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+				pool.AddCert(leaf)
+				return tls.Dial("tcp", l.Addr("tls").String(), &tls.Config{RootCAs: pool})
+			},
+			source:   SyslogTLSSource,
+			payload:  "30 <86>1 - host sshd 42 - - hello",
+			expected: []string{"<86>1 - host sshd 42 - - hello"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := tt.dial()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			if _, err := conn.Write([]byte(tt.payload)); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, evt := range receive(t, l, len(tt.expected)) {
+				got = append(got, evt.Data)
+				if evt.Source != tt.source {
+					t.Errorf("expected source %q, got %q", tt.source, evt.Source)
+				}
+				if evt.Remote != conn.LocalAddr().String() {
+					t.Errorf("expected remote %s, got %s", conn.LocalAddr(), evt.Remote)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSyslogListenerTLSRequiresCertificate(t *testing.T) {
+	l := NewSyslogListener(SyslogListenerConfig{TCP: "127.0.0.1:0", TLS: "127.0.0.1:0"})
+	if err := l.Start(); err == nil {
+		l.Stop()
+		t.Fatal("expected error without certificate")
+	}
+}
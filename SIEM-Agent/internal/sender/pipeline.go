@@ -1,6 +1,7 @@
 package sender
 
 import (
+	"errors"
 	"log"
 	"sync"
 	"time"
@@ -17,12 +18,22 @@ type pipeline struct {
 	filter     filter.Filter
 
 	buffer []domain.Event
+	acks   []*domain.Ack // подтверждения событий буфера и отфильтрованных, по порядку
 	mu     sync.Mutex
 
+	// pending батч, который не удалось ни отправить, ни сохранить на диск; он
+	// повторяется без изменений, чтобы _id документов совпали с первой попыткой
+	pending     *domain.Batch
+	pendingAcks []*domain.Ack
+
 	stop chan struct{}
 	wg   sync.WaitGroup
 }
 
+// defaultSpoolRetry период повтора батчей из дискового буфера, если
+// RetryConfig.MaxDelay не задан
+const defaultSpoolRetry = time.Minute
+
 func NewPipeline(sender Sender, cfg Config) Pipeline {
 	p := &pipeline{
 		sender:     sender,
@@ -41,15 +52,17 @@ func NewPipeline(sender Sender, cfg Config) Pipeline {
 }
 
 func (p *pipeline) Start(input <-chan domain.Event) {
-	if p.diskBuffer != nil {
-		go p.loadPendingBatches()
-	}
-
 	p.wg.Add(1)
 	go p.run(input)
 }
 
-func (p *pipeline) loadPendingBatches() {
+// retrySpooled отправляет батчи из дискового буфера по порядку и
+// останавливается на первой ошибке, чтобы не перебирать весь буфер впустую
+func (p *pipeline) retrySpooled() {
+	if p.diskBuffer == nil {
+		return
+	}
+
 	batches, err := p.diskBuffer.LoadPending()
 	if err != nil {
 		log.Printf("Failed to load pending batches: %v", err)
@@ -62,18 +75,20 @@ func (p *pipeline) loadPendingBatches() {
 
 	log.Printf("Found %d pending batches on disk, sending...", len(batches))
 
+	sent := 0
 	for _, batch := range batches {
-		if err := p.sender.Send(batch); err != nil {
-			log.Printf("Failed to send pending batch: %v", err)
-			continue
+		if err := p.send(batch); err != nil {
+			log.Printf("Failed to send pending batch, %d left on disk: %v", len(batches)-sent, err)
+			return
 		}
 
 		if err := p.diskBuffer.Remove(batch); err != nil {
 			log.Printf("Failed to remove batch from disk: %v", err)
 		}
+		sent++
 	}
 
-	log.Printf("Successfully sent %d pending batches", len(batches))
+	log.Printf("Successfully sent %d pending batches", sent)
 }
 
 func (p *pipeline) Stop() {
@@ -81,9 +96,7 @@ func (p *pipeline) Stop() {
 	p.wg.Wait()
 
 	p.mu.Lock()
-	if len(p.buffer) > 0 {
-		p.flush()
-	}
+	p.flush()
 	p.mu.Unlock()
 
 	log.Println("Pipeline stopped gracefully")
@@ -95,21 +108,35 @@ func (p *pipeline) run(input <-chan domain.Event) {
 	ticker := time.NewTicker(p.config.FlushTimeout)
 	defer ticker.Stop()
 
+	retryInterval := p.config.RetryConfig.MaxDelay
+	if retryInterval <= 0 {
+		retryInterval = defaultSpoolRetry
+	}
+	retry := time.NewTicker(retryInterval)
+	defer retry.Stop()
+
+	p.mu.Lock()
+	p.retrySpooled()
+	p.mu.Unlock()
+
 	for {
+		// пока отложенный батч не доставлен, в памяти копится не больше
+		// одного батча: дальше чтение останавливается и читатели ждут
+		in := input
+		p.mu.Lock()
+		if p.blocked() {
+			in = nil
+		}
+		p.mu.Unlock()
+
 		select {
-		case event, ok := <-input:
+		case event, ok := <-in:
 			if !ok {
 				return
 			}
 
-			if !p.filter.Match(event) {
-				continue
-			}
-
 			p.mu.Lock()
-			p.buffer = append(p.buffer, event)
-
-			if len(p.buffer) >= p.config.BatchSize {
+			if p.add(event) {
 				p.flush()
 				ticker.Reset(p.config.FlushTimeout)
 			}
@@ -117,9 +144,12 @@ func (p *pipeline) run(input <-chan domain.Event) {
 
 		case <-ticker.C:
 			p.mu.Lock()
-			if len(p.buffer) > 0 {
-				p.flush()
-			}
+			p.flush()
+			p.mu.Unlock()
+
+		case <-retry.C:
+			p.mu.Lock()
+			p.retrySpooled()
 			p.mu.Unlock()
 
 		case <-p.stop:
@@ -128,28 +158,120 @@ func (p *pipeline) run(input <-chan domain.Event) {
 	}
 }
 
+// blocked сообщает, что буфер заполнен, а отложенный батч ещё не доставлен.
+// Подтверждения отфильтрованных событий тоже ограничены размером батча
+func (p *pipeline) blocked() bool {
+	return p.pending != nil &&
+		(len(p.buffer) >= p.config.BatchSize || len(p.acks) >= p.config.BatchSize)
+}
+
+// add кладёт событие в буфер, если оно проходит фильтр; true — батч заполнен.
+// Подтверждение отфильтрованного события ждёт следующего батча, чтобы позиция
+// источника не обогнала ещё не доставленные события
+func (p *pipeline) add(event domain.Event) bool {
+	if event.Ack != nil {
+		p.acks = append(p.acks, event.Ack)
+	}
+	if !p.filter.Match(event) {
+		return false
+	}
+	p.buffer = append(p.buffer, event)
+	return len(p.buffer) >= p.config.BatchSize
+}
+
+// flush отправляет буфер; если NoSQLdb недоступна — сохраняет его в дисковый буфер.
+// Позиции источников подтверждаются, только когда батч принят или записан на диск,
+// иначе батч остаётся в памяти и повторяется первым, новые события ждут его
 func (p *pipeline) flush() {
+	if p.pending != nil && !p.deliver() {
+		return
+	}
+
 	if len(p.buffer) == 0 {
+		p.commitAcks(p.acks)
+		p.acks = p.acks[:0]
 		return
 	}
 
-	batch := domain.Batch{
+	p.pending = &domain.Batch{
 		AgentID:   p.config.AgentID,
 		Timestamp: time.Now(),
 		Events:    p.buffer,
 	}
+	p.pendingAcks = p.acks
+	p.buffer = make([]domain.Event, 0, p.config.BatchSize)
+	p.acks = nil
+	p.deliver()
+}
 
-	if err := p.sender.Send(batch); err != nil {
+// deliver отправляет или сохраняет на диск отложенный батч; true — батч
+// больше не нужен в памяти и его события подтверждены
+func (p *pipeline) deliver() bool {
+	if err := p.send(*p.pending); err != nil {
 		log.Printf("Failed to send batch: %v", err)
 
-		if p.diskBuffer != nil {
-			if saveErr := p.diskBuffer.Save(batch); saveErr != nil {
-				log.Printf("CRITICAL: Failed to save batch to disk: %v", saveErr)
-			} else {
-				log.Printf("Batch saved to disk for later retry")
-			}
+		if p.diskBuffer == nil {
+			log.Printf("Batch kept in memory for retry (%d events)", len(p.pending.Events))
+			return false
+		}
+		if saveErr := p.diskBuffer.Save(*p.pending); saveErr != nil {
+			log.Printf("CRITICAL: Failed to save batch to disk, kept in memory for retry: %v", saveErr)
+			return false
 		}
+		log.Printf("Batch saved to disk for later retry")
 	}
 
-	p.buffer = make([]domain.Event, 0, p.config.BatchSize)
+	p.commitAcks(p.pendingAcks)
+	p.pending, p.pendingAcks = nil, nil
+	return true
+}
+
+// send отправляет батч; отклонённые NoSQLdb события повтор не исправит,
+// поэтому они сохраняются отдельно или попадают в лог, а батч считается доставленным
+func (p *pipeline) send(batch domain.Batch) error {
+	err := p.sender.Send(batch)
+	var rejected *RejectedError
+	if !errors.As(err, &rejected) {
+		return err
+	}
+
+	if p.diskBuffer != nil {
+		saveErr := p.diskBuffer.SaveRejected(domain.Batch{
+			AgentID:   batch.AgentID,
+			Timestamp: batch.Timestamp,
+			Events:    rejected.Events,
+		})
+		if saveErr == nil {
+			log.Printf("%d rejected events saved to disk for review", len(rejected.Events))
+			return nil
+		}
+		log.Printf("Failed to save rejected events to disk: %v", saveErr)
+	}
+
+	for i, event := range rejected.Events {
+		log.Printf("REJECTED: source=%s reasons=%v raw_log=%q", event.Source, rejected.Reasons[i], event.RawLog)
+	}
+	return nil
+}
+
+// commitAcks подтверждает доставленные события: для каждого источника
+// вызывается только последнее подтверждение
+func (p *pipeline) commitAcks(acks []*domain.Ack) {
+	if len(acks) == 0 {
+		return
+	}
+
+	last := make(map[string]*domain.Ack)
+	var order []string
+	for _, ack := range acks {
+		if _, seen := last[ack.Source]; !seen {
+			order = append(order, ack.Source)
+		}
+		last[ack.Source] = ack
+	}
+	for _, source := range order {
+		if err := last[source].Commit(); err != nil {
+			log.Printf("Failed to commit read offset for %s: %v", source, err)
+		}
+	}
 }
@@ -0,0 +1,272 @@
+package sender
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Narotan/SIEM-Agent/internal/domain"
+	"github.com/Narotan/SIEM-Agent/internal/filter"
+	"github.com/Narotan/SIEM-Agent/internal/storage"
+)
+
+type fakeSender struct {
+	fail     bool
+	reject   string // RawLog событий, которые отклоняет база
+	batches  []domain.Batch
+	attempts []time.Time // Timestamp каждой попытки отправки
+}
+
+func (s *fakeSender) Send(batch domain.Batch) error {
+	s.attempts = append(s.attempts, batch.Timestamp)
+	if s.fail {
+		return errors.New("connection refused")
+	}
+	s.batches = append(s.batches, batch)
+
+	var rejected *RejectedError
+	for _, event := range batch.Events {
+		if s.reject != "" && event.RawLog == s.reject {
+			if rejected == nil {
+				rejected = &RejectedError{}
+			}
+			rejected.Events = append(rejected.Events, event)
+			rejected.Reasons = append(rejected.Reasons, []string{"missing required field"})
+		}
+	}
+	if rejected != nil {
+		return rejected
+	}
+	return nil
+}
+
+func (s *fakeSender) Close() error { return nil }
+
+// ackRecorder выдаёт подтверждения и записывает, какие из них вызваны
+type ackRecorder struct {
+	committed []string
+}
+
+func (a *ackRecorder) event(source, raw string) domain.Event {
+	return domain.Event{
+		Source: source,
+		RawLog: raw,
+		Ack: &domain.Ack{Source: source, Commit: func() error {
+			a.committed = append(a.committed, raw)
+			return nil
+		}},
+	}
+}
+
+func newTestPipeline(t *testing.T, s Sender, disk *storage.DiskBuffer) *pipeline {
+	t.Helper()
+	f, err := filter.NewFilter(filter.Config{ExcludePatterns: []string{"CRON"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewPipeline(s, Config{
+		AgentID:      "agent-01",
+		BatchSize:    10,
+		FlushTimeout: time.Second,
+		DiskBuffer:   disk,
+		Filter:       f,
+	}).(*pipeline)
+}
+
+func TestPipelineCommitsLastAckPerSource(t *testing.T) {
+	s := &fakeSender{}
+	p := newTestPipeline(t, s, nil)
+	acks := &ackRecorder{}
+
+	p.add(acks.event("auth", "auth 1"))
+	p.add(acks.event("syslog", "syslog 1"))
+	p.add(acks.event("auth", "auth 2"))
+	p.add(acks.event("syslog", "CRON job")) // отфильтровано, но подтверждается
+	p.flush()
+
+	if len(s.batches) != 1 || len(s.batches[0].Events) != 3 {
+		t.Fatalf("expected one batch of 3 events, got %+v", s.batches)
+	}
+	if !reflect.DeepEqual(acks.committed, []string{"auth 2", "CRON job"}) {
+		t.Errorf("expected last ack per source, got %v", acks.committed)
+	}
+
+	// только отфильтрованные события подтверждаются без отправки
+	acks.committed = nil
+	p.add(acks.event("syslog", "CRON again"))
+	p.flush()
+	if len(s.batches) != 1 || !reflect.DeepEqual(acks.committed, []string{"CRON again"}) {
+		t.Errorf("expected filtered ack without a batch, got %d batches, acks %v", len(s.batches), acks.committed)
+	}
+}
+
+func TestPipelineKeepsUndeliveredEvents(t *testing.T) {
+	s := &fakeSender{fail: true}
+	p := newTestPipeline(t, s, nil)
+	acks := &ackRecorder{}
+
+	p.add(acks.event("auth", "auth 1"))
+	p.flush()
+	p.add(acks.event("auth", "auth 2"))
+	p.flush()
+	if len(acks.committed) != 0 || p.pending == nil || len(p.pending.Events) != 1 || len(p.buffer) != 1 {
+		t.Fatalf("failed batch must stay unacknowledged in memory, acks %v, buffer %d", acks.committed, len(p.buffer))
+	}
+
+	s.fail = false
+	p.flush()
+	if len(s.batches) != 2 || len(s.batches[0].Events) != 1 || len(s.batches[1].Events) != 1 {
+		t.Fatalf("expected retried batch followed by the new one, got %+v", s.batches)
+	}
+	// повтор с тем же Timestamp даёт те же _id и не создаёт дубликатов
+	for _, ts := range s.attempts[:3] {
+		if !ts.Equal(s.batches[0].Timestamp) {
+			t.Errorf("retry changed batch timestamp: %v", s.attempts)
+		}
+	}
+	if !reflect.DeepEqual(acks.committed, []string{"auth 1", "auth 2"}) {
+		t.Errorf("unexpected acks %v", acks.committed)
+	}
+}
+
+func TestPipelineFilteredAckWaitsForPendingBatch(t *testing.T) {
+	s := &fakeSender{fail: true}
+	p := newTestPipeline(t, s, nil)
+	acks := &ackRecorder{}
+
+	p.add(acks.event("auth", "auth 1"))
+	p.flush()
+	p.add(acks.event("auth", "CRON job"))
+	p.flush()
+	if len(acks.committed) != 0 {
+		t.Fatalf("ack must wait for the undelivered batch, got %v", acks.committed)
+	}
+
+	s.fail = false
+	p.flush()
+	if !reflect.DeepEqual(acks.committed, []string{"auth 1", "CRON job"}) {
+		t.Errorf("unexpected acks %v", acks.committed)
+	}
+}
+
+func TestPipelineAcksBatchSavedToDisk(t *testing.T) {
+	disk, err := storage.NewDiskBuffer(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newTestPipeline(t, &fakeSender{fail: true}, disk)
+	acks := &ackRecorder{}
+
+	p.add(acks.event("auth", "auth 1"))
+	p.flush()
+
+	if n, _ := disk.Count(); n != 1 {
+		t.Errorf("expected batch on disk, got %d", n)
+	}
+	if !reflect.DeepEqual(acks.committed, []string{"auth 1"}) || len(p.buffer) != 0 {
+		t.Errorf("batch on disk must be acknowledged, acks %v, buffer %d", acks.committed, len(p.buffer))
+	}
+}
+
+func TestPipelineSpoolsRejectedEvents(t *testing.T) {
+	tests := []struct {
+		name     string
+		spool    bool // есть дисковый буфер
+		rejected int  // файлов в <dir>/rejected
+	}{
+		{"with disk buffer", true, 1},
+		{"reported to log", false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			disk, err := storage.NewDiskBuffer(dir, 1<<20)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var spool *storage.DiskBuffer
+			if tt.spool {
+				spool = disk
+			}
+			p := newTestPipeline(t, &fakeSender{reject: "bad"}, spool)
+			acks := &ackRecorder{}
+
+			p.add(acks.event("auth", "auth 1"))
+			p.add(acks.event("auth", "bad"))
+			p.flush()
+
+			// отклонённое событие не повторяется: позиция подтверждается
+			if p.pending != nil || !reflect.DeepEqual(acks.committed, []string{"bad"}) {
+				t.Errorf("expected batch acknowledged, pending %v, acks %v", p.pending, acks.committed)
+			}
+			if n, _ := disk.Count(); n != 0 {
+				t.Errorf("rejected events must not be queued for retry, got %d batches", n)
+			}
+			files, _ := os.ReadDir(filepath.Join(dir, "rejected"))
+			if len(files) != tt.rejected {
+				t.Errorf("expected %d rejected files, got %d", tt.rejected, len(files))
+			}
+		})
+	}
+}
+
+func TestPipelineRetriesSpooledBatches(t *testing.T) {
+	disk, err := storage.NewDiskBuffer(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSender{fail: true}
+	p := newTestPipeline(t, s, disk)
+	acks := &ackRecorder{}
+
+	p.add(acks.event("auth", "auth 1"))
+	p.flush()
+	p.add(acks.event("auth", "auth 2"))
+	p.flush()
+
+	// база недоступна: батчи остаются на диске
+	p.retrySpooled()
+	if n, _ := disk.Count(); n != 2 {
+		t.Fatalf("expected 2 spooled batches, got %d", n)
+	}
+
+	s.fail = false
+	p.retrySpooled()
+	if n, _ := disk.Count(); n != 0 {
+		t.Errorf("expected spooled batches sent, %d left", n)
+	}
+	if len(s.batches) != 2 || s.batches[0].Events[0].RawLog != "auth 1" || s.batches[1].Events[0].RawLog != "auth 2" {
+		t.Errorf("expected spooled batches in order, got %+v", s.batches)
+	}
+}
+
+func TestPipelineBlocksWhilePendingBatch(t *testing.T) {
+	s := &fakeSender{fail: true}
+	p := newTestPipeline(t, s, nil)
+	acks := &ackRecorder{}
+
+	for i := 0; i < 10; i++ {
+		if p.add(acks.event("auth", "auth")) {
+			p.flush()
+		}
+	}
+	if p.pending == nil || p.blocked() {
+		t.Fatalf("expected pending batch with room for one more, pending %v", p.pending)
+	}
+
+	for i := 0; i < 10; i++ {
+		p.add(acks.event("auth", "auth"))
+	}
+	if !p.blocked() {
+		t.Fatalf("expected input blocked with %d buffered events", len(p.buffer))
+	}
+
+	s.fail = false
+	p.flush()
+	if p.blocked() || len(s.batches) != 2 || len(p.buffer) != 0 {
+		t.Errorf("expected both batches delivered, got %d batches, buffer %d", len(s.batches), len(p.buffer))
+	}
+}
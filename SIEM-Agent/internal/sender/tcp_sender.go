@@ -1,29 +1,34 @@
 package sender
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"log"
-	"math"
 	"net"
-	"sync"
+	"strconv"
 	"time"
 
 	"github.com/Narotan/SIEM-Agent/internal/domain"
+	"nosql_db/client"
 )
 
+// sendTimeout предел на доставку одного батча вместе с повторами
+const sendTimeout = 2 * time.Minute
+
 type TCPSender struct {
-	host       string
-	port       int
-	conn       net.Conn
-	mu         sync.Mutex
+	client     *client.Client
 	collection string
 }
 
 func NewTCPSender(host string, port int) *TCPSender {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
 	return &TCPSender{
-		host:       host,
-		port:       port,
+		client: client.New(addr, client.Options{
+			PoolSize:   1,
+			MaxRetries: 2,
+			MinBackoff: 2 * time.Second,
+			MaxBackoff: 30 * time.Second,
+		}),
 		collection: "security_events",
 	}
 }
@@ -32,30 +37,14 @@ func (s *TCPSender) SetCollection(name string) {
 	s.collection = name
 }
 
-func (s *TCPSender) connect() error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if s.conn != nil {
-		return nil
-	}
-
-	addr := fmt.Sprintf("%s:%d", s.host, s.port)
-	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
-	if err != nil {
-		return fmt.Errorf("failed to connect to %s: %w", addr, err)
-	}
-
-	s.conn = conn
-	log.Printf("Connected to NoSQLdb server at %s", addr)
-	return nil
-}
-
-func (s *TCPSender) batchToDBRequest(batch domain.Batch) DBRequest {
+func batchToDocuments(batch domain.Batch) []map[string]any {
 	data := make([]map[string]any, len(batch.Events))
 
 	for i, event := range batch.Events {
 		data[i] = map[string]any{
+			// _id стабилен между повторами одного батча, поэтому повторная
+			// отправка после потерянного ответа не создаёт дубликатов
+			"_id":        fmt.Sprintf("%s-%d-%d", batch.AgentID, batch.Timestamp.UnixNano(), i),
 			"agent_id":   batch.AgentID,
 			"batch_time": batch.Timestamp.Format(time.RFC3339),
 			"timestamp":  event.Timestamp.Format(time.RFC3339),
@@ -68,103 +57,101 @@ func (s *TCPSender) batchToDBRequest(batch domain.Batch) DBRequest {
 			"command":    event.Command,
 			"raw_log":    event.RawLog,
 		}
+		setOptional(data[i], event)
 	}
 
-	return DBRequest{
-		Database: s.collection,
-		Command:  "insert",
-		Data:     data,
+	return data
+}
+
+// setOptional добавляет в документ только заполненные поля
+func setOptional(doc map[string]any, event domain.Event) {
+	fields := map[string]string{
+		"unit":         event.Unit,
+		"facility":     event.Facility,
+		"level":        event.Level,
+		"src_ip":       event.SrcIP,
+		"auth_method":  event.AuthMethod,
+		"target_user":  event.TargetUser,
+		"tty":          event.TTY,
+		"sudo_command": event.SudoCommand,
+		"result":       event.Result,
+		"remote_addr":  event.Remote,
+	}
+	for name, value := range fields {
+		if value != "" {
+			doc[name] = value
+		}
+	}
+	if event.PID != 0 {
+		doc["pid"] = event.PID
+	}
+	if event.SrcPort != 0 {
+		doc["src_port"] = event.SrcPort
+	}
+	if len(event.StructuredData) > 0 {
+		doc["structured_data"] = event.StructuredData
 	}
 }
 
-func (s *TCPSender) Send(batch domain.Batch) error {
-	return s.SendWithRetry(batch, 3, 2*time.Second, 30*time.Second)
+// RejectedError события батча, которые NoSQLdb не сохранила: остальные
+// документы сохранены или уже были в базе, повтор этих не поможет
+type RejectedError struct {
+	Events  []domain.Event
+	Reasons [][]string // причины отказа, по порядку Events
 }
 
-func (s *TCPSender) SendWithRetry(batch domain.Batch, maxAttempts int, initialDelay, maxDelay time.Duration) error {
-	var lastErr error
-
-	for attempt := 0; attempt < maxAttempts; attempt++ {
-		if s.conn == nil {
-			if err := s.connect(); err != nil {
-				lastErr = err
-				if attempt < maxAttempts-1 {
-					delay := s.calculateBackoff(attempt, initialDelay, maxDelay)
-					log.Printf("Connection failed (attempt %d/%d), retrying in %v: %v",
-						attempt+1, maxAttempts, delay, err)
-					time.Sleep(delay)
-					continue
-				}
-				return fmt.Errorf("failed to connect after %d attempts: %w", maxAttempts, err)
-			}
-		}
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("%d event(s) rejected by NoSQLdb", len(e.Events))
+}
 
-		dbReq := s.batchToDBRequest(batch)
+// Send вставляет батч; переподключение и повторы выполняет клиент NoSQLdb.
+// Батч доставлен, только если каждый документ получил _id (вставлен или уже
+// сохранён); иначе возвращается *RejectedError с недоставленными событиями
+func (s *TCPSender) Send(batch domain.Batch) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
 
-		data, err := json.Marshal(dbReq)
-		if err != nil {
-			return fmt.Errorf("failed to marshal DB request: %w", err)
-		}
+	result, err := s.client.Insert(ctx, s.collection, batchToDocuments(batch)...)
+	if err != nil && len(result.Errors) == 0 {
+		return fmt.Errorf("failed to send batch: %w", err)
+	}
 
-		data = append(data, '\n')
+	if rejected := rejectedEvents(batch, result); rejected != nil {
+		log.Printf("NoSQLdb stored %d of %d events (inserted: %d)",
+			len(batch.Events)-len(rejected.Events), len(batch.Events), result.Inserted)
+		return rejected
+	}
 
-		s.mu.Lock()
+	log.Printf("Successfully sent batch with %d events to NoSQLdb (inserted: %d)",
+		len(batch.Events), result.Inserted)
+	return nil
+}
 
-		_, err = s.conn.Write(data)
-		if err != nil {
-			s.conn = nil
-			s.mu.Unlock()
-			lastErr = err
+// rejectedEvents собирает события без _id в ответе; nil — сохранены все
+func rejectedEvents(batch domain.Batch, result client.InsertResult) *RejectedError {
+	reasons := make(map[int][]string, len(result.Errors))
+	for _, docErr := range result.Errors {
+		reasons[docErr.Index] = docErr.Reasons
+	}
 
-			if attempt < maxAttempts-1 {
-				delay := s.calculateBackoff(attempt, initialDelay, maxDelay)
-				log.Printf("Send failed (attempt %d/%d), retrying in %v: %v",
-					attempt+1, maxAttempts, delay, err)
-				time.Sleep(delay)
-				continue
-			}
-			return fmt.Errorf("failed to send after %d attempts: %w", maxAttempts, err)
+	var rejected *RejectedError
+	for i, event := range batch.Events {
+		if i < len(result.IDs) && result.IDs[i] != "" {
+			continue
 		}
-
-		decoder := json.NewDecoder(s.conn)
-		var resp DBResponse
-		if err := decoder.Decode(&resp); err != nil {
-			s.mu.Unlock()
-			log.Printf("Warning: failed to read response from DB: %v", err)
-			return nil
+		why, ok := reasons[i]
+		if !ok {
+			why = []string{"not confirmed by server"}
 		}
-
-		s.mu.Unlock()
-
-		if resp.Status != "success" {
-			log.Printf("Warning: DB returned error: %s", resp.Message)
-			return fmt.Errorf("database error: %s", resp.Message)
+		if rejected == nil {
+			rejected = &RejectedError{}
 		}
-
-		log.Printf("Successfully sent batch with %d events to NoSQLdb (inserted: %d)",
-			len(batch.Events), resp.Count)
-		return nil
+		rejected.Events = append(rejected.Events, event)
+		rejected.Reasons = append(rejected.Reasons, why)
 	}
-
-	return fmt.Errorf("failed after %d attempts: %w", maxAttempts, lastErr)
-}
-
-func (s *TCPSender) calculateBackoff(attempt int, initialDelay, maxDelay time.Duration) time.Duration {
-	delay := initialDelay * time.Duration(math.Pow(2, float64(attempt)))
-	if delay > maxDelay {
-		delay = maxDelay
-	}
-	return delay
+	return rejected
 }
 
 func (s *TCPSender) Close() error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if s.conn != nil {
-		err := s.conn.Close()
-		s.conn = nil
-		return err
-	}
-	return nil
+	return s.client.Close()
 }
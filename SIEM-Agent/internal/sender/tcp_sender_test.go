@@ -0,0 +1,129 @@
+package sender
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Narotan/SIEM-Agent/internal/domain"
+	"nosql_db/client"
+	"nosql_db/client/clienttest"
+)
+
+func newTestSender(t *testing.T, srv *clienttest.Server) *TCPSender {
+	t.Helper()
+	host, portStr, _ := net.SplitHostPort(srv.Addr())
+	port, _ := strconv.Atoi(portStr)
+	s := NewTCPSender(host, port)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func testBatch(n int) domain.Batch {
+	batch := domain.Batch{AgentID: "agent-01", Timestamp: time.Unix(1700000000, 0)}
+	for i := 0; i < n; i++ {
+		batch.Events = append(batch.Events, domain.Event{
+			Timestamp: time.Unix(1700000000, 0),
+			Hostname:  "web-1",
+			Source:    "auth",
+			Severity:  "high",
+			RawLog:    "Failed password for root",
+		})
+	}
+	return batch
+}
+
+func TestTCPSenderSendIsIdempotent(t *testing.T) {
+	srv := clienttest.NewServer()
+	defer srv.Close()
+	s := newTestSender(t, srv)
+
+	batch := testBatch(3)
+	if err := s.Send(batch); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	// повтор того же батча (например, из дискового буфера) не создаёт дубликатов
+	if err := s.Send(batch); err != nil {
+		t.Fatalf("resend error: %v", err)
+	}
+
+	docs := srv.Documents("security_events")
+	if len(docs) != 3 {
+		t.Fatalf("expected 3 documents, got %d", len(docs))
+	}
+	if docs[0]["agent_id"] != "agent-01" || docs[0]["severity"] != "high" {
+		t.Errorf("unexpected document: %v", docs[0])
+	}
+}
+
+func TestTCPSenderResponses(t *testing.T) {
+	tests := []struct {
+		name     string
+		resp     client.Response
+		wantErr  bool
+		rejected int // событий в *RejectedError
+	}{
+		{"all stored is delivered", client.Response{Status: client.StatusSuccess, Count: 1, IDs: []string{"a", "b"}}, false, 0},
+		{"partial rejects the rest", client.Response{Status: client.StatusPartial, Count: 1, IDs: []string{"a", ""},
+			Errors: []client.DocumentError{{Index: 1, Reasons: []string{"missing required field"}}}}, true, 1},
+		{"error is retried later", client.Response{Status: client.StatusError, Message: "disk full"}, true, 0},
+		{"all rejected", client.Response{Status: client.StatusError, Message: "all documents rejected", IDs: []string{"", ""},
+			Errors: []client.DocumentError{{Index: 0, Reasons: []string{"_id conflict"}}, {Index: 1, Reasons: []string{"_id conflict"}}}}, true, 2},
+		{"missing ids are not delivered", client.Response{Status: client.StatusSuccess, Count: 2}, true, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := clienttest.NewServer()
+			defer srv.Close()
+			srv.Handle(func(client.Request) (client.Response, bool) { return tt.resp, true })
+
+			err := newTestSender(t, srv).Send(testBatch(2))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error=%v, got %v", tt.wantErr, err)
+			}
+			var rejected *RejectedError
+			if errors.As(err, &rejected) != (tt.rejected > 0) {
+				t.Fatalf("expected %d rejected events, got %v", tt.rejected, err)
+			}
+			if rejected != nil && (len(rejected.Events) != tt.rejected || len(rejected.Reasons) != tt.rejected) {
+				t.Errorf("expected %d rejected events, got %d", tt.rejected, len(rejected.Events))
+			}
+		})
+	}
+}
+
+func TestBatchToDocumentsOptionalFields(t *testing.T) {
+	batch := testBatch(2)
+	batch.Events[1].EventType = "auth_failure"
+	batch.Events[1].SrcIP = "10.0.0.5"
+	batch.Events[1].SrcPort = 52000
+	batch.Events[1].AuthMethod = "password"
+	batch.Events[1].Result = "failure"
+	batch.Events[1].Unit = "ssh.service"
+	batch.Events[1].PID = 812
+	batch.Events[1].Remote = "10.0.0.7:514"
+	batch.Events[1].Facility = "authpriv"
+	batch.Events[1].Level = "info"
+	batch.Events[1].StructuredData = map[string]map[string]string{"meta": {"seq": "7"}}
+
+	docs := batchToDocuments(batch)
+	if _, ok := docs[0]["src_ip"]; ok {
+		t.Errorf("empty fields should be omitted: %v", docs[0])
+	}
+	doc := docs[1]
+	if doc["src_ip"] != "10.0.0.5" || doc["src_port"] != 52000 || doc["auth_method"] != "password" || doc["result"] != "failure" {
+		t.Errorf("auth fields lost: %v", doc)
+	}
+	if doc["unit"] != "ssh.service" || doc["pid"] != 812 {
+		t.Errorf("journald fields lost: %v", doc)
+	}
+	if sd, _ := doc["structured_data"].(map[string]map[string]string); doc["remote_addr"] != "10.0.0.7:514" || sd["meta"]["seq"] != "7" {
+		t.Errorf("network syslog fields lost: %v", doc)
+	}
+	if doc["facility"] != "authpriv" || doc["level"] != "info" {
+		t.Errorf("syslog priority fields lost: %v", doc)
+	}
+}
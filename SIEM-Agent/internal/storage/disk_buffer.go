@@ -40,8 +40,25 @@ func NewDiskBuffer(directory string, maxSize int64) (*DiskBuffer, error) {
 	return db, nil
 }
 
+// rejectedDir подкаталог для событий, которые NoSQLdb отклонила; они не
+// отправляются повторно и ждут разбора, но учитываются в размере буфера
+const rejectedDir = "rejected"
+
 // Save сохраняет батч на диск
 func (db *DiskBuffer) Save(batch domain.Batch) error {
+	return db.save(db.directory, batch)
+}
+
+// SaveRejected сохраняет отклонённые события отдельно от батчей на повтор
+func (db *DiskBuffer) SaveRejected(batch domain.Batch) error {
+	dir := filepath.Join(db.directory, rejectedDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create rejected directory: %w", err)
+	}
+	return db.save(dir, batch)
+}
+
+func (db *DiskBuffer) save(dir string, batch domain.Batch) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
@@ -52,7 +69,7 @@ func (db *DiskBuffer) Save(batch domain.Batch) error {
 
 	// Генерируем имя файла с timestamp
 	filename := fmt.Sprintf("batch_%d.json", time.Now().UnixNano())
-	path := filepath.Join(db.directory, filename)
+	path := filepath.Join(dir, filename)
 
 	// Сериализуем батч
 	data, err := json.Marshal(batch)
@@ -60,15 +77,54 @@ func (db *DiskBuffer) Save(batch domain.Batch) error {
 		return fmt.Errorf("failed to marshal batch: %w", err)
 	}
 
-	// Сохраняем в файл
-	if err := os.WriteFile(path, data, 0644); err != nil {
-		return fmt.Errorf("failed to write batch file: %w", err)
+	// Сохраняем в файл: после возврата батч должен пережить сбой, иначе
+	// подтверждённые позиции источников укажут за потерянные события
+	if err := writeFileSync(path, data); err != nil {
+		return err
 	}
 
 	db.currentSize += int64(len(data))
 	return nil
 }
 
+// writeFileSync пишет во временный файл, сбрасывает его на диск, атомарно
+// переименовывает и сбрасывает каталог, чтобы переименование не потерялось
+func writeFileSync(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create batch file: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write batch file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to sync batch file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to close batch file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to rename batch file: %w", err)
+	}
+
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return fmt.Errorf("failed to open buffer directory: %w", err)
+	}
+	defer dir.Close()
+	if err := dir.Sync(); err != nil {
+		return fmt.Errorf("failed to sync buffer directory: %w", err)
+	}
+	return nil
+}
+
 // LoadPending загружает все несохранённые батчи с диска
 func (db *DiskBuffer) LoadPending() ([]domain.Batch, error) {
 	db.mu.Lock()
@@ -149,7 +205,7 @@ func (db *DiskBuffer) Remove(batch domain.Batch) error {
 	return nil
 }
 
-// Clear очищает весь буфер на диске
+// Clear удаляет с диска батчи, ожидающие отправки
 func (db *DiskBuffer) Clear() error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
@@ -170,8 +226,8 @@ func (db *DiskBuffer) Clear() error {
 		}
 	}
 
-	db.currentSize = 0
-	return nil
+	// отклонённые события не очищаются и остаются в размере
+	return db.calculateSize()
 }
 
 // Size возвращает текущий размер буфера в байтах
@@ -201,25 +257,30 @@ func (db *DiskBuffer) Count() (int, error) {
 	return count, nil
 }
 
-// calculateSize вычисляет текущий размер буфера
+// calculateSize вычисляет текущий размер буфера вместе с отклонёнными событиями
 func (db *DiskBuffer) calculateSize() error {
-	files, err := os.ReadDir(db.directory)
-	if err != nil {
-		return err
-	}
-
 	var totalSize int64
-	for _, file := range files {
-		if file.IsDir() {
+	for _, dir := range []string{db.directory, filepath.Join(db.directory, rejectedDir)} {
+		files, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
 			continue
 		}
-
-		info, err := file.Info()
 		if err != nil {
-			continue
+			return err
 		}
 
-		totalSize += info.Size()
+		for _, file := range files {
+			if file.IsDir() {
+				continue
+			}
+
+			info, err := file.Info()
+			if err != nil {
+				continue
+			}
+
+			totalSize += info.Size()
+		}
 	}
 
 	db.currentSize = totalSize
@@ -39,4 +39,7 @@ require (
 	golang.org/x/text v0.27.0 // indirect
 	golang.org/x/tools v0.34.0 // indirect
 	google.golang.org/protobuf v1.36.9 // indirect
+	nosql_db v0.0.0
 )
+
+replace nosql_db => ../../NoSQLdb
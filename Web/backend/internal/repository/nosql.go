@@ -1,55 +1,39 @@
 package repository
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"net"
 	"time"
 
-	"github.com/Narotan/Web-SIEM/Web/backend/internal/repository/model"
+	"nosql_db/client"
 )
 
+// queryTimeout предел на один запрос к СУБД вместе с повторами
+const queryTimeout = 30 * time.Second
+
 type Repository interface {
 	FindAll(database string, query map[string]any) ([]map[string]any, error)
 }
 
 type nosqlRepository struct {
-	addr string
+	addr   string
+	client *client.Client
 }
 
 func NewNosqlRepository(addr string) Repository {
 	return &nosqlRepository{
-		addr: addr,
+		addr:   addr,
+		client: client.New(addr, client.Options{}),
 	}
 }
 
 func (r *nosqlRepository) FindAll(database string, query map[string]any) ([]map[string]any, error) {
-	req := model.DBRequest{
-		Database: database,
-		Command:  "find",
-		Query:    query,
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
 
-	conn, err := net.DialTimeout("tcp", r.addr, 5*time.Second)
+	data, err := r.client.Find(ctx, database, query)
 	if err != nil {
-		return nil, fmt.Errorf("не удалось подключиться к СУБД по адресу %s: %w", r.addr, err)
+		return nil, fmt.Errorf("ошибка запроса к СУБД по адресу %s: %w", r.addr, err)
 	}
-	defer conn.Close()
-
-	encoder := json.NewEncoder(conn)
-	if err := encoder.Encode(req); err != nil {
-		return nil, fmt.Errorf("ошибка кодирования запроса: %w", err)
-	}
-
-	var resp model.DBResponse
-	decoder := json.NewDecoder(conn)
-	if err := decoder.Decode(&resp); err != nil {
-		return nil, fmt.Errorf("ошибка чтения ответа от СУБД: %w", err)
-	}
-
-	if resp.Status == "error" {
-		return nil, fmt.Errorf("%s", resp.Message)
-	}
-
-	return resp.Data, nil
+	return data, nil
 }
@@ -9,6 +9,15 @@ import (
 	"github.com/Narotan/Web-SIEM/Web/backend/internal/service/domain"
 )
 
+// loginEventTypes события входа агента для таблицы последних входов: вход по
+// sshd, сессия PAM (в том числе su и sudo), su и неудачная аутентификация
+var loginEventTypes = map[string]bool{
+	"user_login":   true,
+	"session_open": true,
+	"user_switch":  true,
+	"auth_failure": true,
+}
+
 type Service interface {
 	GetEvents(page, limit int) (*domain.EventsPage, error)
 	GetStats() (*domain.DashboardStats, error)
@@ -149,7 +158,7 @@ func (s *siemService) GetStats() (*domain.DashboardStats, error) {
 			stats.EventsPerHour[parsedTime.Hour()]++
 		}
 
-		if eType == "user_login" || eType == "auth_failure" {
+		if loginEventTypes[eType] {
 			stats.LastLogins = append(stats.LastLogins, event)
 		}
 	}
@@ -54,6 +54,9 @@ func TestGetStatsCounts(t *testing.T) {
 		{"timestamp": recent, "agent_id": "a1", "event_type": "user_login", "severity": "low", "user": "alice", "process": "ssh"},
 		{"timestamp": recent, "agent_id": "a2", "event_type": "auth_failure", "severity": "high", "user": "bob", "process": "sudo"},
 		{"timestamp": older, "agent_id": "a1", "event_type": "file_access", "severity": "medium", "user": "alice", "process": "cat"},
+		{"timestamp": recent, "agent_id": "a2", "event_type": "session_open", "severity": "medium", "user": "carol", "process": "sshd"},
+		{"timestamp": recent, "agent_id": "a2", "event_type": "user_switch", "severity": "medium", "user": "dave", "process": "su"},
+		{"timestamp": recent, "agent_id": "a2", "event_type": "session_close", "severity": "medium", "user": "dave", "process": "su"},
 	}}
 
 	svc := NewSiemService(repo, "siem_events")
@@ -83,8 +86,8 @@ func TestGetStatsCounts(t *testing.T) {
 		t.Fatalf("unexpected TopProcesses: %+v", stats.TopProcesses)
 	}
 
-	if len(stats.LastLogins) != 2 {
-		t.Fatalf("expected 2 last logins, got %d", len(stats.LastLogins))
+	if len(stats.LastLogins) != 4 {
+		t.Fatalf("expected 4 last logins, got %d", len(stats.LastLogins))
 	}
 }
 
@@ -145,8 +145,8 @@ function renderLastLogins(logins) {
     const html = logins.map(login => {
         const timestamp = formatTimestamp(login.timestamp);
         const user = login.user || '-';
-        const isSuccess = login.event_type === 'user_login';
-        const ip = login.source_ip || login.ip || '-';
+        const isSuccess = login.result ? login.result === 'success' : login.event_type !== 'auth_failure';
+        const ip = login.src_ip || login.source_ip || login.ip || '-';
         
         return `
             <tr>